@@ -0,0 +1,240 @@
+// Package archive 文章的导入和导出
+// 导出格式为包含YAML front matter的Markdown文件和JSON格式评论的zip或tar.gz压缩包，
+// 导入支持同样格式的压缩包和WordPress导出的WXR文件
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// 导出的压缩包格式
+const (
+	FormatZip = "zip"
+	FormatTar = "tar" // tar.gz
+)
+
+// exportBatch 导出时每批读取的文章数量
+const exportBatch = 100
+
+// Document 导入和导出之间使用的文章
+type Document struct {
+	Source      string // 来源文件或条目，用于导入报告
+	Title       string
+	Slug        string
+	Author      string // 作者用户名
+	AuthorEmail string // 用户名在本站不存在时按邮箱匹配作者
+	Content     string
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Tags        []string // 本站暂不支持标签，导入时忽略
+	Comments    []Comment
+}
+
+// Comment 文章的评论，导出为 <slug>.comments.json
+type Comment struct {
+	Author      string    `json:"author"`
+	AuthorEmail string    `json:"author_email,omitempty"` // 只在导入WXR时使用，导出时不包含邮箱
+	Content     string    `json:"content"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// frontMatter Markdown文件开头的YAML元数据
+type frontMatter struct {
+	Title     string    `yaml:"title"`
+	Slug      string    `yaml:"slug,omitempty"`
+	Author    string    `yaml:"author,omitempty"`
+	CreatedAt time.Time `yaml:"created_at,omitempty"`
+	UpdatedAt time.Time `yaml:"updated_at,omitempty"`
+	Date      time.Time `yaml:"date,omitempty"` // Hugo、Jekyll等使用的发布时间，导入时没有created_at则使用该字段
+	Tags      []string  `yaml:"tags"`
+}
+
+// ValidFormat 判断导出格式是否有效
+func ValidFormat(format string) bool {
+	return format == FormatZip || format == FormatTar
+}
+
+// Export 将所有未删除的文章及其评论导出为压缩包写入w，返回导出的文章数量
+// 每篇文章为 posts/<slug>.md，有评论时评论为 posts/<slug>.comments.json
+func Export(ctx context.Context, w io.Writer, format string) (int, error) {
+	var aw archiveWriter
+	switch format {
+	case FormatZip:
+		aw = &zipWriter{w: zip.NewWriter(w)}
+	case FormatTar:
+		gz := gzip.NewWriter(w)
+		aw = &tarWriter{gz: gz, w: tar.NewWriter(gz)}
+	default:
+		return 0, fmt.Errorf("unknown export format %q", format)
+	}
+
+	// 作者和评论者可能已经注销（软删除），导出时仍保留其用户名
+	withUser := func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped().Select("id", "username")
+	}
+	db := config.GetDB().WithContext(ctx)
+
+	exported := 0
+	var lastID uint
+	for {
+		var posts []models.Post
+		err := db.Preload("User", withUser).
+			Where("id > ?", lastID).Order("id").Limit(exportBatch).
+			Find(&posts).Error
+		if err != nil {
+			return exported, err
+		}
+		if len(posts) == 0 {
+			break
+		}
+		lastID = posts[len(posts)-1].ID
+
+		ids := make([]uint, 0, len(posts))
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+		}
+		var comments []models.Comment
+		err = db.Preload("User", withUser).
+			Where("post_id IN ?", ids).Order("created_at, id").
+			Find(&comments).Error
+		if err != nil {
+			return exported, err
+		}
+		byPost := make(map[uint][]Comment)
+		for _, comment := range comments {
+			byPost[comment.PostID] = append(byPost[comment.PostID], Comment{
+				Author:    comment.User.Username,
+				Content:   comment.Content,
+				CreatedAt: comment.CreatedAt,
+			})
+		}
+
+		for _, post := range posts {
+			doc := Document{
+				Title:     post.Title,
+				Slug:      post.Slug,
+				Author:    post.User.Username,
+				Content:   post.Content,
+				CreatedAt: post.CreatedAt,
+				UpdatedAt: post.UpdatedAt,
+				Comments:  byPost[post.ID],
+			}
+			if err := writeDocument(aw, doc); err != nil {
+				return exported, err
+			}
+			exported++
+		}
+	}
+	return exported, aw.Close()
+}
+
+// writeDocument 将文章写入压缩包
+func writeDocument(aw archiveWriter, doc Document) error {
+	markdown, err := renderMarkdown(doc)
+	if err != nil {
+		return err
+	}
+	name := "posts/" + doc.Slug
+	if err := aw.add(name+".md", doc.UpdatedAt, markdown); err != nil {
+		return err
+	}
+	if len(doc.Comments) == 0 {
+		return nil
+	}
+	comments, err := json.MarshalIndent(doc.Comments, "", "  ")
+	if err != nil {
+		return err
+	}
+	return aw.add(name+".comments.json", doc.UpdatedAt, comments)
+}
+
+// renderMarkdown 生成带front matter的Markdown文件
+func renderMarkdown(doc Document) ([]byte, error) {
+	tags := doc.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	meta, err := yaml.Marshal(frontMatter{
+		Title:     doc.Title,
+		Slug:      doc.Slug,
+		Author:    doc.Author,
+		CreatedAt: doc.CreatedAt.UTC(),
+		UpdatedAt: doc.UpdatedAt.UTC(),
+		Tags:      tags,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("---\n")
+	buf.Write(meta)
+	buf.WriteString("---\n\n")
+	buf.WriteString(doc.Content)
+	return buf.Bytes(), nil
+}
+
+// archiveWriter 压缩包写入
+type archiveWriter interface {
+	add(name string, modTime time.Time, data []byte) error
+	Close() error
+}
+
+// zipWriter zip格式
+type zipWriter struct {
+	w *zip.Writer
+}
+
+func (z *zipWriter) add(name string, modTime time.Time, data []byte) error {
+	f, err := z.w.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+func (z *zipWriter) Close() error {
+	return z.w.Close()
+}
+
+// tarWriter tar.gz格式
+type tarWriter struct {
+	gz *gzip.Writer
+	w  *tar.Writer
+}
+
+func (t *tarWriter) add(name string, modTime time.Time, data []byte) error {
+	err := t.w.WriteHeader(&tar.Header{
+		Name:     name,
+		Mode:     0o644,
+		Size:     int64(len(data)),
+		ModTime:  modTime,
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = t.w.Write(data)
+	return err
+}
+
+func (t *tarWriter) Close() error {
+	if err := t.w.Close(); err != nil {
+		return err
+	}
+	return t.gz.Close()
+}
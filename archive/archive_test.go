@@ -0,0 +1,194 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+// buildArchive 生成包含指定文件的压缩包
+func buildArchive(t *testing.T, format string, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var aw archiveWriter
+	switch format {
+	case FormatZip:
+		aw = &zipWriter{w: zip.NewWriter(&buf)}
+	case FormatTar:
+		gz := gzip.NewWriter(&buf)
+		aw = &tarWriter{gz: gz, w: tar.NewWriter(gz)}
+	}
+	for name, content := range files {
+		if err := aw.add(name, time.Now(), []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	for _, format := range []string{FormatZip, FormatTar} {
+		t.Run(format, func(t *testing.T) {
+			// 在一个数据库中导出
+			testutil.Setup(t)
+			alice := testutil.CreateUser(t, "alice")
+			bob := testutil.CreateUser(t, "bob")
+			created := time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)
+			first := testutil.CreatePost(t, alice, "First post", func(p *models.Post) {
+				p.Content = "Hello **world**\n\nSecond paragraph"
+				p.CreatedAt = created
+			})
+			testutil.CreatePost(t, bob, "Second post", func(p *models.Post) { p.CreatedAt = created.Add(time.Hour) })
+			comment := models.Comment{Content: "Nice post", UserID: bob.ID, PostID: first.ID}
+			comment.CreatedAt = created.Add(time.Minute)
+			config.GetDB().Create(&comment)
+
+			var buf bytes.Buffer
+			n, err := Export(context.Background(), &buf, format)
+			if err != nil || n != 2 {
+				t.Fatalf("Export = %d, %v", n, err)
+			}
+
+			// 导入到只有相同用户的新数据库
+			t.Run("import", func(t *testing.T) {
+				testutil.Setup(t)
+				testutil.CreateUser(t, "alice")
+				testutil.CreateUser(t, "bob")
+
+				dry, err := Import(context.Background(), buf.Bytes(), Options{DryRun: true})
+				if err != nil || dry.Posts != 2 || dry.Comments != 1 {
+					t.Fatalf("dry run = %+v, %v", dry, err)
+				}
+				var count int64
+				config.GetDB().Model(&models.Post{}).Count(&count)
+				if count != 0 {
+					t.Fatalf("dry run saved %d posts", count)
+				}
+
+				report, err := Import(context.Background(), buf.Bytes(), Options{})
+				if err != nil || report.Posts != 2 || report.Comments != 1 || len(report.Skipped) != 0 || len(report.Warnings) != 0 {
+					t.Fatalf("import = %+v, %v", report, err)
+				}
+
+				var post models.Post
+				if err := config.GetDB().Preload("User").Where("slug = ?", first.Slug).First(&post).Error; err != nil {
+					t.Fatal(err)
+				}
+				if post.Title != first.Title || post.Content != first.Content || post.User.Username != "alice" || !post.CreatedAt.Equal(created) {
+					t.Errorf("imported post = %q %q by %s at %v", post.Title, post.Content, post.User.Username, post.CreatedAt)
+				}
+				if post.CommentCount != 1 {
+					t.Errorf("comment_count = %d, want 1", post.CommentCount)
+				}
+
+				// 再次导入时slug已存在，全部跳过
+				again, err := Import(context.Background(), buf.Bytes(), Options{})
+				if err != nil || again.Posts != 0 || len(again.Skipped) != 2 {
+					t.Errorf("second import = %+v, %v", again, err)
+				}
+			})
+		})
+	}
+}
+
+func TestImportLimits(t *testing.T) {
+	post := "---\ntitle: Post\nauthor: alice\n---\n\n" + strings.Repeat("a", 600<<10)
+
+	tests := []struct {
+		name   string
+		limits func(*config.ArchiveConfig)
+		files  map[string]string
+		err    string // 为空表示导入成功
+	}{
+		{"within limits", nil, map[string]string{"posts/a.md": post, "posts/b.md": post}, ""},
+		{"too many files", func(c *config.ArchiveConfig) { c.MaxEntries = 2 }, map[string]string{"posts/a.md": post, "posts/b.md": post, "posts/c.md": post}, "more than 2 files"},
+		{"single file too large", func(c *config.ArchiveConfig) { c.MaxUncompressedSizeMB = 0 }, map[string]string{"posts/a.md": post}, "larger than 0 MB"},
+		{"total too large", func(c *config.ArchiveConfig) { c.MaxUncompressedSizeMB = 1 }, map[string]string{"posts/a.md": post, "posts/b.md": post}, "larger than 1 MB"},
+	}
+	for _, format := range []string{FormatZip, FormatTar} {
+		for _, tt := range tests {
+			t.Run(format+"/"+tt.name, func(t *testing.T) {
+				testutil.Setup(t)
+				testutil.CreateUser(t, "alice")
+				cfg := config.Get()
+				if tt.limits != nil {
+					tt.limits(&cfg.Archive)
+				}
+
+				data := buildArchive(t, format, tt.files)
+				report, err := Import(context.Background(), data, Options{DryRun: true})
+				if tt.err == "" {
+					if err != nil || report.Posts != len(tt.files) {
+						t.Errorf("Import = %+v, %v", report, err)
+					}
+					return
+				}
+				if !errors.Is(err, ErrInvalidArchive) || !strings.Contains(err.Error(), tt.err) {
+					t.Errorf("Import error = %v, want invalid archive: %s", err, tt.err)
+				}
+			})
+		}
+	}
+}
+
+func TestAuthorResolver(t *testing.T) {
+	testutil.Setup(t)
+	alice := testutil.CreateUser(t, "alice")
+	admin := testutil.CreateUser(t, "admin")
+
+	tests := []struct {
+		name          string
+		defaultAuthor string
+		matchEmail    bool
+		username      string
+		email         string
+		want          uint
+		matched       bool
+	}{
+		{"username", "", false, "alice", "", alice.ID, true},
+		// 文件中的邮箱未经验证，默认不用来匹配
+		{"email not matched by default", "", false, "mallory", alice.Email, 0, false},
+		{"email falls back to default author", "admin", false, "mallory", alice.Email, admin.ID, false},
+		{"email matched when enabled", "admin", true, "mallory", alice.Email, alice.ID, true},
+		{"unknown author", "admin", true, "mallory", "mallory@example.com", admin.ID, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, err := newAuthorResolver(config.GetDB(), tt.defaultAuthor, tt.matchEmail)
+			if err != nil {
+				t.Fatal(err)
+			}
+			userID, matched, err := r.resolve(tt.username, tt.email)
+			if err != nil || userID != tt.want || matched != tt.matched {
+				t.Errorf("resolve = %d, %v, %v, want %d, %v", userID, matched, err, tt.want, tt.matched)
+			}
+		})
+	}
+}
+
+func TestImportReportsDefaultAuthor(t *testing.T) {
+	testutil.Setup(t)
+	testutil.CreateUser(t, "admin")
+	data := buildArchive(t, FormatZip, map[string]string{"posts/a.md": "---\ntitle: Post\nauthor: mallory\n---\n\nBody"})
+
+	report, err := Import(context.Background(), data, Options{DefaultAuthor: "admin"})
+	if err != nil || report.Posts != 1 {
+		t.Fatalf("Import = %+v, %v", report, err)
+	}
+	if len(report.Warnings) != 1 || !strings.Contains(report.Warnings[0], `unknown author "mallory"`) {
+		t.Errorf("warnings = %q", report.Warnings)
+	}
+}
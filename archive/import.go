@@ -0,0 +1,474 @@
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"gopkg.in/yaml.v3"
+	"gorm.io/gorm"
+)
+
+// 导入失败的原因，调用方据此区分客户端错误和服务器错误
+var (
+	ErrInvalidArchive       = errors.New("invalid archive")
+	ErrUnknownDefaultAuthor = errors.New("default author not found")
+)
+
+// errDryRun 试运行结束时回滚事务
+var errDryRun = errors.New("dry run")
+
+// Options 导入选项
+type Options struct {
+	DryRun        bool   // 只生成报告，不保存
+	DefaultAuthor string // 作者或评论者在本站不存在时使用的用户名，为空时跳过这些文章和评论
+	// MatchEmail 用户名不匹配时按邮箱匹配本站用户
+	// 导入文件中的邮箱没有经过验证，任何人都可以在文件中填写他人的邮箱，只在信任文件来源时开启
+	MatchEmail bool
+}
+
+// Report 导入报告
+type Report struct {
+	DryRun   bool      `json:"dry_run"`
+	Posts    int       `json:"posts"`    // 导入的文章数，试运行时为将要导入的数量
+	Comments int       `json:"comments"` // 导入的评论数
+	Skipped  []Skipped `json:"skipped"`  // 没有导入的文章
+	Warnings []string  `json:"warnings"`
+}
+
+// Skipped 没有导入的文章及原因
+type Skipped struct {
+	Source string `json:"source"`
+	Reason string `json:"reason"`
+}
+
+// skip 记录没有导入的文章
+func (r *Report) skip(source, format string, args ...interface{}) {
+	r.Skipped = append(r.Skipped, Skipped{Source: source, Reason: fmt.Sprintf(format, args...)})
+}
+
+// warn 记录警告
+func (r *Report) warn(format string, args ...interface{}) {
+	r.Warnings = append(r.Warnings, fmt.Sprintf(format, args...))
+}
+
+// Import 导入压缩包（zip或tar.gz）或WXR文件中的文章和评论
+// 所有文章在同一个事务中导入，slug已存在的文章被跳过，因此可以重复导入同一个文件；
+// 作者按用户名匹配，WXR中的作者和评论者还会按邮箱匹配
+func Import(ctx context.Context, data []byte, opts Options) (*Report, error) {
+	report := &Report{DryRun: opts.DryRun, Skipped: []Skipped{}, Warnings: []string{}}
+
+	docs, err := parse(data, report)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+	// 按发布时间导入，新文章的id与原来的发布顺序一致
+	sort.SliceStable(docs, func(i, j int) bool {
+		return docs[i].CreatedAt.Before(docs[j].CreatedAt)
+	})
+
+	err = config.WithTx(ctx, func(tx *gorm.DB) error {
+		authors, err := newAuthorResolver(tx, opts.DefaultAuthor, opts.MatchEmail)
+		if err != nil {
+			return err
+		}
+		for _, doc := range docs {
+			if err := importDocument(tx, doc, authors, report); err != nil {
+				return err
+			}
+		}
+		if opts.DryRun {
+			return errDryRun
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errDryRun) {
+		return nil, err
+	}
+	return report, nil
+}
+
+// importDocument 导入一篇文章及其评论，无法导入的文章记录到报告中
+func importDocument(tx *gorm.DB, doc Document, authors *authorResolver, report *Report) error {
+	switch {
+	case strings.TrimSpace(doc.Title) == "":
+		report.skip(doc.Source, "missing title")
+		return nil
+	case utf8.RuneCountInString(doc.Title) > 200:
+		report.skip(doc.Source, "title is longer than 200 characters")
+		return nil
+	case strings.TrimSpace(doc.Content) == "":
+		report.skip(doc.Source, "empty content")
+		return nil
+	}
+	if len(doc.Tags) > 0 {
+		report.warn("%s: tags are not supported and were ignored", doc.Source)
+	}
+
+	userID, matched, err := authors.resolve(doc.Author, doc.AuthorEmail)
+	if err != nil {
+		return err
+	}
+	if userID == 0 {
+		report.skip(doc.Source, "unknown author %q", doc.Author)
+		return nil
+	}
+
+	post := models.Post{
+		Title:   doc.Title,
+		Content: doc.Content,
+		UserID:  userID,
+		Version: 1,
+	}
+	post.CreatedAt = doc.CreatedAt
+	post.UpdatedAt = doc.UpdatedAt
+	if post.CreatedAt.IsZero() {
+		post.CreatedAt = time.Now()
+	}
+	if post.UpdatedAt.Before(post.CreatedAt) {
+		post.UpdatedAt = post.CreatedAt
+	}
+
+	if doc.Slug != "" {
+		taken, err := models.SlugTaken(tx, doc.Slug)
+		if err != nil {
+			return err
+		}
+		if taken {
+			report.skip(doc.Source, "slug %q already exists", doc.Slug)
+			return nil
+		}
+		if utils.Slugify(doc.Slug) == doc.Slug {
+			post.Slug = doc.Slug
+		}
+	}
+	if post.Slug == "" {
+		if err := post.AssignSlug(tx); err != nil {
+			return err
+		}
+	}
+	if err := tx.Create(&post).Error; err != nil {
+		return err
+	}
+	if !matched {
+		report.warn("%s: unknown author %q, attributed to the default author", doc.Source, doc.Author)
+	}
+
+	imported := 0
+	for _, comment := range doc.Comments {
+		if strings.TrimSpace(comment.Content) == "" {
+			continue
+		}
+		commenterID, matched, err := authors.resolve(comment.Author, comment.AuthorEmail)
+		if err != nil {
+			return err
+		}
+		if commenterID == 0 {
+			report.warn("%s: skipped comment by unknown author %q", doc.Source, comment.Author)
+			continue
+		}
+		if !matched {
+			report.warn("%s: comment by unknown author %q attributed to the default author", doc.Source, comment.Author)
+		}
+		createdAt := comment.CreatedAt
+		if createdAt.IsZero() {
+			createdAt = post.CreatedAt
+		}
+		err = tx.Create(&models.Comment{
+			Model:   gorm.Model{CreatedAt: createdAt, UpdatedAt: createdAt},
+			Content: comment.Content,
+			UserID:  commenterID,
+			PostID:  post.ID,
+		}).Error
+		if err != nil {
+			return err
+		}
+		imported++
+	}
+	if err := models.RefreshCommentStats(tx, post.ID); err != nil {
+		return err
+	}
+
+	report.Posts++
+	report.Comments += imported
+	return nil
+}
+
+// authorResolver 将导入文件中的作者映射为本站用户，缓存查询结果
+type authorResolver struct {
+	tx            *gorm.DB
+	defaultUserID uint
+	matchEmail    bool
+	cache         map[string]uint
+}
+
+// newAuthorResolver 创建作者映射，默认作者不存在时返回ErrUnknownDefaultAuthor
+func newAuthorResolver(tx *gorm.DB, defaultAuthor string, matchEmail bool) (*authorResolver, error) {
+	r := &authorResolver{tx: tx, matchEmail: matchEmail, cache: make(map[string]uint)}
+	if defaultAuthor == "" {
+		return r, nil
+	}
+	userID, err := r.lookup("username", defaultAuthor)
+	if err != nil {
+		return nil, err
+	}
+	if userID == 0 {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDefaultAuthor, defaultAuthor)
+	}
+	r.defaultUserID = userID
+	return r, nil
+}
+
+// resolve 按用户名查找用户，开启matchEmail时再按邮箱查找
+// 都不存在时返回默认作者且matched为false，没有默认作者时返回0
+func (r *authorResolver) resolve(username, email string) (userID uint, matched bool, err error) {
+	if username != "" {
+		userID, err := r.lookup("username", username)
+		if err != nil || userID != 0 {
+			return userID, true, err
+		}
+	}
+	if r.matchEmail && email != "" {
+		userID, err := r.lookup("email", email)
+		if err != nil || userID != 0 {
+			return userID, true, err
+		}
+	}
+	return r.defaultUserID, false, nil
+}
+
+// lookup 按字段查找未注销的用户
+func (r *authorResolver) lookup(column, value string) (uint, error) {
+	key := column + ":" + value
+	if userID, ok := r.cache[key]; ok {
+		return userID, nil
+	}
+	var user models.User
+	err := r.tx.Select("id").Where(column+" = ?", value).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	r.cache[key] = user.ID
+	return user.ID, nil
+}
+
+// parse 根据文件内容识别格式并读取其中的文章
+func parse(data []byte, report *Report) ([]Document, error) {
+	trimmed := bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(data, []byte("PK\x03\x04")):
+		return readZip(data, report)
+	case bytes.HasPrefix(data, []byte{0x1f, 0x8b}):
+		return readTarGz(data, report)
+	case bytes.HasPrefix(trimmed, []byte("<")):
+		return readWXR(data, report)
+	}
+	return nil, errors.New("unsupported format, expected zip, tar.gz or WordPress WXR")
+}
+
+// readZip 读取zip压缩包
+func readZip(data []byte, report *Report) ([]Document, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	limits := newExtractLimits()
+	files := make(map[string][]byte)
+	for _, f := range zr.File {
+		if err := limits.entry(); err != nil {
+			return nil, err
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := limits.read(rc)
+		rc.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", f.Name, err)
+		}
+		files[f.Name] = content
+	}
+	return collectDocuments(files, report), nil
+}
+
+// readTarGz 读取tar.gz压缩包
+func readTarGz(data []byte, report *Report) ([]Document, error) {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	limits := newExtractLimits()
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err := limits.entry(); err != nil {
+			return nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		content, err := limits.read(tr)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", header.Name, err)
+		}
+		files[header.Name] = content
+	}
+	return collectDocuments(files, report), nil
+}
+
+// extractLimits 限制解压的文件数和总大小，防止很小的压缩包解压后耗尽内存
+// 按实际读取的字节数计算，不信任压缩包中声明的大小
+type extractLimits struct {
+	entries    int
+	maxEntries int
+	remaining  int64
+	maxSizeMB  int
+}
+
+// newExtractLimits 根据配置创建解压限制
+func newExtractLimits() *extractLimits {
+	cfg := config.Get().Archive
+	return &extractLimits{
+		maxEntries: cfg.MaxEntries,
+		remaining:  int64(cfg.MaxUncompressedSizeMB) << 20,
+		maxSizeMB:  cfg.MaxUncompressedSizeMB,
+	}
+}
+
+// entry 记录压缩包中的一个条目（包括目录），超过文件数限制时返回错误
+func (l *extractLimits) entry() error {
+	l.entries++
+	if l.entries > l.maxEntries {
+		return fmt.Errorf("archive contains more than %d files", l.maxEntries)
+	}
+	return nil
+}
+
+// read 读取一个文件的内容，所有文件的总大小超过限制时返回错误
+func (l *extractLimits) read(r io.Reader) ([]byte, error) {
+	content, err := io.ReadAll(io.LimitReader(r, l.remaining+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > l.remaining {
+		return nil, fmt.Errorf("archive is larger than %d MB when decompressed", l.maxSizeMB)
+	}
+	l.remaining -= int64(len(content))
+	return content, nil
+}
+
+// collectDocuments 解析压缩包中的Markdown文件，并读取同名的 .comments.json 评论文件
+func collectDocuments(files map[string][]byte, report *Report) []Document {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var docs []Document
+	for _, name := range names {
+		if strings.HasSuffix(name, ".comments.json") {
+			if _, ok := files[strings.TrimSuffix(name, ".comments.json")+".md"]; !ok {
+				report.warn("%s: no matching Markdown file, ignored", name)
+			}
+			continue
+		}
+		if path.Ext(name) != ".md" {
+			report.warn("%s: not a Markdown file, ignored", name)
+			continue
+		}
+
+		doc, err := parseMarkdown(files[name])
+		if err != nil {
+			report.skip(name, "invalid front matter: %v", err)
+			continue
+		}
+		doc.Source = name
+		if doc.Slug == "" {
+			doc.Slug = strings.TrimSuffix(path.Base(name), ".md")
+		}
+		if comments, ok := files[strings.TrimSuffix(name, ".md")+".comments.json"]; ok {
+			if err := json.Unmarshal(comments, &doc.Comments); err != nil {
+				report.skip(name, "invalid comments file: %v", err)
+				continue
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs
+}
+
+// parseMarkdown 解析带YAML front matter的Markdown文件，没有front matter时第一个标题行作为标题
+func parseMarkdown(data []byte) (Document, error) {
+	text := strings.ReplaceAll(string(data), "\r\n", "\n")
+	text = strings.TrimPrefix(text, "\ufeff")
+
+	var meta frontMatter
+	if strings.HasPrefix(text, "---\n") {
+		raw, body, err := splitFrontMatter(text)
+		if err != nil {
+			return Document{}, err
+		}
+		if err := yaml.Unmarshal([]byte(raw), &meta); err != nil {
+			return Document{}, err
+		}
+		text = strings.TrimPrefix(body, "\n")
+	} else if line, rest, _ := strings.Cut(text, "\n"); strings.HasPrefix(line, "# ") {
+		meta.Title = strings.TrimSpace(strings.TrimPrefix(line, "# "))
+		text = strings.TrimLeft(rest, "\n")
+	}
+
+	createdAt := meta.CreatedAt
+	if createdAt.IsZero() {
+		createdAt = meta.Date
+	}
+	return Document{
+		Title:     meta.Title,
+		Slug:      meta.Slug,
+		Author:    meta.Author,
+		Content:   text,
+		CreatedAt: createdAt,
+		UpdatedAt: meta.UpdatedAt,
+		Tags:      meta.Tags,
+	}, nil
+}
+
+// splitFrontMatter 分离以 --- 开头和结束的front matter与正文
+func splitFrontMatter(text string) (string, string, error) {
+	// 保留开头的换行，front matter为空时结束标记紧跟在开始标记之后也能找到
+	rest := text[len("---"):]
+	if i := strings.Index(rest, "\n---\n"); i >= 0 {
+		return rest[:i], rest[i+len("\n---\n"):], nil
+	}
+	if strings.HasSuffix(rest, "\n---") {
+		return strings.TrimSuffix(rest, "\n---"), "", nil
+	}
+	return "", "", errors.New("missing closing ---")
+}
@@ -0,0 +1,133 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// wxrTimeLayout WXR中的时间格式
+const wxrTimeLayout = "2006-01-02 15:04:05"
+
+// wxrFile WordPress导出的WXR文件，只解析导入需要的字段
+// WXR各版本的wp命名空间不同，字段只按本地名称匹配；content:encoded与excerpt:encoded同名，需要指定命名空间
+type wxrFile struct {
+	Channel struct {
+		Authors []wxrAuthor `xml:"author"`
+		Items   []wxrItem   `xml:"item"`
+	} `xml:"channel"`
+}
+
+// wxrAuthor 站点的作者
+type wxrAuthor struct {
+	Login string `xml:"author_login"`
+	Email string `xml:"author_email"`
+}
+
+// wxrItem 文章、页面或附件
+type wxrItem struct {
+	Title      string        `xml:"title"`
+	Creator    string        `xml:"creator"` // 作者的登录名
+	Content    string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostName   string        `xml:"post_name"`
+	PostType   string        `xml:"post_type"`
+	Status     string        `xml:"status"`
+	PostDate   string        `xml:"post_date_gmt"`
+	Modified   string        `xml:"post_modified_gmt"`
+	Categories []wxrCategory `xml:"category"`
+	Comments   []wxrComment  `xml:"comment"`
+}
+
+// wxrCategory 分类或标签
+type wxrCategory struct {
+	Domain string `xml:"domain,attr"`
+	Name   string `xml:",chardata"`
+}
+
+// wxrComment 评论
+type wxrComment struct {
+	Author   string `xml:"comment_author"`
+	Email    string `xml:"comment_author_email"`
+	Date     string `xml:"comment_date_gmt"`
+	Content  string `xml:"comment_content"`
+	Approved string `xml:"comment_approved"`
+	Type     string `xml:"comment_type"` // pingback、trackback等，普通评论为空或comment
+}
+
+// readWXR 读取WXR文件中已发布的文章和已审核的评论
+// 文章内容保持WordPress中的HTML，页面、附件等其他类型的条目被忽略
+func readWXR(data []byte, report *Report) ([]Document, error) {
+	var file wxrFile
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.Strict = false
+	if err := decoder.Decode(&file); err != nil {
+		return nil, err
+	}
+
+	emails := make(map[string]string, len(file.Channel.Authors))
+	for _, author := range file.Channel.Authors {
+		emails[author.Login] = author.Email
+	}
+
+	var docs []Document
+	ignored, unapproved := 0, 0
+	for i, item := range file.Channel.Items {
+		if item.PostType != "" && item.PostType != "post" {
+			ignored++
+			continue
+		}
+		source := fmt.Sprintf("item %d (%s)", i+1, item.Title)
+		if item.Status != "" && item.Status != "publish" {
+			report.skip(source, "status %q is not imported", item.Status)
+			continue
+		}
+
+		doc := Document{
+			Source:      source,
+			Title:       strings.TrimSpace(item.Title),
+			Slug:        item.PostName,
+			Author:      item.Creator,
+			AuthorEmail: emails[item.Creator],
+			Content:     item.Content,
+			CreatedAt:   parseWXRTime(item.PostDate),
+			UpdatedAt:   parseWXRTime(item.Modified),
+		}
+		for _, category := range item.Categories {
+			if category.Domain == "post_tag" || category.Domain == "category" {
+				doc.Tags = append(doc.Tags, category.Name)
+			}
+		}
+		for _, comment := range item.Comments {
+			if comment.Approved != "1" || (comment.Type != "" && comment.Type != "comment") {
+				unapproved++
+				continue
+			}
+			doc.Comments = append(doc.Comments, Comment{
+				Author:      comment.Author,
+				AuthorEmail: comment.Email,
+				Content:     comment.Content,
+				CreatedAt:   parseWXRTime(comment.Date),
+			})
+		}
+		docs = append(docs, doc)
+	}
+
+	if ignored > 0 {
+		report.warn("%d items that are not posts (pages, attachments, menus) were ignored", ignored)
+	}
+	if unapproved > 0 {
+		report.warn("%d unapproved comments, pingbacks and trackbacks were ignored", unapproved)
+	}
+	return docs, nil
+}
+
+// parseWXRTime 解析WXR中的UTC时间，草稿等没有时间的条目为 0000-00-00 00:00:00，返回零值
+func parseWXRTime(value string) time.Time {
+	t, err := time.Parse(wxrTimeLayout, strings.TrimSpace(value))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
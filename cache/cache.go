@@ -0,0 +1,90 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/test/blog/config"
+)
+
+// Cache 缓存后端
+type Cache interface {
+	// Get 获取缓存值，不存在或已过期时ok为false
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	// Set 设置缓存值，ttl后过期
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete 删除缓存值，键不存在时不返回错误
+	Delete(ctx context.Context, keys ...string) error
+	// Version 获取命名空间的当前版本，从未递增过的命名空间版本为0
+	Version(ctx context.Context, namespace string) (int64, error)
+	// Bump 递增命名空间的版本，使键中包含旧版本号的缓存全部失效
+	Bump(ctx context.Context, namespace string) error
+}
+
+var (
+	current atomic.Value
+	loads   group
+)
+
+// Init 根据配置初始化缓存
+func Init(cfg config.CacheConfig) error {
+	var c Cache
+	switch cfg.Driver {
+	case "memory":
+		c = NewMemoryCache(cfg.MaxEntries)
+	case "redis":
+		c = NewRedisCache(cfg.Redis)
+	case "none":
+		c = noopCache{}
+	default:
+		return fmt.Errorf("unsupported cache driver %q", cfg.Driver)
+	}
+	Set(c)
+	return nil
+}
+
+// Set 设置当前使用的缓存
+func Set(c Cache) {
+	current.Store(&c)
+}
+
+// Get 获取当前使用的缓存，未初始化时返回不缓存任何内容的实现
+func Get() Cache {
+	c, _ := current.Load().(*Cache)
+	if c == nil {
+		return noopCache{}
+	}
+	return *c
+}
+
+// Fetch 获取缓存值，未命中时调用load加载并写入缓存
+// 同一个键的并发未命中只会调用一次load，其余请求等待并共享结果；缓存不可用时直接调用load
+func Fetch(ctx context.Context, key string, ttl time.Duration, load func() ([]byte, error)) ([]byte, error) {
+	c := Get()
+	if value, ok, err := c.Get(ctx, key); err == nil && ok {
+		return value, nil
+	}
+
+	return loads.do(key, func() ([]byte, error) {
+		value, err := load()
+		if err != nil {
+			return nil, err
+		}
+		// 写入失败只影响下一次请求是否命中，不影响本次结果；使用独立的上下文避免发起请求的客户端断开后放弃写入
+		setCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Second)
+		defer cancel()
+		_ = c.Set(setCtx, key, value, ttl)
+		return value, nil
+	})
+}
+
+// noopCache 不缓存任何内容
+type noopCache struct{}
+
+func (noopCache) Get(context.Context, string) ([]byte, bool, error)        { return nil, false, nil }
+func (noopCache) Set(context.Context, string, []byte, time.Duration) error { return nil }
+func (noopCache) Delete(context.Context, ...string) error                  { return nil }
+func (noopCache) Version(context.Context, string) (int64, error)           { return 0, nil }
+func (noopCache) Bump(context.Context, string) error                       { return nil }
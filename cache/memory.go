@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+// memoryEntry LRU链表中的缓存条目
+type memoryEntry struct {
+	key       string
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryCache 进程内LRU缓存，超过最大条目数时淘汰最久未使用的条目
+// 只在单个实例内有效，部署多个实例时应使用Redis，否则其他实例要等缓存过期才能看到修改
+type MemoryCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	entries    map[string]*list.Element
+	order      *list.List // 最近使用的条目在前
+
+	// 命名空间版本单独保存，不参与条目的LRU淘汰，数量同样不超过最大条目数
+	versions     map[string]*list.Element
+	versionOrder *list.List // 最近递增的命名空间在前
+	clock        int64      // 所有命名空间共用的版本计数器，每次递增加1
+	floor        int64      // 已淘汰的最大版本号，没有记录的命名空间使用该版本
+}
+
+// memoryVersion 命名空间的版本
+type memoryVersion struct {
+	namespace string
+	version   int64
+}
+
+// NewMemoryCache 创建进程内LRU缓存
+func NewMemoryCache(maxEntries int) *MemoryCache {
+	return &MemoryCache{
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+
+		versions:     make(map[string]*list.Element),
+		versionOrder: list.New(),
+	}
+}
+
+// Get 获取缓存值
+func (m *MemoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	elem, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	entry := elem.Value.(*memoryEntry)
+	if time.Now().After(entry.expiresAt) {
+		m.remove(elem)
+		return nil, false, nil
+	}
+	m.order.MoveToFront(elem)
+	return entry.value, true, nil
+}
+
+// Set 设置缓存值
+func (m *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	expiresAt := time.Now().Add(ttl)
+	if elem, ok := m.entries[key]; ok {
+		entry := elem.Value.(*memoryEntry)
+		entry.value = value
+		entry.expiresAt = expiresAt
+		m.order.MoveToFront(elem)
+		return nil
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryEntry{key: key, value: value, expiresAt: expiresAt})
+	for m.order.Len() > m.maxEntries {
+		m.remove(m.order.Back())
+	}
+	return nil
+}
+
+// Delete 删除缓存值
+func (m *MemoryCache) Delete(_ context.Context, keys ...string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, key := range keys {
+		if elem, ok := m.entries[key]; ok {
+			m.remove(elem)
+		}
+	}
+	return nil
+}
+
+// Version 获取命名空间的当前版本
+// 版本号取自共用的计数器，淘汰最久没有递增的命名空间时提高floor，
+// 因此任何命名空间的版本都不会回到旧值，不会使递增之前的旧缓存重新生效
+func (m *MemoryCache) Version(_ context.Context, namespace string) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if elem, ok := m.versions[namespace]; ok {
+		return elem.Value.(*memoryVersion).version, nil
+	}
+	return m.floor, nil
+}
+
+// Bump 递增命名空间的版本
+func (m *MemoryCache) Bump(_ context.Context, namespace string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.clock++
+	if elem, ok := m.versions[namespace]; ok {
+		elem.Value.(*memoryVersion).version = m.clock
+		m.versionOrder.MoveToFront(elem)
+		return nil
+	}
+	m.versions[namespace] = m.versionOrder.PushFront(&memoryVersion{namespace: namespace, version: m.clock})
+	for m.versionOrder.Len() > m.maxEntries {
+		oldest := m.versionOrder.Remove(m.versionOrder.Back()).(*memoryVersion)
+		delete(m.versions, oldest.namespace)
+		m.floor = max(m.floor, oldest.version)
+	}
+	return nil
+}
+
+// remove 移除条目，调用方需持有锁
+func (m *MemoryCache) remove(elem *list.Element) {
+	m.order.Remove(elem)
+	delete(m.entries, elem.Value.(*memoryEntry).key)
+}
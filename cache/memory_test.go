@@ -0,0 +1,84 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheLRU(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryCache(2)
+	m.Set(ctx, "a", []byte("1"), time.Minute)
+	m.Set(ctx, "b", []byte("2"), time.Minute)
+	m.Get(ctx, "a") // a变为最近使用
+	m.Set(ctx, "c", []byte("3"), time.Minute)
+	m.Set(ctx, "expired", []byte("4"), -time.Second)
+
+	tests := []struct {
+		key  string
+		want string
+		ok   bool
+	}{
+		{"a", "", false}, // 写入expired时淘汰
+		{"b", "", false},
+		{"c", "3", true},
+		{"expired", "", false},
+	}
+	for _, tt := range tests {
+		value, ok, err := m.Get(ctx, tt.key)
+		if err != nil || ok != tt.ok || string(value) != tt.want {
+			t.Errorf("Get(%q) = %q, %v, %v, want %q, %v", tt.key, value, ok, err, tt.want, tt.ok)
+		}
+	}
+	if len(m.entries) != 1 || m.order.Len() != 1 {
+		t.Errorf("entries = %d, order %d, want 1", len(m.entries), m.order.Len())
+	}
+
+	m.Delete(ctx, "c", "missing")
+	if _, ok, _ := m.Get(ctx, "c"); ok {
+		t.Error("deleted key is still cached")
+	}
+}
+
+func TestMemoryCacheVersionsAreBounded(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemoryCache(10)
+	for i := 0; i < 1000; i++ {
+		m.Bump(ctx, fmt.Sprintf("post:%d", i))
+	}
+	if len(m.versions) != 10 || m.versionOrder.Len() != 10 {
+		t.Errorf("versions = %d, order %d, want 10", len(m.versions), m.versionOrder.Len())
+	}
+}
+
+// 淘汰命名空间的版本后，任何命名空间的版本都不能回到旧值，递增后一定是没有用过的新值
+func TestMemoryCacheVersionsNeverGoBack(t *testing.T) {
+	ctx := context.Background()
+	rng := rand.New(rand.NewSource(1))
+	m := NewMemoryCache(3)
+	last := map[string]int64{}
+	seen := map[string]map[int64]bool{}
+
+	for i := 0; i < 5000; i++ {
+		namespace := fmt.Sprintf("ns%d", rng.Intn(8))
+		if seen[namespace] == nil {
+			seen[namespace] = map[int64]bool{}
+		}
+		bump := rng.Intn(2) == 0
+		if bump {
+			m.Bump(ctx, namespace)
+		}
+		version, _ := m.Version(ctx, namespace)
+		if version < last[namespace] {
+			t.Fatalf("step %d: %s went back from %d to %d", i, namespace, last[namespace], version)
+		}
+		if bump && seen[namespace][version] {
+			t.Fatalf("step %d: %s reused version %d after bump", i, namespace, version)
+		}
+		last[namespace] = version
+		seen[namespace][version] = true
+	}
+}
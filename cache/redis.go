@@ -0,0 +1,297 @@
+package cache
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/test/blog/config"
+)
+
+// Redis操作的超时时间，缓存不可用时请求回退到数据库，不能长时间等待
+const (
+	redisDialTimeout    = 3 * time.Second
+	redisCommandTimeout = time.Second
+)
+
+// redisVersionTTL 命名空间版本键的过期时间，每次递增时刷新，不再更新的命名空间不会一直占用内存
+const redisVersionTTL = 7 * 24 * time.Hour
+
+var (
+	// redisNil 键不存在时的空回复
+	redisNil = errors.New("redis: nil")
+	// errRedisConnClosed 连接出错已关闭，包装了具体的网络错误
+	errRedisConnClosed = errors.New("redis: connection closed")
+)
+
+// redisError Redis返回的错误回复
+type redisError string
+
+func (e redisError) Error() string { return "redis: " + string(e) }
+
+// RedisCache Redis缓存，使用RESP协议直接通信，只实现了缓存需要的几个命令
+type RedisCache struct {
+	cfg  config.RedisConfig
+	idle chan *redisConn // 空闲连接
+	sem  chan struct{}   // 限制同时打开的连接数
+}
+
+// redisConn Redis连接
+type redisConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	w    *bufio.Writer
+}
+
+// NewRedisCache 创建Redis缓存，连接在第一次使用时建立
+func NewRedisCache(cfg config.RedisConfig) *RedisCache {
+	return &RedisCache{
+		cfg:  cfg,
+		idle: make(chan *redisConn, cfg.PoolSize),
+		sem:  make(chan struct{}, cfg.PoolSize),
+	}
+}
+
+// Get 获取缓存值
+func (r *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := r.do(ctx, "GET", r.cfg.KeyPrefix+key)
+	if errors.Is(err, redisNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected reply %T for GET", reply)
+	}
+	return value, true, nil
+}
+
+// Set 设置缓存值
+func (r *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	_, err := r.do(ctx, "SET", r.cfg.KeyPrefix+key, string(value), "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Delete 删除缓存值
+func (r *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	args := []string{"DEL"}
+	for _, key := range keys {
+		args = append(args, r.cfg.KeyPrefix+key)
+	}
+	_, err := r.do(ctx, args...)
+	return err
+}
+
+// Version 获取命名空间的当前版本
+func (r *RedisCache) Version(ctx context.Context, namespace string) (int64, error) {
+	value, ok, err := r.Get(ctx, "version:"+namespace)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+// Bump 递增命名空间的版本并刷新过期时间
+// 版本键过期后INCR会从1重新开始，可能与过期前的版本相同而命中旧缓存，
+// 所以新建的版本键从当前毫秒时间戳开始，不会与之前用过的版本重复
+func (r *RedisCache) Bump(ctx context.Context, namespace string) error {
+	key := r.cfg.KeyPrefix + "version:" + namespace
+	ttl := strconv.FormatInt(redisVersionTTL.Milliseconds(), 10)
+	reply, err := r.do(ctx, "INCR", key)
+	if err != nil {
+		return err
+	}
+	if reply == int64(1) {
+		_, err = r.do(ctx, "SET", key, strconv.FormatInt(time.Now().UnixMilli(), 10), "PX", ttl)
+		return err
+	}
+	_, err = r.do(ctx, "PEXPIRE", key, ttl)
+	return err
+}
+
+// do 执行一条命令并返回回复
+// 出错的连接直接关闭，不放回连接池，避免读取到上一条命令残留的回复
+// Redis重启后连接池中的空闲连接都已断开，使用空闲连接失败且不是超时时建立新连接重试一次
+func (r *RedisCache) do(ctx context.Context, args ...string) (interface{}, error) {
+	conn, reused, err := r.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	reply, err := r.exec(ctx, conn, args)
+	var netErr net.Error
+	if reused && errors.Is(err, errRedisConnClosed) && !(errors.As(err, &netErr) && netErr.Timeout()) && ctx.Err() == nil {
+		if conn, err = r.dialPooled(ctx); err != nil {
+			return nil, err
+		}
+		return r.exec(ctx, conn, args)
+	}
+	return reply, err
+}
+
+// exec 在连接上执行命令，连接出错时关闭并释放连接数
+func (r *RedisCache) exec(ctx context.Context, conn *redisConn, args []string) (interface{}, error) {
+	deadline := time.Now().Add(redisCommandTimeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	_ = conn.conn.SetDeadline(deadline)
+
+	reply, err := conn.command(args...)
+	var replyErr redisError
+	if err != nil && !errors.Is(err, redisNil) && !errors.As(err, &replyErr) {
+		conn.conn.Close()
+		<-r.sem
+		return nil, fmt.Errorf("%w: %w", errRedisConnClosed, err)
+	}
+	r.release(conn)
+	return reply, err
+}
+
+// acquire 从连接池获取连接，没有空闲连接时建立新连接；reused表示是否为之前用过的空闲连接
+func (r *RedisCache) acquire(ctx context.Context) (conn *redisConn, reused bool, err error) {
+	select {
+	case conn := <-r.idle:
+		return conn, true, nil
+	default:
+	}
+
+	select {
+	case r.sem <- struct{}{}:
+	case conn := <-r.idle:
+		return conn, true, nil
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	conn, err = r.dial(ctx)
+	if err != nil {
+		<-r.sem
+		return nil, false, err
+	}
+	return conn, false, nil
+}
+
+// dialPooled 占用一个连接数并建立新连接
+func (r *RedisCache) dialPooled(ctx context.Context) (*redisConn, error) {
+	select {
+	case r.sem <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	conn, err := r.dial(ctx)
+	if err != nil {
+		<-r.sem
+		return nil, err
+	}
+	return conn, nil
+}
+
+// release 将连接放回连接池
+func (r *RedisCache) release(conn *redisConn) {
+	r.idle <- conn
+}
+
+// dial 建立连接并完成认证和选择数据库
+func (r *RedisCache) dial(ctx context.Context) (*redisConn, error) {
+	dialer := net.Dialer{Timeout: redisDialTimeout}
+	nc, err := dialer.DialContext(ctx, "tcp", r.cfg.Addr)
+	if err != nil {
+		return nil, err
+	}
+	conn := &redisConn{conn: nc, r: bufio.NewReader(nc), w: bufio.NewWriter(nc)}
+
+	_ = nc.SetDeadline(time.Now().Add(redisDialTimeout))
+	if r.cfg.Password != "" {
+		if _, err := conn.command("AUTH", r.cfg.Password); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	if r.cfg.DB != 0 {
+		if _, err := conn.command("SELECT", strconv.Itoa(r.cfg.DB)); err != nil {
+			nc.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// command 发送命令并读取回复
+func (c *redisConn) command(args ...string) (interface{}, error) {
+	fmt.Fprintf(c.w, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.w, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	return c.readReply()
+}
+
+// readReply 读取一个RESP回复
+// 简单字符串返回string，整数返回int64，批量字符串返回[]byte，数组返回[]interface{}
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: malformed reply %q", line)
+	}
+	kind, body := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return body, nil
+	case '-':
+		return nil, redisError(body)
+	case ':':
+		return strconv.ParseInt(body, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed bulk length %q", body)
+		}
+		if n < 0 {
+			return nil, redisNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return nil, err
+		}
+		return buf[:n], nil
+	case '*':
+		n, err := strconv.Atoi(body)
+		if err != nil {
+			return nil, fmt.Errorf("redis: malformed array length %q", body)
+		}
+		if n < 0 {
+			return nil, redisNil
+		}
+		items := make([]interface{}, n)
+		for i := range items {
+			item, err := c.readReply()
+			var replyErr redisError
+			switch {
+			case errors.As(err, &replyErr):
+				item = replyErr
+			case err != nil && !errors.Is(err, redisNil):
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+	}
+}
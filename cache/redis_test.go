@@ -0,0 +1,476 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/test/blog/config"
+)
+
+func TestReadReply(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    interface{}
+		wantErr error
+		errText string
+	}{
+		{name: "simple string", input: "+OK\r\n", want: "OK"},
+		{name: "error", input: "-ERR wrong type\r\n", wantErr: redisError("ERR wrong type")},
+		{name: "integer", input: ":42\r\n", want: int64(42)},
+		{name: "negative integer", input: ":-1\r\n", want: int64(-1)},
+		{name: "bulk string", input: "$5\r\nhello\r\n", want: []byte("hello")},
+		{name: "binary bulk string", input: "$4\r\na\r\nb\r\n", want: []byte("a\r\nb")},
+		{name: "empty bulk string", input: "$0\r\n\r\n", want: []byte{}},
+		{name: "nil bulk string", input: "$-1\r\n", wantErr: redisNil},
+		{name: "nil array", input: "*-1\r\n", wantErr: redisNil},
+		{name: "array", input: "*3\r\n$1\r\na\r\n:2\r\n$-1\r\n", want: []interface{}{[]byte("a"), int64(2), nil}},
+		{name: "array with error", input: "*2\r\n-ERR x\r\n+OK\r\n", want: []interface{}{redisError("ERR x"), "OK"}},
+		{name: "nested array", input: "*1\r\n*1\r\n:1\r\n", want: []interface{}{[]interface{}{int64(1)}}},
+		{name: "missing CR", input: "+OK\n", errText: "malformed reply"},
+		{name: "bad bulk length", input: "$x\r\n", errText: "malformed bulk length"},
+		{name: "unknown type", input: "!1\r\n", errText: "unknown reply type"},
+		{name: "truncated bulk", input: "$5\r\nhel", wantErr: io.ErrUnexpectedEOF},
+		{name: "truncated line", input: "+OK", wantErr: io.EOF},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &redisConn{r: bufio.NewReader(strings.NewReader(tt.input))}
+			got, err := conn.readReply()
+			switch {
+			case tt.errText != "":
+				if err == nil || !strings.Contains(err.Error(), tt.errText) {
+					t.Errorf("err = %v, want %q", err, tt.errText)
+				}
+			case tt.wantErr != nil:
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("err = %v, want %v", err, tt.wantErr)
+				}
+			default:
+				if err != nil || !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("readReply = %#v, %v, want %#v", got, err, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestCommandEncoding(t *testing.T) {
+	var out bytes.Buffer
+	conn := &redisConn{r: bufio.NewReader(strings.NewReader("+OK\r\n")), w: bufio.NewWriter(&out)}
+	if _, err := conn.command("SET", "k", "a\r\nb", ""); err != nil {
+		t.Fatal(err)
+	}
+	want := "*4\r\n$3\r\nSET\r\n$1\r\nk\r\n$4\r\na\r\nb\r\n$0\r\n\r\n"
+	if out.String() != want {
+		t.Errorf("encoded %q, want %q", out.String(), want)
+	}
+}
+
+// fakeRedis 实现缓存用到的几个命令的Redis服务器
+type fakeRedis struct {
+	t        *testing.T
+	ln       net.Listener
+	password string
+
+	mu       sync.Mutex
+	data     map[string]string
+	ttls     map[string]string // 键的过期时间（毫秒），只记录不执行
+	commands [][]string
+	conns    int
+	open     []net.Conn
+	// hang 为true时读取命令后不回复，用于测试超时
+	hang bool
+	// dropNext 为true时下一条命令不回复并关闭连接
+	dropNext bool
+}
+
+func newFakeRedis(t *testing.T, password string) *fakeRedis {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &fakeRedis{t: t, ln: ln, password: password, data: map[string]string{}, ttls: map[string]string{}}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.open = append(s.open, conn)
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := s.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.commands = append(s.commands, args)
+		hang, drop := s.hang, s.dropNext
+		s.dropNext = false
+		s.mu.Unlock()
+		if hang {
+			time.Sleep(time.Second)
+			return
+		}
+		if drop {
+			return
+		}
+
+		var reply string
+		switch {
+		case strings.ToUpper(args[0]) == "AUTH":
+			if args[1] != s.password {
+				reply = "-WRONGPASS invalid password\r\n"
+			} else {
+				authed = true
+				reply = "+OK\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required.\r\n"
+		default:
+			reply = s.exec(args)
+		}
+		if _, err := io.WriteString(conn, reply); err != nil {
+			return
+		}
+	}
+}
+
+// restart 断开所有客户端连接，模拟Redis重启，数据保留
+func (s *fakeRedis) restart() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.open {
+		conn.Close()
+	}
+	s.open = nil
+}
+
+func (s *fakeRedis) exec(args []string) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch strings.ToUpper(args[0]) {
+	case "SELECT":
+		return "+OK\r\n"
+	case "GET":
+		value, ok := s.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		s.data[args[1]] = args[2]
+		delete(s.ttls, args[1])
+		if len(args) == 5 && strings.ToUpper(args[3]) == "PX" {
+			s.ttls[args[1]] = args[4]
+		}
+		return "+OK\r\n"
+	case "PEXPIRE":
+		if _, ok := s.data[args[1]]; !ok {
+			return ":0\r\n"
+		}
+		s.ttls[args[1]] = args[2]
+		return ":1\r\n"
+	case "DEL":
+		n := 0
+		for _, key := range args[1:] {
+			if _, ok := s.data[key]; ok {
+				delete(s.data, key)
+				n++
+			}
+		}
+		return fmt.Sprintf(":%d\r\n", n)
+	case "INCR":
+		n, err := strconv.ParseInt(s.data[args[1]], 10, 64)
+		if err != nil && s.data[args[1]] != "" {
+			return "-ERR value is not an integer or out of range\r\n"
+		}
+		s.data[args[1]] = strconv.FormatInt(n+1, 10)
+		return fmt.Sprintf(":%d\r\n", n+1)
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+// readCommand 读取客户端发送的RESP数组
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+	if line[0] != '*' || err != nil {
+		return nil, fmt.Errorf("bad command %q", line)
+	}
+	args := make([]string, n)
+	for i := range args {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, _ := strconv.Atoi(strings.TrimSuffix(line[1:], "\r\n"))
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+		args[i] = string(buf[:size])
+	}
+	return args, nil
+}
+
+func (s *fakeRedis) config() config.RedisConfig {
+	return config.RedisConfig{Addr: s.ln.Addr().String(), Password: s.password, DB: 2, KeyPrefix: "blog:", PoolSize: 2}
+}
+
+func TestRedisCacheCommands(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	r := NewRedisCache(server.config())
+	ctx := context.Background()
+
+	if _, ok, err := r.Get(ctx, "missing"); ok || err != nil {
+		t.Fatalf("Get missing = %v, %v", ok, err)
+	}
+	value := "binary\r\nvalue"
+	if err := r.Set(ctx, "k", []byte(value), 1500*time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok, err := r.Get(ctx, "k"); !ok || err != nil || string(got) != value {
+		t.Fatalf("Get = %q, %v, %v", got, ok, err)
+	}
+	if err := r.Delete(ctx, "k", "other"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok, _ := r.Get(ctx, "k"); ok {
+		t.Error("deleted key is still cached")
+	}
+
+	// 新建的版本键从当前时间戳开始，之后逐个递增
+	start := time.Now().UnixMilli()
+	if err := r.Bump(ctx, "posts"); err != nil {
+		t.Fatal(err)
+	}
+	first, err := r.Version(ctx, "posts")
+	if err != nil || first < start || first > time.Now().UnixMilli() {
+		t.Fatalf("Version = %d, %v, want the current time in milliseconds", first, err)
+	}
+	if err := r.Bump(ctx, "posts"); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := r.Version(ctx, "posts"); v != first+1 || err != nil {
+		t.Errorf("Version = %d, %v, want %d", v, err, first+1)
+	}
+	versionTTL := strconv.FormatInt(redisVersionTTL.Milliseconds(), 10)
+
+	want := [][]string{
+		{"AUTH", "secret"},
+		{"SELECT", "2"},
+		{"GET", "blog:missing"},
+		{"SET", "blog:k", value, "PX", "1500"},
+		{"GET", "blog:k"},
+		{"DEL", "blog:k", "blog:other"},
+		{"GET", "blog:k"},
+		{"INCR", "blog:version:posts"},
+		{"SET", "blog:version:posts", strconv.FormatInt(first, 10), "PX", versionTTL},
+		{"GET", "blog:version:posts"},
+		{"INCR", "blog:version:posts"},
+		{"PEXPIRE", "blog:version:posts", versionTTL},
+		{"GET", "blog:version:posts"},
+	}
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if !reflect.DeepEqual(server.commands, want) {
+		t.Errorf("commands =\n%q\nwant\n%q", server.commands, want)
+	}
+	if server.conns != 1 {
+		t.Errorf("opened %d connections, want 1 reused connection", server.conns)
+	}
+	if server.ttls["blog:version:posts"] != versionTTL {
+		t.Errorf("version key expires in %q ms, want %s", server.ttls["blog:version:posts"], versionTTL)
+	}
+}
+
+func TestRedisCacheErrors(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("wrong password", func(t *testing.T) {
+		server := newFakeRedis(t, "secret")
+		cfg := server.config()
+		cfg.Password = "wrong"
+		var replyErr redisError
+		if _, _, err := NewRedisCache(cfg).Get(ctx, "k"); !errors.As(err, &replyErr) {
+			t.Errorf("err = %v, want a redis error reply", err)
+		}
+	})
+
+	t.Run("error reply keeps the connection", func(t *testing.T) {
+		server := newFakeRedis(t, "")
+		r := NewRedisCache(server.config())
+		server.data["blog:version:posts"] = "abc"
+		if err := r.Bump(ctx, "posts"); err == nil || !strings.Contains(err.Error(), "not an integer") {
+			t.Errorf("Bump = %v", err)
+		}
+		if _, _, err := r.Get(ctx, "k"); err != nil {
+			t.Fatal(err)
+		}
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		if server.conns != 1 {
+			t.Errorf("opened %d connections, want 1", server.conns)
+		}
+	})
+
+	t.Run("broken connection is not reused", func(t *testing.T) {
+		server := newFakeRedis(t, "")
+		r := NewRedisCache(server.config())
+		r.Set(ctx, "k", []byte("v"), time.Minute)
+		server.mu.Lock()
+		server.dropNext = true
+		server.mu.Unlock()
+		// 空闲连接断开后用新连接重试
+		if got, ok, err := r.Get(ctx, "k"); !ok || err != nil || string(got) != "v" {
+			t.Fatalf("Get after reconnect = %q, %v, %v", got, ok, err)
+		}
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		if server.conns != 2 {
+			t.Errorf("opened %d connections, want 2", server.conns)
+		}
+	})
+
+	t.Run("new connection is not retried", func(t *testing.T) {
+		server := newFakeRedis(t, "")
+		server.dropNext = true
+		cfg := server.config()
+		cfg.DB = 0
+		if _, _, err := NewRedisCache(cfg).Get(ctx, "k"); !errors.Is(err, errRedisConnClosed) {
+			t.Errorf("err = %v, want a closed connection", err)
+		}
+		server.mu.Lock()
+		defer server.mu.Unlock()
+		if server.conns != 1 {
+			t.Errorf("opened %d connections, want 1", server.conns)
+		}
+	})
+
+	t.Run("context deadline", func(t *testing.T) {
+		server := newFakeRedis(t, "")
+		server.hang = true
+		cfg := server.config()
+		cfg.DB = 0
+		ctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, _, err := NewRedisCache(cfg).Get(ctx, "k")
+		var netErr net.Error
+		if !errors.As(err, &netErr) || !netErr.Timeout() {
+			t.Errorf("err = %v, want timeout", err)
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Get took %v", elapsed)
+		}
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		ln, _ := net.Listen("tcp", "127.0.0.1:0")
+		addr := ln.Addr().String()
+		ln.Close()
+		if _, _, err := NewRedisCache(config.RedisConfig{Addr: addr, PoolSize: 1}).Get(ctx, "k"); err == nil {
+			t.Error("expected a dial error")
+		}
+	})
+}
+
+func TestRedisCachePoolSize(t *testing.T) {
+	server := newFakeRedis(t, "")
+	r := NewRedisCache(server.config())
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := r.Set(ctx, fmt.Sprintf("k%d", i), []byte("v"), time.Minute); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.conns > 2 || len(server.data) != 20 {
+		t.Errorf("opened %d connections for pool size 2, stored %d keys", server.conns, len(server.data))
+	}
+}
+
+func TestRedisCacheReconnect(t *testing.T) {
+	server := newFakeRedis(t, "secret")
+	r := NewRedisCache(server.config())
+	ctx := context.Background()
+
+	// 连接池中有两个空闲连接
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			if err := r.Set(ctx, fmt.Sprintf("k%d", i), []byte("v"), time.Minute); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	// Redis重启后每个命令都在新连接上重新认证并选择数据库，调用方看不到错误
+	server.restart()
+	for i := 0; i < 4; i++ {
+		if _, ok, err := r.Get(ctx, "k0"); !ok || err != nil {
+			t.Fatalf("Get %d after restart = %v, %v", i, ok, err)
+		}
+	}
+	if err := r.Bump(ctx, "posts"); err != nil {
+		t.Fatalf("Bump after restart: %v", err)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	if server.conns > 4 {
+		t.Errorf("opened %d connections, want at most 4 for pool size 2", server.conns)
+	}
+	var auths int
+	for _, cmd := range server.commands {
+		if cmd[0] == "AUTH" {
+			auths++
+		}
+	}
+	if auths != server.conns {
+		t.Errorf("%d AUTH commands for %d connections", auths, server.conns)
+	}
+}
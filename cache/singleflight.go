@@ -0,0 +1,48 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+)
+
+// errLoadPanicked 加载函数panic时等待者收到的错误
+var errLoadPanicked = errors.New("cache load panicked")
+
+// call 正在进行的加载
+type call struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// group 合并同一个键的并发加载，只有第一个请求真正执行，其余请求等待并共享结果
+type group struct {
+	mu    sync.Mutex
+	calls map[string]*call
+}
+
+// do 执行fn，同一个键已有加载在进行时等待其结果
+func (g *group) do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mu.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if cl, ok := g.calls[key]; ok {
+		g.mu.Unlock()
+		<-cl.done
+		return cl.value, cl.err
+	}
+	cl := &call{done: make(chan struct{}), err: errLoadPanicked}
+	g.calls[key] = cl
+	g.mu.Unlock()
+
+	// fn panic时也要唤醒等待者并移除记录，否则之后的请求会一直阻塞
+	defer func() {
+		g.mu.Lock()
+		delete(g.calls, key)
+		g.mu.Unlock()
+		close(cl.done)
+	}()
+	cl.value, cl.err = fn()
+	return cl.value, cl.err
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/test/blog/archive"
+	"github.com/test/blog/config"
+	"github.com/test/blog/jobs"
+	"gopkg.in/yaml.v3"
+)
+
+// usage 子命令帮助信息
+const usage = `Usage:
+  blog [flags]                 启动服务器
+  blog config check [flags]    验证配置并打印生效的配置（隐藏敏感信息）
+  blog comments reconcile [-dry-run] [flags]
+                               重新计算评论数与实际评论不一致的文章，-dry-run 只检查不修改
+  blog export -o <file> [-format zip|tar] [flags]
+                               导出所有文章和评论为Markdown压缩包
+  blog import -file <file> [-dry-run] [-match-email] [-default-author <username>] [flags]
+                               导入Markdown压缩包或WordPress WXR文件，-dry-run 只输出报告，
+                               -match-email 用户名不匹配时按文件中未经验证的邮箱匹配作者
+`
+
+// runCommand 执行子命令，返回进程退出码
+func runCommand(args []string) int {
+	switch {
+	case len(args) >= 2 && args[0] == "config" && args[1] == "check":
+		return configCheck(args[2:])
+	case len(args) >= 2 && args[0] == "comments" && args[1] == "reconcile":
+		return commentsReconcile(args[2:])
+	case args[0] == "export":
+		return exportPosts(args[1:])
+	case args[0] == "import":
+		return importPosts(args[1:])
+	case args[0] == "help":
+		fmt.Print(usage)
+		return 0
+	}
+
+	fmt.Fprintf(os.Stderr, "unknown command: %s\n\n%s", strings.Join(args, " "), usage)
+	return 2
+}
+
+// configCheck 验证配置并打印生效的配置
+func configCheck(args []string) int {
+	cfg, err := config.Load(args)
+
+	var verr *config.ValidationError
+	if err != nil && !errors.As(err, &verr) {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+
+	out, marshalErr := yaml.Marshal(config.Masked(cfg))
+	if marshalErr != nil {
+		fmt.Fprintf(os.Stderr, "Failed to render configuration: %v\n", marshalErr)
+		return 1
+	}
+	fmt.Println("# Effective configuration")
+	fmt.Print(string(out))
+
+	if verr != nil {
+		fmt.Fprintln(os.Stderr, verr.Error())
+		return 1
+	}
+
+	fmt.Println("# Configuration is valid")
+	return 0
+}
+
+// commentsReconcile 修正文章的评论数和最后评论时间
+func commentsReconcile(args []string) int {
+	flags, rest, err := extractFlags(args, []string{"dry-run"}, nil)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+		return 2
+	}
+	dryRun := flags["dry-run"] != ""
+
+	cfg, err := config.Init(rest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	config.InitDB(cfg)
+
+	drifted, err := jobs.ReconcileCommentStats(context.Background(), dryRun)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to reconcile comment counts: %v\n", err)
+		return 1
+	}
+
+	switch {
+	case len(drifted) == 0:
+		fmt.Println("All comment counts are consistent")
+	case dryRun:
+		fmt.Printf("%d posts have inconsistent comment counts: %v\n", len(drifted), drifted)
+	default:
+		fmt.Printf("Fixed comment counts of %d posts: %v\n", len(drifted), drifted)
+	}
+	return 0
+}
+
+// exportPosts 导出文章到文件
+func exportPosts(args []string) int {
+	flags, rest, err := extractFlags(args, nil, []string{"o", "format"})
+	if err == nil && flags["o"] == "" {
+		err = errors.New("missing -o <file>")
+	}
+	format := flags["format"]
+	if format == "" {
+		format = archive.FormatZip
+	}
+	if err == nil && !archive.ValidFormat(format) {
+		err = fmt.Errorf("invalid format %q, must be zip or tar", format)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+		return 2
+	}
+
+	cfg, err := config.Init(rest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	config.InitDB(cfg)
+
+	f, err := os.Create(flags["o"])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create export file: %v\n", err)
+		return 1
+	}
+	n, err := archive.Export(context.Background(), f, format)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to export posts: %v\n", err)
+		return 1
+	}
+	fmt.Printf("Exported %d posts to %s\n", n, flags["o"])
+	return 0
+}
+
+// importPosts 从文件导入文章并打印报告
+func importPosts(args []string) int {
+	flags, rest, err := extractFlags(args, []string{"dry-run", "match-email"}, []string{"file", "default-author"})
+	if err == nil && flags["file"] == "" {
+		err = errors.New("missing -file <file>")
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n\n%s", err, usage)
+		return 2
+	}
+
+	data, err := os.ReadFile(flags["file"])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to read import file: %v\n", err)
+		return 1
+	}
+
+	cfg, err := config.Init(rest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load configuration: %v\n", err)
+		return 1
+	}
+	config.InitDB(cfg)
+
+	opts := archive.Options{DryRun: flags["dry-run"] != "", MatchEmail: flags["match-email"] != "", DefaultAuthor: flags["default-author"]}
+	report, err := archive.Import(context.Background(), data, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to import posts: %v\n", err)
+		return 1
+	}
+
+	if report.DryRun {
+		fmt.Printf("Dry run: %d posts and %d comments would be imported\n", report.Posts, report.Comments)
+	} else {
+		fmt.Printf("Imported %d posts and %d comments\n", report.Posts, report.Comments)
+	}
+	for _, skipped := range report.Skipped {
+		fmt.Printf("skipped %s: %s\n", skipped.Source, skipped.Reason)
+	}
+	for _, warning := range report.Warnings {
+		fmt.Printf("warning: %s\n", warning)
+	}
+	return 0
+}
+
+// extractFlags 从参数中取出子命令自己的参数，其余参数原样返回用于加载配置
+// boolFlags不带值（也可以写成 -name=false），启用时值为"true"；valueFlags带一个值，支持 -name value 和 -name=value，也支持 --name 形式
+func extractFlags(args, boolFlags, valueFlags []string) (map[string]string, []string, error) {
+	flags := make(map[string]string)
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			rest = append(rest, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+
+		switch {
+		case slices.Contains(boolFlags, name):
+			enabled := true
+			if hasValue {
+				var err error
+				if enabled, err = strconv.ParseBool(value); err != nil {
+					return nil, nil, fmt.Errorf("flag -%s must be true or false", name)
+				}
+			}
+			if enabled {
+				flags[name] = "true"
+			}
+		case slices.Contains(valueFlags, name):
+			if !hasValue {
+				if i+1 >= len(args) {
+					return nil, nil, fmt.Errorf("flag -%s requires a value", name)
+				}
+				i++
+				value = args[i]
+			}
+			flags[name] = value
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	return flags, rest, nil
+}
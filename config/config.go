@@ -1,73 +1,302 @@
 package config
 
 import (
-	"github.com/test/blog/utils"
+	"sync/atomic"
+	"time"
 )
 
 // Config 应用配置结构
 type Config struct {
-	Server   ServerConfig
-	Database DatabaseConfig
-	JWT      JWTConfig
-	Log      LogConfig
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Database    DatabaseConfig    `yaml:"database" toml:"database"`
+	JWT         JWTConfig         `yaml:"jwt" toml:"jwt"`
+	Log         LogConfig         `yaml:"log" toml:"log"`
+	RateLimit   RateLimitConfig   `yaml:"rate_limit" toml:"rate_limit"`
+	OIDC        OIDCConfig        `yaml:"oidc" toml:"oidc"`
+	Upload      UploadConfig      `yaml:"upload" toml:"upload"`
+	Site        SiteConfig        `yaml:"site" toml:"site"`
+	Feed        FeedConfig        `yaml:"feed" toml:"feed"`
+	Trash       TrashConfig       `yaml:"trash" toml:"trash"`
+	Cache       CacheConfig       `yaml:"cache" toml:"cache"`
+	Views       ViewsConfig       `yaml:"views" toml:"views"`
+	Trending    TrendingConfig    `yaml:"trending" toml:"trending"`
+	Batch       BatchConfig       `yaml:"batch" toml:"batch"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+	Archive     ArchiveConfig     `yaml:"archive" toml:"archive"`
+	DataExport  DataExportConfig  `yaml:"data_export" toml:"data_export"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port string
-	Mode string
+	Port           string   `yaml:"port" toml:"port"`
+	Mode           string   `yaml:"mode" toml:"mode"`
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies"` // 可信反向代理的IP或CIDR，只有来自这些地址的X-Forwarded-For才会被用作客户端IP；为空时使用连接的对端地址
 }
 
 // DatabaseConfig 数据库配置
 type DatabaseConfig struct {
-	Host            string
-	Port            string
-	User            string
-	Password        string
-	Database        string
-	MaxIdleConns    int
-	MaxOpenConns    int
-	ConnMaxLifetime int // 分钟
+	Host            string `yaml:"host" toml:"host"`
+	Port            string `yaml:"port" toml:"port"`
+	User            string `yaml:"user" toml:"user"`
+	Password        string `yaml:"password" toml:"password"`
+	Database        string `yaml:"database" toml:"database"`
+	MaxIdleConns    int    `yaml:"max_idle_conns" toml:"max_idle_conns"`
+	MaxOpenConns    int    `yaml:"max_open_conns" toml:"max_open_conns"`
+	ConnMaxLifetime int    `yaml:"conn_max_lifetime" toml:"conn_max_lifetime"` // 分钟
 }
 
 // JWTConfig JWT配置
+// 未配置Keys时使用Secret作为唯一的HS256密钥
 type JWTConfig struct {
-	Secret          string
-	ExpirationHours int
+	Secret          string         `yaml:"secret" toml:"secret"`
+	ExpirationHours int            `yaml:"expiration_hours" toml:"expiration_hours"`
+	Issuer          string         `yaml:"issuer" toml:"issuer"`
+	Audience        string         `yaml:"audience" toml:"audience"`
+	ActiveKeyID     string         `yaml:"active_key_id" toml:"active_key_id"`
+	Keys            []JWTKeyConfig `yaml:"keys" toml:"keys"`
+	LegacyUntil     time.Time      `yaml:"legacy_until" toml:"legacy_until"` // 在此之前接受启用kid之前签发的token（没有kid，使用secret验证），为空时不接受
+}
+
+// JWTKeyConfig JWT签名密钥配置
+// 只配置公钥的密钥仅用于验证，用于密钥轮换时让旧token在过期前继续有效
+type JWTKeyConfig struct {
+	ID             string `yaml:"id" toml:"id"`
+	Algorithm      string `yaml:"algorithm" toml:"algorithm"` // HS256/RS256/EdDSA
+	Secret         string `yaml:"secret" toml:"secret"`
+	PrivateKeyFile string `yaml:"private_key_file" toml:"private_key_file"`
+	PublicKeyFile  string `yaml:"public_key_file" toml:"public_key_file"`
 }
 
 // LogConfig 日志配置
 type LogConfig struct {
-	Level      string
-	Format     string
-	OutputPath string
+	Level      string `yaml:"level" toml:"level"`
+	Format     string `yaml:"format" toml:"format"`
+	OutputPath string `yaml:"output_path" toml:"output_path"`
+}
+
+// RateLimitConfig 限流配置（按客户端IP的令牌桶）
+type RateLimitConfig struct {
+	Enabled           bool    `yaml:"enabled" toml:"enabled"`
+	RequestsPerSecond float64 `yaml:"requests_per_second" toml:"requests_per_second"`
+	Burst             int     `yaml:"burst" toml:"burst"`
+}
+
+// OIDCConfig OIDC第三方登录配置
+type OIDCConfig struct {
+	Providers []OIDCProviderConfig `yaml:"providers" toml:"providers"`
+}
+
+// OIDCProviderConfig OIDC身份提供方配置
+type OIDCProviderConfig struct {
+	Name         string   `yaml:"name" toml:"name"` // 路由中使用的名称，如 google
+	Issuer       string   `yaml:"issuer" toml:"issuer"`
+	ClientID     string   `yaml:"client_id" toml:"client_id"`
+	ClientSecret string   `yaml:"client_secret" toml:"client_secret"`
+	RedirectURL  string   `yaml:"redirect_url" toml:"redirect_url"`
+	Scopes       []string `yaml:"scopes" toml:"scopes"` // 默认 openid email profile
+}
+
+// UploadConfig 文件上传配置
+type UploadConfig struct {
+	MaxSizeMB      int      `yaml:"max_size_mb" toml:"max_size_mb"`
+	AllowedTypes   []string `yaml:"allowed_types" toml:"allowed_types"` // 根据内容识别的MIME类型
+	ThumbnailWidth int      `yaml:"thumbnail_width" toml:"thumbnail_width"`
+	OrphanTTLHours int      `yaml:"orphan_ttl_hours" toml:"orphan_ttl_hours"` // 未关联文章的上传文件保留时间
+	Storage        string   `yaml:"storage" toml:"storage"`                   // local/s3
+	LocalDir       string   `yaml:"local_dir" toml:"local_dir"`
+	LocalURLPrefix string   `yaml:"local_url_prefix" toml:"local_url_prefix"`
+	S3             S3Config `yaml:"s3" toml:"s3"`
+}
+
+// S3Config S3兼容对象存储配置
+type S3Config struct {
+	Endpoint     string `yaml:"endpoint" toml:"endpoint"` // 如 https://s3.amazonaws.com 或 MinIO 地址
+	Region       string `yaml:"region" toml:"region"`
+	Bucket       string `yaml:"bucket" toml:"bucket"`
+	AccessKey    string `yaml:"access_key" toml:"access_key"`
+	SecretKey    string `yaml:"secret_key" toml:"secret_key"`
+	UsePathStyle bool   `yaml:"use_path_style" toml:"use_path_style"`
+	PublicURL    string `yaml:"public_url" toml:"public_url"` // 对外访问地址，如CDN域名
+}
+
+// SiteConfig 站点信息，用于生成订阅源等对外链接
+type SiteConfig struct {
+	Title       string `yaml:"title" toml:"title"`
+	Description string `yaml:"description" toml:"description"`
+	BaseURL     string `yaml:"base_url" toml:"base_url"` // 如 https://blog.example.com，为空时根据请求推断，响应不允许公开缓存
+}
+
+// FeedConfig RSS/Atom订阅源配置
+type FeedConfig struct {
+	ItemCount     int `yaml:"item_count" toml:"item_count"`         // 每个订阅源包含的文章数
+	SummaryLength int `yaml:"summary_length" toml:"summary_length"` // 摘要长度（字符数）
+}
+
+// TrashConfig 回收站配置
+type TrashConfig struct {
+	RetentionDays int `yaml:"retention_days" toml:"retention_days"` // 删除的文章保留天数，之后连同评论一起永久删除
+}
+
+// CacheConfig 热点读接口的响应缓存配置
+type CacheConfig struct {
+	Driver     string      `yaml:"driver" toml:"driver"`           // memory/redis/none
+	TTLSeconds int         `yaml:"ttl_seconds" toml:"ttl_seconds"` // 缓存条目的过期时间
+	MaxEntries int         `yaml:"max_entries" toml:"max_entries"` // memory驱动最多缓存的条目数
+	Redis      RedisConfig `yaml:"redis" toml:"redis"`
+}
+
+// RedisConfig Redis连接配置
+type RedisConfig struct {
+	Addr      string `yaml:"addr" toml:"addr"` // host:port
+	Password  string `yaml:"password" toml:"password"`
+	DB        int    `yaml:"db" toml:"db"`
+	KeyPrefix string `yaml:"key_prefix" toml:"key_prefix"` // 多个应用共用Redis时区分键名
+	PoolSize  int    `yaml:"pool_size" toml:"pool_size"`
+}
+
+// ViewsConfig 文章浏览量统计配置
+type ViewsConfig struct {
+	DedupWindowMinutes   int `yaml:"dedup_window_minutes" toml:"dedup_window_minutes"`     // 同一访客在窗口内重复访问同一篇文章只计一次
+	FlushIntervalSeconds int `yaml:"flush_interval_seconds" toml:"flush_interval_seconds"` // 内存中累积的浏览量写入数据库的间隔
+	MaxVisitors          int `yaml:"max_visitors" toml:"max_visitors"`                     // 内存中最多保存的去重记录数，达到后新访客不计数
+}
+
+// TrendingConfig 热门文章配置
+type TrendingConfig struct {
+	WindowDays    int     `yaml:"window_days" toml:"window_days"`         // 统计最近多少天的浏览和评论
+	HalfLifeHours float64 `yaml:"half_life_hours" toml:"half_life_hours"` // 浏览和评论的权重每经过多少小时减半
+	CommentWeight float64 `yaml:"comment_weight" toml:"comment_weight"`   // 一条评论相当于多少次浏览
 }
 
-// LoadConfig 加载配置
-func LoadConfig() *Config {
+// BatchConfig 批量操作配置
+type BatchConfig struct {
+	MaxOperations int `yaml:"max_operations" toml:"max_operations"` // 一次批量请求最多包含的操作数
+}
+
+// IdempotencyConfig 幂等键配置
+type IdempotencyConfig struct {
+	TTLHours int `yaml:"ttl_hours" toml:"ttl_hours"` // 幂等键保存多久，过期后同一个键会被当作新请求
+}
+
+// ArchiveConfig 文章导入导出配置
+type ArchiveConfig struct {
+	MaxImportSizeMB       int `yaml:"max_import_size_mb" toml:"max_import_size_mb"`             // 通过接口上传的导入文件的最大大小，命令行导入不受限制
+	MaxUncompressedSizeMB int `yaml:"max_uncompressed_size_mb" toml:"max_uncompressed_size_mb"` // 导入的压缩包解压后所有文件的最大总大小，防止压缩炸弹
+	MaxEntries            int `yaml:"max_entries" toml:"max_entries"`                           // 导入的压缩包最多包含的文件数
+}
+
+// DataExportConfig 个人数据导出配置
+type DataExportConfig struct {
+	Dir            string `yaml:"dir" toml:"dir"`                           // 导出文件保存目录，不能是公开访问的上传目录；多实例部署时所有实例必须共享该目录
+	RetentionHours int    `yaml:"retention_hours" toml:"retention_hours"`   // 导出文件保留多久，过期后删除
+	LinkTTLMinutes int    `yaml:"link_ttl_minutes" toml:"link_ttl_minutes"` // 下载链接的有效期
+}
+
+// current 当前生效的配置，只能整体替换，不能原地修改
+var current atomic.Pointer[Config]
+
+// DefaultConfig 返回默认配置
+func DefaultConfig() *Config {
 	return &Config{
 		Server: ServerConfig{
-			Port: utils.GetEnvWithDefault("SERVER_PORT", "8080"),
-			Mode: utils.GetEnvWithDefault("GIN_MODE", "debug"),
+			Port: "8080",
+			Mode: "debug",
 		},
 		Database: DatabaseConfig{
-			Host:            utils.GetEnvWithDefault("DB_HOST", "localhost"),
-			Port:            utils.GetEnvWithDefault("DB_PORT", "3306"),
-			User:            utils.GetEnvWithDefault("DB_USER", "root"),
-			Password:        utils.GetEnvWithDefault("DB_PASSWORD", ""),
-			Database:        utils.GetEnvWithDefault("DB_NAME", "blog"),
-			MaxIdleConns:    utils.GetEnvIntWithDefault("DB_MAX_IDLE_CONNS", 10),
-			MaxOpenConns:    utils.GetEnvIntWithDefault("DB_MAX_OPEN_CONNS", 100),
-			ConnMaxLifetime: utils.GetEnvIntWithDefault("DB_CONN_MAX_LIFETIME", 60),
+			Host:            "localhost",
+			Port:            "3306",
+			User:            "root",
+			Password:        "",
+			Database:        "blog",
+			MaxIdleConns:    10,
+			MaxOpenConns:    100,
+			ConnMaxLifetime: 60,
 		},
 		JWT: JWTConfig{
-			Secret:          utils.GetEnvWithDefault("JWT_SECRET", "your-secret-key-change-in-production"),
-			ExpirationHours: utils.GetEnvIntWithDefault("JWT_EXPIRATION_HOURS", 24),
+			Secret:          "your-secret-key-change-in-production",
+			ExpirationHours: 24,
+			Issuer:          "blog",
+			Audience:        "blog-api",
 		},
 		Log: LogConfig{
-			Level:      utils.GetEnvWithDefault("LOG_LEVEL", "info"),
-			Format:     utils.GetEnvWithDefault("LOG_FORMAT", "json"),
-			OutputPath: utils.GetEnvWithDefault("LOG_OUTPUT_PATH", ""),
+			Level:      "info",
+			Format:     "json",
+			OutputPath: "",
+		},
+		RateLimit: RateLimitConfig{
+			Enabled:           false,
+			RequestsPerSecond: 10,
+			Burst:             20,
+		},
+		Upload: UploadConfig{
+			MaxSizeMB:      5,
+			AllowedTypes:   []string{"image/jpeg", "image/png", "image/gif", "image/webp"},
+			ThumbnailWidth: 320,
+			OrphanTTLHours: 24,
+			Storage:        "local",
+			LocalDir:       "uploads",
+			LocalURLPrefix: "/uploads",
+			S3: S3Config{
+				Region: "us-east-1",
+			},
+		},
+		Site: SiteConfig{
+			Title:       "Blog",
+			Description: "Latest posts",
+		},
+		Feed: FeedConfig{
+			ItemCount:     20,
+			SummaryLength: 200,
+		},
+		Trash: TrashConfig{
+			RetentionDays: 30,
+		},
+		Cache: CacheConfig{
+			Driver:     "memory",
+			TTLSeconds: 60,
+			MaxEntries: 1000,
+			Redis: RedisConfig{
+				Addr:      "localhost:6379",
+				KeyPrefix: "blog:",
+				PoolSize:  10,
+			},
+		},
+		Views: ViewsConfig{
+			DedupWindowMinutes:   30,
+			FlushIntervalSeconds: 10,
+			MaxVisitors:          100000,
+		},
+		Trending: TrendingConfig{
+			WindowDays:    7,
+			HalfLifeHours: 24,
+			CommentWeight: 5,
+		},
+		Batch: BatchConfig{
+			MaxOperations: 100,
+		},
+		Idempotency: IdempotencyConfig{
+			TTLHours: 24,
+		},
+		Archive: ArchiveConfig{
+			MaxImportSizeMB:       50,
+			MaxUncompressedSizeMB: 500,
+			MaxEntries:            20000,
+		},
+		DataExport: DataExportConfig{
+			Dir:            "exports",
+			RetentionHours: 24,
+			LinkTTLMinutes: 15,
 		},
 	}
 }
+
+// Get 获取当前生效的配置
+// 返回的配置在进程内共享，调用方不得修改
+func Get() *Config {
+	return current.Load()
+}
+
+// Set 设置当前生效的配置
+func Set(cfg *Config) {
+	current.Store(cfg)
+}
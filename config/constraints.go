@@ -0,0 +1,121 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/test/blog/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// foreignKey 外键约束定义
+type foreignKey struct {
+	Table    string
+	Column   string
+	RefTable string
+	OnDelete string // RESTRICT/CASCADE/SET NULL
+}
+
+// foreignKeys 数据库中的外键约束
+// 用户只会被软删除（匿名化），所以引用用户的外键使用RESTRICT，防止误删仍被引用的用户；
+// 文章被永久删除时评论、修订版本、历史slug和浏览统计随之删除，附件解除关联后由清理任务删除
+var foreignKeys = []foreignKey{
+	{Table: "posts", Column: "user_id", RefTable: "users", OnDelete: "RESTRICT"},
+	{Table: "comments", Column: "user_id", RefTable: "users", OnDelete: "RESTRICT"},
+	{Table: "comments", Column: "post_id", RefTable: "posts", OnDelete: "CASCADE"},
+	{Table: "post_revisions", Column: "post_id", RefTable: "posts", OnDelete: "CASCADE"},
+	{Table: "post_revisions", Column: "editor_id", RefTable: "users", OnDelete: "RESTRICT"},
+	{Table: "post_slugs", Column: "post_id", RefTable: "posts", OnDelete: "CASCADE"},
+	{Table: "post_view_stats", Column: "post_id", RefTable: "posts", OnDelete: "CASCADE"},
+	{Table: "attachments", Column: "user_id", RefTable: "users", OnDelete: "RESTRICT"},
+	{Table: "attachments", Column: "post_id", RefTable: "posts", OnDelete: "SET NULL"},
+	{Table: "personal_access_tokens", Column: "user_id", RefTable: "users", OnDelete: "CASCADE"},
+	{Table: "user_identities", Column: "user_id", RefTable: "users", OnDelete: "CASCADE"},
+	{Table: "recovery_codes", Column: "user_id", RefTable: "users", OnDelete: "CASCADE"},
+	{Table: "idempotency_keys", Column: "user_id", RefTable: "users", OnDelete: "CASCADE"},
+	{Table: "data_exports", Column: "user_id", RefTable: "users", OnDelete: "CASCADE"},
+}
+
+// existingForeignKey 数据库中已有的外键约束
+type existingForeignKey struct {
+	ConstraintName string
+	DeleteRule     string
+}
+
+// ensureForeignKeys 确保每个外键约束存在且删除规则正确
+// 旧版本由GORM自动创建的约束（名称不同或删除规则不同）会被替换；
+// 添加约束之前先处理孤立行，引用已不存在的用户的孤立行无法自动处理，此时跳过该约束并记录警告，修正数据后下次启动时添加
+func ensureForeignKeys() error {
+	for _, fk := range foreignKeys {
+		var existing []existingForeignKey
+		err := DB.Raw(`
+			SELECT rc.CONSTRAINT_NAME AS constraint_name, rc.DELETE_RULE AS delete_rule
+			FROM information_schema.REFERENTIAL_CONSTRAINTS rc
+			JOIN information_schema.KEY_COLUMN_USAGE k
+				ON k.CONSTRAINT_SCHEMA = rc.CONSTRAINT_SCHEMA AND k.CONSTRAINT_NAME = rc.CONSTRAINT_NAME AND k.TABLE_NAME = rc.TABLE_NAME
+			WHERE k.TABLE_SCHEMA = DATABASE() AND k.TABLE_NAME = ? AND k.COLUMN_NAME = ? AND k.REFERENCED_TABLE_NAME = ?`,
+			fk.Table, fk.Column, fk.RefTable,
+		).Scan(&existing).Error
+		if err != nil {
+			return err
+		}
+
+		kept := false
+		for _, e := range existing {
+			if !kept && strings.EqualFold(e.DeleteRule, fk.OnDelete) {
+				kept = true
+				continue
+			}
+			if err := DB.Exec(fmt.Sprintf("ALTER TABLE `%s` DROP FOREIGN KEY `%s`", fk.Table, e.ConstraintName)).Error; err != nil {
+				return fmt.Errorf("drop foreign key %s.%s: %w", fk.Table, e.ConstraintName, err)
+			}
+		}
+		if kept {
+			continue
+		}
+
+		orphans, err := cleanOrphans(DB, fk)
+		if err != nil {
+			return fmt.Errorf("clean orphans of %s.%s: %w", fk.Table, fk.Column, err)
+		}
+		if orphans > 0 {
+			fields := []zap.Field{zap.String("table", fk.Table), zap.String("column", fk.Column), zap.Int64("rows", orphans)}
+			if fk.OnDelete == "RESTRICT" {
+				utils.LogWarn("foreign key skipped, rows reference missing records and must be fixed manually", fields...)
+				continue
+			}
+			utils.LogWarn("orphan rows cleaned before adding foreign key", append(fields, zap.String("action", fk.OnDelete))...)
+		}
+
+		name := fmt.Sprintf("fk_%s_%s", fk.Table, fk.Column)
+		err = DB.Exec(fmt.Sprintf(
+			"ALTER TABLE `%s` ADD CONSTRAINT `%s` FOREIGN KEY (`%s`) REFERENCES `%s` (`id`) ON DELETE %s",
+			fk.Table, name, fk.Column, fk.RefTable, fk.OnDelete,
+		)).Error
+		if err != nil {
+			return fmt.Errorf("add foreign key %s.%s: %w", fk.Table, fk.Column, err)
+		}
+	}
+	return nil
+}
+
+// cleanOrphans 处理外键列引用了不存在记录的孤立行，返回孤立行数量
+// CASCADE删除孤立行，SET NULL将外键置空，RESTRICT只统计不修改
+func cleanOrphans(db *gorm.DB, fk foreignKey) (int64, error) {
+	orphan := fmt.Sprintf("`%s` IS NOT NULL AND NOT EXISTS (SELECT 1 FROM `%s` r WHERE r.id = `%s`.`%s`)",
+		fk.Column, fk.RefTable, fk.Table, fk.Column)
+
+	switch fk.OnDelete {
+	case "CASCADE":
+		result := db.Exec(fmt.Sprintf("DELETE FROM `%s` WHERE %s", fk.Table, orphan))
+		return result.RowsAffected, result.Error
+	case "SET NULL":
+		result := db.Exec(fmt.Sprintf("UPDATE `%s` SET `%s` = NULL WHERE %s", fk.Table, fk.Column, orphan))
+		return result.RowsAffected, result.Error
+	default:
+		var count int64
+		err := db.Raw(fmt.Sprintf("SELECT COUNT(*) FROM `%s` WHERE %s", fk.Table, orphan)).Scan(&count).Error
+		return count, err
+	}
+}
@@ -0,0 +1,99 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"github.com/test/blog/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// setupTestDB 使用SQLite内存数据库替换全局数据库并迁移表结构，测试结束后恢复
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open("file:"+t.Name()+"?mode=memory&cache=shared"), &gorm.Config{
+		Logger:                                   logger.Discard,
+		DisableForeignKeyConstraintWhenMigrating: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, _ := db.DB()
+	t.Cleanup(func() {
+		sqlDB.Close()
+		DB = nil
+	})
+	DB = db
+	if err := AutoMigrate(); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestCleanOrphans(t *testing.T) {
+	db := setupTestDB(t)
+
+	user := models.User{Username: "alice", Email: "alice@example.com", Password: "x"}
+	db.Create(&user)
+	post := models.Post{Title: "live", Slug: "live", Content: "c", UserID: user.ID}
+	db.Create(&post)
+	missing := uint(999)
+	// 软删除的文章仍然存在，引用它的行不是孤立行
+	trashed := models.Post{Title: "trashed", Slug: "trashed", Content: "c", UserID: user.ID}
+	db.Create(&trashed)
+	db.Delete(&trashed)
+
+	db.Create(&[]models.Comment{
+		{Content: "live", UserID: user.ID, PostID: post.ID},
+		{Content: "trashed", UserID: user.ID, PostID: trashed.ID},
+		{Content: "orphan", UserID: user.ID, PostID: missing},
+		{Content: "orphan user", UserID: missing, PostID: post.ID},
+	})
+	db.Create(&[]models.Attachment{
+		{UserID: user.ID, PostID: &post.ID, StorageKey: "a"},
+		{UserID: user.ID, PostID: &missing, StorageKey: "b"},
+		{UserID: user.ID, StorageKey: "c"},
+	})
+
+	tests := []struct {
+		name  string
+		fk    foreignKey
+		want  int64
+		check func(t *testing.T)
+	}{
+		{"cascade deletes orphans", foreignKey{Table: "comments", Column: "post_id", RefTable: "posts", OnDelete: "CASCADE"}, 1, func(t *testing.T) {
+			var count int64
+			db.Unscoped().Model(&models.Comment{}).Count(&count)
+			if count != 3 {
+				t.Errorf("comments left = %d, want 3", count)
+			}
+		}},
+		{"set null clears references", foreignKey{Table: "attachments", Column: "post_id", RefTable: "posts", OnDelete: "SET NULL"}, 1, func(t *testing.T) {
+			var attachment models.Attachment
+			db.Where("storage_key = ?", "b").First(&attachment)
+			if attachment.ID == 0 || attachment.PostID != nil {
+				t.Errorf("orphan attachment = %+v", attachment)
+			}
+		}},
+		{"restrict only counts", foreignKey{Table: "comments", Column: "user_id", RefTable: "users", OnDelete: "RESTRICT"}, 1, func(t *testing.T) {
+			var count int64
+			db.Model(&models.Comment{}).Where("user_id = ?", missing).Count(&count)
+			if count != 1 {
+				t.Errorf("orphan comment was modified")
+			}
+		}},
+		{"no orphans", foreignKey{Table: "posts", Column: "user_id", RefTable: "users", OnDelete: "RESTRICT"}, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := cleanOrphans(db, tt.fk)
+			if err != nil || got != tt.want {
+				t.Fatalf("cleanOrphans = %d, %v, want %d", got, err, tt.want)
+			}
+			if tt.check != nil {
+				tt.check(t)
+			}
+		})
+	}
+}
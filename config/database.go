@@ -25,6 +25,8 @@ func InitDB(cfg *Config) {
 	var err error
 	DB, err = gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
+		// 外键约束由 ensureForeignKeys 统一维护，以便指定删除规则
+		DisableForeignKeyConstraintWhenMigrating: true,
 	})
 
 	if err != nil {
@@ -47,23 +49,94 @@ func InitDB(cfg *Config) {
 		panic(fmt.Sprintf("Failed to ping database: %v", err))
 	}
 
+	// 添加评论统计字段之前的文章需要在迁移后计算评论数
+	needCommentStats := !DB.Migrator().HasColumn(&models.Post{}, "comment_count")
+
 	// 自动迁移
-	if err := autoMigrate(); err != nil {
+	if err := AutoMigrate(); err != nil {
 		panic(fmt.Sprintf("Failed to migrate database: %v", err))
 	}
 
+	// 创建或更新外键约束
+	if err := ensureForeignKeys(); err != nil {
+		panic(fmt.Sprintf("Failed to ensure foreign keys: %v", err))
+	}
+
+	// 为添加slug之前创建的文章生成slug
+	if err := backfillPostSlugs(); err != nil {
+		panic(fmt.Sprintf("Failed to backfill post slugs: %v", err))
+	}
+
+	if needCommentStats {
+		if err := backfillCommentStats(); err != nil {
+			panic(fmt.Sprintf("Failed to backfill comment stats: %v", err))
+		}
+	}
+
 	fmt.Println("Database connected and migrated successfully")
 }
 
-// autoMigrate 自动迁移数据库表
-func autoMigrate() error {
+// AutoMigrate 自动迁移数据库表
+func AutoMigrate() error {
 	return DB.AutoMigrate(
 		&models.User{},
 		&models.Post{},
+		&models.PostSlug{},
+		&models.PostRevision{},
+		&models.PostViewStat{},
 		&models.Comment{},
+		&models.PersonalAccessToken{},
+		&models.UserIdentity{},
+		&models.RecoveryCode{},
+		&models.Attachment{},
+		&models.IdempotencyKey{},
+		&models.DataExport{},
 	)
 }
 
+// backfillPostSlugs 为没有slug的文章生成slug
+func backfillPostSlugs() error {
+	for {
+		var posts []models.Post
+		if err := DB.Unscoped().Where("slug IS NULL OR slug = ''").Order("id").Limit(100).Find(&posts).Error; err != nil {
+			return err
+		}
+		if len(posts) == 0 {
+			return nil
+		}
+		for i := range posts {
+			post := &posts[i]
+			err := DB.Transaction(func(tx *gorm.DB) error {
+				if err := post.AssignSlug(tx); err != nil {
+					return err
+				}
+				return tx.Unscoped().Model(post).UpdateColumn("slug", post.Slug).Error
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// backfillCommentStats 计算所有文章的评论数和最后评论时间
+func backfillCommentStats() error {
+	var lastID uint
+	for {
+		var ids []uint
+		if err := DB.Unscoped().Model(&models.Post{}).Where("id > ?", lastID).Order("id").Limit(500).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := models.RefreshCommentStats(DB, ids...); err != nil {
+			return err
+		}
+		lastID = ids[len(ids)-1]
+	}
+}
+
 // GetDB 获取数据库实例
 func GetDB() *gorm.DB {
 	return DB
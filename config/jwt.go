@@ -0,0 +1,89 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"sync/atomic"
+
+	"github.com/test/blog/utils"
+)
+
+// legacyKeyID 仅配置了jwt.secret时使用的密钥ID
+const legacyKeyID = "default"
+
+var jwtKeys atomic.Pointer[utils.KeySet]
+
+// InitJWTKeys 根据配置加载JWT密钥集合
+// 密钥不参与SIGHUP热更新，轮换密钥需要重启服务
+func InitJWTKeys(cfg *Config) error {
+	ks, err := BuildKeySet(cfg.JWT)
+	if err != nil {
+		return err
+	}
+	jwtKeys.Store(ks)
+	return nil
+}
+
+// JWTKeys 获取JWT密钥集合
+func JWTKeys() *utils.KeySet {
+	return jwtKeys.Load()
+}
+
+// BuildKeySet 根据JWT配置创建密钥集合
+// 配置了jwt.legacy_until时，没有kid的旧token（密钥轮换之前签发）在此之前使用jwt.secret验证
+func BuildKeySet(cfg JWTConfig) (*utils.KeySet, error) {
+	if len(cfg.Keys) == 0 {
+		key := &utils.SigningKey{
+			ID:        legacyKeyID,
+			Algorithm: utils.AlgHS256,
+			Secret:    []byte(cfg.Secret),
+		}
+		ks, err := utils.NewKeySet([]*utils.SigningKey{key}, legacyKeyID, cfg.Issuer, cfg.Audience)
+		if err != nil {
+			return nil, err
+		}
+		if !cfg.LegacyUntil.IsZero() {
+			ks.SetLegacySecret(key.Secret, cfg.LegacyUntil)
+		}
+		return ks, nil
+	}
+
+	keys := make([]*utils.SigningKey, 0, len(cfg.Keys))
+	for _, kc := range cfg.Keys {
+		key := &utils.SigningKey{
+			ID:        kc.ID,
+			Algorithm: kc.Algorithm,
+			Secret:    []byte(kc.Secret),
+		}
+
+		if kc.PrivateKeyFile != "" {
+			data, err := os.ReadFile(kc.PrivateKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read private key for JWT key %q: %w", kc.ID, err)
+			}
+			if key.PrivateKey, err = utils.ParsePrivateKeyPEM(data); err != nil {
+				return nil, fmt.Errorf("failed to parse private key for JWT key %q: %w", kc.ID, err)
+			}
+		} else if kc.PublicKeyFile != "" {
+			data, err := os.ReadFile(kc.PublicKeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read public key for JWT key %q: %w", kc.ID, err)
+			}
+			if key.PublicKey, err = utils.ParsePublicKeyPEM(data); err != nil {
+				return nil, fmt.Errorf("failed to parse public key for JWT key %q: %w", kc.ID, err)
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	ks, err := utils.NewKeySet(keys, cfg.ActiveKeyID, cfg.Issuer, cfg.Audience)
+	if err != nil {
+		return nil, err
+	}
+	// 配置了jwt.keys后，jwt.secret只用于验证切换前签发的旧token；默认占位符是公开的，不能用于验证
+	if !cfg.LegacyUntil.IsZero() && cfg.Secret != "" && cfg.Secret != placeholderJWTSecret {
+		ks.SetLegacySecret([]byte(cfg.Secret), cfg.LegacyUntil)
+	}
+	return ks, nil
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/test/blog/utils"
+)
+
+// legacyToken 生成升级前格式的token：HS256签名，没有kid、iss和aud
+// audience不为空时模拟使用同一密钥的其他服务签发的token
+func legacyToken(t *testing.T, secret string, audience ...string) string {
+	t.Helper()
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, utils.JWTClaims{UserID: 5, Username: "carol", RegisteredClaims: jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+		IssuedAt:  jwt.NewNumericDate(now),
+		Audience:  audience,
+	}})
+	s, err := token.SignedString([]byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestBuildKeySetLegacyTokens(t *testing.T) {
+	const secret = "legacy-secret-legacy-secret-legacy"
+	hsKey := JWTKeyConfig{ID: "k1", Algorithm: utils.AlgHS256, Secret: "new-secret-new-secret-new-secret-1"}
+	until := time.Now().Add(time.Hour)
+	past := time.Now().Add(-time.Minute)
+
+	tests := []struct {
+		name   string
+		cfg    JWTConfig
+		token  string
+		accept bool
+	}{
+		{"secret only", JWTConfig{Secret: secret, Issuer: "blog", Audience: "blog-api", LegacyUntil: until}, legacyToken(t, secret), true},
+		{"keys with old secret", JWTConfig{Secret: secret, Issuer: "blog", ActiveKeyID: "k1", Keys: []JWTKeyConfig{hsKey}, LegacyUntil: until}, legacyToken(t, secret), true},
+		// 默认不接受没有kid的token
+		{"legacy not enabled", JWTConfig{Secret: secret, Issuer: "blog", Audience: "blog-api"}, legacyToken(t, secret), false},
+		{"legacy period over", JWTConfig{Secret: secret, Issuer: "blog", Audience: "blog-api", LegacyUntil: past}, legacyToken(t, secret), false},
+		{"keys without secret", JWTConfig{Issuer: "blog", ActiveKeyID: "k1", Keys: []JWTKeyConfig{hsKey}, LegacyUntil: until}, legacyToken(t, secret), false},
+		// 默认占位符是公开的，用它签名的token不能通过验证
+		{"keys with placeholder secret", JWTConfig{Secret: placeholderJWTSecret, Issuer: "blog", ActiveKeyID: "k1", Keys: []JWTKeyConfig{hsKey}, LegacyUntil: until}, legacyToken(t, placeholderJWTSecret), false},
+		{"wrong secret", JWTConfig{Secret: secret, Issuer: "blog", LegacyUntil: until}, legacyToken(t, "some-other-secret"), false},
+		{"wrong audience", JWTConfig{Secret: secret, Issuer: "blog", Audience: "blog-api", LegacyUntil: until}, legacyToken(t, secret, "other-api"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks, err := BuildKeySet(tt.cfg)
+			if err != nil {
+				t.Fatalf("BuildKeySet: %v", err)
+			}
+			claims, err := ks.ValidateToken(tt.token)
+			if tt.accept && (err != nil || claims.UserID != 5) {
+				t.Fatalf("expected legacy token to be accepted, got %v", err)
+			}
+			if !tt.accept && err == nil {
+				t.Fatal("expected legacy token to be rejected")
+			}
+		})
+	}
+}
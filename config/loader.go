@@ -0,0 +1,250 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// 配置优先级（从低到高）：默认值 < 配置文件 < 环境变量 < 命令行参数
+
+// loadArgs 首次加载时使用的命令行参数，重新加载时复用
+var loadArgs []string
+
+// Init 加载配置并设置为当前生效的配置
+func Init(args []string) (*Config, error) {
+	cfg, err := Load(args)
+	if err != nil {
+		return nil, err
+	}
+	loadArgs = args
+	Set(cfg)
+	return cfg, nil
+}
+
+// Load 按优先级合并默认值、配置文件、环境变量和命令行参数，并验证结果
+// 配置项有问题时返回合并后的配置和*ValidationError，便于调用方展示完整的报告
+func Load(args []string) (*Config, error) {
+	cfg := DefaultConfig()
+
+	fs := flag.NewFlagSet("blog", flag.ContinueOnError)
+	configFile := fs.String("config", os.Getenv("CONFIG_FILE"), "配置文件路径（.yaml/.yml/.toml）")
+	port := fs.String("port", "", "服务器端口")
+	mode := fs.String("mode", "", "Gin模式 (debug/release/test)")
+	logLevel := fs.String("log-level", "", "日志级别 (debug/info/warn/error)")
+	logFormat := fs.String("log-format", "", "日志格式 (json/console)")
+	dbHost := fs.String("db-host", "", "数据库主机")
+	dbPort := fs.String("db-port", "", "数据库端口")
+	dbName := fs.String("db-name", "", "数据库名称")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// 配置文件
+	if *configFile != "" {
+		if err := loadFile(*configFile, cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// 环境变量
+	verr := &ValidationError{}
+	applyEnv(cfg, verr)
+
+	// 命令行参数（仅覆盖显式设置的参数）
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "port":
+			cfg.Server.Port = *port
+		case "mode":
+			cfg.Server.Mode = *mode
+		case "log-level":
+			cfg.Log.Level = *logLevel
+		case "log-format":
+			cfg.Log.Format = *logFormat
+		case "db-host":
+			cfg.Database.Host = *dbHost
+		case "db-port":
+			cfg.Database.Port = *dbPort
+		case "db-name":
+			cfg.Database.Database = *dbName
+		}
+	})
+
+	validate(cfg, verr)
+	return cfg, verr.Err()
+}
+
+// loadFile 读取YAML或TOML配置文件并覆盖到cfg上
+func loadFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file %s: %w", path, err)
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, cfg)
+	case ".toml":
+		err = toml.Unmarshal(data, cfg)
+	default:
+		return fmt.Errorf("unsupported config file format: %s", path)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return nil
+}
+
+// applyEnv 使用环境变量覆盖配置，格式错误的值记录到verr
+func applyEnv(cfg *Config, verr *ValidationError) {
+	envString("SERVER_PORT", &cfg.Server.Port)
+	envString("GIN_MODE", &cfg.Server.Mode)
+	envList("TRUSTED_PROXIES", &cfg.Server.TrustedProxies)
+
+	envString("DB_HOST", &cfg.Database.Host)
+	envString("DB_PORT", &cfg.Database.Port)
+	envString("DB_USER", &cfg.Database.User)
+	envString("DB_PASSWORD", &cfg.Database.Password)
+	envString("DB_NAME", &cfg.Database.Database)
+	envInt("DB_MAX_IDLE_CONNS", "database.max_idle_conns", &cfg.Database.MaxIdleConns, verr)
+	envInt("DB_MAX_OPEN_CONNS", "database.max_open_conns", &cfg.Database.MaxOpenConns, verr)
+	envInt("DB_CONN_MAX_LIFETIME", "database.conn_max_lifetime", &cfg.Database.ConnMaxLifetime, verr)
+
+	envString("JWT_SECRET", &cfg.JWT.Secret)
+	envInt("JWT_EXPIRATION_HOURS", "jwt.expiration_hours", &cfg.JWT.ExpirationHours, verr)
+	envString("JWT_ISSUER", &cfg.JWT.Issuer)
+	envString("JWT_AUDIENCE", &cfg.JWT.Audience)
+	envTime("JWT_LEGACY_UNTIL", "jwt.legacy_until", &cfg.JWT.LegacyUntil, verr)
+	envString("JWT_ACTIVE_KEY_ID", &cfg.JWT.ActiveKeyID)
+
+	envString("LOG_LEVEL", &cfg.Log.Level)
+	envString("LOG_FORMAT", &cfg.Log.Format)
+	envString("LOG_OUTPUT_PATH", &cfg.Log.OutputPath)
+
+	envInt("UPLOAD_MAX_SIZE_MB", "upload.max_size_mb", &cfg.Upload.MaxSizeMB, verr)
+	envString("UPLOAD_STORAGE", &cfg.Upload.Storage)
+	envString("UPLOAD_LOCAL_DIR", &cfg.Upload.LocalDir)
+	envString("S3_ENDPOINT", &cfg.Upload.S3.Endpoint)
+	envString("S3_REGION", &cfg.Upload.S3.Region)
+	envString("S3_BUCKET", &cfg.Upload.S3.Bucket)
+	envString("S3_ACCESS_KEY", &cfg.Upload.S3.AccessKey)
+	envString("S3_SECRET_KEY", &cfg.Upload.S3.SecretKey)
+
+	envString("SITE_TITLE", &cfg.Site.Title)
+	envString("SITE_BASE_URL", &cfg.Site.BaseURL)
+	envInt("FEED_ITEM_COUNT", "feed.item_count", &cfg.Feed.ItemCount, verr)
+
+	envInt("TRASH_RETENTION_DAYS", "trash.retention_days", &cfg.Trash.RetentionDays, verr)
+
+	envString("CACHE_DRIVER", &cfg.Cache.Driver)
+	envInt("CACHE_TTL_SECONDS", "cache.ttl_seconds", &cfg.Cache.TTLSeconds, verr)
+	envInt("CACHE_MAX_ENTRIES", "cache.max_entries", &cfg.Cache.MaxEntries, verr)
+	envString("REDIS_ADDR", &cfg.Cache.Redis.Addr)
+	envString("REDIS_PASSWORD", &cfg.Cache.Redis.Password)
+	envInt("REDIS_DB", "cache.redis.db", &cfg.Cache.Redis.DB, verr)
+
+	envInt("VIEWS_DEDUP_WINDOW_MINUTES", "views.dedup_window_minutes", &cfg.Views.DedupWindowMinutes, verr)
+	envInt("VIEWS_FLUSH_INTERVAL_SECONDS", "views.flush_interval_seconds", &cfg.Views.FlushIntervalSeconds, verr)
+	envInt("VIEWS_MAX_VISITORS", "views.max_visitors", &cfg.Views.MaxVisitors, verr)
+	envInt("TRENDING_WINDOW_DAYS", "trending.window_days", &cfg.Trending.WindowDays, verr)
+	envFloat("TRENDING_HALF_LIFE_HOURS", "trending.half_life_hours", &cfg.Trending.HalfLifeHours, verr)
+	envFloat("TRENDING_COMMENT_WEIGHT", "trending.comment_weight", &cfg.Trending.CommentWeight, verr)
+	envInt("BATCH_MAX_OPERATIONS", "batch.max_operations", &cfg.Batch.MaxOperations, verr)
+	envInt("IDEMPOTENCY_TTL_HOURS", "idempotency.ttl_hours", &cfg.Idempotency.TTLHours, verr)
+	envInt("ARCHIVE_MAX_IMPORT_SIZE_MB", "archive.max_import_size_mb", &cfg.Archive.MaxImportSizeMB, verr)
+	envInt("ARCHIVE_MAX_UNCOMPRESSED_SIZE_MB", "archive.max_uncompressed_size_mb", &cfg.Archive.MaxUncompressedSizeMB, verr)
+	envInt("ARCHIVE_MAX_ENTRIES", "archive.max_entries", &cfg.Archive.MaxEntries, verr)
+	envString("DATA_EXPORT_DIR", &cfg.DataExport.Dir)
+	envInt("DATA_EXPORT_RETENTION_HOURS", "data_export.retention_hours", &cfg.DataExport.RetentionHours, verr)
+	envInt("DATA_EXPORT_LINK_TTL_MINUTES", "data_export.link_ttl_minutes", &cfg.DataExport.LinkTTLMinutes, verr)
+
+	envBool("RATE_LIMIT_ENABLED", "rate_limit.enabled", &cfg.RateLimit.Enabled, verr)
+	envFloat("RATE_LIMIT_RPS", "rate_limit.requests_per_second", &cfg.RateLimit.RequestsPerSecond, verr)
+	envInt("RATE_LIMIT_BURST", "rate_limit.burst", &cfg.RateLimit.Burst, verr)
+}
+
+// envString 环境变量存在时覆盖字符串配置
+func envString(key string, dst *string) {
+	if value := os.Getenv(key); value != "" {
+		*dst = value
+	}
+}
+
+// envList 环境变量存在时覆盖列表配置，多个值用逗号分隔
+func envList(key string, dst *[]string) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	*dst = list
+}
+
+// envInt 环境变量存在时覆盖整数配置
+func envInt(key, field string, dst *int, verr *ValidationError) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	intValue, err := strconv.Atoi(value)
+	if err != nil {
+		verr.Add(field, "environment variable %s must be a valid integer, got %q", key, value)
+		return
+	}
+	*dst = intValue
+}
+
+// envFloat 环境变量存在时覆盖浮点数配置
+func envFloat(key, field string, dst *float64, verr *ValidationError) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	floatValue, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		verr.Add(field, "environment variable %s must be a valid number, got %q", key, value)
+		return
+	}
+	*dst = floatValue
+}
+
+// envTime 环境变量存在时覆盖时间配置，格式为RFC 3339
+func envTime(key, field string, dst *time.Time, verr *ValidationError) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	timeValue, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		verr.Add(field, "environment variable %s must be an RFC 3339 time, got %q", key, value)
+		return
+	}
+	*dst = timeValue
+}
+
+// envBool 环境变量存在时覆盖布尔配置
+func envBool(key, field string, dst *bool, verr *ValidationError) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	boolValue, err := strconv.ParseBool(value)
+	if err != nil {
+		verr.Add(field, "environment variable %s must be a valid boolean, got %q", key, value)
+		return
+	}
+	*dst = boolValue
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile 在临时目录中写入配置文件，返回路径
+func writeConfigFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	yamlFile := writeConfigFile(t, "config.yaml", "server:\n  port: \"9000\"\ndatabase:\n  host: file-host\n  database: file-db\nlog:\n  level: warn\n")
+	tomlFile := writeConfigFile(t, "config.toml", "[server]\nport = \"9001\"\n\n[database]\nhost = \"toml-host\"\n")
+
+	tests := []struct {
+		name  string
+		env   map[string]string
+		args  []string
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "defaults",
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Server.Port != "8080" || cfg.Database.Host != "localhost" || cfg.Archive.MaxEntries != 20000 {
+					t.Errorf("defaults = %+v %+v", cfg.Server, cfg.Database)
+				}
+			},
+		},
+		{
+			name: "yaml file",
+			args: []string{"-config", yamlFile},
+			check: func(t *testing.T, cfg *Config) {
+				// 文件中没有的配置项保持默认值
+				if cfg.Server.Port != "9000" || cfg.Database.Host != "file-host" || cfg.Log.Level != "warn" || cfg.Database.Port != "3306" {
+					t.Errorf("config = %+v %+v %+v", cfg.Server, cfg.Database, cfg.Log)
+				}
+			},
+		},
+		{
+			name: "toml file from env",
+			env:  map[string]string{"CONFIG_FILE": tomlFile},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Server.Port != "9001" || cfg.Database.Host != "toml-host" {
+					t.Errorf("config = %+v %+v", cfg.Server, cfg.Database)
+				}
+			},
+		},
+		{
+			name: "env overrides file",
+			env:  map[string]string{"DB_HOST": "env-host", "LOG_LEVEL": "error", "RATE_LIMIT_ENABLED": "true", "RATE_LIMIT_RPS": "2.5", "TRUSTED_PROXIES": "10.0.0.1, 172.16.0.0/12"},
+			args: []string{"-config", yamlFile},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Host != "env-host" || cfg.Database.Database != "file-db" || cfg.Log.Level != "error" {
+					t.Errorf("config = %+v %+v", cfg.Database, cfg.Log)
+				}
+				if !cfg.RateLimit.Enabled || cfg.RateLimit.RequestsPerSecond != 2.5 {
+					t.Errorf("rate limit = %+v", cfg.RateLimit)
+				}
+				if strings.Join(cfg.Server.TrustedProxies, ",") != "10.0.0.1,172.16.0.0/12" {
+					t.Errorf("trusted proxies = %v", cfg.Server.TrustedProxies)
+				}
+			},
+		},
+		{
+			name: "flags override env",
+			env:  map[string]string{"DB_HOST": "env-host", "SERVER_PORT": "7000"},
+			args: []string{"-config", yamlFile, "-db-host", "flag-host", "-log-level", "debug"},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.Database.Host != "flag-host" || cfg.Server.Port != "7000" || cfg.Log.Level != "debug" {
+					t.Errorf("config = %+v %+v %+v", cfg.Server, cfg.Database, cfg.Log)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			cfg, err := Load(tt.args)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		env    map[string]string
+		args   []string
+		fields []string // 为空表示不是ValidationError
+		msg    string
+	}{
+		{name: "unknown flag", args: []string{"-unknown"}, msg: "flag provided but not defined"},
+		{name: "missing file", args: []string{"-config", "/nonexistent/config.yaml"}, msg: "failed to read config file"},
+		{name: "unsupported format", args: []string{"-config", writeConfigFile(t, "config.json", "{}")}, msg: "unsupported config file format"},
+		{name: "invalid yaml", args: []string{"-config", writeConfigFile(t, "bad.yaml", "server: [")}, msg: "failed to parse config file"},
+		{
+			name:   "invalid env values",
+			env:    map[string]string{"RATE_LIMIT_BURST": "many", "RATE_LIMIT_ENABLED": "maybe", "JWT_LEGACY_UNTIL": "tomorrow"},
+			fields: []string{"jwt.legacy_until", "rate_limit.enabled", "rate_limit.burst"},
+		},
+		{
+			// 环境变量格式错误和验证失败一起报告
+			name:   "env and validation errors",
+			env:    map[string]string{"BATCH_MAX_OPERATIONS": "ten"},
+			args:   []string{"-port", "0"},
+			fields: []string{"batch.max_operations", "server.port"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+			_, err := Load(tt.args)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			var verr *ValidationError
+			if len(tt.fields) == 0 {
+				if errors.As(err, &verr) || !strings.Contains(err.Error(), tt.msg) {
+					t.Errorf("error = %v, want %q", err, tt.msg)
+				}
+				return
+			}
+			if !errors.As(err, &verr) {
+				t.Fatalf("error = %v, want *ValidationError", err)
+			}
+			var got []string
+			for _, fe := range verr.Errors {
+				got = append(got, fe.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("fields = %v, want %v", got, tt.fields)
+			}
+		})
+	}
+}
+
+func TestReload(t *testing.T) {
+	previous, previousArgs := Get(), loadArgs
+	t.Cleanup(func() {
+		Set(previous)
+		loadArgs = previousArgs
+	})
+
+	path := writeConfigFile(t, "config.yaml", "server:\n  port: \"9000\"\nlog:\n  level: info\n")
+	if _, err := Init([]string{"-config", path}); err != nil {
+		t.Fatal(err)
+	}
+	var reloaded *Config
+	OnReload(func(cfg *Config) { reloaded = cfg })
+	t.Cleanup(func() { reloadHooks = reloadHooks[:len(reloadHooks)-1] })
+
+	// 日志级别和限流热更新，端口需要重启才能生效
+	content := "server:\n  port: \"9100\"\nlog:\n  level: debug\nrate_limit:\n  enabled: true\n  requests_per_second: 3\n  burst: 6\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Reload()
+	if err != nil {
+		t.Fatalf("Reload: %v", err)
+	}
+	if cfg.Log.Level != "debug" || !cfg.RateLimit.Enabled || cfg.RateLimit.Burst != 6 {
+		t.Errorf("reloaded log = %+v, rate limit = %+v", cfg.Log, cfg.RateLimit)
+	}
+	if cfg.Server.Port != "9000" {
+		t.Errorf("port = %s, want 9000 until restart", cfg.Server.Port)
+	}
+	if Get() != cfg || reloaded != cfg {
+		t.Error("reloaded config was not set or hooks were not called")
+	}
+
+	// 新配置无效时保持当前配置
+	if err := os.WriteFile(path, []byte("log:\n  level: verbose\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	reloaded = nil
+	if _, err := Reload(); err == nil {
+		t.Fatal("expected invalid config to be rejected")
+	}
+	if Get() != cfg || reloaded != nil {
+		t.Error("invalid reload changed the current config")
+	}
+}
@@ -0,0 +1,52 @@
+package config
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"sync"
+)
+
+var (
+	reloadMu    sync.Mutex
+	reloadHooks []func(*Config)
+)
+
+// OnReload 注册配置重新加载后的回调
+func OnReload(fn func(*Config)) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+	reloadHooks = append(reloadHooks, fn)
+}
+
+// Reload 重新加载配置（通常由SIGHUP触发）
+// 只有日志级别和限流配置会热更新，其余配置的变更需要重启服务才能生效
+func Reload() (*Config, error) {
+	reloadMu.Lock()
+	defer reloadMu.Unlock()
+
+	old := Get()
+	if old == nil {
+		return nil, errors.New("config has not been initialized")
+	}
+
+	fresh, err := Load(loadArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	next := *old
+	next.Log.Level = fresh.Log.Level
+	next.RateLimit = fresh.RateLimit
+
+	// 提示需要重启才能生效的配置变更
+	if !reflect.DeepEqual(next, *fresh) {
+		log.Println("Some configuration changes require a restart to take effect")
+	}
+
+	Set(&next)
+	for _, fn := range reloadHooks {
+		fn(&next)
+	}
+	return &next, nil
+}
@@ -0,0 +1,62 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"gorm.io/gorm"
+)
+
+// mysqlDuplicateEntry MySQL唯一约束冲突的错误码
+const mysqlDuplicateEntry = 1062
+
+// ConflictError 写入违反唯一约束
+type ConflictError struct {
+	Key string // 冲突的唯一索引名，例如 idx_users_email
+	Err error
+}
+
+// Error 实现error接口
+func (e *ConflictError) Error() string {
+	return "unique constraint conflict on " + e.Key + ": " + e.Err.Error()
+}
+
+// Unwrap 返回原始的数据库错误
+func (e *ConflictError) Unwrap() error {
+	return e.Err
+}
+
+// WithTx 在绑定到ctx的事务中执行fn，fn返回错误或panic时回滚
+// 违反唯一约束的错误被转换为 *ConflictError，其他错误原样返回
+func WithTx(ctx context.Context, fn func(tx *gorm.DB) error) error {
+	return AsConflict(DB.WithContext(ctx).Transaction(fn))
+}
+
+// AsConflict 将违反唯一约束的数据库错误转换为 *ConflictError，其他错误原样返回
+// 开启TranslateError的连接返回gorm.ErrDuplicatedKey，此时无法得到索引名
+func AsConflict(err error) error {
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return &ConflictError{Err: err}
+	}
+	var mysqlErr *mysql.MySQLError
+	if !errors.As(err, &mysqlErr) || mysqlErr.Number != mysqlDuplicateEntry {
+		return err
+	}
+	return &ConflictError{Key: duplicateKeyName(mysqlErr.Message), Err: err}
+}
+
+// duplicateKeyName 从错误信息中解析索引名
+// 格式为 Duplicate entry 'xxx' for key 'users.idx_users_email'，MySQL 8.0之前的版本没有表名前缀
+func duplicateKeyName(message string) string {
+	i := strings.LastIndex(message, " for key '")
+	if i < 0 {
+		return ""
+	}
+	key := strings.TrimSuffix(message[i+len(" for key '"):], "'")
+	if j := strings.LastIndex(key, "."); j >= 0 {
+		key = key[j+1:]
+	}
+	return key
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/test/blog/models"
+	"gorm.io/gorm"
+)
+
+func TestAsConflict(t *testing.T) {
+	mysql8 := &mysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry 'alice' for key 'users.idx_users_username'"}
+	mysql57 := &mysql.MySQLError{Number: mysqlDuplicateEntry, Message: "Duplicate entry 'a@b.c' for key 'idx_users_email'"}
+	foreignKey := &mysql.MySQLError{Number: 1452, Message: "Cannot add or update a child row"}
+	other := errors.New("connection refused")
+
+	tests := []struct {
+		name string
+		err  error
+		key  string // 为空表示不是冲突错误
+	}{
+		{"mysql 8 message", mysql8, "idx_users_username"},
+		{"mysql 5.7 message", mysql57, "idx_users_email"},
+		{"wrapped", fmt.Errorf("create user: %w", mysql8), "idx_users_username"},
+		{"other mysql error", foreignKey, ""},
+		{"other error", other, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := AsConflict(tt.err)
+			var conflict *ConflictError
+			if !errors.As(got, &conflict) {
+				if tt.key != "" {
+					t.Fatalf("AsConflict = %v, want conflict on %s", got, tt.key)
+				}
+				if got != tt.err {
+					t.Errorf("AsConflict changed the error to %v", got)
+				}
+				return
+			}
+			if conflict.Key != tt.key || !errors.Is(got, tt.err) {
+				t.Errorf("conflict key = %q, want %q, unwraps to original: %v", conflict.Key, tt.key, errors.Is(got, tt.err))
+			}
+		})
+	}
+
+	var conflict *ConflictError
+	if got := AsConflict(fmt.Errorf("insert: %w", gorm.ErrDuplicatedKey)); !errors.As(got, &conflict) || conflict.Key != "" {
+		t.Errorf("AsConflict(ErrDuplicatedKey) = %v, want conflict without key", got)
+	}
+
+	if AsConflict(nil) != nil {
+		t.Error("AsConflict(nil) should be nil")
+	}
+}
+
+func TestDuplicateKeyName(t *testing.T) {
+	tests := []struct {
+		message string
+		want    string
+	}{
+		{"Duplicate entry 'x' for key 'posts.idx_posts_slug'", "idx_posts_slug"},
+		{"Duplicate entry 'x' for key 'idx_posts_slug'", "idx_posts_slug"},
+		// 值本身包含引号和for key时按最后一次出现解析
+		{"Duplicate entry 'a' for key 'b' for key 'users.idx_users_email'", "idx_users_email"},
+		{"Duplicate entry 'x'", ""},
+	}
+	for _, tt := range tests {
+		if got := duplicateKeyName(tt.message); got != tt.want {
+			t.Errorf("duplicateKeyName(%q) = %q, want %q", tt.message, got, tt.want)
+		}
+	}
+}
+
+func TestWithTx(t *testing.T) {
+	db := setupTestDB(t)
+	create := func(tx *gorm.DB, name string) error {
+		return tx.Create(&models.User{Username: name, Email: name + "@example.com", Password: "x"}).Error
+	}
+	exists := func(name string) bool {
+		var count int64
+		db.Model(&models.User{}).Where("username = ?", name).Count(&count)
+		return count > 0
+	}
+	errFailed := errors.New("failed")
+
+	tests := []struct {
+		name    string
+		user    string
+		ctx     func() context.Context
+		fn      func(tx *gorm.DB) error
+		wantErr error
+		panics  bool
+		commit  bool
+	}{
+		{"commits", "committed", context.Background, func(tx *gorm.DB) error { return create(tx, "committed") }, nil, false, true},
+		{"rolls back on error", "errored", context.Background, func(tx *gorm.DB) error {
+			if err := create(tx, "errored"); err != nil {
+				return err
+			}
+			return errFailed
+		}, errFailed, false, false},
+		{"rolls back on panic", "panicked", context.Background, func(tx *gorm.DB) error {
+			if err := create(tx, "panicked"); err != nil {
+				return err
+			}
+			panic("boom")
+		}, nil, true, false},
+		{"cancelled context", "cancelled", func() context.Context {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			return ctx
+		}, func(tx *gorm.DB) error { return create(tx, "cancelled") }, context.Canceled, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var err error
+			func() {
+				defer func() {
+					if r := recover(); (r != nil) != tt.panics {
+						t.Errorf("recovered %v, want panic %v", r, tt.panics)
+					}
+				}()
+				err = WithTx(tt.ctx(), tt.fn)
+			}()
+			if tt.wantErr != nil && !errors.Is(err, tt.wantErr) {
+				t.Errorf("WithTx = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !tt.panics && err != nil {
+				t.Errorf("WithTx = %v", err)
+			}
+			if exists(tt.user) != tt.commit {
+				t.Errorf("user %s exists = %v, want %v", tt.user, !tt.commit, tt.commit)
+			}
+		})
+	}
+}
@@ -1,98 +1,348 @@
 package config
 
 import (
+	"fmt"
 	"log"
-	"os"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
 	"strings"
 
 	"github.com/test/blog/utils"
 )
 
-// ValidateConfig 验证配置
-func ValidateConfig() {
-	log.Println("Validating configuration...")
+// placeholderJWTSecret 默认的JWT密钥占位符，生产环境禁止使用
+const placeholderJWTSecret = "your-secret-key-change-in-production"
 
-	// 验证必需的环境变量
-	requiredEnvVars := []string{
-		"JWT_SECRET", // 只有JWT_SECRET是真正必需的
+// minReleaseJWTSecretLength 生产环境JWT密钥的最小长度
+const minReleaseJWTSecretLength = 32
+
+var (
+	validModes      = []string{"debug", "release", "test"}
+	validLogLevels  = []string{"debug", "info", "warn", "error"}
+	validLogFormats = []string{"json", "console"}
+)
+
+// Validate 验证配置，收集所有问题后一次性返回
+func Validate(cfg *Config) error {
+	verr := &ValidationError{}
+	validate(cfg, verr)
+	return verr.Err()
+}
+
+// validate 验证配置并将问题追加到verr
+func validate(cfg *Config, verr *ValidationError) {
+	// 验证服务器配置
+	if cfg.Server.Port == "" {
+		verr.Add("server.port", "cannot be empty")
+	} else if port, err := strconv.Atoi(cfg.Server.Port); err != nil || port < 1 || port > 65535 {
+		verr.Add("server.port", "must be a number between 1 and 65535, got %q", cfg.Server.Port)
+	}
+	if !contains(validModes, cfg.Server.Mode) {
+		verr.Add("server.mode", "must be one of %v, got %q", validModes, cfg.Server.Mode)
+	}
+	for i, proxy := range cfg.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				verr.Add(fmt.Sprintf("server.trusted_proxies[%d]", i), "must be an IP address or CIDR, got %q", proxy)
+			}
+		}
 	}
 
-	var missingVars []string
+	// 验证数据库配置
+	if cfg.Database.Host == "" {
+		verr.Add("database.host", "cannot be empty")
+	}
+	if cfg.Database.Port == "" {
+		verr.Add("database.port", "cannot be empty")
+	}
+	if cfg.Database.User == "" {
+		verr.Add("database.user", "cannot be empty")
+	}
+	if cfg.Database.Database == "" {
+		verr.Add("database.database", "cannot be empty")
+	}
+
+	// 验证数据库连接池配置
+	if cfg.Database.MaxIdleConns <= 0 {
+		verr.Add("database.max_idle_conns", "must be greater than 0")
+	}
+	if cfg.Database.MaxOpenConns <= 0 {
+		verr.Add("database.max_open_conns", "must be greater than 0")
+	}
+	if cfg.Database.ConnMaxLifetime <= 0 {
+		verr.Add("database.conn_max_lifetime", "must be greater than 0")
+	}
+	if cfg.Database.MaxIdleConns > cfg.Database.MaxOpenConns {
+		verr.Add("database.max_idle_conns", "cannot be greater than database.max_open_conns")
+	}
 
-	for _, envVar := range requiredEnvVars {
-		if os.Getenv(envVar) == "" {
-			missingVars = append(missingVars, envVar)
+	// 验证JWT配置
+	if len(cfg.JWT.Keys) == 0 {
+		validateJWTSecret("jwt.secret", cfg.JWT.Secret, cfg.Server.Mode, verr)
+	} else {
+		validateJWTKeys(cfg, verr)
+		// 此时jwt.secret只用于验证旧token，默认占位符会被忽略
+		if cfg.JWT.Secret != "" && cfg.JWT.Secret != placeholderJWTSecret {
+			validateJWTSecret("jwt.secret", cfg.JWT.Secret, cfg.Server.Mode, verr)
 		}
 	}
+	// 配置了jwt.keys时旧token使用jwt.secret验证，默认占位符会被忽略
+	if !cfg.JWT.LegacyUntil.IsZero() && len(cfg.JWT.Keys) > 0 && (cfg.JWT.Secret == "" || cfg.JWT.Secret == placeholderJWTSecret) {
+		verr.Add("jwt.legacy_until", "requires jwt.secret to verify tokens without kid")
+	}
+	if cfg.JWT.ExpirationHours <= 0 {
+		verr.Add("jwt.expiration_hours", "must be greater than 0")
+	}
 
-	if len(missingVars) > 0 {
-		log.Fatalf("Missing required environment variables: %s", strings.Join(missingVars, ", "))
+	// 验证OIDC配置
+	providerNames := make(map[string]bool)
+	for i, provider := range cfg.OIDC.Providers {
+		field := fmt.Sprintf("oidc.providers[%d]", i)
+		if provider.Name == "" {
+			verr.Add(field+".name", "cannot be empty")
+		} else if providerNames[provider.Name] {
+			verr.Add(field+".name", "duplicate provider name %q", provider.Name)
+		}
+		providerNames[provider.Name] = true
+		if provider.Issuer == "" {
+			verr.Add(field+".issuer", "cannot be empty")
+		}
+		if provider.ClientID == "" {
+			verr.Add(field+".client_id", "cannot be empty")
+		}
+		if provider.RedirectURL == "" {
+			verr.Add(field+".redirect_url", "cannot be empty")
+		}
+	}
+
+	// 验证上传配置
+	if cfg.Upload.MaxSizeMB <= 0 {
+		verr.Add("upload.max_size_mb", "must be greater than 0")
+	}
+	if len(cfg.Upload.AllowedTypes) == 0 {
+		verr.Add("upload.allowed_types", "cannot be empty")
+	}
+	if cfg.Upload.ThumbnailWidth <= 0 {
+		verr.Add("upload.thumbnail_width", "must be greater than 0")
+	}
+	if cfg.Upload.OrphanTTLHours <= 0 {
+		verr.Add("upload.orphan_ttl_hours", "must be greater than 0")
+	}
+	switch cfg.Upload.Storage {
+	case "local":
+		if cfg.Upload.LocalDir == "" {
+			verr.Add("upload.local_dir", "cannot be empty when storage is local")
+		}
+	case "s3":
+		if cfg.Upload.S3.Endpoint == "" {
+			verr.Add("upload.s3.endpoint", "cannot be empty when storage is s3")
+		}
+		if cfg.Upload.S3.Bucket == "" {
+			verr.Add("upload.s3.bucket", "cannot be empty when storage is s3")
+		}
+		if cfg.Upload.S3.AccessKey == "" || cfg.Upload.S3.SecretKey == "" {
+			verr.Add("upload.s3", "access_key and secret_key are required when storage is s3")
+		}
+	default:
+		verr.Add("upload.storage", "must be one of [local s3], got %q", cfg.Upload.Storage)
 	}
 
-	// 验证JWT过期时间
-	expirationHours := utils.GetEnvIntWithDefault("JWT_EXPIRATION_HOURS", 24)
-	if expirationHours <= 0 {
-		log.Fatal("JWT_EXPIRATION_HOURS must be greater than 0")
+	// 验证站点和订阅源配置
+	if cfg.Site.BaseURL != "" {
+		if u, err := url.Parse(cfg.Site.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			verr.Add("site.base_url", "must be an absolute http(s) URL, got %q", cfg.Site.BaseURL)
+		}
+	}
+	if cfg.Feed.ItemCount < 1 || cfg.Feed.ItemCount > 100 {
+		verr.Add("feed.item_count", "must be between 1 and 100")
+	}
+	if cfg.Feed.SummaryLength < 1 {
+		verr.Add("feed.summary_length", "must be greater than 0")
 	}
 
-	// 验证服务器端口
-	port := utils.GetEnvWithDefault("SERVER_PORT", "8080")
-	if port == "" {
-		log.Fatal("SERVER_PORT cannot be empty")
+	// 验证回收站配置
+	if cfg.Trash.RetentionDays < 1 {
+		verr.Add("trash.retention_days", "must be at least 1")
 	}
 
-	// 验证Gin模式
-	mode := utils.GetEnvWithDefault("GIN_MODE", "debug")
-	validModes := []string{"debug", "release", "test"}
-	isValidMode := false
-	for _, validMode := range validModes {
-		if mode == validMode {
-			isValidMode = true
-			break
+	// 验证缓存配置
+	switch cfg.Cache.Driver {
+	case "memory":
+		if cfg.Cache.MaxEntries < 1 {
+			verr.Add("cache.max_entries", "must be greater than 0")
+		}
+	case "redis":
+		if cfg.Cache.Redis.Addr == "" {
+			verr.Add("cache.redis.addr", "cannot be empty when driver is redis")
+		}
+		if cfg.Cache.Redis.DB < 0 {
+			verr.Add("cache.redis.db", "cannot be negative")
+		}
+		if cfg.Cache.Redis.PoolSize < 1 {
+			verr.Add("cache.redis.pool_size", "must be greater than 0")
 		}
+	case "none":
+	default:
+		verr.Add("cache.driver", "must be one of [memory redis none], got %q", cfg.Cache.Driver)
 	}
-	if !isValidMode {
-		log.Fatalf("GIN_MODE must be one of: %s", strings.Join(validModes, ", "))
+	if cfg.Cache.TTLSeconds < 1 {
+		verr.Add("cache.ttl_seconds", "must be greater than 0")
 	}
 
-	// 验证数据库配置
-	dbHost := utils.GetEnvWithDefault("DB_HOST", "localhost")
-	dbPort := utils.GetEnvWithDefault("DB_PORT", "3306")
-	dbUser := utils.GetEnvWithDefault("DB_USER", "root")
-	dbName := utils.GetEnvWithDefault("DB_NAME", "blog")
+	// 验证浏览量统计和热门文章配置
+	if cfg.Views.DedupWindowMinutes < 0 {
+		verr.Add("views.dedup_window_minutes", "cannot be negative")
+	}
+	if cfg.Views.FlushIntervalSeconds < 1 {
+		verr.Add("views.flush_interval_seconds", "must be greater than 0")
+	}
+	if cfg.Views.MaxVisitors < 1 {
+		verr.Add("views.max_visitors", "must be greater than 0")
+	}
+	if cfg.Trending.WindowDays < 1 || cfg.Trending.WindowDays > 90 {
+		verr.Add("trending.window_days", "must be between 1 and 90")
+	}
+	if cfg.Trending.HalfLifeHours <= 0 {
+		verr.Add("trending.half_life_hours", "must be greater than 0")
+	}
+	if cfg.Trending.CommentWeight < 0 {
+		verr.Add("trending.comment_weight", "cannot be negative")
+	}
+
+	// 验证批量操作和幂等键配置
+	if cfg.Batch.MaxOperations < 1 || cfg.Batch.MaxOperations > 1000 {
+		verr.Add("batch.max_operations", "must be between 1 and 1000")
+	}
+	if cfg.Idempotency.TTLHours < 1 {
+		verr.Add("idempotency.ttl_hours", "must be greater than 0")
+	}
+	if cfg.Archive.MaxImportSizeMB < 1 {
+		verr.Add("archive.max_import_size_mb", "must be greater than 0")
+	}
+	if cfg.Archive.MaxUncompressedSizeMB < cfg.Archive.MaxImportSizeMB {
+		verr.Add("archive.max_uncompressed_size_mb", "must be at least archive.max_import_size_mb")
+	}
+	if cfg.Archive.MaxEntries < 1 {
+		verr.Add("archive.max_entries", "must be greater than 0")
+	}
+
+	// 验证个人数据导出配置，导出文件包含个人数据，不能放在公开访问的上传目录中
+	if cfg.DataExport.Dir == "" {
+		verr.Add("data_export.dir", "cannot be empty")
+	} else if cfg.Upload.Storage == "local" && isSubdir(cfg.Upload.LocalDir, cfg.DataExport.Dir) {
+		verr.Add("data_export.dir", "cannot be inside the public upload directory")
+	}
+	if cfg.DataExport.RetentionHours < 1 {
+		verr.Add("data_export.retention_hours", "must be greater than 0")
+	}
+	if cfg.DataExport.LinkTTLMinutes < 1 || cfg.DataExport.LinkTTLMinutes > 24*60 {
+		verr.Add("data_export.link_ttl_minutes", "must be between 1 and 1440")
+	}
 
-	if dbHost == "" || dbPort == "" || dbUser == "" || dbName == "" {
-		log.Fatal("Database configuration cannot have empty values")
+	// 验证日志配置
+	if !contains(validLogLevels, cfg.Log.Level) {
+		verr.Add("log.level", "must be one of %v, got %q", validLogLevels, cfg.Log.Level)
+	}
+	if !contains(validLogFormats, cfg.Log.Format) {
+		verr.Add("log.format", "must be one of %v, got %q", validLogFormats, cfg.Log.Format)
 	}
 
-	// 验证日志级别
-	logLevel := utils.GetEnvWithDefault("LOG_LEVEL", "info")
-	validLogLevels := []string{"debug", "info", "warn", "error"}
-	isValidLogLevel := false
-	for _, validLevel := range validLogLevels {
-		if logLevel == validLevel {
-			isValidLogLevel = true
-			break
+	// 验证限流配置
+	if cfg.RateLimit.RequestsPerSecond < 0 {
+		verr.Add("rate_limit.requests_per_second", "cannot be negative")
+	}
+	if cfg.RateLimit.Burst < 0 {
+		verr.Add("rate_limit.burst", "cannot be negative")
+	}
+	if cfg.RateLimit.Enabled {
+		if cfg.RateLimit.RequestsPerSecond == 0 {
+			verr.Add("rate_limit.requests_per_second", "must be greater than 0 when rate limiting is enabled")
+		}
+		if cfg.RateLimit.Burst == 0 {
+			verr.Add("rate_limit.burst", "must be at least 1 when rate limiting is enabled")
 		}
 	}
-	if !isValidLogLevel {
-		log.Fatalf("LOG_LEVEL must be one of: %s", strings.Join(validLogLevels, ", "))
+}
+
+// validateJWTSecret 验证HS256密钥
+func validateJWTSecret(field, secret, mode string, verr *ValidationError) {
+	if secret == "" {
+		verr.Add(field, "is required")
+	} else if mode == "release" {
+		if secret == placeholderJWTSecret {
+			verr.Add(field, "must be changed from the default placeholder in release mode")
+		} else if len(secret) < minReleaseJWTSecretLength {
+			verr.Add(field, "must be at least %d characters in release mode", minReleaseJWTSecretLength)
+		}
 	}
+}
 
-	// 验证数据库连接池配置
-	maxIdleConns := utils.GetEnvIntWithDefault("DB_MAX_IDLE_CONNS", 10)
-	maxOpenConns := utils.GetEnvIntWithDefault("DB_MAX_OPEN_CONNS", 100)
-	connMaxLifetime := utils.GetEnvIntWithDefault("DB_CONN_MAX_LIFETIME", 60)
+// validateJWTKeys 验证JWT密钥列表
+func validateJWTKeys(cfg *Config, verr *ValidationError) {
+	activeFound := false
+	seen := make(map[string]bool)
+	for i, key := range cfg.JWT.Keys {
+		field := fmt.Sprintf("jwt.keys[%d]", i)
+		if key.ID == "" {
+			verr.Add(field+".id", "cannot be empty")
+		} else if seen[key.ID] {
+			verr.Add(field+".id", "duplicate key id %q", key.ID)
+		}
+		seen[key.ID] = true
 
-	if maxIdleConns <= 0 || maxOpenConns <= 0 || connMaxLifetime <= 0 {
-		log.Fatal("Database connection pool settings must be greater than 0")
+		switch key.Algorithm {
+		case utils.AlgHS256:
+			validateJWTSecret(field+".secret", key.Secret, cfg.Server.Mode, verr)
+		case utils.AlgRS256, utils.AlgEdDSA:
+			if key.PrivateKeyFile == "" && key.PublicKeyFile == "" {
+				verr.Add(field, "requires private_key_file or public_key_file")
+			}
+		default:
+			verr.Add(field+".algorithm", "must be one of [%s %s %s], got %q", utils.AlgHS256, utils.AlgRS256, utils.AlgEdDSA, key.Algorithm)
+		}
+
+		if key.ID == cfg.JWT.ActiveKeyID {
+			activeFound = true
+			if key.Algorithm != utils.AlgHS256 && key.PrivateKeyFile == "" {
+				verr.Add("jwt.active_key_id", "key %q has no private_key_file and cannot sign tokens", key.ID)
+			}
+		}
 	}
+	if !activeFound {
+		verr.Add("jwt.active_key_id", "must reference one of jwt.keys, got %q", cfg.JWT.ActiveKeyID)
+	}
+}
 
-	if maxIdleConns > maxOpenConns {
-		log.Fatal("DB_MAX_IDLE_CONNS cannot be greater than DB_MAX_OPEN_CONNS")
+// contains 判断字符串是否在列表中
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
 	}
+	return false
+}
 
-	log.Println("Configuration validation passed!")
+// Masked 返回隐藏了敏感信息的配置副本
+func Masked(cfg *Config) *Config {
+	masked := *cfg
+	masked.Database.Password = maskSecret(cfg.Database.Password)
+	masked.JWT.Secret = maskSecret(cfg.JWT.Secret)
+	masked.JWT.Keys = make([]JWTKeyConfig, len(cfg.JWT.Keys))
+	for i, key := range cfg.JWT.Keys {
+		key.Secret = maskSecret(key.Secret)
+		masked.JWT.Keys[i] = key
+	}
+	masked.Upload.S3.SecretKey = maskSecret(cfg.Upload.S3.SecretKey)
+	masked.Cache.Redis.Password = maskSecret(cfg.Cache.Redis.Password)
+	masked.OIDC.Providers = make([]OIDCProviderConfig, len(cfg.OIDC.Providers))
+	for i, provider := range cfg.OIDC.Providers {
+		provider.ClientSecret = maskSecret(provider.ClientSecret)
+		masked.OIDC.Providers[i] = provider
+	}
+	return &masked
 }
 
 // PrintConfig 打印当前配置（隐藏敏感信息）
@@ -108,15 +358,34 @@ func PrintConfig(cfg *Config) {
 	log.Printf("  Database Max Open Conns: %d", cfg.Database.MaxOpenConns)
 	log.Printf("  Database Conn Max Lifetime: %d minutes", cfg.Database.ConnMaxLifetime)
 	log.Printf("  JWT Expiration Hours: %d", cfg.JWT.ExpirationHours)
-	log.Printf("  JWT Secret: %s", maskSecret(cfg.JWT.Secret))
+	if len(cfg.JWT.Keys) == 0 {
+		log.Printf("  JWT Secret: %s", maskSecret(cfg.JWT.Secret))
+	} else {
+		log.Printf("  JWT Keys: %d (active: %s)", len(cfg.JWT.Keys), cfg.JWT.ActiveKeyID)
+	}
+	log.Printf("  JWT Issuer: %s", cfg.JWT.Issuer)
+	log.Printf("  JWT Audience: %s", cfg.JWT.Audience)
+	log.Printf("  OIDC Providers: %d", len(cfg.OIDC.Providers))
+	log.Printf("  Upload Storage: %s (max %d MB)", cfg.Upload.Storage, cfg.Upload.MaxSizeMB)
+	log.Printf("  Cache: %s (ttl %ds)", cfg.Cache.Driver, cfg.Cache.TTLSeconds)
 	log.Printf("  Log Level: %s", cfg.Log.Level)
 	log.Printf("  Log Format: %s", cfg.Log.Format)
+	log.Printf("  Rate Limit: enabled=%t rps=%.2f burst=%d", cfg.RateLimit.Enabled, cfg.RateLimit.RequestsPerSecond, cfg.RateLimit.Burst)
 }
 
 // maskSecret 隐藏敏感信息
 func maskSecret(secret string) string {
+	if secret == "" {
+		return ""
+	}
 	if len(secret) <= 8 {
 		return "***"
 	}
 	return secret[:4] + "..." + secret[len(secret)-4:]
 }
+
+// isSubdir 判断dir是否为parent本身或位于parent之下
+func isSubdir(parent, dir string) bool {
+	rel, err := filepath.Rel(filepath.Clean(parent), filepath.Clean(dir))
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
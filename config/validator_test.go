@@ -0,0 +1,185 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/test/blog/utils"
+)
+
+func TestValidate(t *testing.T) {
+	const releaseSecret = "release-secret-release-secret-release"
+
+	tests := []struct {
+		name   string
+		mutate func(cfg *Config)
+		fields []string // 按报告顺序列出的出错配置项，为空表示有效
+	}{
+		{"defaults", func(cfg *Config) {}, nil},
+		{"invalid port", func(cfg *Config) { cfg.Server.Port = "http" }, []string{"server.port"}},
+		{"empty port", func(cfg *Config) { cfg.Server.Port = "" }, []string{"server.port"}},
+		{"invalid mode", func(cfg *Config) { cfg.Server.Mode = "prod" }, []string{"server.mode"}},
+		{"trusted proxies", func(cfg *Config) {
+			cfg.Server.TrustedProxies = []string{"10.0.0.1", "10.0.0.0/8", "::1", "proxy.local"}
+		}, []string{"server.trusted_proxies[3]"}},
+		{
+			name: "database",
+			mutate: func(cfg *Config) {
+				cfg.Database.Host = ""
+				cfg.Database.MaxIdleConns = 200
+			},
+			fields: []string{"database.host", "database.max_idle_conns"},
+		},
+		{"placeholder secret in release", func(cfg *Config) { cfg.Server.Mode = "release" }, []string{"jwt.secret"}},
+		{"short secret in release", func(cfg *Config) { cfg.Server.Mode = "release"; cfg.JWT.Secret = "short" }, []string{"jwt.secret"}},
+		{"release secret", func(cfg *Config) { cfg.Server.Mode = "release"; cfg.JWT.Secret = releaseSecret }, nil},
+		{
+			// 配置了密钥列表时默认占位符被忽略
+			name: "jwt keys",
+			mutate: func(cfg *Config) {
+				cfg.Server.Mode = "release"
+				cfg.JWT.ActiveKeyID = "k1"
+				cfg.JWT.Keys = []JWTKeyConfig{{ID: "k1", Algorithm: utils.AlgHS256, Secret: releaseSecret}}
+			},
+		},
+		{
+			name: "legacy tokens without secret",
+			mutate: func(cfg *Config) {
+				cfg.JWT.LegacyUntil = time.Now().Add(time.Hour)
+				cfg.JWT.ActiveKeyID = "k1"
+				cfg.JWT.Keys = []JWTKeyConfig{{ID: "k1", Algorithm: utils.AlgHS256, Secret: "secret"}}
+			},
+			fields: []string{"jwt.legacy_until"},
+		},
+		{
+			name: "invalid jwt keys",
+			mutate: func(cfg *Config) {
+				cfg.JWT.ActiveKeyID = "k2"
+				cfg.JWT.Keys = []JWTKeyConfig{
+					{ID: "k1", Algorithm: utils.AlgHS256, Secret: "secret"},
+					{ID: "k1", Algorithm: "none"},
+					{ID: "k2", Algorithm: utils.AlgRS256, PublicKeyFile: "k2.pub"},
+				}
+			},
+			fields: []string{"jwt.keys[1].id", "jwt.keys[1].algorithm", "jwt.active_key_id"},
+		},
+		{
+			name: "oidc providers",
+			mutate: func(cfg *Config) {
+				cfg.OIDC.Providers = []OIDCProviderConfig{
+					{Name: "google", Issuer: "https://accounts.google.com", ClientID: "id", RedirectURL: "https://blog.example.com/cb"},
+					{Name: "google"},
+				}
+			},
+			fields: []string{"oidc.providers[1].name", "oidc.providers[1].issuer", "oidc.providers[1].client_id", "oidc.providers[1].redirect_url"},
+		},
+		{"s3 without credentials", func(cfg *Config) { cfg.Upload.Storage = "s3" }, []string{"upload.s3.endpoint", "upload.s3.bucket", "upload.s3"}},
+		{"unknown storage", func(cfg *Config) { cfg.Upload.Storage = "ftp" }, []string{"upload.storage"}},
+		{"relative base url", func(cfg *Config) { cfg.Site.BaseURL = "blog.example.com" }, []string{"site.base_url"}},
+		{"no view dedup entries", func(cfg *Config) { cfg.Views.MaxVisitors = 0 }, []string{"views.max_visitors"}},
+		{"redis without addr", func(cfg *Config) { cfg.Cache.Driver = "redis"; cfg.Cache.Redis.Addr = "" }, []string{"cache.redis.addr"}},
+		{"unknown cache driver", func(cfg *Config) { cfg.Cache.Driver = "memcached" }, []string{"cache.driver"}},
+		{"trending window", func(cfg *Config) { cfg.Trending.WindowDays = 91 }, []string{"trending.window_days"}},
+		{"batch size", func(cfg *Config) { cfg.Batch.MaxOperations = 1001 }, []string{"batch.max_operations"}},
+		{"archive import size", func(cfg *Config) { cfg.Archive.MaxImportSizeMB = 0 }, []string{"archive.max_import_size_mb"}},
+		{
+			name: "archive limits",
+			mutate: func(cfg *Config) {
+				cfg.Archive.MaxUncompressedSizeMB = cfg.Archive.MaxImportSizeMB - 1
+				cfg.Archive.MaxEntries = 0
+			},
+			fields: []string{"archive.max_uncompressed_size_mb", "archive.max_entries"},
+		},
+		{"export dir inside uploads", func(cfg *Config) { cfg.DataExport.Dir = "uploads/exports" }, []string{"data_export.dir"}},
+		{"export dir next to uploads", func(cfg *Config) { cfg.DataExport.Dir = "uploads-private" }, nil},
+		{"export dir with s3 uploads", func(cfg *Config) {
+			cfg.Upload.Storage = "s3"
+			cfg.Upload.S3 = S3Config{Endpoint: "https://s3.example.com", Bucket: "b", AccessKey: "a", SecretKey: "s"}
+			cfg.DataExport.Dir = "uploads"
+		}, nil},
+		{"rate limit enabled without limits", func(cfg *Config) {
+			cfg.RateLimit = RateLimitConfig{Enabled: true}
+		}, []string{"rate_limit.requests_per_second", "rate_limit.burst"}},
+		{"log", func(cfg *Config) { cfg.Log.Level = "trace"; cfg.Log.Format = "xml" }, []string{"log.level", "log.format"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.mutate(cfg)
+			err := Validate(cfg)
+			if len(tt.fields) == 0 {
+				if err != nil {
+					t.Fatalf("Validate: %v", err)
+				}
+				return
+			}
+
+			var verr *ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("Validate = %v, want *ValidationError", err)
+			}
+			var got []string
+			for _, fe := range verr.Errors {
+				got = append(got, fe.Field)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.fields, ",") {
+				t.Errorf("fields = %v, want %v", got, tt.fields)
+			}
+			// 报告包含所有问题
+			for _, field := range tt.fields {
+				if !strings.Contains(err.Error(), field+": ") {
+					t.Errorf("error report %q does not mention %s", err.Error(), field)
+				}
+			}
+		})
+	}
+}
+
+func TestValidationErrorReport(t *testing.T) {
+	verr := &ValidationError{}
+	if verr.Err() != nil {
+		t.Fatal("empty ValidationError should not be an error")
+	}
+	verr.Add("server.port", "must be a number, got %q", "x")
+	verr.Add("log.level", "cannot be empty")
+
+	want := "configuration has 2 problem(s):\n  - server.port: must be a number, got \"x\"\n  - log.level: cannot be empty"
+	if err := verr.Err(); err == nil || err.Error() != want {
+		t.Errorf("report = %q, want %q", err, want)
+	}
+}
+
+func TestMasked(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Database.Password = "database-password"
+	cfg.JWT.Secret = "short"
+	cfg.JWT.Keys = []JWTKeyConfig{{ID: "k1", Algorithm: utils.AlgHS256, Secret: "jwt-key-secret-value"}}
+	cfg.Upload.S3.SecretKey = "s3-secret-key"
+	cfg.OIDC.Providers = []OIDCProviderConfig{{Name: "google", ClientSecret: "client-secret"}}
+
+	masked := Masked(cfg)
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{"database password", masked.Database.Password, "data...word"},
+		{"short secret", masked.JWT.Secret, "***"},
+		{"jwt key", masked.JWT.Keys[0].Secret, "jwt-...alue"},
+		{"s3 secret", masked.Upload.S3.SecretKey, "s3-s...-key"},
+		{"oidc client secret", masked.OIDC.Providers[0].ClientSecret, "clie...cret"},
+		{"empty secret", masked.Cache.Redis.Password, ""},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %q, want %q", tt.name, tt.got, tt.want)
+		}
+	}
+
+	// 原配置不受影响
+	if cfg.JWT.Keys[0].Secret != "jwt-key-secret-value" || cfg.OIDC.Providers[0].ClientSecret != "client-secret" {
+		t.Error("Masked modified the original config")
+	}
+}
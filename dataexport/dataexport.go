@@ -0,0 +1,447 @@
+// Package dataexport 生成用户个人数据的导出文件
+// 导出文件是包含多个JSON文件的zip压缩包，manifest.json说明每个文件的内容以及没有包含的数据
+package dataexport
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/storage"
+	"gorm.io/gorm"
+)
+
+// Version 导出文件的格式版本，字段发生不兼容变化时增加
+const Version = 1
+
+// Manifest 导出文件的说明，对应 manifest.json
+type Manifest struct {
+	Version      int               `json:"version"`
+	UserID       uint              `json:"user_id"`
+	GeneratedAt  time.Time         `json:"generated_at"`
+	Files        map[string]string `json:"files"`         // 文件名 -> 内容说明
+	NotCollected map[string]string `json:"not_collected"` // 本站不保存、因此没有导出的数据
+}
+
+// files 导出文件中的JSON文件及其说明
+var files = map[string]string{
+	"profile.json":          "账号信息",
+	"posts.json":            "发表的文章，包括回收站中的文章和文章使用过的slug",
+	"revisions.json":        "编辑过的文章修订版本",
+	"comments.json":         "发表的评论，包括已删除的评论",
+	"attachments.json":      "上传的文件的信息，文件本身可通过url下载",
+	"sessions.json":         "个人访问令牌、绑定的外部身份和两步验证恢复码，不包含令牌和恢复码的明文或哈希",
+	"idempotency_keys.json": "尚未过期的幂等键，保存的响应内容与上面的数据重复，不再导出",
+	"data_exports.json":     "个人数据导出的申请记录",
+}
+
+// notCollected 本站不保存的数据
+var notCollected = map[string]string{
+	"reactions":      "本站没有点赞等互动功能，不保存此类数据",
+	"audit_entries":  "本站不保存操作审计记录，应用日志只在服务器上短期保留，不属于导出范围",
+	"login_sessions": "登录令牌（JWT）是无状态的，服务器不保存登录会话",
+	"post_views":     "浏览量只按文章和日期汇总，不记录访客",
+}
+
+// Profile 账号信息
+type Profile struct {
+	ID          uint      `json:"id"`
+	Username    string    `json:"username"`
+	Email       string    `json:"email"`
+	Role        string    `json:"role"`
+	TOTPEnabled bool      `json:"totp_enabled"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// Post 文章
+type Post struct {
+	ID            uint       `json:"id"`
+	Title         string     `json:"title"`
+	Slug          string     `json:"slug"`
+	PreviousSlugs []string   `json:"previous_slugs"`
+	Content       string     `json:"content"`
+	Version       int        `json:"version"`
+	ViewCount     int64      `json:"view_count"`
+	CommentCount  int        `json:"comment_count"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+	DeletedAt     *time.Time `json:"deleted_at"` // 在回收站中的文章不为空
+}
+
+// Revision 文章修订版本
+type Revision struct {
+	ID            uint      `json:"id"`
+	PostID        uint      `json:"post_id"`
+	Number        int       `json:"number"`
+	Title         string    `json:"title"`
+	Content       string    `json:"content"`
+	RestoredFrom  *int      `json:"restored_from,omitempty"`
+	ChangedFields string    `json:"changed_fields"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// Comment 评论
+type Comment struct {
+	ID        uint       `json:"id"`
+	PostID    uint       `json:"post_id"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+// Attachment 上传的文件
+type Attachment struct {
+	ID           uint       `json:"id"`
+	PostID       *uint      `json:"post_id"`
+	OriginalName string     `json:"original_name"`
+	ContentType  string     `json:"content_type"`
+	Size         int64      `json:"size"`
+	Width        int        `json:"width"`
+	Height       int        `json:"height"`
+	URL          string     `json:"url"`
+	CreatedAt    time.Time  `json:"created_at"`
+	DeletedAt    *time.Time `json:"deleted_at"`
+}
+
+// Sessions 可以代表用户访问本站的凭据
+type Sessions struct {
+	AccessTokens  []AccessToken  `json:"access_tokens"`
+	Identities    []Identity     `json:"identities"`
+	RecoveryCodes []RecoveryCode `json:"recovery_codes"`
+}
+
+// AccessToken 个人访问令牌，已撤销的令牌RevokedAt不为空
+type AccessToken struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	RevokedAt  *time.Time `json:"revoked_at"`
+}
+
+// Identity 绑定的外部身份
+type Identity struct {
+	ID        uint      `json:"id"`
+	Provider  string    `json:"provider"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// RecoveryCode 两步验证恢复码
+type RecoveryCode struct {
+	ID        uint       `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at"`
+}
+
+// IdempotencyKey 幂等键
+type IdempotencyKey struct {
+	Scope      string    `json:"scope"`
+	Key        string    `json:"key"`
+	StatusCode int       `json:"status_code"`
+	CreatedAt  time.Time `json:"created_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// ExportRequest 个人数据导出的申请记录
+type ExportRequest struct {
+	ID          uint       `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+}
+
+// Build 将用户的个人数据写入w，w中是一个zip压缩包
+// 所有数据在同一个事务中读取，保证导出内容一致
+func Build(ctx context.Context, userID uint, w io.Writer) error {
+	now := time.Now()
+	zw := zip.NewWriter(w)
+
+	err := config.GetDB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.First(&user, userID).Error; err != nil {
+			return err
+		}
+		entries := []struct {
+			name string
+			load func(tx *gorm.DB, userID uint) (any, error)
+		}{
+			{"profile.json", func(*gorm.DB, uint) (any, error) { return profile(user), nil }},
+			{"posts.json", loadPosts},
+			{"revisions.json", loadRevisions},
+			{"comments.json", loadComments},
+			{"attachments.json", loadAttachments},
+			{"sessions.json", loadSessions},
+			{"idempotency_keys.json", loadIdempotencyKeys},
+			{"data_exports.json", loadExportRequests},
+		}
+		for _, entry := range entries {
+			data, err := entry.load(tx, userID)
+			if err != nil {
+				return err
+			}
+			if err := writeJSON(zw, entry.name, now, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	manifest := Manifest{
+		Version:      Version,
+		UserID:       userID,
+		GeneratedAt:  now.UTC(),
+		Files:        files,
+		NotCollected: notCollected,
+	}
+	if err := writeJSON(zw, "manifest.json", now, manifest); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// writeJSON 将数据以缩进的JSON格式写入压缩包
+func writeJSON(zw *zip.Writer, name string, modTime time.Time, data any) error {
+	f, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: modTime})
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(data)
+}
+
+// profile 账号信息，不包含密码哈希和两步验证密钥
+func profile(user models.User) Profile {
+	return Profile{
+		ID:          user.ID,
+		Username:    user.Username,
+		Email:       user.Email,
+		Role:        user.Role,
+		TOTPEnabled: user.TOTPEnabled,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
+
+// deletedAt 将软删除时间转换为指针，未删除时为nil
+func deletedAt(value gorm.DeletedAt) *time.Time {
+	if !value.Valid {
+		return nil
+	}
+	return &value.Time
+}
+
+func loadPosts(tx *gorm.DB, userID uint) (any, error) {
+	var posts []models.Post
+	if err := tx.Unscoped().Where("user_id = ?", userID).Order("id").Find(&posts).Error; err != nil {
+		return nil, err
+	}
+	ids := make([]uint, 0, len(posts))
+	for _, post := range posts {
+		ids = append(ids, post.ID)
+	}
+	var slugs []models.PostSlug
+	if len(ids) > 0 {
+		if err := tx.Where("post_id IN ?", ids).Order("id").Find(&slugs).Error; err != nil {
+			return nil, err
+		}
+	}
+	previous := make(map[uint][]string)
+	for _, slug := range slugs {
+		previous[slug.PostID] = append(previous[slug.PostID], slug.Slug)
+	}
+
+	result := make([]Post, 0, len(posts))
+	for _, post := range posts {
+		slugs := previous[post.ID]
+		if slugs == nil {
+			slugs = []string{}
+		}
+		result = append(result, Post{
+			ID:            post.ID,
+			Title:         post.Title,
+			Slug:          post.Slug,
+			PreviousSlugs: slugs,
+			Content:       post.Content,
+			Version:       post.Version,
+			ViewCount:     post.ViewCount,
+			CommentCount:  post.CommentCount,
+			CreatedAt:     post.CreatedAt,
+			UpdatedAt:     post.UpdatedAt,
+			DeletedAt:     deletedAt(post.DeletedAt),
+		})
+	}
+	return result, nil
+}
+
+func loadRevisions(tx *gorm.DB, userID uint) (any, error) {
+	var revisions []models.PostRevision
+	if err := tx.Where("editor_id = ?", userID).Order("post_id, number").Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+	result := make([]Revision, 0, len(revisions))
+	for _, revision := range revisions {
+		result = append(result, Revision{
+			ID:            revision.ID,
+			PostID:        revision.PostID,
+			Number:        revision.Number,
+			Title:         revision.Title,
+			Content:       revision.Content,
+			RestoredFrom:  revision.RestoredFrom,
+			ChangedFields: revision.ChangedFields,
+			CreatedAt:     revision.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+func loadComments(tx *gorm.DB, userID uint) (any, error) {
+	var comments []models.Comment
+	if err := tx.Unscoped().Where("user_id = ?", userID).Order("id").Find(&comments).Error; err != nil {
+		return nil, err
+	}
+	result := make([]Comment, 0, len(comments))
+	for _, comment := range comments {
+		result = append(result, Comment{
+			ID:        comment.ID,
+			PostID:    comment.PostID,
+			Content:   comment.Content,
+			CreatedAt: comment.CreatedAt,
+			UpdatedAt: comment.UpdatedAt,
+			DeletedAt: deletedAt(comment.DeletedAt),
+		})
+	}
+	return result, nil
+}
+
+func loadAttachments(tx *gorm.DB, userID uint) (any, error) {
+	var attachments []models.Attachment
+	if err := tx.Unscoped().Where("user_id = ?", userID).Order("id").Find(&attachments).Error; err != nil {
+		return nil, err
+	}
+	store := storage.Get()
+	result := make([]Attachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		item := Attachment{
+			ID:           attachment.ID,
+			PostID:       attachment.PostID,
+			OriginalName: attachment.OriginalName,
+			ContentType:  attachment.ContentType,
+			Size:         attachment.Size,
+			Width:        attachment.Width,
+			Height:       attachment.Height,
+			CreatedAt:    attachment.CreatedAt,
+			DeletedAt:    deletedAt(attachment.DeletedAt),
+		}
+		if item.DeletedAt == nil {
+			item.URL = store.URL(attachment.StorageKey)
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+func loadSessions(tx *gorm.DB, userID uint) (any, error) {
+	sessions := Sessions{
+		AccessTokens:  []AccessToken{},
+		Identities:    []Identity{},
+		RecoveryCodes: []RecoveryCode{},
+	}
+
+	var tokens []models.PersonalAccessToken
+	if err := tx.Unscoped().Where("user_id = ?", userID).Order("id").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	for _, token := range tokens {
+		sessions.AccessTokens = append(sessions.AccessTokens, AccessToken{
+			ID:         token.ID,
+			Name:       token.Name,
+			Prefix:     token.Prefix,
+			Scopes:     strings.Split(token.Scopes, ","),
+			LastUsedAt: token.LastUsedAt,
+			ExpiresAt:  token.ExpiresAt,
+			CreatedAt:  token.CreatedAt,
+			RevokedAt:  deletedAt(token.DeletedAt),
+		})
+	}
+
+	var identities []models.UserIdentity
+	if err := tx.Where("user_id = ?", userID).Order("id").Find(&identities).Error; err != nil {
+		return nil, err
+	}
+	for _, identity := range identities {
+		sessions.Identities = append(sessions.Identities, Identity{
+			ID:        identity.ID,
+			Provider:  identity.Provider,
+			Subject:   identity.Subject,
+			Email:     identity.Email,
+			CreatedAt: identity.CreatedAt,
+		})
+	}
+
+	var codes []models.RecoveryCode
+	if err := tx.Where("user_id = ?", userID).Order("id").Find(&codes).Error; err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		sessions.RecoveryCodes = append(sessions.RecoveryCodes, RecoveryCode{
+			ID:        code.ID,
+			CreatedAt: code.CreatedAt,
+			UsedAt:    code.UsedAt,
+		})
+	}
+	return sessions, nil
+}
+
+func loadIdempotencyKeys(tx *gorm.DB, userID uint) (any, error) {
+	var keys []models.IdempotencyKey
+	err := tx.Select("id", "scope", "idempotency_key", "status_code", "created_at", "expires_at").
+		Where("user_id = ? AND expires_at > ?", userID, time.Now()).Order("id").
+		Find(&keys).Error
+	if err != nil {
+		return nil, err
+	}
+	result := make([]IdempotencyKey, 0, len(keys))
+	for _, key := range keys {
+		result = append(result, IdempotencyKey{
+			Scope:      key.Scope,
+			Key:        key.Key,
+			StatusCode: key.StatusCode,
+			CreatedAt:  key.CreatedAt,
+			ExpiresAt:  key.ExpiresAt,
+		})
+	}
+	return result, nil
+}
+
+func loadExportRequests(tx *gorm.DB, userID uint) (any, error) {
+	var exports []models.DataExport
+	if err := tx.Where("user_id = ?", userID).Order("id").Find(&exports).Error; err != nil {
+		return nil, err
+	}
+	result := make([]ExportRequest, 0, len(exports))
+	for _, export := range exports {
+		result = append(result, ExportRequest{
+			ID:          export.ID,
+			Status:      export.Status,
+			CreatedAt:   export.CreatedAt,
+			CompletedAt: export.CompletedAt,
+		})
+	}
+	return result, nil
+}
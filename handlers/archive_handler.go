@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/archive"
+	"github.com/test/blog/config"
+	"github.com/test/blog/utils"
+	"go.uber.org/zap"
+)
+
+// AdminExportPosts 导出所有文章和评论为Markdown压缩包（仅管理员）
+// 压缩包边生成边发送，开始发送后出错只能记录日志，客户端会收到不完整的文件
+func AdminExportPosts(c *gin.Context) {
+	format := c.DefaultQuery("format", archive.FormatZip)
+	if !archive.ValidFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid query parameters",
+			"errors":  gin.H{"format": "must be one of zip, tar"},
+		})
+		return
+	}
+
+	filename := "blog-export-" + time.Now().Format("20060102")
+	contentType := "application/zip"
+	if format == archive.FormatTar {
+		filename += ".tar.gz"
+		contentType = "application/gzip"
+	} else {
+		filename += ".zip"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	n, err := archive.Export(c.Request.Context(), c.Writer, format)
+	if err != nil {
+		utils.LogError("export posts error", err)
+		return
+	}
+	utils.LogInfo("posts exported", zap.Int("count", n), utils.WithUserID(c.GetUint("user_id")))
+}
+
+// AdminImportPosts 导入Markdown压缩包或WordPress WXR文件中的文章和评论（仅管理员）
+// dry_run=true时只返回导入报告，不保存；default_author指定作者在本站不存在时使用的用户；
+// match_email=true时用户名不匹配的作者再按文件中未经验证的邮箱匹配
+func AdminImportPosts(c *gin.Context) {
+	opts := archive.Options{DefaultAuthor: c.Query("default_author")}
+	fieldErrors := gin.H{}
+	for name, target := range map[string]*bool{"dry_run": &opts.DryRun, "match_email": &opts.MatchEmail} {
+		if value := c.Query(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				fieldErrors[name] = "must be true or false"
+			}
+			*target = parsed
+		}
+	}
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid query parameters",
+			"errors":  fieldErrors,
+		})
+		return
+	}
+
+	maxSize := int64(config.Get().Archive.MaxImportSizeMB) << 20
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+	file, _, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"message": "File is too large",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Missing file field",
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		utils.LogError("import read error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Failed to read file",
+		})
+		return
+	}
+	if int64(len(data)) > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"success": false,
+			"message": "File is too large",
+		})
+		return
+	}
+
+	report, err := archive.Import(c.Request.Context(), data, opts)
+	if errors.Is(err, archive.ErrInvalidArchive) || errors.Is(err, archive.ErrUnknownDefaultAuthor) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("import posts error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to import posts",
+		})
+		return
+	}
+
+	message := "Import dry run completed"
+	if !opts.DryRun {
+		message = "Posts imported successfully"
+		if report.Posts > 0 {
+			invalidatePostCache(c.Request.Context())
+		}
+		utils.LogInfo("posts imported", zap.Int("posts", report.Posts), zap.Int("comments", report.Comments), utils.WithUserID(c.GetUint("user_id")))
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": message,
+		"data":    report,
+	})
+}
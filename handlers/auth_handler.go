@@ -1,12 +1,24 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/test/blog/config"
 	"github.com/test/blog/models"
 	"github.com/test/blog/utils"
+	"gorm.io/gorm"
+)
+
+// mfaTokenExpiration 两步验证挑战令牌的有效期
+const mfaTokenExpiration = 5 * time.Minute
+
+// 注册时用户名或邮箱已被使用
+var (
+	errUsernameTaken = errors.New("username already exists")
+	errEmailTaken    = errors.New("email already exists")
 )
 
 // Register 用户注册
@@ -21,25 +33,6 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// 检查用户名是否已存在
-	var existingUser models.User
-	if err := config.GetDB().Where("username = ?", req.Username).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Username already exists",
-		})
-		return
-	}
-
-	// 检查邮箱是否已存在
-	if err := config.GetDB().Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Email already exists",
-		})
-		return
-	}
-
 	// 加密密码
 	hashedPassword, err := utils.HashPassword(req.Password)
 	if err != nil {
@@ -51,46 +44,57 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// 创建用户
 	user := models.User{
 		Username: req.Username,
 		Password: hashedPassword,
 		Email:    req.Email,
+		Role:     models.RoleUser,
 	}
 
-	if createErr := config.GetDB().Create(&user).Error; createErr != nil {
-		utils.LogError("user creation error", createErr)
-		c.JSON(http.StatusInternalServerError, gin.H{
+	// 检查用户名和邮箱是否已存在后创建用户，并发注册时由唯一索引兜底
+	err = runTx(c, func(tx *gorm.DB) error {
+		var count int64
+		if err := tx.Model(&models.User{}).Unscoped().Where("username = ?", req.Username).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errUsernameTaken
+		}
+		if err := tx.Model(&models.User{}).Unscoped().Where("email = ?", req.Email).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return errEmailTaken
+		}
+		return tx.Create(&user).Error
+	})
+	if errors.Is(err, errUsernameTaken) {
+		c.JSON(http.StatusConflict, gin.H{
 			"success": false,
-			"message": "Failed to create user",
+			"message": "Username already exists",
 		})
 		return
 	}
-
-	// 生成JWT token
-	cfg := config.LoadConfig()
-	token, err := utils.GenerateToken(user.ID, user.Username, cfg.JWT.Secret)
+	if errors.Is(err, errEmailTaken) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "Email already exists",
+		})
+		return
+	}
+	if respondConflict(c, err) {
+		return
+	}
 	if err != nil {
-		utils.LogError("token generation error", err)
+		utils.LogError("user creation error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
-			"message": "Failed to generate token",
+			"message": "Failed to create user",
 		})
 		return
 	}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"success": true,
-		"message": "User registered successfully",
-		"data": gin.H{
-			"token": token,
-			"user": gin.H{
-				"id":       user.ID,
-				"username": user.Username,
-				"email":    user.Email,
-			},
-		},
-	})
+	respondWithToken(c, http.StatusCreated, "User registered successfully", user)
 }
 
 // Login 用户登录
@@ -124,30 +128,7 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// 生成JWT token
-	cfg := config.LoadConfig()
-	token, err := utils.GenerateToken(user.ID, user.Username, cfg.JWT.Secret)
-	if err != nil {
-		utils.LogError("token generation error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "Failed to generate token",
-		})
-		return
-	}
-
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "Login successful",
-		"data": gin.H{
-			"token": token,
-			"user": gin.H{
-				"id":       user.ID,
-				"username": user.Username,
-				"email":    user.Email,
-			},
-		},
-	})
+	respondLogin(c, "Login successful", user)
 }
 
 // GetProfile 获取用户信息
@@ -175,9 +156,66 @@ func GetProfile(c *gin.Context) {
 		"success": true,
 		"message": "Profile retrieved successfully",
 		"data": gin.H{
-			"id":       user.ID,
-			"username": user.Username,
-			"email":    user.Email,
+			"id":           user.ID,
+			"username":     user.Username,
+			"email":        user.Email,
+			"role":         user.Role,
+			"totp_enabled": user.TOTPEnabled,
+		},
+	})
+}
+
+// respondLogin 返回登录结果
+// 已启用两步验证时返回挑战令牌，验证通过后才签发JWT
+func respondLogin(c *gin.Context, message string, user models.User) {
+	if !user.TOTPEnabled {
+		respondWithToken(c, http.StatusOK, message, user)
+		return
+	}
+
+	mfaToken, err := config.JWTKeys().GenerateMFAToken(user.ID, user.Username, mfaTokenExpiration)
+	if err != nil {
+		utils.LogError("mfa token generation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Two-factor authentication required",
+		"data": gin.H{
+			"mfa_required": true,
+			"mfa_token":    mfaToken,
+		},
+	})
+}
+
+// respondWithToken 签发JWT并返回认证结果
+func respondWithToken(c *gin.Context, status int, message string, user models.User) {
+	expiration := time.Duration(config.Get().JWT.ExpirationHours) * time.Hour
+	token, err := config.JWTKeys().GenerateToken(user.ID, user.Username, user.Role, expiration)
+	if err != nil {
+		utils.LogError("token generation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"success": true,
+		"message": message,
+		"data": gin.H{
+			"token": token,
+			"user": gin.H{
+				"id":       user.ID,
+				"username": user.Username,
+				"email":    user.Email,
+			},
 		},
 	})
 }
@@ -0,0 +1,37 @@
+package handlers
+
+// 批量操作的类型
+const (
+	BatchOpCreate = "create"
+	BatchOpUpdate = "update"
+	BatchOpDelete = "delete"
+)
+
+// BatchPostRequest 批量操作文章请求
+type BatchPostRequest struct {
+	Atomic     bool                 `json:"atomic"` // 为true时所有操作在同一个事务中执行，任一操作失败则全部回滚
+	Operations []BatchPostOperation `json:"operations"`
+}
+
+// BatchPostOperation 批量请求中的一个操作
+type BatchPostOperation struct {
+	Op             string `json:"op"`
+	ID             uint   `json:"id,omitempty"` // 更新和删除的文章id
+	Title          string `json:"title,omitempty"`
+	Content        string `json:"content,omitempty"`
+	AttachmentIDs  []uint `json:"attachment_ids,omitempty"`
+	Version        *int   `json:"version,omitempty"`         // 可选，文章当前版本不同时返回412，与If-Match相同
+	IdempotencyKey string `json:"idempotency_key,omitempty"` // 可选，重试时使用相同的键不会重复执行
+}
+
+// BatchPostResult 批量请求中一个操作的结果
+type BatchPostResult struct {
+	Index    int    `json:"index"`
+	Op       string `json:"op"`
+	Status   int    `json:"status"` // 与单独调用对应接口时的HTTP状态码相同
+	ID       uint   `json:"id,omitempty"`
+	Slug     string `json:"slug,omitempty"`
+	Version  int    `json:"version,omitempty"`
+	Message  string `json:"message,omitempty"`
+	Replayed bool   `json:"replayed,omitempty"` // 幂等键已经使用过，返回的是第一次执行的结果
+}
@@ -0,0 +1,388 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"gorm.io/gorm"
+)
+
+// batchIdempotencyScope 批量操作中幂等键的作用域
+const batchIdempotencyScope = "posts:batch"
+
+// BatchPosts 批量创建、更新和删除文章
+// 每个操作与单独调用对应接口的校验和权限检查相同；非原子模式下每个操作使用独立的事务，互不影响
+func BatchPosts(c *gin.Context) {
+	var req BatchPostRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError("batch posts validation error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError("batch posts unauthorized", nil)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	if fieldErrors := validateBatchRequest(req); len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data",
+			"errors":  fieldErrors,
+		})
+		return
+	}
+
+	results := make([]BatchPostResult, len(req.Operations))
+	if req.Atomic {
+		failed := -1
+		err := runTx(c, func(tx *gorm.DB) error {
+			for i, op := range req.Operations {
+				result, err := executeBatchOperation(tx, userID.(uint), i, op)
+				if err != nil {
+					failed = i
+					return err
+				}
+				results[i] = result
+			}
+			return nil
+		})
+		if err != nil {
+			if failed < 0 {
+				utils.LogError("batch posts commit error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"message": "Failed to execute batch",
+				})
+				return
+			}
+
+			for i, op := range req.Operations {
+				results[i] = BatchPostResult{
+					Index:   i,
+					Op:      op.Op,
+					Status:  http.StatusFailedDependency,
+					Message: fmt.Sprintf("Rolled back because operation %d failed", failed),
+				}
+			}
+			results[failed] = batchFailure(failed, req.Operations[failed], err)
+			c.JSON(results[failed].Status, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("Operation %d failed, no changes were applied", failed),
+				"data": gin.H{
+					"results": results,
+				},
+			})
+			return
+		}
+	} else {
+		for i, op := range req.Operations {
+			var result BatchPostResult
+			err := runTx(c, func(tx *gorm.DB) error {
+				var err error
+				result, err = executeBatchOperation(tx, userID.(uint), i, op)
+				return err
+			})
+			if err != nil {
+				result = batchFailure(i, op, err)
+			}
+			results[i] = result
+		}
+	}
+
+	succeeded := 0
+	var changed []uint
+	for _, result := range results {
+		if result.Status < http.StatusBadRequest {
+			succeeded++
+			changed = append(changed, result.ID)
+		}
+	}
+	if len(changed) > 0 {
+		invalidatePostCache(c.Request.Context(), changed...)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": succeeded == len(results),
+		"message": fmt.Sprintf("%d of %d operations succeeded", succeeded, len(results)),
+		"data": gin.H{
+			"results": results,
+		},
+	})
+}
+
+// validateBatchRequest 检查操作数量和每个操作的参数，返回按字段路径记录的错误
+func validateBatchRequest(req BatchPostRequest) gin.H {
+	fieldErrors := gin.H{}
+	maxOperations := config.Get().Batch.MaxOperations
+	if len(req.Operations) == 0 || len(req.Operations) > maxOperations {
+		fieldErrors["operations"] = fmt.Sprintf("must contain between 1 and %d operations", maxOperations)
+	}
+
+	keys := make(map[string]int)
+	for i, op := range req.Operations {
+		field := func(name string) string {
+			return fmt.Sprintf("operations[%d].%s", i, name)
+		}
+
+		switch op.Op {
+		case BatchOpCreate, BatchOpUpdate:
+			if op.Op == BatchOpUpdate && op.ID == 0 {
+				fieldErrors[field("id")] = "is required"
+			}
+			if n := utf8.RuneCountInString(op.Title); n < 1 || n > 200 {
+				fieldErrors[field("title")] = "must be between 1 and 200 characters"
+			}
+			if op.Content == "" {
+				fieldErrors[field("content")] = "is required"
+			}
+			if len(op.AttachmentIDs) > 50 {
+				fieldErrors[field("attachment_ids")] = "must contain at most 50 ids"
+			}
+		case BatchOpDelete:
+			if op.ID == 0 {
+				fieldErrors[field("id")] = "is required"
+			}
+		default:
+			fieldErrors[field("op")] = "must be one of create, update, delete"
+		}
+
+		if op.IdempotencyKey != "" {
+			if len(op.IdempotencyKey) > 255 {
+				fieldErrors[field("idempotency_key")] = "must be at most 255 characters"
+			}
+			if j, ok := keys[op.IdempotencyKey]; ok {
+				fieldErrors[field("idempotency_key")] = fmt.Sprintf("duplicates operations[%d]", j)
+			}
+			keys[op.IdempotencyKey] = i
+		}
+	}
+	return fieldErrors
+}
+
+// executeBatchOperation 在事务中执行一个操作
+// 带幂等键的操作已经成功执行过时直接返回第一次的结果，执行成功后在同一事务中记录结果
+func executeBatchOperation(tx *gorm.DB, userID uint, index int, op BatchPostOperation) (BatchPostResult, error) {
+	var hash string
+	if op.IdempotencyKey != "" {
+		hash = batchOperationHash(op)
+		result, ok, err := replayBatchOperation(tx, userID, op.IdempotencyKey, hash)
+		if err != nil || ok {
+			result.Index = index
+			return result, err
+		}
+	}
+
+	var result BatchPostResult
+	var err error
+	switch op.Op {
+	case BatchOpCreate:
+		result, err = batchCreatePost(tx, userID, op)
+	case BatchOpUpdate:
+		result, err = batchUpdatePost(tx, userID, op)
+	case BatchOpDelete:
+		result, err = batchDeletePost(tx, userID, op)
+	}
+	if err != nil {
+		return result, err
+	}
+	result.Index = index
+	result.Op = op.Op
+
+	if op.IdempotencyKey != "" {
+		if err := saveBatchOperation(tx, userID, op.IdempotencyKey, hash, result); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+// batchCreatePost 创建文章
+func batchCreatePost(tx *gorm.DB, userID uint, op BatchPostOperation) (BatchPostResult, error) {
+	post := models.Post{
+		Title:   op.Title,
+		Content: op.Content,
+		UserID:  userID,
+		Version: 1,
+	}
+	if err := post.AssignSlug(tx); err != nil {
+		return BatchPostResult{}, err
+	}
+	if err := tx.Create(&post).Error; err != nil {
+		return BatchPostResult{}, err
+	}
+	if err := recordRevision(tx, &post, userID, []string{"title", "content"}, nil); err != nil {
+		return BatchPostResult{}, err
+	}
+	if err := linkAttachments(tx, userID, post.ID, op.AttachmentIDs); err != nil {
+		return BatchPostResult{}, err
+	}
+	return BatchPostResult{Status: http.StatusCreated, ID: post.ID, Slug: post.Slug, Version: post.Version}, nil
+}
+
+// batchUpdatePost 更新文章，只有作者可以更新
+func batchUpdatePost(tx *gorm.DB, userID uint, op BatchPostOperation) (BatchPostResult, error) {
+	post, err := batchLoadOwnPost(tx, userID, op)
+	if err != nil {
+		return BatchPostResult{}, err
+	}
+
+	previous := post
+	post.Title = op.Title
+	post.Content = op.Content
+	post.UpdatedAt = time.Now()
+	if err := savePostChanges(tx, &post, previous, userID, nil); err != nil {
+		return BatchPostResult{}, err
+	}
+	if err := linkAttachments(tx, post.UserID, post.ID, op.AttachmentIDs); err != nil {
+		return BatchPostResult{}, err
+	}
+	return BatchPostResult{Status: http.StatusOK, ID: post.ID, Slug: post.Slug, Version: post.Version}, nil
+}
+
+// batchDeletePost 删除文章，评论随文章一起进入回收站，只有作者可以删除
+func batchDeletePost(tx *gorm.DB, userID uint, op BatchPostOperation) (BatchPostResult, error) {
+	post, err := batchLoadOwnPost(tx, userID, op)
+	if err != nil {
+		return BatchPostResult{}, err
+	}
+
+	now := time.Now()
+	result := tx.Model(&models.Post{}).
+		Where("id = ? AND version = ?", post.ID, post.Version).
+		UpdateColumn("deleted_at", now)
+	if result.Error != nil {
+		return BatchPostResult{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return BatchPostResult{}, errPostVersionConflict
+	}
+	if err := softDeletePostComments(tx, []uint{post.ID}, now); err != nil {
+		return BatchPostResult{}, err
+	}
+	return BatchPostResult{Status: http.StatusOK, ID: post.ID}, nil
+}
+
+// batchLoadOwnPost 读取要修改的文章，检查作者和操作中指定的版本
+func batchLoadOwnPost(tx *gorm.DB, userID uint, op BatchPostOperation) (models.Post, error) {
+	var post models.Post
+	if err := tx.First(&post, op.ID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return post, utils.NewNotFoundError("Post not found")
+		}
+		return post, err
+	}
+	if post.UserID != userID {
+		return post, utils.NewError("You are not the author of this post", http.StatusForbidden)
+	}
+	if op.Version != nil && *op.Version != post.Version {
+		return post, errPostVersionConflict
+	}
+	return post, nil
+}
+
+// batchFailure 将操作的错误转换为结果
+func batchFailure(index int, op BatchPostOperation, err error) BatchPostResult {
+	result := BatchPostResult{Index: index, Op: op.Op}
+
+	var customErr utils.CustomError
+	var conflict *config.ConflictError
+	switch {
+	case errors.As(err, &customErr):
+		result.Status = customErr.Code
+		result.Message = customErr.Message
+	case errors.Is(err, errInvalidAttachments):
+		result.Status = http.StatusBadRequest
+		result.Message = "Invalid attachment ids"
+	case errors.Is(err, errPostVersionConflict):
+		// 与respondPostConflict相同：指定了version时是前置条件不满足，否则是读取后被并发修改
+		result.Status = http.StatusConflict
+		if op.Version != nil {
+			result.Status = http.StatusPreconditionFailed
+		}
+		result.Message = "Post has been modified, fetch the latest version and retry"
+	case errors.As(err, &conflict):
+		result.Status = http.StatusConflict
+		result.Message = conflictMessages[conflict.Key]
+		if result.Message == "" {
+			result.Message = "Resource already exists"
+		}
+	default:
+		utils.LogError("batch posts operation error", err)
+		result.Status = http.StatusInternalServerError
+		result.Message = "Internal server error"
+	}
+	return result
+}
+
+// batchOperationHash 计算操作内容的哈希，不包括幂等键本身
+func batchOperationHash(op BatchPostOperation) string {
+	op.IdempotencyKey = ""
+	data, _ := json.Marshal(op)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// replayBatchOperation 查找幂等键记录的结果，返回结果和是否找到
+// 同一个键用于内容不同的操作时返回409；过期的记录被删除，当作新操作执行
+func replayBatchOperation(tx *gorm.DB, userID uint, key, hash string) (BatchPostResult, bool, error) {
+	var record models.IdempotencyKey
+	err := tx.Where("user_id = ? AND scope = ? AND idempotency_key = ?", userID, batchIdempotencyScope, key).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return BatchPostResult{}, false, nil
+	}
+	if err != nil {
+		return BatchPostResult{}, false, err
+	}
+
+	if record.ExpiresAt.Before(time.Now()) {
+		return BatchPostResult{}, false, tx.Delete(&record).Error
+	}
+	if record.RequestHash != hash {
+		return BatchPostResult{}, false, utils.NewError("Idempotency key was already used for a different operation", http.StatusConflict)
+	}
+
+	var result BatchPostResult
+	if err := json.Unmarshal(record.Response, &result); err != nil {
+		return BatchPostResult{}, false, err
+	}
+	result.Replayed = true
+	return result, true, nil
+}
+
+// saveBatchOperation 记录幂等键和操作的结果
+// 同一个键的操作正在另一个请求中执行时插入会因唯一索引冲突失败，客户端稍后重试即可得到第一次的结果
+func saveBatchOperation(tx *gorm.DB, userID uint, key, hash string, result BatchPostResult) error {
+	response, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return tx.Create(&models.IdempotencyKey{
+		UserID:      userID,
+		Scope:       batchIdempotencyScope,
+		Key:         key,
+		RequestHash: hash,
+		StatusCode:  result.Status,
+		Response:    response,
+		ExpiresAt:   time.Now().Add(time.Duration(config.Get().Idempotency.TTLHours) * time.Hour),
+	}).Error
+}
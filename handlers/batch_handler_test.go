@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+	"github.com/test/blog/utils"
+)
+
+func batchRouter() *gin.Engine {
+	r := gin.New()
+	r.POST("/api/posts/batch", middleware.AuthMiddleware(), BatchPosts)
+	return r
+}
+
+// batchResults 返回响应中每个操作的结果
+func batchResults(t *testing.T, body map[string]any) []map[string]any {
+	t.Helper()
+	data, ok := body["data"].(map[string]any)
+	if !ok {
+		t.Fatalf("response has no data: %v", body)
+	}
+	var results []map[string]any
+	for _, r := range data["results"].([]any) {
+		results = append(results, r.(map[string]any))
+	}
+	return results
+}
+
+func TestBatchFailure(t *testing.T) {
+	version := 3
+	tests := []struct {
+		name   string
+		op     BatchPostOperation
+		err    error
+		status int
+	}{
+		{"version given", BatchPostOperation{Op: BatchOpUpdate, Version: &version}, errPostVersionConflict, http.StatusPreconditionFailed},
+		{"concurrent modification", BatchPostOperation{Op: BatchOpUpdate}, errPostVersionConflict, http.StatusConflict},
+		{"concurrent delete", BatchPostOperation{Op: BatchOpDelete}, fmt.Errorf("delete: %w", errPostVersionConflict), http.StatusConflict},
+		{"custom error", BatchPostOperation{Op: BatchOpDelete}, utils.NewNotFoundError("Post not found"), http.StatusNotFound},
+		{"invalid attachments", BatchPostOperation{Op: BatchOpCreate}, errInvalidAttachments, http.StatusBadRequest},
+		{"unique conflict", BatchPostOperation{Op: BatchOpCreate}, &config.ConflictError{Key: "idx_posts_slug"}, http.StatusConflict},
+		{"unknown error", BatchPostOperation{Op: BatchOpCreate}, errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := batchFailure(2, tt.op, tt.err)
+			if result.Status != tt.status {
+				t.Errorf("status = %d, want %d", result.Status, tt.status)
+			}
+			if result.Index != 2 || result.Op != tt.op.Op || result.Message == "" {
+				t.Errorf("result = %+v", result)
+			}
+		})
+	}
+}
+
+func TestBatchPosts(t *testing.T) {
+	type operation = map[string]any
+
+	tests := []struct {
+		name     string
+		atomic   bool
+		ops      func(own, other models.Post) []operation
+		status   int
+		statuses []int
+		posts    int64 // 执行后alice未删除的文章数，开始时有1篇
+	}{
+		{
+			name: "independent operations",
+			ops: func(own, other models.Post) []operation {
+				return []operation{
+					{"op": "create", "title": "New", "content": "Body"},
+					{"op": "update", "id": own.ID, "title": "Changed", "content": "Body", "version": own.Version + 1},
+					{"op": "delete", "id": other.ID},
+					{"op": "delete", "id": 9999},
+					{"op": "update", "id": own.ID, "title": "Changed", "content": "Body", "version": own.Version},
+				}
+			},
+			status:   http.StatusOK,
+			statuses: []int{http.StatusCreated, http.StatusPreconditionFailed, http.StatusForbidden, http.StatusNotFound, http.StatusOK},
+			posts:    2,
+		},
+		{
+			name: "delete own post",
+			ops: func(own, other models.Post) []operation {
+				return []operation{{"op": "delete", "id": own.ID, "version": own.Version}}
+			},
+			status:   http.StatusOK,
+			statuses: []int{http.StatusOK},
+			posts:    0,
+		},
+		{
+			name:   "atomic success",
+			atomic: true,
+			ops: func(own, other models.Post) []operation {
+				return []operation{
+					{"op": "create", "title": "New", "content": "Body"},
+					{"op": "delete", "id": own.ID},
+				}
+			},
+			status:   http.StatusOK,
+			statuses: []int{http.StatusCreated, http.StatusOK},
+			posts:    1,
+		},
+		{
+			name:   "atomic rollback",
+			atomic: true,
+			ops: func(own, other models.Post) []operation {
+				return []operation{
+					{"op": "create", "title": "New", "content": "Body"},
+					{"op": "delete", "id": own.ID},
+					{"op": "update", "id": own.ID, "title": "Changed", "content": "Body"},
+				}
+			},
+			status:   http.StatusNotFound,
+			statuses: []int{http.StatusFailedDependency, http.StatusFailedDependency, http.StatusNotFound},
+			posts:    1,
+		},
+		{
+			name:   "atomic version mismatch",
+			atomic: true,
+			ops: func(own, other models.Post) []operation {
+				return []operation{
+					{"op": "create", "title": "New", "content": "Body"},
+					{"op": "delete", "id": own.ID, "version": own.Version + 1},
+				}
+			},
+			status:   http.StatusPreconditionFailed,
+			statuses: []int{http.StatusFailedDependency, http.StatusPreconditionFailed},
+			posts:    1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Setup(t)
+			alice := testutil.CreateUser(t, "alice")
+			bob := testutil.CreateUser(t, "bob")
+			own := testutil.CreatePost(t, alice, "Own")
+			other := testutil.CreatePost(t, bob, "Other")
+
+			body := map[string]any{"atomic": tt.atomic, "operations": tt.ops(own, other)}
+			w := testutil.Request(t, batchRouter(), http.MethodPost, "/api/posts/batch", testutil.Token(t, alice), body)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			results := batchResults(t, testutil.Decode(t, w))
+			if len(results) != len(tt.statuses) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.statuses))
+			}
+			for i, want := range tt.statuses {
+				if got := int(results[i]["status"].(float64)); got != want {
+					t.Errorf("operations[%d] status = %d, want %d: %v", i, got, want, results[i])
+				}
+				if int(results[i]["index"].(float64)) != i {
+					t.Errorf("operations[%d] index = %v", i, results[i]["index"])
+				}
+			}
+
+			var count int64
+			config.GetDB().Model(&models.Post{}).Where("user_id = ?", alice.ID).Count(&count)
+			if count != tt.posts {
+				t.Errorf("alice has %d posts, want %d", count, tt.posts)
+			}
+			var bobs int64
+			config.GetDB().Model(&models.Post{}).Where("user_id = ?", bob.ID).Count(&bobs)
+			if bobs != 1 {
+				t.Errorf("bob's post was changed by alice's batch")
+			}
+		})
+	}
+}
+
+func TestBatchPostsValidation(t *testing.T) {
+	testutil.Setup(t)
+	alice := testutil.CreateUser(t, "alice")
+	token := testutil.Token(t, alice)
+
+	tests := []struct {
+		name   string
+		body   any
+		fields []string
+	}{
+		{"no operations", map[string]any{"operations": []any{}}, []string{"operations"}},
+		{"unknown op", map[string]any{"operations": []any{map[string]any{"op": "archive"}}}, []string{"operations[0].op"}},
+		{
+			"missing fields",
+			map[string]any{"operations": []any{
+				map[string]any{"op": "update", "content": "Body"},
+				map[string]any{"op": "delete"},
+			}},
+			[]string{"operations[0].id", "operations[0].title", "operations[1].id"},
+		},
+		{
+			"duplicate idempotency keys",
+			map[string]any{"operations": []any{
+				map[string]any{"op": "create", "title": "A", "content": "Body", "idempotency_key": "k"},
+				map[string]any{"op": "create", "title": "B", "content": "Body", "idempotency_key": "k"},
+			}},
+			[]string{"operations[1].idempotency_key"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Request(t, batchRouter(), http.MethodPost, "/api/posts/batch", token, tt.body)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400: %s", w.Code, w.Body.String())
+			}
+			fieldErrors, _ := testutil.Decode(t, w)["errors"].(map[string]any)
+			if len(fieldErrors) != len(tt.fields) {
+				t.Errorf("errors = %v, want keys %v", fieldErrors, tt.fields)
+			}
+			for _, field := range tt.fields {
+				if _, ok := fieldErrors[field]; !ok {
+					t.Errorf("errors = %v, missing %s", fieldErrors, field)
+				}
+			}
+		})
+	}
+
+	var count int64
+	config.GetDB().Model(&models.Post{}).Count(&count)
+	if count != 0 {
+		t.Errorf("invalid batches created %d posts", count)
+	}
+}
+
+func TestBatchPostsIdempotency(t *testing.T) {
+	testutil.Setup(t)
+	alice := testutil.CreateUser(t, "alice")
+	token := testutil.Token(t, alice)
+	r := batchRouter()
+
+	create := map[string]any{"op": "create", "title": "Once", "content": "Body", "idempotency_key": "import-1"}
+	send := func(ops ...map[string]any) []map[string]any {
+		t.Helper()
+		w := testutil.Request(t, r, http.MethodPost, "/api/posts/batch", token, map[string]any{"operations": ops})
+		if w.Code != http.StatusOK {
+			t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+		}
+		return batchResults(t, testutil.Decode(t, w))
+	}
+
+	first := send(create)[0]
+	if first["status"] != float64(http.StatusCreated) || first["replayed"] != nil {
+		t.Fatalf("first result = %v", first)
+	}
+
+	// 重试时幂等键放在不同的位置，返回第一次的结果而不重复创建
+	retry := send(map[string]any{"op": "create", "title": "Other", "content": "Body"}, create)[1]
+	if retry["status"] != float64(http.StatusCreated) || retry["replayed"] != true || retry["id"] != first["id"] || retry["index"] != float64(1) {
+		t.Errorf("retried result = %v, first = %v", retry, first)
+	}
+
+	changed := map[string]any{"op": "create", "title": "Changed", "content": "Body", "idempotency_key": "import-1"}
+	if result := send(changed)[0]; result["status"] != float64(http.StatusConflict) {
+		t.Errorf("reused key result = %v, want 409", result)
+	}
+
+	var count int64
+	config.GetDB().Model(&models.Post{}).Where("title = ?", "Once").Count(&count)
+	if count != 1 {
+		t.Errorf("idempotent create ran %d times", count)
+	}
+
+	// 其他用户使用相同的键互不影响
+	bob := testutil.CreateUser(t, "bob")
+	w := testutil.Request(t, r, http.MethodPost, "/api/posts/batch", testutil.Token(t, bob), map[string]any{"operations": []any{create}})
+	if result := batchResults(t, testutil.Decode(t, w))[0]; result["replayed"] != nil || result["id"] == first["id"] {
+		t.Errorf("bob's result = %v", result)
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/cache"
+	"github.com/test/blog/config"
+	"github.com/test/blog/utils"
+)
+
+// postListNamespace 文章列表缓存的命名空间，任何文章变化都会使所有列表页失效
+const postListNamespace = "posts:list"
+
+// cachedResponse 缓存的响应
+type cachedResponse struct {
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"last_modified,omitempty"`
+	Body         []byte    `json:"body"`
+}
+
+// postNamespace 单篇文章缓存的命名空间
+func postNamespace(postID uint) string {
+	return fmt.Sprintf("post:%d", postID)
+}
+
+// cacheTTL 缓存条目的过期时间
+func cacheTTL() time.Duration {
+	return time.Duration(config.Get().Cache.TTLSeconds) * time.Second
+}
+
+// versionedCacheKey 生成包含命名空间当前版本的缓存键，命名空间版本递增后旧的键不再被使用
+// 获取版本失败时返回空字符串，调用方应跳过缓存
+func versionedCacheKey(ctx context.Context, namespace, suffix string) string {
+	version, err := cache.Get().Version(ctx, namespace)
+	if err != nil {
+		utils.LogError("get cache version error", err)
+		return ""
+	}
+	return fmt.Sprintf("%s:v%d:%s", namespace, version, suffix)
+}
+
+// serveCached 从缓存中返回响应，未命中时调用load生成响应并写入缓存
+// load返回utils.CustomError时按其状态码返回错误，错误不会被缓存
+func serveCached(c *gin.Context, key string, load func() (cachedResponse, error)) {
+	loadBytes := func() ([]byte, error) {
+		resp, err := load()
+		if err != nil {
+			return nil, err
+		}
+		return json.Marshal(resp)
+	}
+
+	var (
+		data []byte
+		err  error
+	)
+	if key == "" {
+		data, err = loadBytes()
+	} else {
+		data, err = cache.Fetch(c.Request.Context(), key, cacheTTL(), loadBytes)
+	}
+
+	var resp cachedResponse
+	if err == nil {
+		err = json.Unmarshal(data, &resp)
+	}
+	var customErr utils.CustomError
+	if errors.As(err, &customErr) {
+		c.JSON(customErr.Code, gin.H{
+			"success": false,
+			"message": customErr.Message,
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("serve cached response error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Internal server error",
+		})
+		return
+	}
+
+	if !resp.LastModified.IsZero() {
+		c.Header("Last-Modified", resp.LastModified.UTC().Format(http.TimeFormat))
+	}
+	if resp.ETag != "" {
+		c.Header("ETag", resp.ETag)
+		if notModified(c, resp.ETag, resp.LastModified) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", resp.Body)
+}
+
+// invalidatePostCache 文章或其评论发生变化后使相关缓存失效
+// 在事务提交之后调用；失败只记录日志，缓存会在过期后自然更新
+func invalidatePostCache(ctx context.Context, postIDs ...uint) {
+	// 使用独立的上下文，客户端断开连接不应该导致缓存没有被清除
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 2*time.Second)
+	defer cancel()
+
+	c := cache.Get()
+	for _, postID := range postIDs {
+		if err := c.Bump(ctx, postNamespace(postID)); err != nil {
+			utils.LogError("invalidate post cache error", err)
+		}
+	}
+	if err := c.Bump(ctx, postListNamespace); err != nil {
+		utils.LogError("invalidate post list cache error", err)
+	}
+}
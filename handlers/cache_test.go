@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/cache"
+	"github.com/test/blog/config"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+// useMemoryCache 使用进程内缓存，测试结束后恢复为不缓存
+func useMemoryCache(t *testing.T) {
+	t.Helper()
+	cache.Set(cache.NewMemoryCache(100))
+	t.Cleanup(func() { cache.Init(config.CacheConfig{Driver: "none"}) })
+}
+
+func TestPostCache(t *testing.T) {
+	testutil.Setup(t)
+	useMemoryCache(t)
+	alice := testutil.CreateUser(t, "alice")
+	post := testutil.CreatePost(t, alice, "Cached")
+	r := gin.New()
+	public := r.Group("/api", middleware.PublicCache(0))
+	public.GET("/posts", GetPosts)
+	public.GET("/posts/:id", GetPost)
+
+	path := fmt.Sprintf("/api/posts/%d", post.ID)
+	get := func(path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		return w
+	}
+	title := func(w *httptest.ResponseRecorder) any {
+		return testutil.Decode(t, w)["data"].(map[string]any)["title"]
+	}
+	listTitle := func(w *httptest.ResponseRecorder) any {
+		return testutil.Decode(t, w)["data"].(map[string]any)["posts"].([]any)[0].(map[string]any)["title"]
+	}
+
+	w := get(path, "")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || !strings.HasPrefix(etag, fmt.Sprintf(`"post-%d-v1-`, post.ID)) || w.Header().Get("Last-Modified") == "" {
+		t.Fatalf("status = %d, ETag %q, Last-Modified %q", w.Code, etag, w.Header().Get("Last-Modified"))
+	}
+	if w := get(path, etag); w.Code != http.StatusNotModified {
+		t.Errorf("conditional request status = %d, want 304", w.Code)
+	}
+	if w := get("/api/posts", ""); listTitle(w) != "Cached" {
+		t.Fatalf("list = %s", w.Body.String())
+	}
+
+	// 绕过接口直接修改数据库，缓存仍返回旧内容
+	config.GetDB().Model(&models.Post{}).Where("id = ?", post.ID).Updates(map[string]any{"title": "Changed", "version": 2})
+	if got := title(get(path, "")); got != "Cached" {
+		t.Fatalf("title = %v, want the cached title", got)
+	}
+	if got := listTitle(get("/api/posts", "")); got != "Cached" {
+		t.Fatalf("list title = %v, want the cached title", got)
+	}
+
+	invalidatePostCache(context.Background(), post.ID)
+	w = get(path, etag)
+	if w.Code != http.StatusOK || title(w) != "Changed" || !strings.HasPrefix(w.Header().Get("ETag"), fmt.Sprintf(`"post-%d-v2-`, post.ID)) {
+		t.Errorf("after invalidation status = %d, ETag %q: %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+	}
+	if got := listTitle(get("/api/posts", "")); got != "Changed" {
+		t.Errorf("list title after invalidation = %v", got)
+	}
+
+	// 作者改名不改变文章版本，ETag根据响应内容生成，不会返回过期的304
+	etag = get(path, "").Header().Get("ETag")
+	config.GetDB().Model(&alice).Update("username", "alice2")
+	invalidatePostCache(context.Background(), post.ID)
+	if w := get(path, etag); w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("after renaming the author status = %d, ETag %q", w.Code, w.Header().Get("ETag"))
+	}
+
+	// 错误不缓存
+	if w := get("/api/posts/999", ""); w.Code != http.StatusNotFound {
+		t.Fatalf("missing post status = %d", w.Code)
+	}
+	late := testutil.CreatePost(t, alice, "Late")
+	config.GetDB().Model(&late).Update("id", 999)
+	if w := get("/api/posts/999", ""); w.Code != http.StatusOK {
+		t.Errorf("status after creating the post = %d, want 200", w.Code)
+	}
+}
@@ -1,13 +1,16 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/test/blog/config"
 	"github.com/test/blog/models"
 	"github.com/test/blog/utils"
+	"gorm.io/gorm"
 )
 
 // CreateComment 创建评论
@@ -43,24 +46,35 @@ func CreateComment(c *gin.Context) {
 		return
 	}
 
-	// 检查文章是否存在
-	var post models.Post
-	if err := config.GetDB().First(&post, postID).Error; err != nil {
-		utils.LogError("create comment post not found", err)
+	// 先更新文章的评论统计：文章不存在或已删除时不影响任何行；同时锁定文章行，防止文章同时被删除
+	comment := models.Comment{
+		Content: req.Content,
+		UserID:  userID.(uint),
+		PostID:  uint(postID),
+	}
+	err = runTx(c, func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Post{}).Where("id = ?", postID).UpdateColumns(map[string]interface{}{
+			"comment_count":   gorm.Expr("comment_count + 1"),
+			"last_comment_at": now,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		comment.CreatedAt = now
+		return tx.Create(&comment).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"message": "Post not found",
 		})
 		return
 	}
-
-	// 创建评论
-	comment := models.Comment{
-		Content: req.Content,
-		UserID:  userID.(uint),
-		PostID:  uint(postID),
-	}
-	if err := config.GetDB().Create(&comment).Error; err != nil {
+	if err != nil {
 		utils.LogError("create comment database error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -69,6 +83,7 @@ func CreateComment(c *gin.Context) {
 		return
 	}
 
+	invalidatePostCache(c.Request.Context(), comment.PostID)
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"message": "Comment created successfully",
@@ -96,6 +111,16 @@ func GetComments(c *gin.Context) {
 		return
 	}
 
+	// 文章已删除时评论也不可见
+	var post models.Post
+	if err := config.GetDB().Select("id").First(&post, postID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Post not found",
+		})
+		return
+	}
+
 	// 分页参数
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
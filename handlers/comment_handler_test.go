@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func TestCreateCommentUpdatesStats(t *testing.T) {
+	testutil.Setup(t)
+	alice := testutil.CreateUser(t, "alice")
+	post := testutil.CreatePost(t, alice, "Post")
+	trashed := testutil.CreatePost(t, alice, "Trashed")
+	config.GetDB().Delete(&trashed)
+	r := gin.New()
+	r.POST("/api/posts/:id/comments", middleware.AuthMiddleware(), CreateComment)
+	token := testutil.Token(t, alice)
+
+	tests := []struct {
+		name   string
+		postID uint
+		body   gin.H
+		want   int
+		count  int
+	}{
+		{"first comment", post.ID, gin.H{"content": "one"}, http.StatusCreated, 1},
+		{"second comment", post.ID, gin.H{"content": "two"}, http.StatusCreated, 2},
+		{"empty content", post.ID, gin.H{"content": ""}, http.StatusBadRequest, 2},
+		{"missing post", 999, gin.H{"content": "x"}, http.StatusNotFound, 2},
+		{"trashed post", trashed.ID, gin.H{"content": "x"}, http.StatusNotFound, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Request(t, r, http.MethodPost, fmt.Sprintf("/api/posts/%d/comments", tt.postID), token, tt.body)
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			var stored models.Post
+			config.GetDB().First(&stored, post.ID)
+			if stored.CommentCount != tt.count || stored.LastCommentAt == nil {
+				t.Errorf("comment count = %d, last comment at %v", stored.CommentCount, stored.LastCommentAt)
+			}
+		})
+	}
+
+	var comments int64
+	config.GetDB().Unscoped().Model(&models.Comment{}).Count(&comments)
+	if comments != 2 {
+		t.Errorf("comments = %d, want 2", comments)
+	}
+	// 接口维护的统计与重新计算的结果一致
+	if drifted, err := models.DriftedCommentStats(config.GetDB(), post.ID); err != nil || len(drifted) != 0 {
+		t.Errorf("drifted = %v, %v", drifted, err)
+	}
+}
@@ -0,0 +1,17 @@
+package handlers
+
+import "time"
+
+// DataExportResponse 个人数据导出响应
+// 导出可以下载时包含下载链接，链接带有签名，无需认证即可访问，过期后需要重新查询状态获取新链接
+type DataExportResponse struct {
+	ID                uint       `json:"id"`
+	Status            string     `json:"status"` // pending/running/ready/failed/expired
+	Size              int64      `json:"size,omitempty"`
+	Error             string     `json:"error,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+	CompletedAt       *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"` // 导出文件的删除时间
+	DownloadURL       string     `json:"download_url,omitempty"`
+	DownloadExpiresAt *time.Time `json:"download_expires_at,omitempty"`
+}
@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/jobs"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RequestDataExport 申请导出当前用户的个人数据（仅限登录会话）
+// 导出由后台任务异步生成，返回202和导出记录；已有未完成的导出时返回该导出，不重复生成
+func RequestDataExport(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	var export models.DataExport
+	created := false
+	err := runTx(c, func(tx *gorm.DB) error {
+		// 锁定用户记录，避免并发请求创建多个导出
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&models.User{}, user.ID).Error; err != nil {
+			return err
+		}
+		err := tx.Where("user_id = ? AND status IN ?", user.ID, []string{models.DataExportPending, models.DataExportRunning}).
+			Order("id").First(&export).Error
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		export = models.DataExport{UserID: user.ID, Status: models.DataExportPending}
+		created = true
+		return tx.Create(&export).Error
+	})
+	if err != nil {
+		utils.LogError("data export request database error", err, utils.WithUserID(user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to request data export",
+		})
+		return
+	}
+
+	message := "Data export is already in progress"
+	if created {
+		message = "Data export requested successfully"
+		jobs.NotifyDataExport()
+		utils.LogInfo("data export requested", utils.WithUserID(user.ID), zap.Uint("export_id", export.ID))
+	}
+	c.Header("Location", fmt.Sprintf("/api/profile/export/%d", export.ID))
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": message,
+		"data":    dataExportResponse(export),
+	})
+}
+
+// GetDataExport 查询个人数据导出的状态（仅限登录会话），可以下载时返回带签名的下载链接
+func GetDataExport(c *gin.Context) {
+	exportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid export id",
+		})
+		return
+	}
+
+	var export models.DataExport
+	if err := config.GetDB().Where("user_id = ?", c.GetUint("user_id")).First(&export, exportID).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.LogError("data export retrieval error", err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Data export not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Data export retrieved successfully",
+		"data":    dataExportResponse(export),
+	})
+}
+
+// DownloadDataExport 通过带签名的链接下载个人数据导出文件，无需认证
+// 令牌只能用于下载签发时指定的导出，导出过期或用户注销后链接失效
+func DownloadDataExport(c *gin.Context) {
+	exportID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid export id",
+		})
+		return
+	}
+
+	claims, tokenExportID, err := config.JWTKeys().ValidateDataExportToken(c.Query("token"))
+	if err != nil || tokenExportID != uint(exportID) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Invalid or expired download link",
+		})
+		return
+	}
+
+	var export models.DataExport
+	err = config.GetDB().Where("user_id = ? AND status = ? AND expires_at > ?", claims.UserID, models.DataExportReady, time.Now()).
+		First(&export, exportID).Error
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.LogError("data export retrieval error", err)
+		}
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Data export not found or expired",
+		})
+		return
+	}
+
+	path := filepath.Join(config.Get().DataExport.Dir, export.FileName)
+	if _, err := os.Stat(path); err != nil {
+		// 多实例部署时DATA_EXPORT_DIR没有共享，文件可能在其他实例上
+		utils.LogError("data export file missing", err, utils.WithUserID(export.UserID), zap.Uint("export_id", export.ID))
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Data export not found or expired",
+		})
+		return
+	}
+
+	utils.LogInfo("data export downloaded", utils.WithUserID(export.UserID), zap.Uint("export_id", export.ID))
+	c.FileAttachment(path, fmt.Sprintf("personal-data-%s.zip", export.CreatedAt.Format("20060102")))
+}
+
+// dataExportResponse 生成导出响应，可以下载时签发下载链接
+// 链接的有效期不超过导出文件的删除时间；签发失败时只记录日志，客户端可以重新查询
+// 链接只使用配置的站点地址，不根据请求的Host推断，防止伪造Host把令牌发到其他站点；未配置时返回相对地址
+func dataExportResponse(export models.DataExport) DataExportResponse {
+	response := DataExportResponse{
+		ID:          export.ID,
+		Status:      export.Status,
+		Size:        export.Size,
+		Error:       export.Error,
+		CreatedAt:   export.CreatedAt,
+		CompletedAt: export.CompletedAt,
+		ExpiresAt:   export.ExpiresAt,
+	}
+	if export.Status != models.DataExportReady || export.ExpiresAt == nil {
+		return response
+	}
+
+	ttl := time.Duration(config.Get().DataExport.LinkTTLMinutes) * time.Minute
+	if remaining := time.Until(*export.ExpiresAt); remaining < ttl {
+		ttl = remaining
+	}
+	if ttl <= 0 {
+		return response
+	}
+	token, err := config.JWTKeys().GenerateDataExportToken(export.UserID, export.ID, ttl)
+	if err != nil {
+		utils.LogError("data export token generation error", err, utils.WithUserID(export.UserID))
+		return response
+	}
+	expiresAt := time.Now().Add(ttl)
+	baseURL := strings.TrimSuffix(config.Get().Site.BaseURL, "/")
+	response.DownloadURL = fmt.Sprintf("%s/api/profile/export/%d/download?token=%s", baseURL, export.ID, url.QueryEscape(token))
+	response.DownloadExpiresAt = &expiresAt
+	return response
+}
+
+// expireDataExports 取消用户未完成的导出并使已生成的导出立即过期，需要在事务中调用
+func expireDataExports(tx *gorm.DB, userID uint, now time.Time) error {
+	err := tx.Model(&models.DataExport{}).
+		Where("user_id = ? AND status IN ?", userID, []string{models.DataExportPending, models.DataExportRunning}).
+		Updates(map[string]interface{}{"status": models.DataExportFailed, "error": "Account deleted"}).Error
+	if err != nil {
+		return err
+	}
+	return tx.Model(&models.DataExport{}).
+		Where("user_id = ? AND status = ?", userID, models.DataExportReady).
+		UpdateColumn("expires_at", now).Error
+}
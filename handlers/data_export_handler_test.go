@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/jobs"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func dataExportRouter() *gin.Engine {
+	r := gin.New()
+	r.GET("/api/profile/export/:id/download", middleware.PrivateNoStore(), DownloadDataExport)
+	dataExport := r.Group("/api/profile/export", middleware.AuthMiddleware(), middleware.RequireUserSession())
+	dataExport.POST("", RequestDataExport)
+	dataExport.GET("/:id", GetDataExport)
+	return r
+}
+
+// readyDataExport 申请导出并运行后台任务生成导出文件，返回导出id
+func readyDataExport(t *testing.T, r http.Handler, token string) uint {
+	t.Helper()
+	w := testutil.Request(t, r, http.MethodPost, "/api/profile/export", token, nil)
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("request export status = %d: %s", w.Code, w.Body.String())
+	}
+	data := testutil.Decode(t, w)["data"].(map[string]any)
+	if data["status"] != models.DataExportPending || data["download_url"] != nil {
+		t.Fatalf("requested export = %v", data)
+	}
+	if n, err := jobs.ProcessDataExports(context.Background()); err != nil || n != 1 {
+		t.Fatalf("ProcessDataExports = %d, %v", n, err)
+	}
+	return uint(data["id"].(float64))
+}
+
+func TestDataExportDownloadURL(t *testing.T) {
+	tests := []struct {
+		name    string
+		baseURL string
+		prefix  string
+	}{
+		{"base url", "https://blog.example.com/", "https://blog.example.com/api/profile/export/"},
+		// 未配置站点地址时不使用请求的Host
+		{"relative", "", "/api/profile/export/"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Setup(t)
+			cfg := config.Get()
+			cfg.Site.BaseURL = tt.baseURL
+			cfg.DataExport.Dir = t.TempDir()
+			alice := testutil.CreateUser(t, "alice")
+			token := testutil.Token(t, alice)
+			r := dataExportRouter()
+			id := readyDataExport(t, r, token)
+
+			req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/api/profile/export/%d", id), nil)
+			req.Host = "attacker.example"
+			req.Header.Set("Authorization", "Bearer "+token)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			data := testutil.Decode(t, w)["data"].(map[string]any)
+			downloadURL, _ := data["download_url"].(string)
+			if !strings.HasPrefix(downloadURL, fmt.Sprintf("%s%d/download?token=", tt.prefix, id)) {
+				t.Errorf("download_url = %q, want prefix %s", downloadURL, tt.prefix)
+			}
+			if data["status"] != models.DataExportReady || data["download_expires_at"] == nil {
+				t.Errorf("export = %v", data)
+			}
+		})
+	}
+}
+
+func TestDownloadDataExport(t *testing.T) {
+	testutil.Setup(t)
+	cfg := config.Get()
+	cfg.DataExport.Dir = t.TempDir()
+	alice := testutil.CreateUser(t, "alice")
+	bob := testutil.CreateUser(t, "bob")
+	r := dataExportRouter()
+	aliceID := readyDataExport(t, r, testutil.Token(t, alice))
+	bobID := readyDataExport(t, r, testutil.Token(t, bob))
+
+	downloadPath := func(id uint, token string) string {
+		w := testutil.Request(t, r, http.MethodGet, fmt.Sprintf("/api/profile/export/%d", id), token, nil)
+		return testutil.Decode(t, w)["data"].(map[string]any)["download_url"].(string)
+	}
+	alicePath := downloadPath(aliceID, testutil.Token(t, alice))
+	_, aliceToken, _ := strings.Cut(alicePath, "?")
+
+	tests := []struct {
+		name   string
+		path   string
+		status int
+	}{
+		{"valid link", alicePath, http.StatusOK},
+		{"no token", fmt.Sprintf("/api/profile/export/%d/download", aliceID), http.StatusForbidden},
+		{"token for another export", fmt.Sprintf("/api/profile/export/%d/download?%s", bobID, aliceToken), http.StatusForbidden},
+		{"invalid token", fmt.Sprintf("/api/profile/export/%d/download?token=invalid", aliceID), http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Request(t, r, http.MethodGet, tt.path, "", nil)
+			if w.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if w.Header().Get("Cache-Control") != "private, no-store" {
+				t.Errorf("Cache-Control = %q", w.Header().Get("Cache-Control"))
+			}
+			if tt.status != http.StatusOK {
+				return
+			}
+			zr, err := zip.NewReader(bytes.NewReader(w.Body.Bytes()), int64(w.Body.Len()))
+			if err != nil {
+				t.Fatalf("download is not a zip: %v", err)
+			}
+			found := false
+			for _, f := range zr.File {
+				found = found || f.Name == "profile.json"
+			}
+			if !found {
+				t.Error("export has no profile.json")
+			}
+		})
+	}
+
+	// 文件不在本实例的导出目录中（目录没有共享）或导出已过期时链接失效
+	var export models.DataExport
+	config.GetDB().First(&export, aliceID)
+	if err := os.Remove(filepath.Join(cfg.DataExport.Dir, export.FileName)); err != nil {
+		t.Fatal(err)
+	}
+	if w := testutil.Request(t, r, http.MethodGet, alicePath, "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("missing file status = %d, want 404", w.Code)
+	}
+	bobPath := downloadPath(bobID, testutil.Token(t, bob))
+	config.GetDB().Model(&models.DataExport{}).Where("id = ?", bobID).UpdateColumn("expires_at", time.Now().Add(-time.Minute))
+	if w := testutil.Request(t, r, http.MethodGet, bobPath, "", nil); w.Code != http.StatusNotFound {
+		t.Errorf("expired export status = %d, want 404", w.Code)
+	}
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+)
+
+// 订阅源格式
+const (
+	feedFormatRSS  = "rss"
+	feedFormatAtom = "atom"
+)
+
+// GetSiteRSS 全站RSS订阅源
+func GetSiteRSS(c *gin.Context) {
+	serveFeed(c, feedFormatRSS, nil)
+}
+
+// GetSiteAtom 全站Atom订阅源
+func GetSiteAtom(c *gin.Context) {
+	serveFeed(c, feedFormatAtom, nil)
+}
+
+// GetAuthorRSS 作者RSS订阅源
+func GetAuthorRSS(c *gin.Context) {
+	serveAuthorFeed(c, feedFormatRSS)
+}
+
+// GetAuthorAtom 作者Atom订阅源
+func GetAuthorAtom(c *gin.Context) {
+	serveAuthorFeed(c, feedFormatAtom)
+}
+
+// serveAuthorFeed 根据路径中的用户名生成作者订阅源
+func serveAuthorFeed(c *gin.Context, format string) {
+	var author models.User
+	if err := config.GetDB().Where("username = ?", c.Param("username")).First(&author).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Author not found",
+		})
+		return
+	}
+	serveFeed(c, format, &author)
+}
+
+// serveFeed 生成订阅源，author为空时生成全站订阅源
+// 支持If-None-Match和If-Modified-Since条件请求
+func serveFeed(c *gin.Context, format string, author *models.User) {
+	cfg := config.Get()
+
+	query := config.GetDB().Preload("User").Order("updated_at desc").Limit(cfg.Feed.ItemCount)
+	if author != nil {
+		query = query.Where("user_id = ?", author.ID)
+	}
+	var posts []models.Post
+	if err := query.Find(&posts).Error; err != nil {
+		utils.LogError("get feed posts error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to generate feed",
+		})
+		return
+	}
+
+	baseURL := siteBaseURL(c)
+	title := cfg.Site.Title
+	if author != nil {
+		title = fmt.Sprintf("%s - %s", cfg.Site.Title, author.Username)
+	}
+	selfURL := baseURL + c.Request.URL.Path
+
+	// 文章按更新时间倒序，第一篇的更新时间即订阅源的更新时间
+	var updated time.Time
+	if len(posts) > 0 {
+		updated = posts[0].UpdatedAt.UTC()
+	}
+
+	var (
+		body        []byte
+		contentType string
+		err         error
+	)
+	switch format {
+	case feedFormatAtom:
+		body, err = renderAtom(posts, title, baseURL, selfURL, updated, cfg.Feed.SummaryLength)
+		contentType = "application/atom+xml; charset=utf-8"
+	default:
+		body, err = renderRSS(posts, title, cfg.Site.Description, baseURL, selfURL, updated, cfg.Feed.SummaryLength)
+		contentType = "application/rss+xml; charset=utf-8"
+	}
+	if err != nil {
+		utils.LogError("render feed error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to generate feed",
+		})
+		return
+	}
+
+	sum := sha256.Sum256(body)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Header("ETag", etag)
+	if !updated.IsZero() {
+		c.Header("Last-Modified", updated.Format(http.TimeFormat))
+	}
+
+	if notModified(c, etag, updated) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	c.Data(http.StatusOK, contentType, body)
+}
+
+// renderRSS 生成RSS 2.0文档
+func renderRSS(posts []models.Post, title, description, baseURL, selfURL string, updated time.Time, summaryLength int) ([]byte, error) {
+	feed := RSSFeed{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		Channel: RSSChannel{
+			Title:       title,
+			Link:        baseURL,
+			Description: description,
+			SelfLink:    AtomLink{Href: selfURL, Rel: "self", Type: "application/rss+xml"},
+		},
+	}
+	if !updated.IsZero() {
+		feed.Channel.LastBuildDate = updated.Format(time.RFC1123Z)
+	}
+	for _, post := range posts {
+		link := postURL(baseURL, post)
+		feed.Channel.Items = append(feed.Channel.Items, RSSItem{
+			Title:       post.Title,
+			Link:        link,
+			GUID:        RSSGUID{IsPermaLink: true, Value: postIDURL(baseURL, post)},
+			Description: utils.Summarize(post.Content, summaryLength),
+			PubDate:     post.CreatedAt.UTC().Format(time.RFC1123Z),
+		})
+	}
+	return marshalXML(feed)
+}
+
+// renderAtom 生成Atom文档
+func renderAtom(posts []models.Post, title, baseURL, selfURL string, updated time.Time, summaryLength int) ([]byte, error) {
+	if updated.IsZero() {
+		updated = time.Unix(0, 0).UTC()
+	}
+	feed := AtomFeed{
+		Title:   title,
+		ID:      selfURL,
+		Updated: updated.Format(time.RFC3339),
+		Links: []AtomLink{
+			{Href: selfURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: baseURL, Rel: "alternate"},
+		},
+	}
+	for _, post := range posts {
+		link := postURL(baseURL, post)
+		feed.Entries = append(feed.Entries, AtomEntry{
+			Title:     post.Title,
+			ID:        postIDURL(baseURL, post),
+			Link:      AtomLink{Href: link, Rel: "alternate"},
+			Published: post.CreatedAt.UTC().Format(time.RFC3339),
+			Updated:   post.UpdatedAt.UTC().Format(time.RFC3339),
+			Author:    AtomAuthor{Name: post.User.Username},
+			Summary:   utils.Summarize(post.Content, summaryLength),
+		})
+	}
+	return marshalXML(feed)
+}
+
+// marshalXML 序列化XML文档并添加声明
+func marshalXML(v interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
+
+// notModified 判断条件请求是否可以返回304
+func notModified(c *gin.Context, etag string, lastModified time.Time) bool {
+	return utils.NotModified(c.Request.Header, etag, lastModified)
+}
+
+// siteBaseURL 获取站点地址，未配置时根据请求推断
+// 推断的地址来自客户端可以伪造的Host和X-Forwarded-Proto，响应禁止被共享缓存保存，避免缓存投毒
+func siteBaseURL(c *gin.Context) string {
+	if baseURL := config.Get().Site.BaseURL; baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/")
+	}
+	c.Header("Cache-Control", "private, no-store")
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host
+}
+
+// postURL 获取文章的访问地址，优先使用slug
+func postURL(baseURL string, post models.Post) string {
+	if post.Slug != "" {
+		return baseURL + "/api/posts/by-slug/" + url.PathEscape(post.Slug)
+	}
+	return postIDURL(baseURL, post)
+}
+
+// postIDURL 获取基于id的文章地址，标题修改后保持不变，用作订阅源条目的唯一标识
+func postIDURL(baseURL string, post models.Post) string {
+	return fmt.Sprintf("%s/api/posts/%d", baseURL, post.ID)
+}
@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+// setupFeeds 创建两位作者的文章，返回挂载了公开缓存策略的订阅源路由
+func setupFeeds(t *testing.T, baseURL string) *gin.Engine {
+	t.Helper()
+	testutil.Setup(t)
+	cfg := config.DefaultConfig()
+	cfg.Site.BaseURL = baseURL
+	cfg.Feed.ItemCount = 2
+	config.Set(cfg)
+
+	alice := testutil.CreateUser(t, "alice")
+	bob := testutil.CreateUser(t, "bob")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, author := range []models.User{alice, bob, alice} {
+		post := models.Post{Title: "Post " + string(rune('A'+i)), Slug: "post-" + string(rune('a'+i)), Content: "# Heading\n\nHello **world**", UserID: author.ID}
+		post.CreatedAt = start.Add(time.Duration(i) * time.Hour)
+		post.UpdatedAt = post.CreatedAt
+		if err := config.GetDB().Create(&post).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r := gin.New()
+	feeds := r.Group("", middleware.PublicCache(5*time.Minute))
+	feeds.GET("/feed.rss", GetSiteRSS)
+	feeds.GET("/feed.atom", GetSiteAtom)
+	feeds.GET("/authors/:username/feed.rss", GetAuthorRSS)
+	feeds.GET("/authors/:username/feed.atom", GetAuthorAtom)
+	feeds.GET("/sitemap.xml", GetSitemap)
+	return r
+}
+
+func getFeed(r *gin.Engine, path, host string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Host = host
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestFeeds(t *testing.T) {
+	r := setupFeeds(t, "https://blog.example.com/")
+
+	t.Run("site rss", func(t *testing.T) {
+		w := getFeed(r, "/feed.rss", "blog.example.com", nil)
+		if w.Code != http.StatusOK || !strings.HasPrefix(w.Header().Get("Content-Type"), "application/rss+xml") {
+			t.Fatalf("status = %d, content type %q", w.Code, w.Header().Get("Content-Type"))
+		}
+		var feed RSSFeed
+		if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+			t.Fatal(err)
+		}
+		items := feed.Channel.Items
+		if len(items) != 2 || items[0].Title != "Post C" || items[1].Title != "Post B" {
+			t.Fatalf("unexpected items %+v", items)
+		}
+		if items[0].Link != "https://blog.example.com/api/posts/by-slug/post-c" || items[0].Description != "Heading Hello world" {
+			t.Errorf("unexpected item %+v", items[0])
+		}
+		if w.Header().Get("Last-Modified") != "Mon, 01 Jan 2024 02:00:00 GMT" {
+			t.Errorf("Last-Modified = %q", w.Header().Get("Last-Modified"))
+		}
+	})
+
+	t.Run("author atom", func(t *testing.T) {
+		w := getFeed(r, "/authors/bob/feed.atom", "blog.example.com", nil)
+		var feed AtomFeed
+		if err := xml.Unmarshal(w.Body.Bytes(), &feed); err != nil {
+			t.Fatal(err)
+		}
+		if len(feed.Entries) != 1 || feed.Entries[0].Author.Name != "bob" || feed.Updated != "2024-01-01T01:00:00Z" {
+			t.Errorf("unexpected feed %+v", feed)
+		}
+	})
+
+	t.Run("unknown author", func(t *testing.T) {
+		if w := getFeed(r, "/authors/nobody/feed.rss", "blog.example.com", nil); w.Code != http.StatusNotFound {
+			t.Errorf("status = %d, want 404", w.Code)
+		}
+	})
+
+	t.Run("conditional request", func(t *testing.T) {
+		etag := getFeed(r, "/feed.atom", "blog.example.com", nil).Header().Get("ETag")
+		w := getFeed(r, "/feed.atom", "blog.example.com", map[string]string{"If-None-Match": etag})
+		if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+			t.Errorf("status = %d, body %d bytes, want 304", w.Code, w.Body.Len())
+		}
+	})
+}
+
+func TestFeedBaseURLAndCaching(t *testing.T) {
+	tests := []struct {
+		name        string
+		baseURL     string
+		wantCache   string
+		wantPrefix  string
+		forbidInURL string
+	}{
+		{"configured base url ignores the host header", "https://blog.example.com", "public, max-age=300", "https://blog.example.com/", "evil.example"},
+		// 地址根据可伪造的Host生成，不能被CDN缓存后返回给其他用户
+		{"inferred base url is not cached publicly", "", "private, no-store", "http://evil.example/", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := setupFeeds(t, tt.baseURL)
+			for _, path := range []string{"/feed.rss", "/feed.atom", "/authors/alice/feed.rss", "/sitemap.xml"} {
+				w := getFeed(r, path, "evil.example", nil)
+				if w.Code != http.StatusOK {
+					t.Fatalf("%s status = %d", path, w.Code)
+				}
+				if got := w.Header().Get("Cache-Control"); got != tt.wantCache {
+					t.Errorf("%s Cache-Control = %q, want %q", path, got, tt.wantCache)
+				}
+				body := w.Body.String()
+				if !strings.Contains(body, tt.wantPrefix) || (tt.forbidInURL != "" && strings.Contains(body, tt.forbidInURL)) {
+					t.Errorf("%s links do not use %q:\n%s", path, tt.wantPrefix, body)
+				}
+			}
+		})
+	}
+}
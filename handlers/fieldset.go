@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Fieldset 读取接口通过 fields 和 include 参数选择的字段和嵌入的关联资源
+type Fieldset struct {
+	Fields   []string // 为空表示返回所有字段
+	Includes []string
+}
+
+// parseFieldset 解析 fields 和 include 参数，只允许资源白名单中的字段和关联资源
+// 无效的值记录到fieldErrors中
+func parseFieldset(c *gin.Context, allowedFields map[string]string, allowedIncludes []string, fieldErrors gin.H) Fieldset {
+	var fieldset Fieldset
+
+	if value, ok := c.GetQuery("fields"); ok {
+		fieldset.Fields = splitList(value)
+		var unknown []string
+		for _, field := range fieldset.Fields {
+			if _, ok := allowedFields[field]; !ok {
+				unknown = append(unknown, field)
+			}
+		}
+		switch {
+		case len(fieldset.Fields) == 0:
+			fieldErrors["fields"] = "must list at least one field"
+		case len(unknown) > 0:
+			fieldErrors["fields"] = "unknown fields: " + strings.Join(unknown, ", ")
+		}
+	}
+
+	if value, ok := c.GetQuery("include"); ok {
+		fieldset.Includes = splitList(value)
+		var unknown []string
+		for _, include := range fieldset.Includes {
+			if !contains(allowedIncludes, include) {
+				unknown = append(unknown, include)
+			}
+		}
+		switch {
+		case len(fieldset.Includes) == 0:
+			fieldErrors["include"] = "must list at least one resource"
+		case len(unknown) > 0:
+			fieldErrors["include"] = "must be one of " + strings.Join(allowedIncludes, ", ")
+		}
+	}
+
+	return fieldset
+}
+
+// splitList 拆分逗号分隔的列表，去掉空白和重复项并排序，相同的选择得到相同的缓存键
+func splitList(value string) []string {
+	seen := make(map[string]bool)
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" || seen[item] {
+			continue
+		}
+		seen[item] = true
+		items = append(items, item)
+	}
+	sort.Strings(items)
+	return items
+}
+
+// sparse 是否使用了字段选择或嵌入，没有使用时返回原来的完整响应
+func (f Fieldset) sparse() bool {
+	return len(f.Fields) > 0 || len(f.Includes) > 0
+}
+
+// hasField 是否需要返回字段
+func (f Fieldset) hasField(name string) bool {
+	return len(f.Fields) == 0 || contains(f.Fields, name)
+}
+
+// includes 是否需要嵌入关联资源
+func (f Fieldset) includes(name string) bool {
+	return contains(f.Includes, name)
+}
+
+// cacheKey 生成字段选择对应的缓存键，没有选择时为空字符串
+func (f Fieldset) cacheKey() string {
+	values := url.Values{}
+	if len(f.Fields) > 0 {
+		values.Set("fields", strings.Join(f.Fields, ","))
+	}
+	if len(f.Includes) > 0 {
+		values.Set("include", strings.Join(f.Includes, ","))
+	}
+	return values.Encode()
+}
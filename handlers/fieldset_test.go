@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func TestParseFieldset(t *testing.T) {
+	tests := []struct {
+		query    string
+		fields   []string
+		includes []string
+		errors   []string
+		cacheKey string
+	}{
+		{"", nil, nil, nil, ""},
+		{"?fields=title,id", []string{"id", "title"}, nil, nil, "fields=id%2Ctitle"},
+		{"?fields=%20title%20,%20id,title,", []string{"id", "title"}, nil, nil, "fields=id%2Ctitle"},
+		{"?include=comments,author", nil, []string{"author", "comments"}, nil, "include=author%2Ccomments"},
+		{"?fields=title&include=author", []string{"title"}, []string{"author"}, nil, "fields=title&include=author"},
+		{"?fields=", nil, nil, []string{"fields"}, ""},
+		{"?fields=title,password", []string{"password", "title"}, nil, []string{"fields"}, ""},
+		{"?include=tags", nil, []string{"tags"}, []string{"include"}, ""},
+		{"?fields=,&include=,", nil, nil, []string{"fields", "include"}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest(http.MethodGet, "/"+tt.query, nil)
+			fieldErrors := gin.H{}
+			fieldset := parseFieldset(c, postFields, postIncludes, fieldErrors)
+
+			var keys []string
+			for key := range fieldErrors {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(fieldset.Fields, tt.fields) || !reflect.DeepEqual(fieldset.Includes, tt.includes) || !reflect.DeepEqual(keys, tt.errors) {
+				t.Errorf("fieldset = %v %v, errors %v", fieldset.Fields, fieldset.Includes, fieldErrors)
+			}
+			if len(keys) == 0 && fieldset.cacheKey() != tt.cacheKey {
+				t.Errorf("cacheKey = %q, want %q", fieldset.cacheKey(), tt.cacheKey)
+			}
+		})
+	}
+}
+
+func TestPostFieldsets(t *testing.T) {
+	testutil.Setup(t)
+	db := config.GetDB()
+	alice := testutil.CreateUser(t, "alice")
+	bob := testutil.CreateUser(t, "bob")
+	post := testutil.CreatePost(t, alice, "Post", func(p *models.Post) { p.Content = "# Title\n\n**Bold** text" })
+	for i := 1; i <= maxEmbeddedComments+2; i++ {
+		db.Create(&models.Comment{Content: fmt.Sprintf("comment %d", i), UserID: bob.ID, PostID: post.ID})
+	}
+	// 已删除的评论不嵌入
+	db.Where("content = ?", fmt.Sprintf("comment %d", maxEmbeddedComments+2)).Delete(&models.Comment{})
+
+	r := gin.New()
+	r.GET("/api/posts", GetPosts)
+	r.GET("/api/posts/:id", GetPost)
+
+	keys := func(item map[string]any) string {
+		var names []string
+		for name := range item {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		keys  string
+		check func(t *testing.T, item map[string]any)
+	}{
+		{"selected fields", "/api/posts/%d?fields=title,excerpt", "excerpt,title", func(t *testing.T, item map[string]any) {
+			if item["excerpt"] != "Title Bold text" {
+				t.Errorf("excerpt = %q", item["excerpt"])
+			}
+		}},
+		{"embedded author", "/api/posts/%d?fields=id&include=author", "author,id", func(t *testing.T, item map[string]any) {
+			if author := item["author"].(map[string]any); author["username"] != "alice" {
+				t.Errorf("author = %v", author)
+			}
+		}},
+		{"embedded comments", "/api/posts/%d?fields=id&include=comments", "comments,id", func(t *testing.T, item map[string]any) {
+			comments := item["comments"].([]any)
+			first := comments[0].(map[string]any)
+			if len(comments) != maxEmbeddedComments || first["content"] != fmt.Sprintf("comment %d", maxEmbeddedComments+1) || first["username"] != "bob" {
+				t.Errorf("comments = %v", comments)
+			}
+		}},
+		{"list with fields", "/api/posts?fields=id,comment_count", "comment_count,id", func(t *testing.T, item map[string]any) {
+			if item["id"] != float64(post.ID) {
+				t.Errorf("id = %v, want %d", item["id"], post.ID)
+			}
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if strings.Contains(path, "%d") {
+				path = fmt.Sprintf(path, post.ID)
+			}
+			w := testutil.Request(t, r, http.MethodGet, path, "", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			data := testutil.Decode(t, w)["data"].(map[string]any)
+			item := data
+			if posts, ok := data["posts"].([]any); ok {
+				item = posts[0].(map[string]any)
+			}
+			if got := keys(item); got != tt.keys {
+				t.Errorf("keys = %s, want %s", got, tt.keys)
+			}
+			tt.check(t, item)
+			// 选择字段的响应不使用文章版本作为ETag，由缓存中间件根据内容生成
+			if w.Header().Get("ETag") != "" {
+				t.Errorf("ETag = %q", w.Header().Get("ETag"))
+			}
+		})
+	}
+
+	w := testutil.Request(t, r, http.MethodGet, fmt.Sprintf("/api/posts/%d?fields=password", post.ID), "", nil)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("unknown field status = %d, want 400", w.Code)
+	}
+}
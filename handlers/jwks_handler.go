@@ -0,0 +1,14 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+)
+
+// GetJWKS 获取JWT公钥集合 (JWKS)
+// 按RFC 7517格式直接返回，不使用通用响应结构
+func GetJWKS(c *gin.Context) {
+	c.JSON(http.StatusOK, config.JWTKeys().JWKS())
+}
@@ -0,0 +1,11 @@
+package handlers
+
+import "time"
+
+// IdentityResponse 外部身份响应
+type IdentityResponse struct {
+	ID        uint      `json:"id"`
+	Provider  string    `json:"provider"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
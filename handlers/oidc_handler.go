@@ -0,0 +1,418 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/oidc"
+	"github.com/test/blog/utils"
+	"gorm.io/gorm"
+)
+
+// invalidUsernameChars 用户名中不允许的字符
+var invalidUsernameChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+const (
+	// oidcBindingCookie 把授权请求绑定到发起它的浏览器，防止登录CSRF和绑定劫持
+	oidcBindingCookie = "oidc_binding"
+	// oidcCookiePath 只在OIDC登录和回调时发送
+	oidcCookiePath = "/api/auth/oidc/"
+)
+
+// OIDCLogin 跳转到身份提供方进行登录
+func OIDCLogin(c *gin.Context) {
+	startOIDCAuth(c, 0)
+}
+
+// LinkIdentity 发起外部身份绑定，返回授权地址
+func LinkIdentity(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "User not authenticated",
+		})
+		return
+	}
+	startOIDCAuth(c, userID.(uint))
+}
+
+// startOIDCAuth 发起授权请求，linkUserID为0表示登录，否则表示绑定到该用户
+func startOIDCAuth(c *gin.Context, linkUserID uint) {
+	provider, ok := oidc.GetProvider(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Unknown identity provider",
+		})
+		return
+	}
+
+	codeVerifier, err := oidc.RandomString()
+	if err != nil {
+		utils.LogError("oidc code verifier generation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to start authorization",
+		})
+		return
+	}
+	nonce, err := oidc.RandomString()
+	if err != nil {
+		utils.LogError("oidc nonce generation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to start authorization",
+		})
+		return
+	}
+	binding, err := oidc.RandomString()
+	if err != nil {
+		utils.LogError("oidc binding generation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to start authorization",
+		})
+		return
+	}
+
+	state, err := oidc.States.Save(oidc.AuthRequest{
+		Provider:     provider.Name(),
+		CodeVerifier: codeVerifier,
+		Nonce:        nonce,
+		LinkUserID:   linkUserID,
+		Binding:      binding,
+	})
+	if err != nil {
+		utils.LogError("oidc state generation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to start authorization",
+		})
+		return
+	}
+
+	authURL, err := provider.AuthCodeURL(c.Request.Context(), state, nonce, codeVerifier)
+	if err != nil {
+		utils.LogError("oidc discovery error", err)
+		c.JSON(http.StatusBadGateway, gin.H{
+			"success": false,
+			"message": "Identity provider is unavailable",
+		})
+		return
+	}
+
+	// 回调是身份提供方发起的跳转，SameSite=Lax时Cookie仍会随顶层GET请求发送
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oidcBindingCookie, binding, int(oidc.StateTTL/time.Second), oidcCookiePath, "", secureCookie(c), true)
+
+	// 绑定由已登录的API调用发起，无法直接跳转，返回授权地址由客户端打开
+	if linkUserID != 0 {
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Open the authorization URL to link the identity",
+			"data": gin.H{
+				"authorization_url": authURL,
+			},
+		})
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// OIDCCallback 身份提供方授权回调
+func OIDCCallback(c *gin.Context) {
+	provider, ok := oidc.GetProvider(c.Param("provider"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Unknown identity provider",
+		})
+		return
+	}
+
+	if authErr := c.Query("error"); authErr != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Authorization failed: " + authErr,
+		})
+		return
+	}
+
+	// 回调只能由发起授权的浏览器完成，否则攻击者可以让受害者登录攻击者的账号，
+	// 或者把攻击者的外部身份绑定到受害者的账号上
+	binding, _ := c.Cookie(oidcBindingCookie)
+	c.SetCookie(oidcBindingCookie, "", -1, oidcCookiePath, "", secureCookie(c), true)
+
+	authReq, ok := oidc.States.Take(c.Query("state"))
+	if !ok || authReq.Provider != provider.Name() || binding == "" ||
+		subtle.ConstantTimeCompare([]byte(binding), []byte(authReq.Binding)) != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid or expired state",
+		})
+		return
+	}
+
+	claims, err := provider.Exchange(c.Request.Context(), c.Query("code"), authReq.CodeVerifier, authReq.Nonce)
+	if err != nil {
+		utils.LogError("oidc exchange error", err)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Failed to verify identity",
+		})
+		return
+	}
+
+	// 查找已绑定的外部身份
+	var identity models.UserIdentity
+	err = config.GetDB().Where("provider = ? AND subject = ?", provider.Name(), claims.Subject).First(&identity).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.LogError("oidc identity lookup error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to look up identity",
+		})
+		return
+	}
+	found := err == nil
+
+	// 绑定到当前用户
+	if authReq.LinkUserID != 0 {
+		if found {
+			if identity.UserID != authReq.LinkUserID {
+				c.JSON(http.StatusConflict, gin.H{
+					"success": false,
+					"message": "This identity is already linked to another account",
+				})
+				return
+			}
+		} else {
+			identity = models.UserIdentity{
+				UserID:   authReq.LinkUserID,
+				Provider: provider.Name(),
+				Subject:  claims.Subject,
+				Email:    claims.Email,
+			}
+			if err := config.AsConflict(config.GetDB().Create(&identity).Error); err != nil {
+				if respondConflict(c, err) {
+					return
+				}
+				utils.LogError("oidc identity creation error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{
+					"success": false,
+					"message": "Failed to link identity",
+				})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"message": "Identity linked successfully",
+			"data":    toIdentityResponse(identity),
+		})
+		return
+	}
+
+	// 已绑定的外部身份直接登录
+	if found {
+		var user models.User
+		if err := config.GetDB().First(&user, identity.UserID).Error; err != nil {
+			utils.LogError("oidc linked user not found", err)
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Linked account not found",
+			})
+			return
+		}
+		respondLogin(c, "Login successful", user)
+		return
+	}
+
+	// 首次登录，创建账号
+	if claims.Email == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Identity provider did not return an email address",
+		})
+		return
+	}
+
+	// 先检查邮箱是否已验证，未验证的邮箱不能用来探测本地账号是否存在
+	if !claims.EmailVerified {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Email address is not verified by the identity provider",
+		})
+		return
+	}
+
+	// 邮箱已被本地账号使用时，要求用户登录后手动绑定，避免通过外部身份接管账号
+	// 已注销的账号仍然占用邮箱的唯一索引，与注册相同包括已注销的账号
+	var existingUser models.User
+	if err := config.GetDB().Unscoped().Where("email = ?", claims.Email).First(&existingUser).Error; err == nil {
+		if existingUser.DeletedAt.Valid {
+			c.JSON(http.StatusConflict, gin.H{
+				"success": false,
+				"message": "Email already exists",
+			})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "An account with this email already exists, log in and link this identity from your profile",
+		})
+		return
+	}
+
+	username, err := availableUsername(claims)
+	if err != nil {
+		utils.LogError("oidc username generation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to create user",
+		})
+		return
+	}
+
+	// 外部身份创建的账号没有可用的密码，只能通过身份提供方登录
+	randomPassword, err := oidc.RandomString()
+	if err != nil {
+		utils.LogError("oidc password generation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to create user",
+		})
+		return
+	}
+	hashedPassword, err := utils.HashPassword(randomPassword)
+	if err != nil {
+		utils.LogError("password hashing error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to create user",
+		})
+		return
+	}
+
+	user := models.User{
+		Username: username,
+		Password: hashedPassword,
+		Email:    claims.Email,
+		Role:     models.RoleUser,
+	}
+	err = runTx(c, func(tx *gorm.DB) error {
+		if err := tx.Create(&user).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.UserIdentity{
+			UserID:   user.ID,
+			Provider: provider.Name(),
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}).Error
+	})
+	if respondConflict(c, err) {
+		return
+	}
+	if err != nil {
+		utils.LogError("oidc user creation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to create user",
+		})
+		return
+	}
+
+	respondWithToken(c, http.StatusCreated, "User registered successfully", user)
+}
+
+// GetIdentities 获取当前用户绑定的外部身份
+func GetIdentities(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var identities []models.UserIdentity
+	if err := config.GetDB().Where("user_id = ?", userID).Order("created_at").Find(&identities).Error; err != nil {
+		utils.LogError("get identities error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to get identities",
+		})
+		return
+	}
+
+	responses := make([]IdentityResponse, 0, len(identities))
+	for _, identity := range identities {
+		responses = append(responses, toIdentityResponse(identity))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Identities retrieved successfully",
+		"data": gin.H{
+			"identities": responses,
+		},
+	})
+}
+
+// secureCookie 判断Cookie是否只能通过HTTPS发送
+func secureCookie(c *gin.Context) bool {
+	return c.Request.TLS != nil || strings.HasPrefix(config.Get().Site.BaseURL, "https://")
+}
+
+// availableUsername 根据外部身份生成一个未被使用的用户名
+func availableUsername(claims *oidc.Claims) (string, error) {
+	base := claims.PreferredUsername
+	if base == "" {
+		base = strings.SplitN(claims.Email, "@", 2)[0]
+	}
+	base = invalidUsernameChars.ReplaceAllString(base, "")
+	if len(base) > 40 {
+		base = base[:40]
+	}
+	for len(base) < 3 {
+		base += "_"
+	}
+
+	candidate := base
+	for i := 0; i < 10; i++ {
+		var count int64
+		// 已注销的账号仍然占用用户名
+		if err := config.GetDB().Model(&models.User{}).Unscoped().Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+		candidate = fmt.Sprintf("%s_%04d", base, rand.Intn(10000))
+	}
+	return "", errors.New("no available username")
+}
+
+// toIdentityResponse 转换为外部身份响应
+func toIdentityResponse(identity models.UserIdentity) IdentityResponse {
+	return IdentityResponse{
+		ID:        identity.ID,
+		Provider:  identity.Provider,
+		Email:     identity.Email,
+		CreatedAt: identity.CreatedAt,
+	}
+}
@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/oidc"
+	"github.com/test/blog/oidc/oidctest"
+	"github.com/test/blog/testutil"
+)
+
+// oidcFlow 一次进行中的授权：身份提供方回调的参数和浏览器中的Cookie
+type oidcFlow struct {
+	code   string
+	state  string
+	cookie *http.Cookie
+}
+
+func setupOIDC(t *testing.T) (*gin.Engine, *oidctest.Provider) {
+	t.Helper()
+	testutil.Setup(t)
+	idp := oidctest.NewProvider()
+	t.Cleanup(idp.Close)
+	oidc.Register(oidc.NewProvider(idp.Config("mock", "http://blog.test/api/auth/oidc/mock/callback")))
+
+	r := gin.New()
+	r.GET("/api/auth/oidc/:provider/login", OIDCLogin)
+	r.GET("/api/auth/oidc/:provider/callback", OIDCCallback)
+	r.POST("/api/profile/identities/:provider", middleware.AuthMiddleware(), LinkIdentity)
+	return r, idp
+}
+
+// startFlow 发起登录（token为空）或绑定，并让身份提供方签发授权码
+func startFlow(t *testing.T, r *gin.Engine, token string) oidcFlow {
+	t.Helper()
+	var w *httptest.ResponseRecorder
+	var authURL string
+	if token == "" {
+		w = httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/auth/oidc/mock/login", nil))
+		if w.Code != http.StatusFound {
+			t.Fatalf("login status = %d: %s", w.Code, w.Body.String())
+		}
+		authURL = w.Header().Get("Location")
+	} else {
+		w = testutil.Request(t, r, http.MethodPost, "/api/profile/identities/mock", token, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("link status = %d: %s", w.Code, w.Body.String())
+		}
+		authURL = testutil.Decode(t, w)["data"].(map[string]any)["authorization_url"].(string)
+	}
+
+	var flow oidcFlow
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == oidcBindingCookie {
+			flow.cookie = cookie
+		}
+	}
+	if flow.cookie == nil || !flow.cookie.HttpOnly || flow.cookie.SameSite != http.SameSiteLaxMode {
+		t.Fatalf("binding cookie not set correctly: %+v", flow.cookie)
+	}
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	location, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil || resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, location %q", resp.StatusCode, resp.Header.Get("Location"))
+	}
+	flow.code = location.Query().Get("code")
+	flow.state = location.Query().Get("state")
+	return flow
+}
+
+// callback 模拟浏览器带着Cookie回到回调地址
+func callback(r *gin.Engine, code, state string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	q := url.Values{"code": {code}, "state": {state}}
+	req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/mock/callback?"+q.Encode(), nil)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestOIDCLogin(t *testing.T) {
+	r, _ := setupOIDC(t)
+
+	flow := startFlow(t, r, "")
+	w := callback(r, flow.code, flow.state, flow.cookie)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("first login status = %d: %s", w.Code, w.Body.String())
+	}
+	var user models.User
+	if err := config.GetDB().Where("email = ?", "oidc-user@example.com").First(&user).Error; err != nil {
+		t.Fatalf("user was not created: %v", err)
+	}
+
+	// 已绑定的外部身份再次登录，不会重复创建账号
+	flow = startFlow(t, r, "")
+	if w := callback(r, flow.code, flow.state, flow.cookie); w.Code != http.StatusOK {
+		t.Fatalf("second login status = %d: %s", w.Code, w.Body.String())
+	}
+	var count int64
+	config.GetDB().Model(&models.User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("users = %d, want 1", count)
+	}
+
+	// state只能使用一次
+	if w := callback(r, flow.code, flow.state, flow.cookie); w.Code != http.StatusBadRequest {
+		t.Errorf("replayed state status = %d, want 400", w.Code)
+	}
+}
+
+func TestOIDCLink(t *testing.T) {
+	r, idp := setupOIDC(t)
+	alice := testutil.CreateUser(t, "alice")
+	idp.SetUser(oidctest.User{Subject: "alice-sub", Email: "someone-else@example.com", EmailVerified: true})
+
+	flow := startFlow(t, r, testutil.Token(t, alice))
+	if w := callback(r, flow.code, flow.state, flow.cookie); w.Code != http.StatusOK {
+		t.Fatalf("link status = %d: %s", w.Code, w.Body.String())
+	}
+	var identity models.UserIdentity
+	if err := config.GetDB().Where("provider = ? AND subject = ?", "mock", "alice-sub").First(&identity).Error; err != nil || identity.UserID != alice.ID {
+		t.Fatalf("identity not linked to alice: %+v %v", identity, err)
+	}
+
+	// 同一个外部身份不能再绑定到其他账号
+	bob := testutil.CreateUser(t, "bob")
+	flow = startFlow(t, r, testutil.Token(t, bob))
+	if w := callback(r, flow.code, flow.state, flow.cookie); w.Code != http.StatusConflict {
+		t.Errorf("link to another account status = %d, want 409", w.Code)
+	}
+
+	// 绑定后可以用外部身份登录alice的账号
+	flow = startFlow(t, r, "")
+	w := callback(r, flow.code, flow.state, flow.cookie)
+	if w.Code != http.StatusOK {
+		t.Fatalf("login status = %d: %s", w.Code, w.Body.String())
+	}
+	data := testutil.Decode(t, w)["data"].(map[string]any)
+	if data["user"].(map[string]any)["username"] != "alice" {
+		t.Errorf("logged in as %v, want alice", data["user"])
+	}
+}
+
+func TestOIDCCallbackRejects(t *testing.T) {
+	r, idp := setupOIDC(t)
+	testutil.CreateUser(t, "taken", func(u *models.User) { u.Email = "taken@example.com" })
+	gone := testutil.CreateUser(t, "gone", func(u *models.User) { u.Email = "gone@example.com" })
+	config.GetDB().Delete(&gone)
+
+	tests := []struct {
+		name string
+		user *oidctest.User
+		// call 在发起授权后完成回调，可以篡改参数
+		call func(flow oidcFlow) *httptest.ResponseRecorder
+		want int
+	}{
+		{"missing binding cookie", nil, func(f oidcFlow) *httptest.ResponseRecorder {
+			return callback(r, f.code, f.state, nil)
+		}, http.StatusBadRequest},
+		{"cookie from another browser", nil, func(f oidcFlow) *httptest.ResponseRecorder {
+			other := startFlow(t, r, "")
+			return callback(r, f.code, f.state, other.cookie)
+		}, http.StatusBadRequest},
+		{"unknown state", nil, func(f oidcFlow) *httptest.ResponseRecorder {
+			return callback(r, f.code, "forged-state", f.cookie)
+		}, http.StatusBadRequest},
+		// 授权码属于另一次授权，code_verifier对不上
+		{"PKCE mismatch", nil, func(f oidcFlow) *httptest.ResponseRecorder {
+			other := startFlow(t, r, "")
+			return callback(r, other.code, f.state, f.cookie)
+		}, http.StatusUnauthorized},
+		{"unverified email", &oidctest.User{Subject: "unverified", Email: "new@example.com"}, func(f oidcFlow) *httptest.ResponseRecorder {
+			return callback(r, f.code, f.state, f.cookie)
+		}, http.StatusForbidden},
+		// 未验证的邮箱不能用来探测已有账号
+		{"unverified email of existing account", &oidctest.User{Subject: "probe", Email: "taken@example.com"}, func(f oidcFlow) *httptest.ResponseRecorder {
+			return callback(r, f.code, f.state, f.cookie)
+		}, http.StatusForbidden},
+		{"verified email of existing account", &oidctest.User{Subject: "collision", Email: "taken@example.com", EmailVerified: true}, func(f oidcFlow) *httptest.ResponseRecorder {
+			return callback(r, f.code, f.state, f.cookie)
+		}, http.StatusConflict},
+		// 已注销账号的邮箱仍然被占用
+		{"verified email of deleted account", &oidctest.User{Subject: "revived", Email: "gone@example.com", EmailVerified: true}, func(f oidcFlow) *httptest.ResponseRecorder {
+			return callback(r, f.code, f.state, f.cookie)
+		}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user := oidctest.User{Subject: "default-sub", Email: "default@example.com", EmailVerified: true}
+			if tt.user != nil {
+				user = *tt.user
+			}
+			idp.SetUser(user)
+			if w := tt.call(startFlow(t, r, "")); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+
+	var count int64
+	config.GetDB().Model(&models.User{}).Count(&count)
+	if count != 1 {
+		t.Errorf("users = %d, want only the existing one", count)
+	}
+}
+
+func TestOIDCUsernameOfDeletedAccount(t *testing.T) {
+	r, idp := setupOIDC(t)
+	ghost := testutil.CreateUser(t, "ghost")
+	config.GetDB().Delete(&ghost)
+	idp.SetUser(oidctest.User{Subject: "ghost-sub", Email: "new-ghost@example.com", EmailVerified: true, PreferredUsername: "ghost"})
+
+	flow := startFlow(t, r, "")
+	if w := callback(r, flow.code, flow.state, flow.cookie); w.Code != http.StatusCreated {
+		t.Fatalf("login status = %d: %s", w.Code, w.Body.String())
+	}
+	var user models.User
+	if err := config.GetDB().Where("email = ?", "new-ghost@example.com").First(&user).Error; err != nil {
+		t.Fatalf("user was not created: %v", err)
+	}
+	if user.Username == "ghost" {
+		t.Error("new account took the username of a deleted account")
+	}
+}
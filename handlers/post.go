@@ -1,21 +1,26 @@
 package handlers
 
+import "time"
+
 // CreatePostRequest 创建文章请求
 type CreatePostRequest struct {
-	Title   string `json:"title" binding:"required,min=1,max=200"`
-	Content string `json:"content" binding:"required,min=1"`
+	Title         string `json:"title" binding:"required,min=1,max=200"`
+	Content       string `json:"content" binding:"required,min=1"`
+	AttachmentIDs []uint `json:"attachment_ids" binding:"omitempty,max=50"` // 通过 /api/uploads 上传的附件
 }
 
 // UpdatePostRequest 更新文章请求
 type UpdatePostRequest struct {
-	Title   string `json:"title" binding:"required,min=1,max=200"`
-	Content string `json:"content" binding:"required,min=1"`
+	Title         string `json:"title" binding:"required,min=1,max=200"`
+	Content       string `json:"content" binding:"required,min=1"`
+	AttachmentIDs []uint `json:"attachment_ids" binding:"omitempty,max=50"` // 追加关联的附件
 }
 
 // PostResponse 文章响应
 type PostResponse struct {
 	ID        uint   `json:"id"`
 	Title     string `json:"title"`
+	Slug      string `json:"slug"`
 	Content   string `json:"content"`
 	UserID    uint   `json:"user_id"`
 	Username  string `json:"username"`
@@ -30,3 +35,67 @@ type PostListResponse struct {
 	Page  int            `json:"page"`
 	Limit int            `json:"limit"`
 }
+
+// PostListQuery 文章列表的查询参数
+type PostListQuery struct {
+	Page        int
+	Limit       int
+	Sort        string
+	Author      string     // 作者用户名
+	From        *time.Time // 创建时间下限（包含）
+	To          *time.Time // 创建时间上限（不包含）
+	HasComments *bool
+	Fieldset    Fieldset
+}
+
+// postSortOrders 文章列表允许的排序方式，相同时按id排序保证分页稳定
+var postSortOrders = map[string]string{
+	"newest":         "posts.created_at DESC, posts.id DESC",
+	"oldest":         "posts.created_at ASC, posts.id ASC",
+	"most_commented": "posts.comment_count DESC, posts.id DESC",
+	"updated":        "posts.updated_at DESC, posts.id DESC",
+	"title":          "posts.title ASC, posts.id ASC",
+}
+
+// postListParams 文章列表允许的查询参数
+var postListParams = []string{"page", "limit", "sort", "author", "from", "to", "has_comments", "fields", "include"}
+
+// excerptLength 文章摘要长度（字符数）
+const excerptLength = 120
+
+// maxEmbeddedComments include=comments 时每篇文章最多嵌入的评论数，只嵌入最新的评论
+const maxEmbeddedComments = 5
+
+// postFields 文章允许通过 fields 选择的字段及读取的数据库列，excerpt根据内容生成
+var postFields = map[string]string{
+	"id":              "id",
+	"title":           "title",
+	"slug":            "slug",
+	"content":         "content",
+	"excerpt":         "content",
+	"user_id":         "user_id",
+	"version":         "version",
+	"view_count":      "view_count",
+	"comment_count":   "comment_count",
+	"last_comment_at": "last_comment_at",
+	"created_at":      "created_at",
+	"updated_at":      "updated_at",
+}
+
+// postIncludes 文章允许通过 include 嵌入的关联资源
+var postIncludes = []string{"author", "comments"}
+
+// EmbeddedAuthor 嵌入文章的作者
+type EmbeddedAuthor struct {
+	ID       uint   `json:"id"`
+	Username string `json:"username"`
+}
+
+// EmbeddedComment 嵌入文章的评论
+type EmbeddedComment struct {
+	ID        uint      `json:"id"`
+	Content   string    `json:"content"`
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
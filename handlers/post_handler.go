@@ -1,16 +1,30 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 	"github.com/test/blog/config"
 	"github.com/test/blog/models"
 	"github.com/test/blog/utils"
+	"github.com/test/blog/views"
+	"gorm.io/gorm"
 )
 
+// errPostVersionConflict 文章在读取之后已被其他请求修改
+var errPostVersionConflict = errors.New("post version conflict")
+
 // CreatePost 创建文章
 func CreatePost(c *gin.Context) {
 	var req CreatePostRequest
@@ -37,8 +51,35 @@ func CreatePost(c *gin.Context) {
 		Title:   req.Title,
 		Content: req.Content,
 		UserID:  userID.(uint),
+		Version: 1,
 	}
-	if err := config.GetDB().Create(&post).Error; err != nil {
+	var user models.User
+	err := runTx(c, func(tx *gorm.DB) error {
+		if err := post.AssignSlug(tx); err != nil {
+			return err
+		}
+		if err := tx.Create(&post).Error; err != nil {
+			return err
+		}
+		if err := recordRevision(tx, &post, post.UserID, []string{"title", "content"}, nil); err != nil {
+			return err
+		}
+		if err := linkAttachments(tx, post.UserID, post.ID, req.AttachmentIDs); err != nil {
+			return err
+		}
+		return tx.Select("id", "username").First(&user, post.UserID).Error
+	})
+	if errors.Is(err, errInvalidAttachments) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid attachment ids",
+		})
+		return
+	}
+	if respondConflict(c, err) {
+		return
+	}
+	if err != nil {
 		utils.LogError("create post database error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -47,18 +88,17 @@ func CreatePost(c *gin.Context) {
 		return
 	}
 
-	var user models.User
-	if err := config.GetDB().First(&user, userID).Error; err != nil {
-		utils.LogError("get user for post creation", err)
-	}
-
+	invalidatePostCache(c.Request.Context(), post.ID)
+	c.Header("ETag", postETag(post))
 	c.JSON(http.StatusCreated, gin.H{
 		"success": true,
 		"message": "Post created successfully",
 		"data": gin.H{
 			"id":         post.ID,
 			"title":      post.Title,
+			"slug":       post.Slug,
 			"content":    post.Content,
+			"version":    post.Version,
 			"user_id":    post.UserID,
 			"username":   user.Username,
 			"created_at": post.CreatedAt,
@@ -119,10 +159,35 @@ func UpdatePost(c *gin.Context) {
 		return
 	}
 
+	if !checkIfMatch(c, post) {
+		return
+	}
+
+	previous := post
 	post.Title = req.Title
 	post.Content = req.Content
 	post.UpdatedAt = time.Now()
-	if err := config.GetDB().Save(&post).Error; err != nil {
+	err = runTx(c, func(tx *gorm.DB) error {
+		if err := savePostChanges(tx, &post, previous, userID.(uint), nil); err != nil {
+			return err
+		}
+		return linkAttachments(tx, post.UserID, post.ID, req.AttachmentIDs)
+	})
+	if errors.Is(err, errInvalidAttachments) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid attachment ids",
+		})
+		return
+	}
+	if errors.Is(err, errPostVersionConflict) {
+		respondPostConflict(c)
+		return
+	}
+	if respondConflict(c, err) {
+		return
+	}
+	if err != nil {
 		utils.LogError("update post database error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -131,13 +196,17 @@ func UpdatePost(c *gin.Context) {
 		return
 	}
 
+	invalidatePostCache(c.Request.Context(), post.ID)
+	c.Header("ETag", postETag(post))
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Post updated successfully",
 		"data": gin.H{
 			"id":         post.ID,
 			"title":      post.Title,
+			"slug":       post.Slug,
 			"content":    post.Content,
+			"version":    post.Version,
 			"user_id":    post.UserID,
 			"username":   c.GetString("username"),
 			"created_at": post.CreatedAt,
@@ -146,6 +215,201 @@ func UpdatePost(c *gin.Context) {
 	})
 }
 
+// postPatchFields 可以通过PATCH修改的字段及其校验和赋值逻辑，新增可修改字段时在这里注册
+var postPatchFields = map[string]func(post *models.Post, raw json.RawMessage) error{
+	"title": func(post *models.Post, raw json.RawMessage) error {
+		title, err := patchString(raw, 1, 200)
+		if err != nil {
+			return err
+		}
+		post.Title = title
+		return nil
+	},
+	"content": func(post *models.Post, raw json.RawMessage) error {
+		content, err := patchString(raw, 1, 0)
+		if err != nil {
+			return err
+		}
+		post.Content = content
+		return nil
+	},
+}
+
+// PatchPost 部分更新文章，请求体为JSON Merge Patch（RFC 7396），只修改提供的字段
+func PatchPost(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.LogError("patch post invalid id", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid post id",
+		})
+		return
+	}
+
+	contentType := c.ContentType()
+	if contentType != "application/merge-patch+json" && contentType != "application/json" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"success": false,
+			"message": "Content-Type must be application/merge-patch+json",
+		})
+		return
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.NewDecoder(c.Request.Body).Decode(&patch); err != nil || patch == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Request body must be a JSON object",
+		})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError("patch post unauthorized", nil)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var post models.Post
+	if err := config.GetDB().First(&post, postID).Error; err != nil {
+		utils.LogError("patch post not found", err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Post not found",
+		})
+		return
+	}
+
+	if post.UserID != userID.(uint) {
+		utils.LogError("patch post forbidden", nil)
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "You are not the author of this post",
+		})
+		return
+	}
+
+	if !checkIfMatch(c, post) {
+		return
+	}
+
+	// 逐个字段校验，一次返回所有错误
+	previous := post
+	var attachmentIDs []uint
+	fieldErrors := gin.H{}
+	for field, raw := range patch {
+		if field == "attachment_ids" {
+			if err := json.Unmarshal(raw, &attachmentIDs); err != nil {
+				fieldErrors[field] = "must be an array of ids"
+			}
+			continue
+		}
+		apply, ok := postPatchFields[field]
+		if !ok {
+			fieldErrors[field] = "unknown or read-only field"
+			continue
+		}
+		if err := apply(&post, raw); err != nil {
+			fieldErrors[field] = err.Error()
+		}
+	}
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data",
+			"errors":  fieldErrors,
+		})
+		return
+	}
+
+	// 附件包含在文章的响应中，只修改附件时也要增加版本号，但不记录修订版本
+	changed := changedPostFields(previous, post)
+	if len(changed) > 0 || len(attachmentIDs) > 0 {
+		post.UpdatedAt = time.Now()
+		err = runTx(c, func(tx *gorm.DB) error {
+			if len(changed) > 0 {
+				if err := savePostChanges(tx, &post, previous, userID.(uint), nil); err != nil {
+					return err
+				}
+			} else if err := savePostVersioned(tx, &post); err != nil {
+				return err
+			}
+			return linkAttachments(tx, post.UserID, post.ID, attachmentIDs)
+		})
+	}
+	if errors.Is(err, errInvalidAttachments) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid attachment ids",
+		})
+		return
+	}
+	if errors.Is(err, errPostVersionConflict) {
+		respondPostConflict(c)
+		return
+	}
+	if respondConflict(c, err) {
+		return
+	}
+	if err != nil {
+		utils.LogError("patch post database error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to update post",
+		})
+		return
+	}
+
+	if len(changed) > 0 || len(attachmentIDs) > 0 {
+		invalidatePostCache(c.Request.Context(), post.ID)
+	}
+	if changed == nil {
+		changed = []string{}
+	}
+	c.Header("ETag", postETag(post))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Post updated successfully",
+		"data": gin.H{
+			"id":             post.ID,
+			"title":          post.Title,
+			"slug":           post.Slug,
+			"content":        post.Content,
+			"version":        post.Version,
+			"user_id":        post.UserID,
+			"username":       c.GetString("username"),
+			"created_at":     post.CreatedAt,
+			"updated_at":     post.UpdatedAt,
+			"changed_fields": changed,
+		},
+	})
+}
+
+// patchString 解析合并补丁中的字符串字段，null表示删除字段，必填字段不允许
+// maxLen为0表示不限制长度
+func patchString(raw json.RawMessage, minLen, maxLen int) (string, error) {
+	var value *string
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return "", errors.New("must be a string")
+	}
+	if value == nil {
+		return "", errors.New("cannot be removed")
+	}
+	n := utf8.RuneCountInString(*value)
+	if n < minLen {
+		return "", fmt.Errorf("must be at least %d characters", minLen)
+	}
+	if maxLen > 0 && n > maxLen {
+		return "", fmt.Errorf("must be at most %d characters", maxLen)
+	}
+	return *value, nil
+}
+
 // DeletePost 删除文章
 func DeletePost(c *gin.Context) {
 	postIDStr := c.Param("id")
@@ -188,7 +452,29 @@ func DeletePost(c *gin.Context) {
 		return
 	}
 
-	if err := config.GetDB().Delete(&post).Error; err != nil {
+	if !checkIfMatch(c, post) {
+		return
+	}
+
+	// 只删除读取时的版本，避免删除其他人刚刚更新的内容；评论随文章一起进入回收站
+	err = runTx(c, func(tx *gorm.DB) error {
+		now := time.Now()
+		result := tx.Model(&models.Post{}).
+			Where("id = ? AND version = ?", post.ID, post.Version).
+			UpdateColumn("deleted_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errPostVersionConflict
+		}
+		return softDeletePostComments(tx, []uint{post.ID}, now)
+	})
+	if errors.Is(err, errPostVersionConflict) {
+		respondPostConflict(c)
+		return
+	}
+	if err != nil {
 		utils.LogError("delete post database error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"success": false,
@@ -197,6 +483,7 @@ func DeletePost(c *gin.Context) {
 		return
 	}
 
+	invalidatePostCache(c.Request.Context(), post.ID)
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"message": "Post deleted successfully",
@@ -205,46 +492,312 @@ func DeletePost(c *gin.Context) {
 
 // GetPosts 获取文章列表
 func GetPosts(c *gin.Context) {
-	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
-	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	if page < 1 {
-		page = 1
-	}
-	if limit < 1 || limit > 100 {
-		limit = 10
-	}
-	offset := (page - 1) * limit
-
-	var posts []models.Post
-	var total int64
-	if err := config.GetDB().Model(&models.Post{}).Count(&total).Error; err != nil {
-		utils.LogError("get posts count error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
+	query, fieldErrors := parsePostListQuery(c)
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
 			"success": false,
-			"message": "Failed to get posts count",
+			"message": "Invalid query parameters",
+			"errors":  fieldErrors,
 		})
 		return
 	}
 
-	if err := config.GetDB().Preload("User").Order("created_at desc").Offset(offset).Limit(limit).Find(&posts).Error; err != nil {
-		utils.LogError("get posts list error", err)
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"success": false,
-			"message": "Failed to get posts",
+	key := versionedCacheKey(c.Request.Context(), postListNamespace, query.cacheKey())
+	serveCached(c, key, func() (cachedResponse, error) {
+		var posts []models.Post
+		var total int64
+		if err := query.filter(config.GetDB().Model(&models.Post{})).Count(&total).Error; err != nil {
+			utils.LogError("get posts count error", err)
+			return cachedResponse{}, utils.NewInternalError("Failed to get posts count")
+		}
+
+		db := config.GetDB()
+		if query.Fieldset.sparse() {
+			db = selectPostColumns(db, query.Fieldset)
+		} else {
+			db = db.Preload("User")
+		}
+		err := query.filter(db).
+			Order(postSortOrders[query.Sort]).
+			Offset((query.Page - 1) * query.Limit).
+			Limit(query.Limit).
+			Find(&posts).Error
+		if err != nil {
+			utils.LogError("get posts list error", err)
+			return cachedResponse{}, utils.NewInternalError("Failed to get posts")
+		}
+
+		var data interface{} = posts
+		if query.Fieldset.sparse() {
+			if data, err = renderPosts(posts, query.Fieldset); err != nil {
+				utils.LogError("render posts error", err)
+				return cachedResponse{}, utils.NewInternalError("Failed to get posts")
+			}
+		}
+
+		body, err := json.Marshal(gin.H{
+			"success": true,
+			"message": "Posts retrieved successfully",
+			"data": gin.H{
+				"posts": data,
+				"total": total,
+				"page":  query.Page,
+				"limit": query.Limit,
+			},
 		})
-		return
+		return cachedResponse{Body: body}, err
+	})
+}
+
+// selectPostColumns 只读取字段选择需要的列，嵌入作者时预加载作者的用户名
+func selectPostColumns(db *gorm.DB, fieldset Fieldset) *gorm.DB {
+	columns := []string{"posts.id"}
+	seen := map[string]bool{"id": true}
+	add := func(column string) {
+		if !seen[column] {
+			seen[column] = true
+			columns = append(columns, "posts."+column)
+		}
 	}
+	for field, column := range postFields {
+		if fieldset.hasField(field) {
+			add(column)
+		}
+	}
+	if fieldset.includes("author") {
+		add("user_id")
+		db = db.Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Select("id", "username")
+		})
+	}
+	sort.Strings(columns[1:])
+	return db.Select(columns)
+}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "Posts retrieved successfully",
-		"data": gin.H{
-			"posts": posts,
-			"total": total,
-			"page":  page,
-			"limit": limit,
-		},
-	})
+// renderPosts 按字段选择生成文章响应，并嵌入请求的关联资源
+// 嵌入作者时文章需要已经预加载User
+func renderPosts(posts []models.Post, fieldset Fieldset) ([]gin.H, error) {
+	var comments map[uint][]EmbeddedComment
+	if fieldset.includes("comments") && len(posts) > 0 {
+		ids := make([]uint, 0, len(posts))
+		for _, post := range posts {
+			ids = append(ids, post.ID)
+		}
+		var err error
+		if comments, err = embeddedComments(ids); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]gin.H, 0, len(posts))
+	for _, post := range posts {
+		item := gin.H{}
+		for field := range postFields {
+			if fieldset.hasField(field) {
+				item[field] = postFieldValue(post, field)
+			}
+		}
+		if fieldset.includes("author") {
+			item["author"] = EmbeddedAuthor{ID: post.User.ID, Username: post.User.Username}
+		}
+		if fieldset.includes("comments") {
+			postComments := comments[post.ID]
+			if postComments == nil {
+				postComments = []EmbeddedComment{}
+			}
+			item["comments"] = postComments
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// postFieldValue 读取文章字段的值
+func postFieldValue(post models.Post, field string) interface{} {
+	switch field {
+	case "id":
+		return post.ID
+	case "title":
+		return post.Title
+	case "slug":
+		return post.Slug
+	case "content":
+		return post.Content
+	case "excerpt":
+		return utils.Summarize(post.Content, excerptLength)
+	case "user_id":
+		return post.UserID
+	case "version":
+		return post.Version
+	case "view_count":
+		return post.ViewCount
+	case "comment_count":
+		return post.CommentCount
+	case "last_comment_at":
+		return post.LastCommentAt
+	case "created_at":
+		return post.CreatedAt
+	case "updated_at":
+		return post.UpdatedAt
+	}
+	return nil
+}
+
+// embeddedComments 获取每篇文章最新的maxEmbeddedComments条评论
+// 通过统计更新的评论数在一次查询中限制每篇文章的条数
+func embeddedComments(postIDs []uint) (map[uint][]EmbeddedComment, error) {
+	var comments []models.Comment
+	err := config.GetDB().
+		Preload("User", func(db *gorm.DB) *gorm.DB {
+			return db.Unscoped().Select("id", "username")
+		}).
+		Where("comments.post_id IN ?", postIDs).
+		Where("(SELECT COUNT(*) FROM comments AS newer WHERE newer.post_id = comments.post_id AND newer.deleted_at IS NULL AND newer.id > comments.id) < ?", maxEmbeddedComments).
+		Order("comments.post_id, comments.id DESC").
+		Find(&comments).Error
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[uint][]EmbeddedComment)
+	for _, comment := range comments {
+		result[comment.PostID] = append(result[comment.PostID], EmbeddedComment{
+			ID:        comment.ID,
+			Content:   comment.Content,
+			UserID:    comment.UserID,
+			Username:  comment.User.Username,
+			CreatedAt: comment.CreatedAt,
+		})
+	}
+	return result, nil
+}
+
+// parsePostListQuery 解析文章列表的查询参数，未知的参数和无效的值记录到返回的错误中
+func parsePostListQuery(c *gin.Context) (PostListQuery, gin.H) {
+	query := PostListQuery{Page: 1, Limit: 10, Sort: "newest"}
+	fieldErrors := gin.H{}
+
+	for name := range c.Request.URL.Query() {
+		if !contains(postListParams, name) {
+			fieldErrors[name] = "unknown parameter"
+		}
+	}
+
+	if value := c.Query("page"); value != "" {
+		page, err := strconv.Atoi(value)
+		if err != nil || page < 1 {
+			fieldErrors["page"] = "must be a positive integer"
+		}
+		query.Page = page
+	}
+	if value := c.Query("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > 100 {
+			fieldErrors["limit"] = "must be an integer between 1 and 100"
+		}
+		query.Limit = limit
+	}
+	if value := c.Query("sort"); value != "" {
+		if _, ok := postSortOrders[value]; !ok {
+			fieldErrors["sort"] = "must be one of newest, oldest, most_commented, updated, title"
+		}
+		query.Sort = value
+	}
+	query.Author = c.Query("author")
+
+	if value := c.Query("from"); value != "" {
+		from, err := parseDateParam(value, false)
+		if err != nil {
+			fieldErrors["from"] = err.Error()
+		}
+		query.From = &from
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := parseDateParam(value, true)
+		if err != nil {
+			fieldErrors["to"] = err.Error()
+		}
+		query.To = &to
+	}
+	if query.From != nil && query.To != nil && fieldErrors["from"] == nil && fieldErrors["to"] == nil && !query.From.Before(*query.To) {
+		fieldErrors["to"] = "must be after from"
+	}
+
+	if value := c.Query("has_comments"); value != "" {
+		hasComments, err := strconv.ParseBool(value)
+		if err != nil {
+			fieldErrors["has_comments"] = "must be true or false"
+		}
+		query.HasComments = &hasComments
+	}
+
+	query.Fieldset = parseFieldset(c, postFields, postIncludes, fieldErrors)
+
+	return query, fieldErrors
+}
+
+// parseDateParam 解析日期参数，支持 2006-01-02 和 RFC3339 格式
+// 只有日期的上限参数表示包含当天，返回第二天零点
+func parseDateParam(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	t, err := time.ParseInLocation("2006-01-02", value, time.Local)
+	if err != nil {
+		return time.Time{}, errors.New("must be a date (2006-01-02) or RFC3339 time")
+	}
+	if endOfDay {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
+
+// filter 为查询添加筛选条件
+func (q PostListQuery) filter(db *gorm.DB) *gorm.DB {
+	if q.Author != "" {
+		db = db.Where("posts.user_id IN (?)", config.GetDB().Model(&models.User{}).Select("id").Where("username = ?", q.Author))
+	}
+	if q.From != nil {
+		db = db.Where("posts.created_at >= ?", *q.From)
+	}
+	if q.To != nil {
+		db = db.Where("posts.created_at < ?", *q.To)
+	}
+	if q.HasComments != nil {
+		if *q.HasComments {
+			db = db.Where("posts.comment_count > 0")
+		} else {
+			db = db.Where("posts.comment_count = 0")
+		}
+	}
+	return db
+}
+
+// cacheKey 根据规范化后的参数生成缓存键
+func (q PostListQuery) cacheKey() string {
+	values := url.Values{}
+	values.Set("page", strconv.Itoa(q.Page))
+	values.Set("limit", strconv.Itoa(q.Limit))
+	values.Set("sort", q.Sort)
+	if q.Author != "" {
+		values.Set("author", q.Author)
+	}
+	if q.From != nil {
+		values.Set("from", q.From.UTC().Format(time.RFC3339))
+	}
+	if q.To != nil {
+		values.Set("to", q.To.UTC().Format(time.RFC3339))
+	}
+	if q.HasComments != nil {
+		values.Set("has_comments", strconv.FormatBool(*q.HasComments))
+	}
+	if len(q.Fieldset.Fields) > 0 {
+		values.Set("fields", strings.Join(q.Fieldset.Fields, ","))
+	}
+	if len(q.Fieldset.Includes) > 0 {
+		values.Set("include", strings.Join(q.Fieldset.Includes, ","))
+	}
+	return values.Encode()
 }
 
 // GetPost 获取单个文章
@@ -260,19 +813,227 @@ func GetPost(c *gin.Context) {
 		return
 	}
 
+	fieldErrors := gin.H{}
+	fieldset := parseFieldset(c, postFields, postIncludes, fieldErrors)
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid query parameters",
+			"errors":  fieldErrors,
+		})
+		return
+	}
+
+	suffix := "body"
+	if fieldset.sparse() {
+		suffix += "?" + fieldset.cacheKey()
+	}
+	key := versionedCacheKey(c.Request.Context(), postNamespace(uint(postID)), suffix)
+	serveCached(c, key, func() (cachedResponse, error) {
+		var post models.Post
+		if err := config.GetDB().Preload("User").Preload("Attachments").First(&post, postID).Error; err != nil {
+			utils.LogError("get post not found", err)
+			return cachedResponse{}, utils.NewNotFoundError("Post not found")
+		}
+		for i := range post.Attachments {
+			setAttachmentURLs(&post.Attachments[i])
+		}
+		data, err := postDetailData(post, fieldset)
+		if err != nil {
+			utils.LogError("render post error", err)
+			return cachedResponse{}, utils.NewInternalError("Failed to get post")
+		}
+
+		body, err := json.Marshal(gin.H{
+			"success": true,
+			"message": "Post retrieved successfully",
+			"data":    data,
+		})
+		if fieldset.sparse() {
+			// 嵌入的评论等内容变化时文章版本不变，由缓存中间件根据响应内容生成ETag
+			return cachedResponse{Body: body}, err
+		}
+		return cachedResponse{ETag: postBodyETag(post, body), LastModified: post.UpdatedAt, Body: body}, err
+	})
+
+	// 成功返回文章时记录浏览，304表示访客重新打开了文章，同样计数
+	if status := c.Writer.Status(); status == http.StatusOK || status == http.StatusNotModified {
+		views.Default().Record(uint(postID), visitorID(c))
+	}
+}
+
+// GetPostBySlug 根据slug获取文章，旧slug重定向到当前slug
+func GetPostBySlug(c *gin.Context) {
+	slug := c.Param("slug")
+	fieldErrors := gin.H{}
+	fieldset := parseFieldset(c, postFields, postIncludes, fieldErrors)
+	if len(fieldErrors) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid query parameters",
+			"errors":  fieldErrors,
+		})
+		return
+	}
+
 	var post models.Post
-	if err := config.GetDB().Preload("User").First(&post, postID).Error; err != nil {
-		utils.LogError("get post not found", err)
+	err := config.GetDB().Preload("User").Preload("Attachments").Where("slug = ?", slug).First(&post).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		var old models.PostSlug
+		if err := config.GetDB().Where("slug = ?", slug).First(&old).Error; err == nil {
+			var current models.Post
+			if err := config.GetDB().Select("id", "slug").First(&current, old.PostID).Error; err == nil {
+				location := "/api/posts/by-slug/" + current.Slug
+				if c.Request.URL.RawQuery != "" {
+					location += "?" + c.Request.URL.RawQuery
+				}
+				c.Redirect(http.StatusMovedPermanently, location)
+				return
+			}
+		}
+	}
+	if err != nil {
+		utils.LogError("get post by slug not found", err)
 		c.JSON(http.StatusNotFound, gin.H{
 			"success": false,
 			"message": "Post not found",
 		})
 		return
 	}
+	views.Default().Record(post.ID, visitorID(c))
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"message": "Post retrieved successfully",
-		"data":    post,
+	for i := range post.Attachments {
+		setAttachmentURLs(&post.Attachments[i])
+	}
+	data, err := postDetailData(post, fieldset)
+	var body []byte
+	if err == nil {
+		body, err = json.Marshal(gin.H{
+			"success": true,
+			"message": "Post retrieved successfully",
+			"data":    data,
+		})
+	}
+	if err != nil {
+		utils.LogError("render post error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to get post",
+		})
+		return
+	}
+
+	if !fieldset.sparse() {
+		etag := postBodyETag(post, body)
+		c.Header("ETag", etag)
+		c.Header("Last-Modified", post.UpdatedAt.UTC().Format(http.TimeFormat))
+		if notModified(c, etag, post.UpdatedAt) {
+			c.Status(http.StatusNotModified)
+			return
+		}
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+}
+
+// postDetailData 单个文章响应的data，使用字段选择时只返回选择的字段和嵌入的资源
+func postDetailData(post models.Post, fieldset Fieldset) (interface{}, error) {
+	if !fieldset.sparse() {
+		return post, nil
+	}
+	items, err := renderPosts([]models.Post{post}, fieldset)
+	if err != nil {
+		return nil, err
+	}
+	return items[0], nil
+}
+
+// postETag 根据文章版本生成ETag，用于写操作的响应和If-Match
+func postETag(post models.Post) string {
+	return fmt.Sprintf(`"post-%d-v%d"`, post.ID, post.Version)
+}
+
+// postBodyETag 读取文章时根据响应内容生成ETag
+// 作者信息、附件地址等变化时文章版本不变，只用版本号做条件请求会返回过期的304；
+// ETag中仍然包含版本号，客户端可以直接用于If-Match
+func postBodyETag(post models.Post, body []byte) string {
+	sum := sha256.Sum256(body)
+	return fmt.Sprintf(`"post-%d-v%d-%s"`, post.ID, post.Version, hex.EncodeToString(sum[:8]))
+}
+
+// checkIfMatch 检查If-Match请求头，与当前版本不一致时返回412，失败时已写入响应
+// 读取文章返回的ETag只比较其中的版本号；未提供If-Match时不做检查，更新仍然只会应用到读取时的版本
+func checkIfMatch(c *gin.Context, post models.Post) bool {
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		return true
+	}
+
+	etag := postETag(post)
+	bodyPrefix := strings.TrimSuffix(etag, `"`) + "-"
+	for _, candidate := range strings.Split(ifMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == etag || candidate == "*" || strings.HasPrefix(candidate, bodyPrefix) {
+			return true
+		}
+	}
+
+	c.Header("ETag", etag)
+	respondPostConflict(c)
+	return false
+}
+
+// savePostVersioned 使用条件更新保存文章，只有数据库中的版本仍是读取时的版本才会更新
+// 更新成功后版本号加1；版本不一致时返回errPostVersionConflict
+func savePostVersioned(tx *gorm.DB, post *models.Post) error {
+	result := tx.Model(&models.Post{}).
+		Where("id = ? AND version = ?", post.ID, post.Version).
+		Updates(map[string]interface{}{
+			"title":      post.Title,
+			"slug":       post.Slug,
+			"content":    post.Content,
+			"updated_at": post.UpdatedAt,
+			"version":    gorm.Expr("version + 1"),
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errPostVersionConflict
+	}
+	post.Version++
+	return nil
+}
+
+// softDeletePostComments 将文章下的评论随文章一起软删除
+// 评论的删除时间与文章相同，恢复文章时据此只恢复随文章删除的评论
+func softDeletePostComments(tx *gorm.DB, postIDs []uint, deletedAt time.Time) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+	err := tx.Model(&models.Comment{}).
+		Where("post_id IN ?", postIDs).
+		UpdateColumn("deleted_at", deletedAt).Error
+	if err != nil {
+		return err
+	}
+	return models.RefreshCommentStats(tx, postIDs...)
+}
+
+// visitorID 根据客户端IP和User-Agent生成访客标识，用于浏览量去重，不保存原始IP
+func visitorID(c *gin.Context) string {
+	sum := sha256.Sum256([]byte(c.ClientIP() + "|" + c.Request.UserAgent()))
+	return hex.EncodeToString(sum[:16])
+}
+
+// respondPostConflict 返回并发修改冲突
+// 客户端通过If-Match声明了前置条件时返回412，否则是读取后被并发修改，返回409
+func respondPostConflict(c *gin.Context) {
+	status := http.StatusConflict
+	if c.GetHeader("If-Match") != "" {
+		status = http.StatusPreconditionFailed
+	}
+	c.JSON(status, gin.H{
+		"success": false,
+		"message": "Post has been modified, fetch the latest version and retry",
 	})
 }
@@ -0,0 +1,401 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func TestGetPostBySlug(t *testing.T) {
+	testutil.Setup(t)
+	alice := testutil.CreateUser(t, "alice")
+	post := testutil.CreatePost(t, alice, "Hello World")
+	post.Title = "Renamed Post"
+	if err := post.AssignSlug(config.GetDB()); err != nil {
+		t.Fatal(err)
+	}
+	config.GetDB().Save(&post)
+
+	r := gin.New()
+	r.GET("/api/posts/by-slug/:slug", GetPostBySlug)
+
+	tests := []struct {
+		name     string
+		path     string
+		want     int
+		location string
+	}{
+		{"current slug", "/api/posts/by-slug/renamed-post", http.StatusOK, ""},
+		{"old slug redirects", "/api/posts/by-slug/hello-world", http.StatusMovedPermanently, "/api/posts/by-slug/renamed-post"},
+		{"query is kept", "/api/posts/by-slug/hello-world?fields=title", http.StatusMovedPermanently, "/api/posts/by-slug/renamed-post?fields=title"},
+		{"unknown slug", "/api/posts/by-slug/missing", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Request(t, r, http.MethodGet, tt.path, "", nil)
+			if w.Code != tt.want || w.Header().Get("Location") != tt.location {
+				t.Fatalf("status = %d, Location %q, want %d %q", w.Code, w.Header().Get("Location"), tt.want, tt.location)
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+			if testutil.Decode(t, w)["data"].(map[string]any)["slug"] != "renamed-post" {
+				t.Errorf("unexpected body %s", w.Body.String())
+			}
+			etag := w.Header().Get("ETag")
+			if !strings.HasPrefix(etag, fmt.Sprintf(`"post-%d-v`, post.ID)) {
+				t.Fatalf("ETag = %q", etag)
+			}
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Header.Set("If-None-Match", etag)
+			w = httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != http.StatusNotModified {
+				t.Errorf("conditional request status = %d, want 304", w.Code)
+			}
+		})
+	}
+}
+
+// withHeader 在请求转给路由之前设置请求头
+func withHeader(h http.Handler, key, value string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if value != "" {
+			req.Header.Set(key, value)
+		}
+		h.ServeHTTP(w, req)
+	})
+}
+
+func TestUpdatePostIfMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		ifMatch  string
+		want     int
+		wantETag string
+	}{
+		{"no precondition", "", http.StatusOK, `"post-1-v2"`},
+		{"current version", `"post-1-v1"`, http.StatusOK, `"post-1-v2"`},
+		{"one of several", `"post-1-v0", "post-1-v1"`, http.StatusOK, `"post-1-v2"`},
+		{"wildcard", "*", http.StatusOK, `"post-1-v2"`},
+		// 读取文章返回的ETag只比较版本号
+		{"read ETag", `"post-1-v1-0123456789abcdef"`, http.StatusOK, `"post-1-v2"`},
+		{"stale version", `"post-1-v0"`, http.StatusPreconditionFailed, `"post-1-v1"`},
+		{"stale read ETag", `"post-1-v0-0123456789abcdef"`, http.StatusPreconditionFailed, `"post-1-v1"`},
+		{"version prefix of another version", `"post-1-v11"`, http.StatusPreconditionFailed, `"post-1-v1"`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Setup(t)
+			alice := testutil.CreateUser(t, "alice")
+			post := testutil.CreatePost(t, alice, "Post")
+			r := gin.New()
+			r.PUT("/api/posts/:id", middleware.AuthMiddleware(), UpdatePost)
+
+			h := withHeader(r, "If-Match", tt.ifMatch)
+			w := testutil.Request(t, h, http.MethodPut, fmt.Sprintf("/api/posts/%d", post.ID), testutil.Token(t, alice), gin.H{"title": "Post", "content": "changed"})
+			if w.Code != tt.want || w.Header().Get("ETag") != tt.wantETag {
+				t.Errorf("status = %d, ETag %q, want %d %q: %s", w.Code, w.Header().Get("ETag"), tt.want, tt.wantETag, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRespondPostConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		ifMatch string
+		want    int
+	}{
+		// 声明了前置条件，按RFC 9110返回412
+		{"with If-Match", `"post-1-v1"`, http.StatusPreconditionFailed},
+		// 没有前置条件，读取后被并发修改
+		{"without If-Match", "", http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPut, "/api/posts/1", nil)
+			if tt.ifMatch != "" {
+				c.Request.Header.Set("If-Match", tt.ifMatch)
+			}
+			respondPostConflict(c)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
+
+func TestSavePostVersioned(t *testing.T) {
+	testutil.Setup(t)
+	alice := testutil.CreateUser(t, "alice")
+	post := testutil.CreatePost(t, alice, "Post")
+	stale := post
+
+	post.Content = "first"
+	if err := savePostVersioned(config.GetDB(), &post); err != nil || post.Version != 2 {
+		t.Fatalf("save = %v, version %d", err, post.Version)
+	}
+	// 基于旧版本的修改不能覆盖已经提交的修改
+	stale.Content = "second"
+	if err := savePostVersioned(config.GetDB(), &stale); !errors.Is(err, errPostVersionConflict) {
+		t.Fatalf("stale save = %v, want errPostVersionConflict", err)
+	}
+	var stored models.Post
+	config.GetDB().First(&stored, post.ID)
+	if stored.Content != "first" || stored.Version != 2 {
+		t.Errorf("stored post = %q v%d", stored.Content, stored.Version)
+	}
+}
+
+func TestPatchPost(t *testing.T) {
+	long := strings.Repeat("长", 201)
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		other       bool
+		want        int
+		changed     string
+		errors      []string
+		title       string
+		content     string
+		version     int
+	}{
+		{name: "title only", body: `{"title":"New"}`, want: http.StatusOK, changed: "[title]", title: "New", content: "Content of Post", version: 2},
+		{name: "both fields", body: `{"title":"New","content":"Body"}`, want: http.StatusOK, changed: "[title content]", title: "New", content: "Body", version: 2},
+		{name: "merge patch content type", contentType: "application/merge-patch+json", body: `{"content":"Body"}`, want: http.StatusOK, changed: "[content]", title: "Post", content: "Body", version: 2},
+		// 值没有变化时不产生新版本
+		{name: "unchanged value", body: `{"title":"Post"}`, want: http.StatusOK, changed: "[]", title: "Post", content: "Content of Post", version: 1},
+		{name: "empty patch", body: `{}`, want: http.StatusOK, changed: "[]", title: "Post", content: "Content of Post", version: 1},
+		// 附件包含在文章的响应中，只修改附件也会产生新版本
+		{name: "attachments only", body: `{"attachment_ids":[1]}`, want: http.StatusOK, changed: "[]", title: "Post", content: "Content of Post", version: 2},
+		{name: "empty title", body: `{"title":""}`, want: http.StatusBadRequest, errors: []string{"title"}},
+		{name: "title too long", body: `{"title":"` + long + `"}`, want: http.StatusBadRequest, errors: []string{"title"}},
+		{name: "null removes a required field", body: `{"content":null}`, want: http.StatusBadRequest, errors: []string{"content"}},
+		{name: "wrong type", body: `{"title":1}`, want: http.StatusBadRequest, errors: []string{"title"}},
+		{name: "read-only field", body: `{"user_id":2}`, want: http.StatusBadRequest, errors: []string{"user_id"}},
+		{name: "all errors at once", body: `{"title":"","version":3,"attachment_ids":"x","content":"ok"}`, want: http.StatusBadRequest, errors: []string{"attachment_ids", "title", "version"}},
+		{name: "array body", body: `["title"]`, want: http.StatusBadRequest},
+		{name: "null body", body: `null`, want: http.StatusBadRequest},
+		{name: "unsupported content type", contentType: "text/plain", body: `{"title":"New"}`, want: http.StatusUnsupportedMediaType},
+		{name: "not the author", body: `{"title":"New"}`, other: true, want: http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Setup(t)
+			alice := testutil.CreateUser(t, "alice")
+			bob := testutil.CreateUser(t, "bob")
+			post := testutil.CreatePost(t, alice, "Post")
+			if err := config.GetDB().Create(&models.Attachment{UserID: alice.ID, StorageKey: "image.png", ContentType: "image/png"}).Error; err != nil {
+				t.Fatal(err)
+			}
+			r := gin.New()
+			r.PATCH("/api/posts/:id", middleware.AuthMiddleware(), PatchPost)
+
+			token := testutil.Token(t, alice)
+			if tt.other {
+				token = testutil.Token(t, bob)
+			}
+			h := withHeader(r, "Content-Type", tt.contentType)
+			w := testutil.Request(t, h, http.MethodPatch, fmt.Sprintf("/api/posts/%d", post.ID), token, json.RawMessage(tt.body))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+
+			if tt.errors != nil {
+				fieldErrors, _ := testutil.Decode(t, w)["errors"].(map[string]any)
+				var keys []string
+				for key := range fieldErrors {
+					keys = append(keys, key)
+				}
+				sort.Strings(keys)
+				if !reflect.DeepEqual(keys, tt.errors) {
+					t.Errorf("errors = %v, want fields %v", fieldErrors, tt.errors)
+				}
+			}
+			if tt.want != http.StatusOK {
+				return
+			}
+
+			if changed := fmt.Sprint(testutil.Decode(t, w)["data"].(map[string]any)["changed_fields"]); changed != tt.changed {
+				t.Errorf("changed_fields = %s, want %s", changed, tt.changed)
+			}
+			var stored models.Post
+			config.GetDB().First(&stored, post.ID)
+			if stored.Title != tt.title || stored.Content != tt.content || stored.Version != tt.version {
+				t.Errorf("stored post = %q %q v%d, want %q %q v%d", stored.Title, stored.Content, stored.Version, tt.title, tt.content, tt.version)
+			}
+		})
+	}
+}
+
+func TestGetPostsQuery(t *testing.T) {
+	testutil.Setup(t)
+	db := config.GetDB()
+	alice := testutil.CreateUser(t, "alice")
+	bob := testutil.CreateUser(t, "bob")
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i, p := range []struct {
+		title    string
+		author   models.User
+		comments int
+	}{
+		{"Banana", alice, 0},
+		{"Apple", bob, 3},
+		{"Cherry", alice, 1},
+	} {
+		post := testutil.CreatePost(t, p.author, p.title, func(post *models.Post) {
+			post.CreatedAt = start.AddDate(0, 0, i)
+			// 最早的文章最近被修改
+			post.UpdatedAt = start.AddDate(0, 0, 10-i)
+			post.CommentCount = p.comments
+		})
+		db.Model(&post).UpdateColumns(map[string]any{"created_at": post.CreatedAt, "updated_at": post.UpdatedAt})
+	}
+	r := gin.New()
+	r.GET("/api/posts", GetPosts)
+
+	tests := []struct {
+		query string
+		want  string
+		total float64
+	}{
+		{"", "[Cherry Apple Banana]", 3},
+		{"?sort=oldest", "[Banana Apple Cherry]", 3},
+		{"?sort=most_commented", "[Apple Cherry Banana]", 3},
+		{"?sort=updated", "[Banana Apple Cherry]", 3},
+		{"?sort=title", "[Apple Banana Cherry]", 3},
+		{"?author=alice", "[Cherry Banana]", 2},
+		{"?author=nobody", "[]", 0},
+		{"?has_comments=true&sort=title", "[Apple Cherry]", 2},
+		{"?has_comments=false", "[Banana]", 1},
+		{"?from=2024-01-02T00:00:00Z", "[Cherry Apple]", 2},
+		{"?from=2024-01-02T00:00:00Z&to=2024-01-03T00:00:00Z", "[Apple]", 1},
+		{"?limit=2&page=2", "[Banana]", 3},
+		{"?page=5", "[]", 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := testutil.Request(t, r, http.MethodGet, "/api/posts"+tt.query, "", nil)
+			if w.Code != http.StatusOK {
+				t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+			}
+			data := testutil.Decode(t, w)["data"].(map[string]any)
+			var titles []any
+			for _, post := range data["posts"].([]any) {
+				titles = append(titles, post.(map[string]any)["title"])
+			}
+			if got := fmt.Sprint(titles); got != tt.want {
+				t.Errorf("titles = %s, want %s", got, tt.want)
+			}
+			if data["total"] != tt.total {
+				t.Errorf("total = %v, want %v", data["total"], tt.total)
+			}
+		})
+	}
+}
+
+func TestGetPostsInvalidQuery(t *testing.T) {
+	r := gin.New()
+	r.GET("/api/posts", GetPosts)
+
+	tests := []struct {
+		query  string
+		errors []string
+	}{
+		{"?page=0", []string{"page"}},
+		{"?page=x", []string{"page"}},
+		{"?limit=101", []string{"limit"}},
+		{"?sort=popular", []string{"sort"}},
+		{"?from=yesterday", []string{"from"}},
+		{"?from=2024-02-01&to=2024-01-01", []string{"to"}},
+		{"?has_comments=maybe", []string{"has_comments"}},
+		{"?user_id=1", []string{"user_id"}},
+		{"?fields=password", []string{"fields"}},
+		{"?page=-1&limit=0&order=asc", []string{"limit", "order", "page"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := testutil.Request(t, r, http.MethodGet, "/api/posts"+tt.query, "", nil)
+			if w.Code != http.StatusBadRequest {
+				t.Fatalf("status = %d, want 400", w.Code)
+			}
+			fieldErrors := testutil.Decode(t, w)["errors"].(map[string]any)
+			var keys []string
+			for key := range fieldErrors {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.errors) {
+				t.Errorf("errors = %v, want fields %v", fieldErrors, tt.errors)
+			}
+		})
+	}
+}
+
+func TestParseDateParam(t *testing.T) {
+	tests := []struct {
+		value    string
+		endOfDay bool
+		want     time.Time
+	}{
+		{"2024-01-02", false, time.Date(2024, 1, 2, 0, 0, 0, 0, time.Local)},
+		// 只有日期的上限包含当天
+		{"2024-01-02", true, time.Date(2024, 1, 3, 0, 0, 0, 0, time.Local)},
+		{"2024-01-02T10:00:00+08:00", true, time.Date(2024, 1, 2, 2, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		got, err := parseDateParam(tt.value, tt.endOfDay)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("parseDateParam(%q, %v) = %v, %v, want %v", tt.value, tt.endOfDay, got, err, tt.want)
+		}
+	}
+	if _, err := parseDateParam("02/01/2024", false); err == nil {
+		t.Error("expected an error for an unsupported format")
+	}
+}
+
+// 等价的查询参数使用相同的缓存键
+func TestPostListCacheKey(t *testing.T) {
+	key := func(query string) string {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/api/posts"+query, nil)
+		q, fieldErrors := parsePostListQuery(c)
+		if len(fieldErrors) > 0 {
+			t.Fatalf("%s: %v", query, fieldErrors)
+		}
+		return q.cacheKey()
+	}
+	tests := []struct {
+		a, b string
+		same bool
+	}{
+		{"", "?page=1&limit=10&sort=newest", true},
+		{"?limit=10&page=2", "?page=2&limit=10", true},
+		{"?from=2024-01-02T08:00:00%2B08:00", "?from=2024-01-02T00:00:00Z", true},
+		{"?has_comments=1", "?has_comments=true", true},
+		{"?page=2", "?page=3", false},
+		{"?author=alice", "?author=bob", false},
+	}
+	for _, tt := range tests {
+		if same := key(tt.a) == key(tt.b); same != tt.same {
+			t.Errorf("cacheKey(%q) == cacheKey(%q) is %v, want %v", tt.a, tt.b, same, tt.same)
+		}
+	}
+}
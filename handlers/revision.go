@@ -0,0 +1,16 @@
+package handlers
+
+import "time"
+
+// RevisionResponse 修订版本响应（列表中不包含正文）
+type RevisionResponse struct {
+	ID           uint      `json:"id"`
+	Number       int       `json:"number"`
+	Title        string    `json:"title"`
+	Content      string    `json:"content,omitempty"`
+	EditorID     uint      `json:"editor_id"`
+	Editor       string    `json:"editor"`
+	RestoredFrom *int      `json:"restored_from,omitempty"`
+	Changed      []string  `json:"changed_fields"`
+	CreatedAt    time.Time `json:"created_at"`
+}
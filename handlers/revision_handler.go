@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"gorm.io/gorm"
+)
+
+// diffContextLines 统一格式差异中每处修改前后保留的行数
+const diffContextLines = 3
+
+// GetPostRevisions 获取文章的修订历史（仅作者和管理员）
+func GetPostRevisions(c *gin.Context) {
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	var revisions []models.PostRevision
+	err := config.GetDB().Preload("Editor").
+		Omit("content").
+		Where("post_id = ?", post.ID).
+		Order("number desc").
+		Find(&revisions).Error
+	if err != nil {
+		utils.LogError("get revisions database error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to get revisions",
+		})
+		return
+	}
+
+	responses := make([]RevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		responses = append(responses, toRevisionResponse(revision))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Revisions retrieved successfully",
+		"data":    responses,
+	})
+}
+
+// GetPostRevision 获取单个修订版本的完整内容（仅作者和管理员）
+func GetPostRevision(c *gin.Context) {
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	revision, ok := findRevision(c, post.ID, c.Param("rev"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Revision retrieved successfully",
+		"data":    toRevisionResponse(revision),
+	})
+}
+
+// DiffPostRevisions 比较两个修订版本（仅作者和管理员）
+// 参数 from、to 为版本号，mode 为 unified（按行，默认）或 word（按词）
+func DiffPostRevisions(c *gin.Context) {
+	post, ok := revisionPost(c)
+	if !ok {
+		return
+	}
+
+	mode := c.DefaultQuery("mode", "unified")
+	if mode != "unified" && mode != "word" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid mode, must be unified or word",
+		})
+		return
+	}
+
+	from, ok := findRevision(c, post.ID, c.Query("from"))
+	if !ok {
+		return
+	}
+	to, ok := findRevision(c, post.ID, c.Query("to"))
+	if !ok {
+		return
+	}
+
+	var content interface{}
+	if mode == "word" {
+		content = utils.WordDiff(from.Content, to.Content)
+	} else {
+		content = utils.UnifiedDiff(from.Content, to.Content,
+			fmt.Sprintf("revision-%d", from.Number), fmt.Sprintf("revision-%d", to.Number), diffContextLines)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Diff generated successfully",
+		"data": gin.H{
+			"from":    from.Number,
+			"to":      to.Number,
+			"mode":    mode,
+			"title":   utils.WordDiff(from.Title, to.Title),
+			"content": content,
+		},
+	})
+}
+
+// RestorePostRevision 将文章恢复到指定版本，恢复本身会产生一个新版本（仅作者）
+func RestorePostRevision(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid post id",
+		})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError("restore revision unauthorized", nil)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var post models.Post
+	if err := config.GetDB().First(&post, postID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Post not found",
+		})
+		return
+	}
+	if post.UserID != userID.(uint) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "You are not the author of this post",
+		})
+		return
+	}
+
+	if !checkIfMatch(c, post) {
+		return
+	}
+
+	revision, ok := findRevision(c, post.ID, c.Param("rev"))
+	if !ok {
+		return
+	}
+
+	previous := post
+	post.Title = revision.Title
+	post.Content = revision.Content
+	post.UpdatedAt = time.Now()
+	err = runTx(c, func(tx *gorm.DB) error {
+		return savePostChanges(tx, &post, previous, userID.(uint), &revision.Number)
+	})
+	if errors.Is(err, errPostVersionConflict) {
+		respondPostConflict(c)
+		return
+	}
+	if respondConflict(c, err) {
+		return
+	}
+	if err != nil {
+		utils.LogError("restore revision database error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to restore revision",
+		})
+		return
+	}
+
+	invalidatePostCache(c.Request.Context(), post.ID)
+	c.Header("ETag", postETag(post))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": fmt.Sprintf("Post restored to revision %d", revision.Number),
+		"data": gin.H{
+			"id":         post.ID,
+			"title":      post.Title,
+			"slug":       post.Slug,
+			"content":    post.Content,
+			"version":    post.Version,
+			"user_id":    post.UserID,
+			"username":   c.GetString("username"),
+			"created_at": post.CreatedAt,
+			"updated_at": post.UpdatedAt,
+		},
+	})
+}
+
+// revisionPost 获取路径中的文章并检查当前用户是否可以查看修订历史，失败时已写入响应
+func revisionPost(c *gin.Context) (models.Post, bool) {
+	var post models.Post
+
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid post id",
+		})
+		return post, false
+	}
+
+	if err := config.GetDB().First(&post, postID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Post not found",
+		})
+		return post, false
+	}
+
+	if post.UserID != c.GetUint("user_id") && c.GetString("role") != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "You are not the author of this post",
+		})
+		return post, false
+	}
+
+	return post, true
+}
+
+// findRevision 根据版本号获取修订版本，失败时已写入响应
+func findRevision(c *gin.Context, postID uint, number string) (models.PostRevision, bool) {
+	var revision models.PostRevision
+
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid revision number",
+		})
+		return revision, false
+	}
+
+	if err := config.GetDB().Preload("Editor").Where("post_id = ? AND number = ?", postID, n).First(&revision).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("Revision %d not found", n),
+		})
+		return revision, false
+	}
+
+	return revision, true
+}
+
+// savePostChanges 保存文章的修改并记录修订版本，需要在事务中调用
+// previous为修改前的文章，用于补充初始版本和判断哪些字段发生了变化
+func savePostChanges(tx *gorm.DB, post *models.Post, previous models.Post, editorID uint, restoredFrom *int) error {
+	if err := ensureBaselineRevision(tx, previous); err != nil {
+		return err
+	}
+	if post.Title != previous.Title || post.Slug == "" {
+		if err := post.AssignSlug(tx); err != nil {
+			return err
+		}
+	}
+	if err := savePostVersioned(tx, post); err != nil {
+		return err
+	}
+	return recordRevision(tx, post, editorID, changedPostFields(previous, *post), restoredFrom)
+}
+
+// changedPostFields 比较修改前后的文章，返回发生变化的字段
+func changedPostFields(before, after models.Post) []string {
+	var changed []string
+	if before.Title != after.Title {
+		changed = append(changed, "title")
+	}
+	if before.Content != after.Content {
+		changed = append(changed, "content")
+	}
+	return changed
+}
+
+// recordRevision 将文章当前内容保存为新的修订版本
+func recordRevision(tx *gorm.DB, post *models.Post, editorID uint, changed []string, restoredFrom *int) error {
+	var last int
+	err := tx.Model(&models.PostRevision{}).
+		Where("post_id = ?", post.ID).
+		Select("COALESCE(MAX(number), 0)").
+		Scan(&last).Error
+	if err != nil {
+		return err
+	}
+
+	return tx.Create(&models.PostRevision{
+		PostID:        post.ID,
+		Number:        last + 1,
+		EditorID:      editorID,
+		Title:         post.Title,
+		Content:       post.Content,
+		RestoredFrom:  restoredFrom,
+		ChangedFields: strings.Join(changed, ","),
+	}).Error
+}
+
+// ensureBaselineRevision 为启用修订历史之前创建的文章补充初始版本，避免第一次更新丢失原内容
+func ensureBaselineRevision(tx *gorm.DB, post models.Post) error {
+	var count int64
+	if err := tx.Model(&models.PostRevision{}).Where("post_id = ?", post.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return tx.Create(&models.PostRevision{
+		PostID:    post.ID,
+		Number:    1,
+		EditorID:  post.UserID,
+		Title:     post.Title,
+		Content:   post.Content,
+		CreatedAt: post.UpdatedAt,
+	}).Error
+}
+
+// toRevisionResponse 转换为修订版本响应
+func toRevisionResponse(revision models.PostRevision) RevisionResponse {
+	return RevisionResponse{
+		ID:           revision.ID,
+		Number:       revision.Number,
+		Title:        revision.Title,
+		Content:      revision.Content,
+		EditorID:     revision.EditorID,
+		Editor:       revision.Editor.Username,
+		RestoredFrom: revision.RestoredFrom,
+		Changed:      splitChangedFields(revision.ChangedFields),
+		CreatedAt:    revision.CreatedAt,
+	}
+}
+
+// splitChangedFields 将逗号分隔的字段列表转换为切片
+func splitChangedFields(fields string) []string {
+	if fields == "" {
+		return []string{}
+	}
+	return strings.Split(fields, ",")
+}
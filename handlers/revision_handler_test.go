@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func revisionRouter() *gin.Engine {
+	r := gin.New()
+	authorized := r.Group("/api", middleware.AuthMiddleware())
+	authorized.PUT("/posts/:id", UpdatePost)
+	authorized.GET("/posts/:id/revisions", GetPostRevisions)
+	authorized.GET("/posts/:id/revisions/diff", DiffPostRevisions)
+	authorized.GET("/posts/:id/revisions/:rev", GetPostRevision)
+	authorized.POST("/posts/:id/revisions/:rev/restore", RestorePostRevision)
+	return r
+}
+
+func TestPostRevisions(t *testing.T) {
+	testutil.Setup(t)
+	alice := testutil.CreateUser(t, "alice")
+	token := testutil.Token(t, alice)
+	post := testutil.CreatePost(t, alice, "First", func(p *models.Post) { p.Content = "one\ntwo\nthree\n" })
+	r := revisionRouter()
+	base := fmt.Sprintf("/api/posts/%d", post.ID)
+
+	// 第一次更新时补充初始版本
+	for _, body := range []gin.H{
+		{"title": "First", "content": "one\n2\nthree\n"},
+		{"title": "Second", "content": "one\n2\nthree\n"},
+	} {
+		if w := testutil.Request(t, r, http.MethodPut, base, token, body); w.Code != http.StatusOK {
+			t.Fatalf("update status = %d: %s", w.Code, w.Body.String())
+		}
+	}
+
+	w := testutil.Request(t, r, http.MethodGet, base+"/revisions", token, nil)
+	revisions := testutil.Decode(t, w)["data"].([]any)
+	if len(revisions) != 3 {
+		t.Fatalf("revisions = %d, want 3", len(revisions))
+	}
+	latest := revisions[0].(map[string]any)
+	if latest["number"] != float64(3) || fmt.Sprint(latest["changed_fields"]) != "[title]" {
+		t.Errorf("latest revision = %v", latest)
+	}
+
+	w = testutil.Request(t, r, http.MethodGet, base+"/revisions/diff?from=1&to=2", token, nil)
+	content, _ := testutil.Decode(t, w)["data"].(map[string]any)["content"].(string)
+	if !strings.Contains(content, "-two\n+2\n") {
+		t.Errorf("unified diff = %q", content)
+	}
+	w = testutil.Request(t, r, http.MethodGet, base+"/revisions/diff?from=2&to=3&mode=word", token, nil)
+	title := testutil.Decode(t, w)["data"].(map[string]any)["title"].([]any)
+	if len(title) != 2 || title[0].(map[string]any)["type"] != "delete" || title[1].(map[string]any)["text"] != "Second" {
+		t.Errorf("word diff of title = %v", title)
+	}
+
+	// 恢复产生新版本
+	w = testutil.Request(t, r, http.MethodPost, base+"/revisions/1/restore", token, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore status = %d: %s", w.Code, w.Body.String())
+	}
+	var restored models.Post
+	config.GetDB().First(&restored, post.ID)
+	if restored.Title != "First" || restored.Content != "one\ntwo\nthree\n" {
+		t.Errorf("restored post = %q %q", restored.Title, restored.Content)
+	}
+	var revision models.PostRevision
+	config.GetDB().Where("post_id = ? AND number = 4", post.ID).First(&revision)
+	if revision.RestoredFrom == nil || *revision.RestoredFrom != 1 {
+		t.Errorf("revision 4 restored from %v, want 1", revision.RestoredFrom)
+	}
+}
+
+func TestPostRevisionsAccess(t *testing.T) {
+	testutil.Setup(t)
+	alice := testutil.CreateUser(t, "alice")
+	bob := testutil.CreateUser(t, "bob")
+	admin := testutil.CreateUser(t, "root", func(u *models.User) { u.Role = models.RoleAdmin })
+	post := testutil.CreatePost(t, alice, "Post")
+	r := revisionRouter()
+	base := fmt.Sprintf("/api/posts/%d", post.ID)
+	testutil.Request(t, r, http.MethodPut, base, testutil.Token(t, alice), gin.H{"title": "Post", "content": "changed"})
+
+	tests := []struct {
+		name   string
+		user   models.User
+		method string
+		path   string
+		want   int
+	}{
+		{"other user cannot list", bob, http.MethodGet, base + "/revisions", http.StatusForbidden},
+		{"admin can list", admin, http.MethodGet, base + "/revisions", http.StatusOK},
+		{"admin cannot restore", admin, http.MethodPost, base + "/revisions/1/restore", http.StatusForbidden},
+		{"invalid diff mode", alice, http.MethodGet, base + "/revisions/diff?from=1&to=2&mode=side", http.StatusBadRequest},
+		{"unknown revision", alice, http.MethodGet, base + "/revisions/9", http.StatusNotFound},
+		{"invalid revision", alice, http.MethodGet, base + "/revisions/0", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := testutil.Request(t, r, tt.method, tt.path, testutil.Token(t, tt.user), nil); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
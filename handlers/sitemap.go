@@ -0,0 +1,27 @@
+package handlers
+
+import "encoding/xml"
+
+// SitemapURLSet 站点地图
+type SitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []SitemapURL `xml:"url"`
+}
+
+// SitemapURL 站点地图中的地址
+type SitemapURL struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+// SitemapIndex 站点地图索引，地址超过单个站点地图的上限时使用
+type SitemapIndex struct {
+	XMLName  xml.Name         `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []SitemapPointer `xml:"sitemap"`
+}
+
+// SitemapPointer 站点地图索引中的条目
+type SitemapPointer struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+)
+
+// sitemapMaxURLs 单个站点地图最多包含的地址数（sitemaps.org协议上限）
+const sitemapMaxURLs = 50000
+
+// GetSitemap 生成站点地图
+// 文章数超过单个站点地图的上限时返回站点地图索引，各分页通过 ?page=N 获取
+func GetSitemap(c *gin.Context) {
+	db := config.GetDB()
+
+	var total int64
+	if err := db.Model(&models.Post{}).Count(&total).Error; err != nil {
+		utils.LogError("sitemap count error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to generate sitemap",
+		})
+		return
+	}
+
+	var lastModified time.Time
+	var latest models.Post
+	if err := db.Select("updated_at").Order("updated_at desc").Limit(1).Find(&latest).Error; err == nil {
+		lastModified = latest.UpdatedAt.UTC()
+	}
+
+	baseURL := siteBaseURL(c)
+	pages := int((total + sitemapMaxURLs - 1) / sitemapMaxURLs)
+
+	var doc interface{}
+	pageParam := c.Query("page")
+	if pageParam == "" && pages > 1 {
+		index := SitemapIndex{}
+		for page := 1; page <= pages; page++ {
+			index.Sitemaps = append(index.Sitemaps, SitemapPointer{
+				Loc:     fmt.Sprintf("%s/sitemap.xml?page=%d", baseURL, page),
+				LastMod: lastModified.Format(time.RFC3339),
+			})
+		}
+		doc = index
+	} else {
+		page := 1
+		if pageParam != "" {
+			var err error
+			page, err = strconv.Atoi(pageParam)
+			if err != nil || page < 1 || (page > pages && page != 1) {
+				c.JSON(http.StatusNotFound, gin.H{
+					"success": false,
+					"message": "Sitemap page not found",
+				})
+				return
+			}
+		}
+
+		var posts []models.Post
+		err := db.Select("id", "slug", "updated_at").Order("id").
+			Offset((page - 1) * sitemapMaxURLs).Limit(sitemapMaxURLs).
+			Find(&posts).Error
+		if err != nil {
+			utils.LogError("sitemap posts error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Failed to generate sitemap",
+			})
+			return
+		}
+
+		urlSet := SitemapURLSet{URLs: make([]SitemapURL, 0, len(posts))}
+		for _, post := range posts {
+			urlSet.URLs = append(urlSet.URLs, SitemapURL{
+				Loc:     postURL(baseURL, post),
+				LastMod: post.UpdatedAt.UTC().Format(time.RFC3339),
+			})
+		}
+		doc = urlSet
+	}
+
+	body, err := marshalXML(doc)
+	if err != nil {
+		utils.LogError("render sitemap error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to generate sitemap",
+		})
+		return
+	}
+
+	if !lastModified.IsZero() {
+		c.Header("Last-Modified", lastModified.Format(http.TimeFormat))
+	}
+	c.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+}
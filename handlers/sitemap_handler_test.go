@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/xml"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/testutil"
+)
+
+func TestGetSitemap(t *testing.T) {
+	testutil.Setup(t)
+	cfg := config.DefaultConfig()
+	cfg.Site.BaseURL = "https://blog.example.com"
+	config.Set(cfg)
+	alice := testutil.CreateUser(t, "alice")
+	testutil.CreatePost(t, alice, "First Post")
+	testutil.CreatePost(t, alice, "Second Post")
+
+	r := gin.New()
+	r.GET("/sitemap.xml", GetSitemap)
+
+	w := testutil.Request(t, r, http.MethodGet, "/sitemap.xml", "", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+	var urlSet SitemapURLSet
+	if err := xml.Unmarshal(w.Body.Bytes(), &urlSet); err != nil {
+		t.Fatal(err)
+	}
+	if len(urlSet.URLs) != 2 || urlSet.URLs[0].Loc != "https://blog.example.com/api/posts/by-slug/first-post" || urlSet.URLs[0].LastMod == "" {
+		t.Errorf("unexpected sitemap %+v", urlSet)
+	}
+
+	for _, page := range []string{"0", "2", "abc"} {
+		if w := testutil.Request(t, r, http.MethodGet, "/sitemap.xml?page="+page, "", nil); w.Code != http.StatusNotFound {
+			t.Errorf("page %s status = %d, want 404", page, w.Code)
+		}
+	}
+}
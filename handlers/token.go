@@ -0,0 +1,21 @@
+package handlers
+
+import "time"
+
+// CreateTokenRequest 创建个人访问令牌请求
+type CreateTokenRequest struct {
+	Name          string   `json:"name" binding:"required,min=1,max=100"`
+	Scopes        []string `json:"scopes" binding:"required,min=1"`                   // 每一项都必须在 models.ValidScopes 中
+	ExpiresInDays int      `json:"expires_in_days" binding:"omitempty,min=1,max=365"` // 为空表示永不过期
+}
+
+// TokenResponse 个人访问令牌响应
+type TokenResponse struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
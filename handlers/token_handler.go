@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+)
+
+// tokenPrefixLength 保存的令牌前缀长度
+const tokenPrefixLength = len(utils.APITokenPrefix) + 4
+
+// CreateToken 创建个人访问令牌
+func CreateToken(c *gin.Context) {
+	var req CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError("create token validation error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+	for _, scope := range req.Scopes {
+		if !contains(models.ValidScopes, scope) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid request data",
+				"errors":  gin.H{"scopes": "must be one of " + strings.Join(models.ValidScopes, ", ")},
+			})
+			return
+		}
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError("create token unauthorized", nil)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	plain, hash, err := utils.GenerateAPIToken()
+	if err != nil {
+		utils.LogError("token generation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to generate token",
+		})
+		return
+	}
+
+	token := models.PersonalAccessToken{
+		UserID:    userID.(uint),
+		Name:      req.Name,
+		TokenHash: hash,
+		Prefix:    plain[:tokenPrefixLength],
+		Scopes:    strings.Join(uniqueScopes(req.Scopes), ","),
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		token.ExpiresAt = &expiresAt
+	}
+
+	if err := config.GetDB().Create(&token).Error; err != nil {
+		utils.LogError("create token database error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to create token",
+		})
+		return
+	}
+
+	// 令牌明文只返回这一次
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Token created successfully, store it now as it will not be shown again",
+		"data": gin.H{
+			"token":      plain,
+			"token_info": toTokenResponse(token),
+		},
+	})
+}
+
+// GetTokens 获取个人访问令牌列表
+func GetTokens(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var tokens []models.PersonalAccessToken
+	if err := config.GetDB().Where("user_id = ?", userID).Order("created_at desc").Find(&tokens).Error; err != nil {
+		utils.LogError("get tokens list error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to get tokens",
+		})
+		return
+	}
+
+	responses := make([]TokenResponse, 0, len(tokens))
+	for _, token := range tokens {
+		responses = append(responses, toTokenResponse(token))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Tokens retrieved successfully",
+		"data": gin.H{
+			"tokens": responses,
+		},
+	})
+}
+
+// RevokeToken 撤销个人访问令牌
+func RevokeToken(c *gin.Context) {
+	tokenIDStr := c.Param("id")
+	tokenID, err := strconv.Atoi(tokenIDStr)
+	if err != nil {
+		utils.LogError("revoke token invalid id", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid token id",
+		})
+		return
+	}
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	var token models.PersonalAccessToken
+	if err := config.GetDB().Where("user_id = ?", userID).First(&token, tokenID).Error; err != nil {
+		utils.LogError("revoke token not found", err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Token not found",
+		})
+		return
+	}
+
+	if err := config.GetDB().Delete(&token).Error; err != nil {
+		utils.LogError("revoke token database error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to revoke token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Token revoked successfully",
+	})
+}
+
+// toTokenResponse 转换为令牌响应
+func toTokenResponse(token models.PersonalAccessToken) TokenResponse {
+	return TokenResponse{
+		ID:         token.ID,
+		Name:       token.Name,
+		Prefix:     token.Prefix,
+		Scopes:     strings.Split(token.Scopes, ","),
+		LastUsedAt: token.LastUsedAt,
+		ExpiresAt:  token.ExpiresAt,
+		CreatedAt:  token.CreatedAt,
+	}
+}
+
+// uniqueScopes 去除重复的权限范围
+func uniqueScopes(scopes []string) []string {
+	seen := make(map[string]bool, len(scopes))
+	result := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if !seen[scope] {
+			seen[scope] = true
+			result = append(result, scope)
+		}
+	}
+	return result
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func TestCreateTokenScopes(t *testing.T) {
+	testutil.Setup(t)
+	user := testutil.CreateUser(t, "alice")
+	jwt := testutil.Token(t, user)
+
+	r := gin.New()
+	r.POST("/tokens", middleware.AuthMiddleware(), CreateToken)
+
+	tests := []struct {
+		name   string
+		scopes []string
+		want   int
+		stored string
+	}{
+		{"single scope", []string{models.ScopeRead}, http.StatusCreated, "read"},
+		{"all valid scopes", models.ValidScopes, http.StatusCreated, "read,posts:write,comments:write"},
+		{"duplicates removed", []string{models.ScopePostsWrite, models.ScopePostsWrite}, http.StatusCreated, "posts:write"},
+		{"unknown scope", []string{models.ScopeRead, "admin"}, http.StatusBadRequest, ""},
+		{"empty scopes", []string{}, http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Request(t, r, http.MethodPost, "/tokens", jwt, gin.H{"name": tt.name, "scopes": tt.scopes})
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want != http.StatusCreated {
+				return
+			}
+			var pat models.PersonalAccessToken
+			if err := config.GetDB().Where("name = ?", tt.name).First(&pat).Error; err != nil {
+				t.Fatal(err)
+			}
+			if pat.Scopes != tt.stored {
+				t.Errorf("stored scopes = %q, want %q", pat.Scopes, tt.stored)
+			}
+		})
+	}
+}
+
+func TestUniqueScopes(t *testing.T) {
+	tests := []struct {
+		in   []string
+		want []string
+	}{
+		{[]string{"read"}, []string{"read"}},
+		{[]string{"read", "read", "posts:write"}, []string{"read", "posts:write"}},
+		{[]string{"posts:write", "read", "posts:write"}, []string{"posts:write", "read"}},
+	}
+	for _, tt := range tests {
+		if got := uniqueScopes(tt.in); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("uniqueScopes(%v) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"gorm.io/gorm"
+)
+
+// conflictMessages 唯一索引冲突时返回给客户端的提示
+var conflictMessages = map[string]string{
+	"idx_users_username":             "Username already exists",
+	"idx_users_email":                "Email already exists",
+	"idx_posts_slug":                 "Slug already exists, please retry",
+	"idx_post_slugs_slug":            "Slug already exists, please retry",
+	"idx_revision_post_number":       "Post was modified concurrently, please retry",
+	"idx_identity_provider_subject":  "This identity is already linked to another account",
+	"idx_idempotency_user_scope_key": "A request with this idempotency key is in progress, please retry",
+}
+
+// runTx 在绑定到请求上下文的事务中执行fn，客户端断开连接时事务被取消
+func runTx(c *gin.Context, fn func(tx *gorm.DB) error) error {
+	return config.WithTx(c.Request.Context(), fn)
+}
+
+// respondConflict 错误为唯一约束冲突时返回409，返回是否已写入响应
+func respondConflict(c *gin.Context, err error) bool {
+	var conflict *config.ConflictError
+	if !errors.As(err, &conflict) {
+		return false
+	}
+
+	message, ok := conflictMessages[conflict.Key]
+	if !ok {
+		message = "Resource already exists"
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"success": false,
+		"message": message,
+	})
+	return true
+}
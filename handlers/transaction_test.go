@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/testutil"
+)
+
+func TestRespondConflict(t *testing.T) {
+	tests := []struct {
+		name    string
+		err     error
+		handled bool
+		message string
+	}{
+		{"known index", &config.ConflictError{Key: "idx_users_email", Err: errors.New("dup")}, true, "Email already exists"},
+		{"unknown index", &config.ConflictError{Key: "idx_other", Err: errors.New("dup")}, true, "Resource already exists"},
+		{"other error", errors.New("boom"), false, ""},
+		{"no error", nil, false, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			if got := respondConflict(c, tt.err); got != tt.handled {
+				t.Fatalf("respondConflict = %v, want %v", got, tt.handled)
+			}
+			if !tt.handled {
+				return
+			}
+			if w.Code != http.StatusConflict || testutil.Decode(t, w)["message"] != tt.message {
+				t.Errorf("status = %d, body %s", w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// 提示信息的键必须是真实存在的唯一索引名，否则冲突时只会返回通用提示
+func TestConflictMessagesMatchIndexes(t *testing.T) {
+	db := testutil.Setup(t)
+	var indexes []string
+	db.Raw("SELECT name FROM sqlite_master WHERE type = 'index'").Scan(&indexes)
+	exists := map[string]bool{}
+	for _, name := range indexes {
+		exists[name] = true
+	}
+	for key := range conflictMessages {
+		if !exists[key] {
+			t.Errorf("conflictMessages has %q, which is not an index", key)
+		}
+	}
+}
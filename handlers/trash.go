@@ -0,0 +1,14 @@
+package handlers
+
+import "time"
+
+// TrashedPostResponse 回收站中的文章
+type TrashedPostResponse struct {
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Slug      string    `json:"slug"`
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"` // 超过该时间后会被永久删除
+}
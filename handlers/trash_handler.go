@@ -0,0 +1,207 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"gorm.io/gorm"
+)
+
+// GetTrash 获取当前用户已删除的文章
+func GetTrash(c *gin.Context) {
+	userID := c.GetUint("user_id")
+	listTrash(c, &userID)
+}
+
+// GetAdminTrash 获取所有用户已删除的文章（仅管理员）
+func GetAdminTrash(c *gin.Context) {
+	listTrash(c, nil)
+}
+
+// GetAdminTrashedPost 获取已删除文章的完整内容和评论（仅管理员）
+func GetAdminTrashedPost(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid post id",
+		})
+		return
+	}
+
+	var post models.Post
+	err = config.GetDB().Unscoped().
+		Preload("User").
+		Preload("Comments", func(db *gorm.DB) *gorm.DB { return db.Unscoped().Order("created_at asc") }).
+		Where("deleted_at IS NOT NULL").
+		First(&post, postID).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Post not found in trash",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Post retrieved successfully",
+		"data":    post,
+	})
+}
+
+// RestorePost 从回收站恢复文章（作者或管理员）
+func RestorePost(c *gin.Context) {
+	postID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		utils.LogError("restore post invalid id", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid post id",
+		})
+		return
+	}
+
+	var post models.Post
+	if err := config.GetDB().Unscoped().Where("deleted_at IS NOT NULL").First(&post, postID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Post not found in trash",
+		})
+		return
+	}
+
+	if post.UserID != c.GetUint("user_id") && c.GetString("role") != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "You are not the author of this post",
+		})
+		return
+	}
+
+	// 同时恢复随文章删除的评论（删除时间与文章相同），单独删除的评论保持删除
+	err = runTx(c, func(tx *gorm.DB) error {
+		result := tx.Unscoped().Model(&models.Post{}).
+			Where("id = ? AND deleted_at = ?", post.ID, post.DeletedAt.Time).
+			Updates(map[string]interface{}{
+				"deleted_at": nil,
+				"version":    gorm.Expr("version + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		err := tx.Unscoped().Model(&models.Comment{}).
+			Where("post_id = ? AND deleted_at = ?", post.ID, post.DeletedAt.Time).
+			UpdateColumn("deleted_at", nil).Error
+		if err != nil {
+			return err
+		}
+		return models.RefreshCommentStats(tx, post.ID)
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "Post not found in trash",
+		})
+		return
+	}
+	if err != nil {
+		utils.LogError("restore post database error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to restore post",
+		})
+		return
+	}
+
+	post.DeletedAt = gorm.DeletedAt{}
+	post.Version++
+	invalidatePostCache(c.Request.Context(), post.ID)
+	c.Header("ETag", postETag(post))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Post restored successfully",
+		"data": gin.H{
+			"id":         post.ID,
+			"title":      post.Title,
+			"slug":       post.Slug,
+			"content":    post.Content,
+			"version":    post.Version,
+			"user_id":    post.UserID,
+			"created_at": post.CreatedAt,
+			"updated_at": post.UpdatedAt,
+		},
+	})
+}
+
+// listTrash 分页获取已删除的文章，userID为空时返回所有用户的文章
+func listTrash(c *gin.Context, userID *uint) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	offset := (page - 1) * limit
+
+	query := config.GetDB().Unscoped().Model(&models.Post{}).Where("deleted_at IS NOT NULL")
+	if userID != nil {
+		query = query.Where("user_id = ?", *userID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		utils.LogError("get trash count error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to get trash",
+		})
+		return
+	}
+
+	var posts []models.Post
+	if err := query.Preload("User").Order("deleted_at desc").Offset(offset).Limit(limit).Find(&posts).Error; err != nil {
+		utils.LogError("get trash list error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to get trash",
+		})
+		return
+	}
+
+	retention := time.Duration(config.Get().Trash.RetentionDays) * 24 * time.Hour
+	items := make([]TrashedPostResponse, 0, len(posts))
+	for _, post := range posts {
+		items = append(items, TrashedPostResponse{
+			ID:        post.ID,
+			Title:     post.Title,
+			Slug:      post.Slug,
+			UserID:    post.UserID,
+			Username:  post.User.Username,
+			DeletedAt: post.DeletedAt.Time,
+			PurgeAt:   post.DeletedAt.Time.Add(retention),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Trash retrieved successfully",
+		"data": gin.H{
+			"posts": items,
+			"total": total,
+			"page":  page,
+			"limit": limit,
+		},
+	})
+}
@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func trashRouter() *gin.Engine {
+	r := gin.New()
+	authorized := r.Group("/api", middleware.AuthMiddleware())
+	authorized.DELETE("/posts/:id", DeletePost)
+	authorized.GET("/profile/trash", GetTrash)
+	authorized.POST("/posts/:id/restore", RestorePost)
+	authorized.GET("/admin/trash", middleware.RequireRole(models.RoleAdmin), GetAdminTrash)
+	authorized.GET("/admin/trash/:id", middleware.RequireRole(models.RoleAdmin), GetAdminTrashedPost)
+	return r
+}
+
+func TestTrashRestore(t *testing.T) {
+	testutil.Setup(t)
+	alice := testutil.CreateUser(t, "alice")
+	bob := testutil.CreateUser(t, "bob")
+	post := testutil.CreatePost(t, alice, "Post")
+	db := config.GetDB()
+
+	// 单独删除的评论恢复文章时保持删除
+	kept := models.Comment{Content: "kept", UserID: bob.ID, PostID: post.ID}
+	removed := models.Comment{Content: "removed", UserID: bob.ID, PostID: post.ID}
+	db.Create(&kept)
+	db.Create(&removed)
+	db.Delete(&removed)
+	models.RefreshCommentStats(db, post.ID)
+
+	r := trashRouter()
+	aliceToken := testutil.Token(t, alice)
+	path := fmt.Sprintf("/api/posts/%d", post.ID)
+	if w := testutil.Request(t, r, http.MethodDelete, path, aliceToken, nil); w.Code != http.StatusOK {
+		t.Fatalf("delete status = %d: %s", w.Code, w.Body.String())
+	}
+
+	w := testutil.Request(t, r, http.MethodGet, "/api/profile/trash", aliceToken, nil)
+	posts := testutil.Decode(t, w)["data"].(map[string]any)["posts"].([]any)
+	if len(posts) != 1 || posts[0].(map[string]any)["title"] != "Post" {
+		t.Fatalf("alice's trash = %v", posts)
+	}
+	w = testutil.Request(t, r, http.MethodGet, "/api/profile/trash", testutil.Token(t, bob), nil)
+	if posts := testutil.Decode(t, w)["data"].(map[string]any)["posts"].([]any); len(posts) != 0 {
+		t.Errorf("bob sees %v in his trash", posts)
+	}
+
+	if w := testutil.Request(t, r, http.MethodPost, path+"/restore", testutil.Token(t, bob), nil); w.Code != http.StatusForbidden {
+		t.Errorf("restore by other user status = %d, want 403", w.Code)
+	}
+	w = testutil.Request(t, r, http.MethodPost, path+"/restore", aliceToken, nil)
+	if w.Code != http.StatusOK || w.Header().Get("ETag") != fmt.Sprintf(`"post-%d-v2"`, post.ID) {
+		t.Fatalf("restore status = %d, ETag %q: %s", w.Code, w.Header().Get("ETag"), w.Body.String())
+	}
+
+	var restored models.Post
+	db.First(&restored, post.ID)
+	if restored.CommentCount != 1 {
+		t.Errorf("comment count = %d, want 1", restored.CommentCount)
+	}
+	var comments []models.Comment
+	db.Where("post_id = ?", post.ID).Find(&comments)
+	if len(comments) != 1 || comments[0].ID != kept.ID {
+		t.Errorf("visible comments = %v, want only %d", comments, kept.ID)
+	}
+
+	if w := testutil.Request(t, r, http.MethodPost, path+"/restore", aliceToken, nil); w.Code != http.StatusNotFound {
+		t.Errorf("restoring a live post status = %d, want 404", w.Code)
+	}
+}
+
+func TestAdminTrash(t *testing.T) {
+	testutil.Setup(t)
+	cfg := config.DefaultConfig()
+	cfg.Trash.RetentionDays = 7
+	config.Set(cfg)
+	alice := testutil.CreateUser(t, "alice")
+	admin := testutil.CreateUser(t, "root", func(u *models.User) { u.Role = models.RoleAdmin })
+	trashed := testutil.CreatePost(t, alice, "Trashed")
+	live := testutil.CreatePost(t, alice, "Live")
+	config.GetDB().Delete(&trashed)
+
+	r := trashRouter()
+	adminToken := testutil.Token(t, admin)
+
+	w := testutil.Request(t, r, http.MethodGet, "/api/admin/trash", adminToken, nil)
+	posts := testutil.Decode(t, w)["data"].(map[string]any)["posts"].([]any)
+	if len(posts) != 1 {
+		t.Fatalf("admin trash = %v", posts)
+	}
+	item := posts[0].(map[string]any)
+	deletedAt, _ := time.Parse(time.RFC3339Nano, item["deleted_at"].(string))
+	purgeAt, _ := time.Parse(time.RFC3339Nano, item["purge_at"].(string))
+	if item["username"] != "alice" || purgeAt.Sub(deletedAt) != 7*24*time.Hour {
+		t.Errorf("unexpected item %v", item)
+	}
+
+	tests := []struct {
+		name   string
+		token  string
+		method string
+		path   string
+		want   int
+	}{
+		{"admin views trashed post", adminToken, http.MethodGet, fmt.Sprintf("/api/admin/trash/%d", trashed.ID), http.StatusOK},
+		{"live post is not in trash", adminToken, http.MethodGet, fmt.Sprintf("/api/admin/trash/%d", live.ID), http.StatusNotFound},
+		{"author cannot use admin view", testutil.Token(t, alice), http.MethodGet, "/api/admin/trash", http.StatusForbidden},
+		{"admin restores any post", adminToken, http.MethodPost, fmt.Sprintf("/api/posts/%d/restore", trashed.ID), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := testutil.Request(t, r, tt.method, tt.path, tt.token, nil); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,18 @@
+package handlers
+
+import "time"
+
+// TrendingPostResponse 热门文章
+type TrendingPostResponse struct {
+	ID        uint      `json:"id"`
+	Title     string    `json:"title"`
+	Slug      string    `json:"slug"`
+	Excerpt   string    `json:"excerpt"`
+	UserID    uint      `json:"user_id"`
+	Username  string    `json:"username"`
+	ViewCount int64     `json:"view_count"`
+	Views     int64     `json:"recent_views"`    // 统计窗口内的浏览量
+	Comments  int64     `json:"recent_comments"` // 统计窗口内的评论数
+	Score     float64   `json:"score"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+)
+
+// dailyActivity 文章某一天的浏览量和评论数
+type dailyActivity struct {
+	PostID uint
+	Day    time.Time
+	Count  int64
+}
+
+// GetTrendingPosts 获取热门文章
+// 分数为统计窗口内每天的浏览量与评论数（按权重折算为浏览量）之和，按距今时间指数衰减
+// 注意：分数不包含点赞等互动数据。项目目前没有点赞功能，也就没有可以统计的数据；
+// 加入点赞后需要按天汇总，并像评论一样按权重折算计入rankActivity
+func GetTrendingPosts(c *gin.Context) {
+	limit := 10
+	if value := c.Query("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 || limit > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid query parameters",
+				"errors":  gin.H{"limit": "must be an integer between 1 and 50"},
+			})
+			return
+		}
+	}
+
+	key := versionedCacheKey(c.Request.Context(), postListNamespace, fmt.Sprintf("trending:limit=%d", limit))
+	serveCached(c, key, func() (cachedResponse, error) {
+		posts, err := trendingPosts(limit, time.Now())
+		if err != nil {
+			utils.LogError("get trending posts error", err)
+			return cachedResponse{}, utils.NewInternalError("Failed to get trending posts")
+		}
+
+		body, err := json.Marshal(gin.H{
+			"success": true,
+			"message": "Trending posts retrieved successfully",
+			"data":    posts,
+		})
+		return cachedResponse{Body: body}, err
+	})
+}
+
+// trendingPosts 计算分数最高的limit篇文章
+func trendingPosts(limit int, now time.Time) ([]TrendingPostResponse, error) {
+	cfg := config.Get().Trending
+	since := now.AddDate(0, 0, -cfg.WindowDays)
+	db := config.GetDB()
+
+	var viewRows []dailyActivity
+	err := db.Model(&models.PostViewStat{}).
+		Select("post_id, day, views AS count").
+		Where("day >= ?", since.Format("2006-01-02")).
+		Scan(&viewRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	var commentRows []dailyActivity
+	err = db.Model(&models.Comment{}).
+		Select("post_id, DATE(created_at) AS day, COUNT(*) AS count").
+		Where("created_at >= ?", since).
+		Group("post_id, DATE(created_at)").
+		Scan(&commentRows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := rankActivity(viewRows, commentRows, now, cfg)
+
+	// 多取一些，已删除的文章会被过滤掉
+	candidates := ranked
+	if len(candidates) > limit*2 {
+		candidates = candidates[:limit*2]
+	}
+	ids := make([]uint, 0, len(candidates))
+	for _, e := range candidates {
+		ids = append(ids, e.ID)
+	}
+	var posts []models.Post
+	if len(ids) > 0 {
+		if err := db.Preload("User").Where("id IN ?", ids).Find(&posts).Error; err != nil {
+			return nil, err
+		}
+	}
+	byID := make(map[uint]models.Post, len(posts))
+	for _, post := range posts {
+		byID[post.ID] = post
+	}
+
+	result := make([]TrendingPostResponse, 0, limit)
+	for _, e := range candidates {
+		post, ok := byID[e.ID]
+		if !ok {
+			continue
+		}
+		e.Title = post.Title
+		e.Slug = post.Slug
+		e.Excerpt = utils.Summarize(post.Content, excerptLength)
+		e.UserID = post.UserID
+		e.Username = post.User.Username
+		e.ViewCount = post.ViewCount
+		e.Score = math.Round(e.Score*100) / 100
+		e.CreatedAt = post.CreatedAt
+		result = append(result, *e)
+		if len(result) == limit {
+			break
+		}
+	}
+	return result, nil
+}
+
+// rankActivity 根据每天的浏览量和评论数计算分数，按分数从高到低排序，同分时新文章在前
+func rankActivity(viewRows, commentRows []dailyActivity, now time.Time, cfg config.TrendingConfig) []*TrendingPostResponse {
+	// 每天的数据按当天中午计算衰减
+	decay := func(day time.Time) float64 {
+		age := now.Sub(day.Add(12 * time.Hour)).Hours()
+		if age < 0 {
+			age = 0
+		}
+		return math.Pow(0.5, age/cfg.HalfLifeHours)
+	}
+
+	scores := make(map[uint]*TrendingPostResponse)
+	entry := func(postID uint) *TrendingPostResponse {
+		if scores[postID] == nil {
+			scores[postID] = &TrendingPostResponse{ID: postID}
+		}
+		return scores[postID]
+	}
+	for _, row := range viewRows {
+		e := entry(row.PostID)
+		e.Views += row.Count
+		e.Score += float64(row.Count) * decay(row.Day)
+	}
+	for _, row := range commentRows {
+		e := entry(row.PostID)
+		e.Comments += row.Count
+		e.Score += float64(row.Count) * cfg.CommentWeight * decay(row.Day)
+	}
+
+	ranked := make([]*TrendingPostResponse, 0, len(scores))
+	for _, e := range scores {
+		ranked = append(ranked, e)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		return ranked[i].ID > ranked[j].ID
+	})
+	return ranked
+}
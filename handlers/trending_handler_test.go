@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func TestRankActivity(t *testing.T) {
+	cfg := config.TrendingConfig{WindowDays: 7, HalfLifeHours: 24, CommentWeight: 5}
+	today := time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)
+	now := today.Add(12 * time.Hour)
+	day := func(ago int) time.Time { return today.AddDate(0, 0, -ago) }
+
+	tests := []struct {
+		name     string
+		views    []dailyActivity
+		comments []dailyActivity
+		want     []uint
+		scores   []float64
+	}{
+		{"views decay by half life", []dailyActivity{{1, day(0), 10}, {2, day(1), 10}, {3, day(2), 10}}, nil,
+			[]uint{1, 2, 3}, []float64{10, 5, 2.5}},
+		{"comments are weighted", []dailyActivity{{1, day(0), 4}}, []dailyActivity{{2, day(0), 1}},
+			[]uint{2, 1}, []float64{5, 4}},
+		{"views and comments add up", []dailyActivity{{1, day(0), 2}, {1, day(1), 2}}, []dailyActivity{{1, day(1), 1}},
+			[]uint{1}, []float64{2 + 1 + 2.5}},
+		// 同分时id大的（较新的）文章在前
+		{"ties prefer newer posts", []dailyActivity{{1, day(0), 10}, {2, day(2), 40}}, nil,
+			[]uint{2, 1}, []float64{10, 10}},
+		// 当天中午之前的数据不会因为时间为负而放大
+		{"future activity is not amplified", []dailyActivity{{1, day(-1), 3}}, nil,
+			[]uint{1}, []float64{3}},
+		{"no activity", nil, nil, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ranked := rankActivity(tt.views, tt.comments, now, cfg)
+			if len(ranked) != len(tt.want) {
+				t.Fatalf("ranked %d posts, want %d", len(ranked), len(tt.want))
+			}
+			for i, e := range ranked {
+				if e.ID != tt.want[i] || e.Score != tt.scores[i] {
+					t.Errorf("#%d = post %d score %v, want post %d score %v", i, e.ID, e.Score, tt.want[i], tt.scores[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTrendingPosts(t *testing.T) {
+	testutil.Setup(t)
+	cfg := config.DefaultConfig()
+	cfg.Trending.WindowDays = 7
+	config.Set(cfg)
+	db := config.GetDB()
+	alice := testutil.CreateUser(t, "alice")
+
+	today := time.Now().Truncate(24 * time.Hour)
+	views := func(title string, ago int, n int64) models.Post {
+		post := testutil.CreatePost(t, alice, title)
+		db.Create(&models.PostViewStat{PostID: post.ID, Day: today.AddDate(0, 0, -ago), Views: n})
+		return post
+	}
+	views("Popular", 0, 50)
+	views("Quiet", 0, 5)
+	views("Stale", 8, 1000) // 超出统计窗口
+	deleted := views("Deleted", 0, 100)
+	db.Delete(&deleted)
+
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{10, []string{"Popular", "Quiet"}},
+		{1, []string{"Popular"}},
+	}
+	for _, tt := range tests {
+		posts, err := trendingPosts(tt.limit, today.Add(12*time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var titles []string
+		for _, post := range posts {
+			titles = append(titles, post.Title)
+		}
+		if len(titles) != len(tt.want) || titles[0] != tt.want[0] || titles[len(titles)-1] != tt.want[len(tt.want)-1] {
+			t.Errorf("limit %d: titles = %v, want %v", tt.limit, titles, tt.want)
+		}
+		if posts[0].Username != "alice" || posts[0].Views != 50 || posts[0].Excerpt == "" {
+			t.Errorf("unexpected entry %+v", posts[0])
+		}
+	}
+
+	r := gin.New()
+	r.GET("/api/posts/trending", GetTrendingPosts)
+	w := testutil.Request(t, r, http.MethodGet, "/api/posts/trending", "", nil)
+	if data := testutil.Decode(t, w)["data"].([]any); w.Code != http.StatusOK || len(data) != 2 {
+		t.Errorf("status = %d: %s", w.Code, w.Body.String())
+	}
+
+	// 与文章列表一样，超出范围的limit返回400而不是使用默认值
+	for _, limit := range []string{"0", "51", "500", "ten"} {
+		w := testutil.Request(t, r, http.MethodGet, "/api/posts/trending?limit="+limit, "", nil)
+		errs, _ := testutil.Decode(t, w)["errors"].(map[string]any)
+		if w.Code != http.StatusBadRequest || errs["limit"] == nil {
+			t.Errorf("limit=%s status = %d: %s", limit, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package handlers
+
+// ConfirmTwoFactorRequest 确认启用两步验证请求
+type ConfirmTwoFactorRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// DisableTwoFactorRequest 关闭两步验证请求
+type DisableTwoFactorRequest struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// RegenerateRecoveryCodesRequest 重新生成恢复码请求
+type RegenerateRecoveryCodesRequest struct {
+	Code string `json:"code" binding:"required,len=6,numeric"`
+}
+
+// VerifyTwoFactorRequest 两步验证登录请求，验证码和恢复码二选一
+type VerifyTwoFactorRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code" binding:"required_without=RecoveryCode,omitempty,len=6,numeric"`
+	RecoveryCode string `json:"recovery_code" binding:"required_without=Code"`
+}
@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// totpIssuer 身份验证器应用中显示的名称
+	totpIssuer = "Blog"
+	// recoveryCodeCount 每次生成的恢复码数量
+	recoveryCodeCount = 10
+	// maxMFAAttempts 连续失败多少次后锁定两步验证登录
+	maxMFAAttempts = 5
+	// mfaLockoutBase 第一次锁定的时长，之后每次失败翻倍
+	mfaLockoutBase = time.Minute
+	// mfaLockoutMax 锁定时长的上限
+	mfaLockoutMax = time.Hour
+)
+
+// SetupTwoFactor 开始启用两步验证，生成TOTP密钥
+// 需要调用ConfirmTwoFactor验证一次验证码后才会真正启用
+func SetupTwoFactor(c *gin.Context) {
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "Two-factor authentication is already enabled",
+		})
+		return
+	}
+
+	secret, err := utils.GenerateTOTPSecret()
+	if err != nil {
+		utils.LogError("totp secret generation error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to set up two-factor authentication",
+		})
+		return
+	}
+
+	if err := config.GetDB().Model(&user).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		utils.LogError("totp secret save error", err, utils.WithUserID(user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to set up two-factor authentication",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Scan the provisioning URI with an authenticator app, then confirm with a code",
+		"data": gin.H{
+			"secret":           secret,
+			"provisioning_uri": utils.TOTPProvisioningURI(totpIssuer, user.Username, secret),
+		},
+	})
+}
+
+// ConfirmTwoFactor 验证验证码并启用两步验证，返回一次性恢复码
+func ConfirmTwoFactor(c *gin.Context) {
+	var req ConfirmTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError("confirm 2fa validation error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "Two-factor authentication is already enabled",
+		})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Two-factor authentication has not been set up",
+		})
+		return
+	}
+
+	if !verifyTOTP(&user, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid verification code",
+		})
+		return
+	}
+
+	var codes []string
+	err := runTx(c, func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Update("totp_enabled", true).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		utils.LogError("enable 2fa error", err, utils.WithUserID(user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to enable two-factor authentication",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Two-factor authentication enabled, store the recovery codes now as they will not be shown again",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// DisableTwoFactor 关闭两步验证，管理员不能关闭
+func DisableTwoFactor(c *gin.Context) {
+	var req DisableTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError("disable 2fa validation error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.Role == models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Administrators must keep two-factor authentication enabled",
+		})
+		return
+	}
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Two-factor authentication is not enabled",
+		})
+		return
+	}
+
+	if !utils.CheckPassword(req.Password, user.Password) || !verifyTOTP(&user, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid password or verification code",
+		})
+		return
+	}
+
+	err := runTx(c, func(tx *gorm.DB) error {
+		if err := tx.Model(&user).Updates(map[string]interface{}{
+			"totp_enabled": false,
+			"totp_secret":  "",
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error
+	})
+	if err != nil {
+		utils.LogError("disable 2fa error", err, utils.WithUserID(user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to disable two-factor authentication",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Two-factor authentication disabled",
+	})
+}
+
+// RegenerateRecoveryCodes 重新生成恢复码，之前的恢复码全部失效
+func RegenerateRecoveryCodes(c *gin.Context) {
+	var req RegenerateRecoveryCodesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError("regenerate recovery codes validation error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Two-factor authentication is not enabled",
+		})
+		return
+	}
+	if !verifyTOTP(&user, req.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid verification code",
+		})
+		return
+	}
+
+	var codes []string
+	err := runTx(c, func(tx *gorm.DB) error {
+		var err error
+		codes, err = replaceRecoveryCodes(tx, user.ID)
+		return err
+	})
+	if err != nil {
+		utils.LogError("regenerate recovery codes error", err, utils.WithUserID(user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to regenerate recovery codes",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Recovery codes regenerated, store them now as they will not be shown again",
+		"data": gin.H{
+			"recovery_codes": codes,
+		},
+	})
+}
+
+// VerifyTwoFactor 使用挑战令牌和验证码（或恢复码）完成登录
+func VerifyTwoFactor(c *gin.Context) {
+	var req VerifyTwoFactorRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.LogError("verify 2fa validation error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	claims, err := config.JWTKeys().ValidateMFAToken(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid or expired MFA token",
+		})
+		return
+	}
+
+	// 验证成功后，之前签发的挑战令牌全部失效
+	// 令牌的iat精确到秒，验证时间截断到秒后再比较
+	var user models.User
+	if err := config.GetDB().First(&user, claims.UserID).Error; err != nil || !user.TOTPEnabled ||
+		(user.MFAVerifiedAt != nil && !user.MFAVerifiedAt.Truncate(time.Second).Before(claims.IssuedAt.Time)) {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid or expired MFA token",
+		})
+		return
+	}
+
+	// 失败次数按用户记录，重新登录获取新的挑战令牌不能绕过锁定
+	if user.MFALockedUntil != nil && time.Now().Before(*user.MFALockedUntil) {
+		retryAfter := int(time.Until(*user.MFALockedUntil)/time.Second) + 1
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"success": false,
+			"message": "Too many failed verification attempts, try again later",
+		})
+		return
+	}
+
+	var verified bool
+	if req.RecoveryCode != "" {
+		verified = useRecoveryCode(user.ID, req.RecoveryCode)
+	} else {
+		verified = verifyTOTP(&user, req.Code)
+	}
+	if !verified {
+		if err := recordMFAFailure(c, user.ID); err != nil {
+			utils.LogError("mfa failure record error", err, utils.WithUserID(user.ID))
+		}
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid verification code",
+		})
+		return
+	}
+
+	// 清零失败次数，并使挑战令牌只能成功使用一次
+	// 验证时间按秒保存，与iat精度一致，避免数据库舍入到下一秒使之后签发的令牌失效
+	result := config.GetDB().Model(&models.User{}).
+		Where("id = ? AND (mfa_verified_at IS NULL OR mfa_verified_at < ?)", user.ID, claims.IssuedAt.Time).
+		Updates(map[string]interface{}{
+			"mfa_failures":     0,
+			"mfa_locked_until": nil,
+			"mfa_verified_at":  time.Now().Truncate(time.Second),
+		})
+	if result.Error != nil {
+		utils.LogError("mfa success record error", result.Error, utils.WithUserID(user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to verify two-factor authentication",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid or expired MFA token",
+		})
+		return
+	}
+
+	respondWithToken(c, http.StatusOK, "Login successful", user)
+}
+
+// currentUser 获取当前登录用户，失败时已写入响应
+func currentUser(c *gin.Context) (models.User, bool) {
+	var user models.User
+
+	userID, exists := c.Get("user_id")
+	if !exists {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "User not authenticated",
+		})
+		return user, false
+	}
+
+	if err := config.GetDB().First(&user, userID).Error; err != nil {
+		utils.LogError("user retrieval error", err)
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "User not found",
+		})
+		return user, false
+	}
+
+	return user, true
+}
+
+// verifyTOTP 验证TOTP验证码，并原子地记录时间步防止同一验证码被重复使用
+func verifyTOTP(user *models.User, code string) bool {
+	step, ok := utils.ValidateTOTP(user.TOTPSecret, code, time.Now())
+	if !ok || step <= user.TOTPLastStep {
+		return false
+	}
+
+	result := config.GetDB().Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		utils.LogError("totp step update error", result.Error, utils.WithUserID(user.ID))
+		return false
+	}
+	if result.RowsAffected == 0 {
+		return false
+	}
+
+	user.TOTPLastStep = step
+	return true
+}
+
+// useRecoveryCode 使用恢复码，成功后该恢复码失效
+func useRecoveryCode(userID uint, code string) bool {
+	result := config.GetDB().Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, utils.HashRecoveryCode(code)).
+		Limit(1).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		utils.LogError("recovery code update error", result.Error, utils.WithUserID(userID))
+		return false
+	}
+	return result.RowsAffected == 1
+}
+
+// replaceRecoveryCodes 删除旧的恢复码并生成新的恢复码，返回明文
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	codes, err := utils.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	records := make([]models.RecoveryCode, 0, len(codes))
+	for _, code := range codes {
+		records = append(records, models.RecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashRecoveryCode(code),
+		})
+	}
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// recordMFAFailure 记录一次两步验证登录失败，连续失败达到上限后锁定，锁定时长随失败次数翻倍
+func recordMFAFailure(c *gin.Context, userID uint) error {
+	return runTx(c, func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "mfa_failures").First(&user, userID).Error; err != nil {
+			return err
+		}
+
+		updates := map[string]interface{}{"mfa_failures": user.MFAFailures + 1}
+		if lockout := mfaLockout(user.MFAFailures + 1); lockout > 0 {
+			updates["mfa_locked_until"] = time.Now().Add(lockout)
+			utils.LogInfo("mfa locked after repeated failures", utils.WithUserID(userID), zap.Int("failures", user.MFAFailures+1))
+		}
+		return tx.Model(&user).Updates(updates).Error
+	})
+}
+
+// mfaLockout 计算连续失败failures次后的锁定时长，未达到上限时返回0
+func mfaLockout(failures int) time.Duration {
+	if failures < maxMFAAttempts {
+		return 0
+	}
+	lockout := mfaLockoutBase
+	for i := maxMFAAttempts; i < failures && lockout < mfaLockoutMax; i++ {
+		lockout *= 2
+	}
+	if lockout > mfaLockoutMax {
+		lockout = mfaLockoutMax
+	}
+	return lockout
+}
@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+	"github.com/test/blog/utils"
+)
+
+func TestMFALockout(t *testing.T) {
+	tests := []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, 0},
+		{maxMFAAttempts - 1, 0},
+		{maxMFAAttempts, time.Minute},
+		{maxMFAAttempts + 1, 2 * time.Minute},
+		{maxMFAAttempts + 3, 8 * time.Minute},
+		{maxMFAAttempts + 100, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := mfaLockout(tt.failures); got != tt.want {
+			t.Errorf("mfaLockout(%d) = %v, want %v", tt.failures, got, tt.want)
+		}
+	}
+}
+
+// setupTwoFactorUser 创建已启用两步验证的用户和一个可用的恢复码
+func setupTwoFactorUser(t *testing.T) (models.User, string) {
+	t.Helper()
+	user := testutil.CreateUser(t, "alice", func(u *models.User) {
+		u.TOTPSecret = "JBSWY3DPEHPK3PXP"
+		u.TOTPEnabled = true
+	})
+	const code = "abcde-fghij"
+	if err := config.GetDB().Create(&models.RecoveryCode{UserID: user.ID, CodeHash: utils.HashRecoveryCode(code)}).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user, code
+}
+
+func mfaToken(t *testing.T, user models.User) string {
+	t.Helper()
+	token, err := config.JWTKeys().GenerateMFAToken(user.ID, user.Username, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func TestVerifyTwoFactorLockout(t *testing.T) {
+	testutil.Setup(t)
+	user, code := setupTwoFactorUser(t)
+	r := gin.New()
+	r.POST("/verify", VerifyTwoFactor)
+
+	verify := func(token, recoveryCode string) int {
+		return testutil.Request(t, r, http.MethodPost, "/verify", "", gin.H{"mfa_token": token, "recovery_code": recoveryCode}).Code
+	}
+
+	for i := 0; i < maxMFAAttempts; i++ {
+		if got := verify(mfaToken(t, user), "wrong-code"); got != http.StatusUnauthorized {
+			t.Fatalf("attempt %d status = %d, want 401", i+1, got)
+		}
+	}
+
+	// 锁定按用户计算，换一个新的挑战令牌也不能继续尝试
+	w := testutil.Request(t, r, http.MethodPost, "/verify", "", gin.H{"mfa_token": mfaToken(t, user), "recovery_code": code})
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Fatalf("locked status = %d, Retry-After %q", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	var stored models.User
+	config.GetDB().First(&stored, user.ID)
+	if stored.MFAFailures != maxMFAAttempts || stored.MFALockedUntil == nil {
+		t.Fatalf("failures = %d, locked until %v", stored.MFAFailures, stored.MFALockedUntil)
+	}
+
+	// 锁定结束后验证成功，失败次数清零
+	config.GetDB().Model(&stored).Update("mfa_locked_until", time.Now().Add(-time.Second))
+	token := mfaToken(t, user)
+	if got := verify(token, code); got != http.StatusOK {
+		t.Fatalf("status after lockout = %d, want 200", got)
+	}
+	stored = models.User{}
+	config.GetDB().First(&stored, user.ID)
+	if stored.MFAFailures != 0 || stored.MFALockedUntil != nil || stored.MFAVerifiedAt == nil {
+		t.Errorf("state not reset: failures %d, locked until %v", stored.MFAFailures, stored.MFALockedUntil)
+	}
+
+	// 挑战令牌只能成功使用一次，即使提交的是另一个有效的恢复码
+	const other = "klmno-pqrst"
+	config.GetDB().Create(&models.RecoveryCode{UserID: user.ID, CodeHash: utils.HashRecoveryCode(other)})
+	if got := verify(token, other); got != http.StatusUnauthorized {
+		t.Errorf("reused token status = %d, want 401", got)
+	}
+}
+
+func TestVerifyTwoFactorAfterVerification(t *testing.T) {
+	testutil.Setup(t)
+	user, code := setupTwoFactorUser(t)
+	r := gin.New()
+	r.POST("/verify", VerifyTwoFactor)
+
+	if w := testutil.Request(t, r, http.MethodPost, "/verify", "", gin.H{"mfa_token": mfaToken(t, user), "recovery_code": code}); w.Code != http.StatusOK {
+		t.Fatalf("status = %d: %s", w.Code, w.Body.String())
+	}
+	var stored models.User
+	config.GetDB().First(&stored, user.ID)
+	if stored.MFAVerifiedAt == nil || stored.MFAVerifiedAt.Nanosecond() != 0 {
+		t.Fatalf("verified at = %v, want whole seconds", stored.MFAVerifiedAt)
+	}
+
+	// 下一秒签发的挑战令牌不受上一次验证影响
+	time.Sleep(time.Until(stored.MFAVerifiedAt.Add(time.Second)))
+	const other = "klmno-pqrst"
+	config.GetDB().Create(&models.RecoveryCode{UserID: user.ID, CodeHash: utils.HashRecoveryCode(other)})
+	if w := testutil.Request(t, r, http.MethodPost, "/verify", "", gin.H{"mfa_token": mfaToken(t, user), "recovery_code": other}); w.Code != http.StatusOK {
+		t.Errorf("next login status = %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestVerifyTwoFactorLocksAgainAfterLockout(t *testing.T) {
+	testutil.Setup(t)
+	user, _ := setupTwoFactorUser(t)
+	r := gin.New()
+	r.POST("/verify", VerifyTwoFactor)
+
+	// 锁定结束后再次失败，立即以更长的时长重新锁定
+	config.GetDB().Model(&user).Updates(map[string]interface{}{"mfa_failures": maxMFAAttempts, "mfa_locked_until": time.Now().Add(-time.Second)})
+	w := testutil.Request(t, r, http.MethodPost, "/verify", "", gin.H{"mfa_token": mfaToken(t, user), "recovery_code": "wrong-code"})
+	if w.Code != http.StatusUnauthorized {
+		t.Fatalf("status = %d, want 401", w.Code)
+	}
+
+	var stored models.User
+	config.GetDB().First(&stored, user.ID)
+	if stored.MFALockedUntil == nil || time.Until(*stored.MFALockedUntil) <= time.Minute {
+		t.Errorf("locked until %v, want about two minutes from now", stored.MFALockedUntil)
+	}
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/storage"
+	"github.com/test/blog/utils"
+	"gorm.io/gorm"
+)
+
+// multipartOverhead 请求体中除文件外的multipart开销上限
+const multipartOverhead = 1 << 20
+
+// errInvalidAttachments 附件不存在、不属于当前用户或已关联其他文章
+var errInvalidAttachments = errors.New("invalid attachment ids")
+
+// UploadFile 上传图片，返回的附件id在创建或更新文章时通过attachment_ids关联
+func UploadFile(c *gin.Context) {
+	userID, exists := c.Get("user_id")
+	if !exists {
+		utils.LogError("upload unauthorized", nil)
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "User not authenticated",
+		})
+		return
+	}
+
+	cfg := config.Get().Upload
+	maxSize := int64(cfg.MaxSizeMB) << 20
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxSize+multipartOverhead)
+
+	file, header, err := c.Request.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"message": "File is too large",
+			})
+			return
+		}
+		utils.LogError("upload missing file", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Missing file field",
+		})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxSize+1))
+	if err != nil {
+		utils.LogError("upload read error", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Failed to read file",
+		})
+		return
+	}
+	if int64(len(data)) > maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+			"success": false,
+			"message": "File is too large",
+		})
+		return
+	}
+
+	// 根据文件内容识别类型，不信任客户端提供的Content-Type和扩展名
+	contentType := http.DetectContentType(data)
+	ext, known := utils.ImageExtensions[contentType]
+	if !known || !contains(cfg.AllowedTypes, contentType) {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{
+			"success": false,
+			"message": "Unsupported file type: " + contentType,
+		})
+		return
+	}
+
+	imgCfg, err := utils.DecodeImageConfig(data)
+	if err != nil {
+		utils.LogError("upload invalid image", err)
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid or oversized image",
+		})
+		return
+	}
+
+	store := storage.Get()
+	ctx := c.Request.Context()
+	id := uuid.New().String()
+	dir := time.Now().Format("2006/01")
+
+	attachment := models.Attachment{
+		UserID:       userID.(uint),
+		StorageKey:   path.Join(dir, id+ext),
+		OriginalName: path.Base(header.Filename),
+		ContentType:  contentType,
+		Size:         int64(len(data)),
+		Width:        imgCfg.Width,
+		Height:       imgCfg.Height,
+	}
+	if err := store.Put(ctx, attachment.StorageKey, bytes.NewReader(data), attachment.Size, contentType); err != nil {
+		utils.LogError("upload storage error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to store file",
+		})
+		return
+	}
+
+	// 缩略图生成失败不影响上传
+	thumb, thumbType, err := utils.GenerateThumbnail(data, contentType, cfg.ThumbnailWidth)
+	if err != nil {
+		utils.LogError("upload thumbnail error", err)
+	} else if thumb != nil {
+		thumbKey := path.Join(dir, id+"_thumb"+utils.ImageExtensions[thumbType])
+		if err := store.Put(ctx, thumbKey, bytes.NewReader(thumb), int64(len(thumb)), thumbType); err != nil {
+			utils.LogError("upload thumbnail storage error", err)
+		} else {
+			attachment.ThumbnailKey = thumbKey
+		}
+	}
+
+	if err := config.GetDB().Create(&attachment).Error; err != nil {
+		utils.LogError("create attachment database error", err)
+		deleteAttachmentFiles(context.Background(), attachment)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to save attachment",
+		})
+		return
+	}
+
+	setAttachmentURLs(&attachment)
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "File uploaded successfully",
+		"data":    attachment,
+	})
+}
+
+// linkAttachments 将当前用户上传的附件关联到文章
+// 附件必须属于该用户，且尚未关联文章或已关联到同一篇文章
+// MySQL的RowsAffected只统计值发生变化的行，重复关联同一篇文章时为0，所以先查询符合条件的数量
+func linkAttachments(tx *gorm.DB, userID, postID uint, ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	seen := make(map[uint]struct{}, len(ids))
+	unique := make([]uint, 0, len(ids))
+	for _, id := range ids {
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		unique = append(unique, id)
+	}
+
+	var count int64
+	if err := tx.Model(&models.Attachment{}).
+		Where("id IN ? AND user_id = ? AND (post_id IS NULL OR post_id = ?)", unique, userID, postID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count != int64(len(unique)) {
+		return errInvalidAttachments
+	}
+	return tx.Model(&models.Attachment{}).
+		Where("id IN ?", unique).
+		Update("post_id", postID).Error
+}
+
+// setAttachmentURLs 根据当前存储生成附件的访问地址
+func setAttachmentURLs(attachment *models.Attachment) {
+	store := storage.Get()
+	attachment.URL = store.URL(attachment.StorageKey)
+	if attachment.ThumbnailKey != "" {
+		attachment.ThumbnailURL = store.URL(attachment.ThumbnailKey)
+	}
+}
+
+// deleteAttachmentFiles 删除附件在存储中的文件
+func deleteAttachmentFiles(ctx context.Context, attachment models.Attachment) {
+	store := storage.Get()
+	if err := store.Delete(ctx, attachment.StorageKey); err != nil {
+		utils.LogError("delete attachment file error", err)
+	}
+	if attachment.ThumbnailKey != "" {
+		if err := store.Delete(ctx, attachment.ThumbnailKey); err != nil {
+			utils.LogError("delete attachment thumbnail error", err)
+		}
+	}
+}
+
+// contains 判断切片是否包含指定字符串
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/storage"
+	"github.com/test/blog/testutil"
+)
+
+// uploadRequest 构造multipart上传请求
+func uploadRequest(t *testing.T, token, filename string, data []byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	part, err := w.CreateFormFile("file", filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write(data)
+	w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/uploads", &body)
+	req.Header.Set("Content-Type", w.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func pngImage(t *testing.T, width, height int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestUploadFile(t *testing.T) {
+	testutil.Setup(t)
+	dir := t.TempDir()
+	local, err := storage.NewLocalStorage(dir, "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.Set(local)
+	cfg := config.DefaultConfig()
+	cfg.Upload.MaxSizeMB = 1
+	config.Set(cfg)
+
+	user := testutil.CreateUser(t, "alice")
+	token := testutil.Token(t, user)
+	r := gin.New()
+	r.POST("/uploads", middleware.AuthMiddleware(), UploadFile)
+
+	tests := []struct {
+		name      string
+		filename  string
+		data      []byte
+		want      int
+		wantThumb bool
+	}{
+		{"large image gets a thumbnail", "photo.png", pngImage(t, 800, 600), http.StatusCreated, true},
+		{"small image", "icon.png", pngImage(t, 16, 16), http.StatusCreated, false},
+		// 类型根据内容识别，扩展名无关
+		{"text disguised as image", "evil.png", []byte("<script>alert(1)</script>"), http.StatusUnsupportedMediaType, false},
+		{"too large", "big.png", make([]byte, 1<<20+1), http.StatusRequestEntityTooLarge, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, uploadRequest(t, token, tt.filename, tt.data))
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+			if tt.want != http.StatusCreated {
+				return
+			}
+
+			var attachment models.Attachment
+			if err := config.GetDB().Where("original_name = ?", tt.filename).First(&attachment).Error; err != nil {
+				t.Fatal(err)
+			}
+			if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(attachment.StorageKey))); err != nil {
+				t.Errorf("stored file missing: %v", err)
+			}
+			if (attachment.ThumbnailKey != "") != tt.wantThumb {
+				t.Errorf("thumbnail key = %q, want thumbnail %v", attachment.ThumbnailKey, tt.wantThumb)
+			}
+			data := testutil.Decode(t, w)["data"].(map[string]any)
+			if data["url"] != "/uploads/"+attachment.StorageKey {
+				t.Errorf("url = %v", data["url"])
+			}
+		})
+	}
+}
+
+func TestLinkAttachments(t *testing.T) {
+	testutil.Setup(t)
+	alice := testutil.CreateUser(t, "alice")
+	bob := testutil.CreateUser(t, "bob")
+	post := testutil.CreatePost(t, alice, "Post")
+	other := testutil.CreatePost(t, alice, "Other")
+
+	var created int
+	attachment := func(owner models.User, postID *uint) uint {
+		created++
+		a := models.Attachment{UserID: owner.ID, PostID: postID, StorageKey: fmt.Sprintf("key-%d", created), ContentType: "image/png"}
+		if err := config.GetDB().Create(&a).Error; err != nil {
+			t.Fatal(err)
+		}
+		return a.ID
+	}
+	free := attachment(alice, nil)
+	linked := attachment(alice, &post.ID)
+	elsewhere := attachment(alice, &other.ID)
+	bobs := attachment(bob, nil)
+
+	tests := []struct {
+		name    string
+		ids     []uint
+		wantErr bool
+	}{
+		{"unlinked", []uint{free}, false},
+		// 重复的ID和已关联到同一篇文章的附件都不算无效
+		{"duplicates and already linked", []uint{free, free, linked}, false},
+		{"linked to another post", []uint{free, elsewhere}, true},
+		{"another user's attachment", []uint{bobs}, true},
+		{"missing", []uint{free, 99999}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := linkAttachments(config.GetDB(), alice.ID, post.ID, tt.ids)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	var bobAttachment models.Attachment
+	config.GetDB().First(&bobAttachment, bobs)
+	if bobAttachment.PostID != nil {
+		t.Error("another user's attachment was linked")
+	}
+}
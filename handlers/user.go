@@ -0,0 +1,13 @@
+package handlers
+
+// 删除用户时对其内容的处理方式
+const (
+	DeleteModeAnonymize = "anonymize" // 保留文章和评论，只匿名化用户信息
+	DeleteModeRemove    = "remove"    // 文章和评论一起删除（文章进入回收站）
+)
+
+// DeleteAccountRequest 注销账号请求
+type DeleteAccountRequest struct {
+	Confirm string `json:"confirm" binding:"required"` // 需要填写当前用户名以确认
+	Mode    string `json:"mode" binding:"omitempty,oneof=anonymize remove"`
+}
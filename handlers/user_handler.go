@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"gorm.io/gorm"
+)
+
+// DeleteAccount 注销当前用户的账号
+func DeleteAccount(c *gin.Context) {
+	var req DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request data: " + err.Error(),
+		})
+		return
+	}
+
+	user, ok := currentUser(c)
+	if !ok {
+		return
+	}
+
+	if req.Confirm != user.Username {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Confirmation does not match your username",
+		})
+		return
+	}
+
+	mode := req.Mode
+	if mode == "" {
+		mode = DeleteModeAnonymize
+	}
+	var postIDs []uint
+	if err := runTx(c, func(tx *gorm.DB) error {
+		var err error
+		postIDs, err = deleteUser(tx, user, mode)
+		return err
+	}); err != nil {
+		utils.LogError("delete account database error", err, utils.WithUserID(user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to delete account",
+		})
+		return
+	}
+
+	invalidatePostCache(c.Request.Context(), postIDs...)
+	utils.LogInfo("account deleted", utils.WithUserID(user.ID))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Account deleted successfully",
+	})
+}
+
+// AdminDeleteUser 删除指定用户（仅管理员）
+func AdminDeleteUser(c *gin.Context) {
+	userID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid user id",
+		})
+		return
+	}
+
+	mode := c.DefaultQuery("mode", DeleteModeAnonymize)
+	if mode != DeleteModeAnonymize && mode != DeleteModeRemove {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid mode, must be anonymize or remove",
+		})
+		return
+	}
+
+	if uint(userID) == c.GetUint("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Use DELETE /api/profile to delete your own account",
+		})
+		return
+	}
+
+	var user models.User
+	if err := config.GetDB().First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "User not found",
+		})
+		return
+	}
+
+	var postIDs []uint
+	if err := runTx(c, func(tx *gorm.DB) error {
+		var err error
+		postIDs, err = deleteUser(tx, user, mode)
+		return err
+	}); err != nil {
+		utils.LogError("delete user database error", err, utils.WithUserID(user.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to delete user",
+		})
+		return
+	}
+
+	invalidatePostCache(c.Request.Context(), postIDs...)
+	utils.LogInfo("user deleted by admin", utils.WithUserID(user.ID))
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "User deleted successfully",
+	})
+}
+
+// deleteUser 删除用户，需要在事务中调用
+// 用户记录被匿名化后软删除，用户名和邮箱可以重新注册；登录凭据（令牌、外部身份、恢复码）全部删除，
+// 尚未完成的个人数据导出被取消，已生成的导出文件立即过期，由清理任务删除。
+// remove模式下用户的文章（连同文章下的评论）和用户发表的评论一起软删除
+// 返回受影响的文章id（用户的文章和用户评论过的文章），调用方需要在提交后使这些文章的缓存失效
+func deleteUser(tx *gorm.DB, user models.User, mode string) ([]uint, error) {
+	now := time.Now()
+
+	var postIDs []uint
+	if err := tx.Model(&models.Post{}).Where("user_id = ?", user.ID).Pluck("id", &postIDs).Error; err != nil {
+		return nil, err
+	}
+
+	if mode == DeleteModeRemove {
+		if len(postIDs) > 0 {
+			if err := tx.Model(&models.Post{}).Where("id IN ?", postIDs).UpdateColumn("deleted_at", now).Error; err != nil {
+				return nil, err
+			}
+			if err := softDeletePostComments(tx, postIDs, now); err != nil {
+				return nil, err
+			}
+		}
+		var commentedPostIDs []uint
+		if err := tx.Model(&models.Comment{}).Where("user_id = ?", user.ID).Distinct().Pluck("post_id", &commentedPostIDs).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&models.Comment{}).Where("user_id = ?", user.ID).UpdateColumn("deleted_at", now).Error; err != nil {
+			return nil, err
+		}
+		if err := models.RefreshCommentStats(tx, commentedPostIDs...); err != nil {
+			return nil, err
+		}
+		postIDs = append(postIDs, commentedPostIDs...)
+	}
+
+	if err := tx.Where("user_id = ?", user.ID).Delete(&models.PersonalAccessToken{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.UserIdentity{}).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	if err := expireDataExports(tx, user.ID, now); err != nil {
+		return nil, err
+	}
+
+	// 密码设置为无法通过校验的值
+	err := tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumns(map[string]interface{}{
+		"username":       fmt.Sprintf("deleted-%d", user.ID),
+		"email":          fmt.Sprintf("deleted-%d@deleted.invalid", user.ID),
+		"password":       "!",
+		"totp_secret":    "",
+		"totp_enabled":   false,
+		"totp_last_step": 0,
+		"deleted_at":     now,
+	}).Error
+	return postIDs, err
+}
@@ -0,0 +1,51 @@
+package jobs
+
+import (
+	"context"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"gorm.io/gorm"
+)
+
+// reconcileBatch 每批检查的文章数量
+const reconcileBatch = 500
+
+// ReconcileCommentStats 检查所有文章（包括回收站中的文章）的评论数和最后评论时间，修正与实际评论不一致的文章
+// dryRun为true时只检查不修改，返回不一致的文章id
+func ReconcileCommentStats(ctx context.Context, dryRun bool) ([]uint, error) {
+	db := config.GetDB().WithContext(ctx)
+
+	var drifted []uint
+	var lastID uint
+	for {
+		var ids []uint
+		err := db.Unscoped().Model(&models.Post{}).
+			Where("id > ?", lastID).Order("id").Limit(reconcileBatch).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return drifted, err
+		}
+		if len(ids) == 0 {
+			return drifted, nil
+		}
+		lastID = ids[len(ids)-1]
+
+		batch, err := models.DriftedCommentStats(db, ids...)
+		if err != nil {
+			return drifted, err
+		}
+		if len(batch) == 0 {
+			continue
+		}
+		drifted = append(drifted, batch...)
+		if dryRun {
+			continue
+		}
+		if err := config.WithTx(ctx, func(tx *gorm.DB) error {
+			return models.RefreshCommentStats(tx, batch...)
+		}); err != nil {
+			return drifted, err
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package jobs
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func TestReconcileCommentStats(t *testing.T) {
+	db := testutil.Setup(t)
+	user := testutil.CreateUser(t, "alice")
+
+	create := func(title string, comments int, mutate func(*models.Post)) models.Post {
+		t.Helper()
+		post := testutil.CreatePost(t, user, title)
+		for i := 0; i < comments; i++ {
+			db.Create(&models.Comment{Content: "c", UserID: user.ID, PostID: post.ID})
+		}
+		if err := models.RefreshCommentStats(db, post.ID); err != nil {
+			t.Fatal(err)
+		}
+		if mutate != nil {
+			db.First(&post, post.ID)
+			mutate(&post)
+			db.Unscoped().Model(&post).UpdateColumns(map[string]any{"comment_count": post.CommentCount, "last_comment_at": post.LastCommentAt})
+		}
+		return post
+	}
+	create("correct", 2, nil)
+	create("empty", 0, nil)
+	wrongCount := create("wrong count", 2, func(p *models.Post) { p.CommentCount = 5 })
+	missingTime := create("missing time", 1, func(p *models.Post) { p.LastCommentAt = nil })
+	stale := time.Now().Add(-time.Hour)
+	noComments := create("no comments", 0, func(p *models.Post) { p.CommentCount = 1; p.LastCommentAt = &stale })
+	// 回收站中的文章同样检查
+	trashed := create("trashed", 1, func(p *models.Post) { p.CommentCount = 0 })
+	db.Delete(&trashed)
+
+	want := []uint{wrongCount.ID, missingTime.ID, noComments.ID, trashed.ID}
+	drifted, err := ReconcileCommentStats(context.Background(), true)
+	if err != nil || !reflect.DeepEqual(drifted, want) {
+		t.Fatalf("dry run = %v, %v, want %v", drifted, err, want)
+	}
+	var count int
+	db.Model(&models.Post{}).Where("id = ?", wrongCount.ID).Select("comment_count").Scan(&count)
+	if count != 5 {
+		t.Fatal("dry run modified the post")
+	}
+
+	if drifted, err := ReconcileCommentStats(context.Background(), false); err != nil || !reflect.DeepEqual(drifted, want) {
+		t.Fatalf("reconcile = %v, %v, want %v", drifted, err, want)
+	}
+	if drifted, err := ReconcileCommentStats(context.Background(), true); err != nil || len(drifted) != 0 {
+		t.Errorf("after reconcile = %v, %v, want no drift", drifted, err)
+	}
+	var fixed models.Post
+	config.GetDB().Unscoped().First(&fixed, trashed.ID)
+	if fixed.CommentCount != 1 || fixed.LastCommentAt == nil {
+		t.Errorf("trashed post stats = %d %v", fixed.CommentCount, fixed.LastCommentAt)
+	}
+}
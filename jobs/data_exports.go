@@ -0,0 +1,196 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/dataexport"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// dataExportBatch 每轮最多生成的导出数量，避免一轮占用太长时间
+const dataExportBatch = 10
+
+// staleDataExportTimeout 生成中的导出超过该时间仍未完成，视为进程在生成过程中退出
+const staleDataExportTimeout = 30 * time.Minute
+
+// dataExportWake 有新的导出申请时唤醒后台任务，不必等到下一个周期
+var dataExportWake = make(chan struct{}, 1)
+
+// NotifyDataExport 通知后台任务有新的导出申请，任务未启动时不会阻塞
+func NotifyDataExport() {
+	select {
+	case dataExportWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartDataExports 定期生成等待中的个人数据导出并删除过期的导出文件，ctx取消后停止
+func StartDataExports(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if n, err := ProcessDataExports(ctx); err != nil {
+				utils.LogError("data export processing failed", err)
+			} else if n > 0 {
+				utils.LogInfo("data exports processed", zap.Int("count", n))
+			}
+			if n, err := PurgeExpiredDataExports(ctx); err != nil {
+				utils.LogError("data export purge failed", err)
+			} else if n > 0 {
+				utils.LogInfo("expired data exports purged", zap.Int("count", n))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-dataExportWake:
+			}
+		}
+	}()
+}
+
+// ProcessDataExports 生成等待中的导出，返回处理的数量（包括生成失败的）
+// 多个实例同时运行时通过条件更新状态认领导出，同一个导出只会由一个实例生成
+func ProcessDataExports(ctx context.Context) (int, error) {
+	db := config.GetDB().WithContext(ctx)
+
+	// 生成过程中进程退出的导出标记为失败，用户可以重新申请
+	err := db.Model(&models.DataExport{}).
+		Where("status = ? AND started_at < ?", models.DataExportRunning, time.Now().Add(-staleDataExportTimeout)).
+		Updates(map[string]interface{}{"status": models.DataExportFailed, "error": "Export was interrupted, please request a new one"}).Error
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for processed < dataExportBatch {
+		var export models.DataExport
+		err := db.Where("status = ?", models.DataExportPending).Order("id").First(&export).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return processed, nil
+		}
+		if err != nil {
+			return processed, err
+		}
+
+		result := db.Model(&models.DataExport{}).
+			Where("id = ? AND status = ?", export.ID, models.DataExportPending).
+			Updates(map[string]interface{}{"status": models.DataExportRunning, "started_at": time.Now()})
+		if result.Error != nil {
+			return processed, result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue // 已被其他实例认领
+		}
+
+		if err := generateDataExport(ctx, export); err != nil {
+			utils.LogError("data export generation failed", err, utils.WithUserID(export.UserID), zap.Uint("export_id", export.ID))
+			err = db.Model(&models.DataExport{}).
+				Where("id = ? AND status = ?", export.ID, models.DataExportRunning).
+				Updates(map[string]interface{}{"status": models.DataExportFailed, "error": "Failed to generate export"}).Error
+			if err != nil {
+				return processed, err
+			}
+		}
+		processed++
+	}
+	return processed, nil
+}
+
+// generateDataExport 生成导出文件并将导出标记为可下载
+// 文件先写入临时文件再重命名，文件名包含随机部分；生成期间导出被取消（如用户注销）时删除文件
+func generateDataExport(ctx context.Context, export models.DataExport) error {
+	cfg := config.Get().DataExport
+	if err := os.MkdirAll(cfg.Dir, 0o700); err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 16)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.zip", export.ID, hex.EncodeToString(suffix))
+
+	tmp, err := os.CreateTemp(cfg.Dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	err = dataexport.Build(ctx, export.UserID, tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	path := filepath.Join(cfg.Dir, name)
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+	now := time.Now()
+	result := config.GetDB().WithContext(ctx).Model(&models.DataExport{}).
+		Where("id = ? AND status = ?", export.ID, models.DataExportRunning).
+		Updates(map[string]interface{}{
+			"status":       models.DataExportReady,
+			"file_name":    name,
+			"size":         info.Size(),
+			"completed_at": now,
+			"expires_at":   now.Add(time.Duration(cfg.RetentionHours) * time.Hour),
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		os.Remove(path)
+	}
+	return result.Error
+}
+
+// PurgeExpiredDataExports 删除过期的导出文件并将导出标记为已过期，返回清理的数量
+// 文件删除失败的导出保留到下次重试
+func PurgeExpiredDataExports(ctx context.Context) (int, error) {
+	db := config.GetDB().WithContext(ctx)
+	dir := config.Get().DataExport.Dir
+
+	var exports []models.DataExport
+	err := db.Where("status = ? AND expires_at < ?", models.DataExportReady, time.Now()).
+		Order("id").Find(&exports).Error
+	if err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for _, export := range exports {
+		if export.FileName != "" {
+			err := os.Remove(filepath.Join(dir, export.FileName))
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				utils.LogError("delete data export file error", err, zap.Uint("export_id", export.ID))
+				continue
+			}
+		}
+		err := db.Model(&models.DataExport{}).Where("id = ?", export.ID).
+			Updates(map[string]interface{}{"status": models.DataExportExpired, "file_name": ""}).Error
+		if err != nil {
+			return purged, err
+		}
+		purged++
+	}
+	return purged, nil
+}
@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func TestProcessDataExports(t *testing.T) {
+	testutil.Setup(t)
+	cfg := config.DefaultConfig()
+	cfg.DataExport.Dir = t.TempDir()
+	config.Set(cfg)
+	db := config.GetDB()
+	user := testutil.CreateUser(t, "alice")
+
+	pending := models.DataExport{UserID: user.ID, Status: models.DataExportPending}
+	startedAt := time.Now().Add(-2 * staleDataExportTimeout)
+	stale := models.DataExport{UserID: user.ID, Status: models.DataExportRunning, StartedAt: &startedAt}
+	db.Create(&pending)
+	db.Create(&stale)
+
+	n, err := ProcessDataExports(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("processed %d, %v, want 1", n, err)
+	}
+
+	tests := []struct {
+		id     uint
+		status string
+	}{
+		{pending.ID, models.DataExportReady},
+		{stale.ID, models.DataExportFailed},
+	}
+	for _, tt := range tests {
+		var export models.DataExport
+		db.First(&export, tt.id)
+		if export.Status != tt.status {
+			t.Errorf("export %d status = %s, want %s", tt.id, export.Status, tt.status)
+		}
+	}
+
+	var ready models.DataExport
+	db.First(&ready, pending.ID)
+	info, err := os.Stat(filepath.Join(cfg.DataExport.Dir, ready.FileName))
+	if err != nil || info.Size() != ready.Size || ready.ExpiresAt == nil {
+		t.Fatalf("export file = %v, %v, record = %+v", info, err, ready)
+	}
+
+	// 过期后删除文件
+	db.Model(&ready).UpdateColumn("expires_at", time.Now().Add(-time.Minute))
+	if n, err := PurgeExpiredDataExports(context.Background()); err != nil || n != 1 {
+		t.Fatalf("purged %d, %v, want 1", n, err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.DataExport.Dir, ready.FileName)); !os.IsNotExist(err) {
+		t.Errorf("expired export file still exists: %v", err)
+	}
+	db.First(&ready, pending.ID)
+	if ready.Status != models.DataExportExpired || ready.FileName != "" {
+		t.Errorf("purged export = %s %q", ready.Status, ready.FileName)
+	}
+}
@@ -0,0 +1,54 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"go.uber.org/zap"
+)
+
+// idempotencyPurgeBatch 每批删除的过期幂等键数量
+const idempotencyPurgeBatch = 1000
+
+// StartIdempotencyPurge 定期删除过期的幂等键，ctx取消后停止
+func StartIdempotencyPurge(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if n, err := PurgeExpiredIdempotencyKeys(ctx); err != nil {
+				utils.LogError("idempotency key purge failed", err)
+			} else if n > 0 {
+				utils.LogInfo("expired idempotency keys purged", zap.Int64("count", n))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PurgeExpiredIdempotencyKeys 分批删除过期的幂等键，返回删除的数量
+func PurgeExpiredIdempotencyKeys(ctx context.Context) (int64, error) {
+	db := config.GetDB().WithContext(ctx)
+	now := time.Now()
+
+	var purged int64
+	for {
+		result := db.Where("expires_at < ?", now).Limit(idempotencyPurgeBatch).Delete(&models.IdempotencyKey{})
+		if result.Error != nil {
+			return purged, result.Error
+		}
+		purged += result.RowsAffected
+		if result.RowsAffected < idempotencyPurgeBatch {
+			return purged, nil
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// trashPurgeBatch 每批永久删除的文章数量
+const trashPurgeBatch = 100
+
+// StartTrashPurge 定期永久删除在回收站中超过保留天数的文章，ctx取消后停止
+func StartTrashPurge(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if n, err := PurgeDeletedPosts(ctx); err != nil {
+				utils.LogError("trash purge failed", err)
+			} else if n > 0 {
+				utils.LogInfo("deleted posts purged", zap.Int("count", n))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// PurgeDeletedPosts 永久删除超过保留天数的文章及其评论、修订版本、历史slug和浏览统计，返回删除的文章数量
+// 文章的附件解除关联后由上传文件清理任务删除
+func PurgeDeletedPosts(ctx context.Context) (int, error) {
+	days := config.Get().Trash.RetentionDays
+	cutoff := time.Now().AddDate(0, 0, -days)
+	db := config.GetDB().WithContext(ctx)
+
+	purged := 0
+	for {
+		var ids []uint
+		err := db.Unscoped().Model(&models.Post{}).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Order("id").Limit(trashPurgeBatch).
+			Pluck("id", &ids).Error
+		if err != nil {
+			return purged, err
+		}
+		if len(ids) == 0 {
+			return purged, nil
+		}
+
+		err = config.WithTx(ctx, func(tx *gorm.DB) error {
+			if err := tx.Unscoped().Where("post_id IN ?", ids).Delete(&models.Comment{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id IN ?", ids).Delete(&models.PostRevision{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id IN ?", ids).Delete(&models.PostSlug{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("post_id IN ?", ids).Delete(&models.PostViewStat{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Attachment{}).Where("post_id IN ?", ids).Update("post_id", nil).Error; err != nil {
+				return err
+			}
+			return tx.Unscoped().Where("id IN ?", ids).Delete(&models.Post{}).Error
+		})
+		if err != nil {
+			return purged, err
+		}
+		purged += len(ids)
+	}
+}
@@ -0,0 +1,62 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func TestPurgeDeletedPosts(t *testing.T) {
+	testutil.Setup(t)
+	cfg := config.DefaultConfig()
+	cfg.Trash.RetentionDays = 30
+	config.Set(cfg)
+	db := config.GetDB()
+	user := testutil.CreateUser(t, "alice")
+
+	create := func(title string, deletedAt *time.Time) models.Post {
+		t.Helper()
+		post := testutil.CreatePost(t, user, title)
+		db.Create(&models.Comment{Content: "c", UserID: user.ID, PostID: post.ID})
+		db.Create(&models.PostRevision{PostID: post.ID, Number: 1, Title: title, Content: post.Content, EditorID: user.ID})
+		db.Create(&models.Attachment{UserID: user.ID, PostID: &post.ID, StorageKey: title + ".png", ContentType: "image/png", Size: 1})
+		if deletedAt != nil {
+			db.Model(&post).UpdateColumn("deleted_at", *deletedAt)
+		}
+		return post
+	}
+	expired := time.Now().AddDate(0, 0, -31)
+	recent := time.Now().AddDate(0, 0, -1)
+	old := create("old", &expired)
+	kept := create("recent", &recent)
+	live := create("live", nil)
+
+	n, err := PurgeDeletedPosts(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("purged %d, %v, want 1", n, err)
+	}
+
+	for _, tt := range []struct {
+		post models.Post
+		want int64
+	}{{old, 0}, {kept, 1}, {live, 1}} {
+		var posts, comments, revisions int64
+		db.Unscoped().Model(&models.Post{}).Where("id = ?", tt.post.ID).Count(&posts)
+		db.Unscoped().Model(&models.Comment{}).Where("post_id = ?", tt.post.ID).Count(&comments)
+		db.Model(&models.PostRevision{}).Where("post_id = ?", tt.post.ID).Count(&revisions)
+		if posts != tt.want || comments != tt.want || revisions != tt.want {
+			t.Errorf("%s: posts %d, comments %d, revisions %d, want %d", tt.post.Title, posts, comments, revisions, tt.want)
+		}
+	}
+
+	// 附件解除关联，留给上传文件清理任务删除
+	var attachment models.Attachment
+	db.Where("storage_key = ?", "old.png").First(&attachment)
+	if attachment.ID == 0 || attachment.PostID != nil {
+		t.Errorf("attachment of purged post = %+v", attachment)
+	}
+}
@@ -0,0 +1,80 @@
+// Package jobs 后台定时任务
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/storage"
+	"github.com/test/blog/utils"
+	"go.uber.org/zap"
+)
+
+// orphanCleanupBatch 每批清理的附件数量
+const orphanCleanupBatch = 100
+
+// StartOrphanUploadCleanup 定期清理未关联文章且超过保留时间的上传文件，ctx取消后停止
+func StartOrphanUploadCleanup(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if n, err := CleanupOrphanUploads(ctx); err != nil {
+				utils.LogError("orphan upload cleanup failed", err)
+			} else if n > 0 {
+				utils.LogInfo("orphan uploads removed", zap.Int("count", n))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// CleanupOrphanUploads 删除未关联文章且超过保留时间的附件，返回删除的数量
+// 先删除存储中的文件再删除记录，文件删除失败的记录保留到下次重试
+func CleanupOrphanUploads(ctx context.Context) (int, error) {
+	ttl := time.Duration(config.Get().Upload.OrphanTTLHours) * time.Hour
+	cutoff := time.Now().Add(-ttl)
+	store := storage.Get()
+
+	removed := 0
+	lastID := uint(0)
+	for {
+		var attachments []models.Attachment
+		err := config.GetDB().WithContext(ctx).
+			Where("post_id IS NULL AND created_at < ? AND id > ?", cutoff, lastID).
+			Order("id").Limit(orphanCleanupBatch).
+			Find(&attachments).Error
+		if err != nil {
+			return removed, err
+		}
+		if len(attachments) == 0 {
+			return removed, nil
+		}
+
+		for _, attachment := range attachments {
+			lastID = attachment.ID
+			if err := store.Delete(ctx, attachment.StorageKey); err != nil {
+				utils.LogError("delete orphan upload failed", err, zap.Uint("attachment_id", attachment.ID))
+				continue
+			}
+			if attachment.ThumbnailKey != "" {
+				if err := store.Delete(ctx, attachment.ThumbnailKey); err != nil {
+					utils.LogError("delete orphan thumbnail failed", err, zap.Uint("attachment_id", attachment.ID))
+					continue
+				}
+			}
+			if err := config.GetDB().WithContext(ctx).Unscoped().Delete(&attachment).Error; err != nil {
+				return removed, err
+			}
+			removed++
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/storage"
+	"github.com/test/blog/testutil"
+)
+
+func TestCleanupOrphanUploads(t *testing.T) {
+	testutil.Setup(t)
+	dir := t.TempDir()
+	local, err := storage.NewLocalStorage(dir, "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	storage.Set(local)
+
+	user := testutil.CreateUser(t, "alice")
+	post := models.Post{Title: "t", Content: "c", UserID: user.ID}
+	config.GetDB().Create(&post)
+
+	old := time.Now().Add(-48 * time.Hour)
+	create := func(key, thumb string, postID *uint, createdAt time.Time) {
+		t.Helper()
+		for _, k := range []string{key, thumb} {
+			if k != "" {
+				local.Put(context.Background(), k, strings.NewReader("x"), 1, "image/png")
+			}
+		}
+		a := models.Attachment{UserID: user.ID, PostID: postID, StorageKey: key, ThumbnailKey: thumb, ContentType: "image/png", Size: 1}
+		a.CreatedAt = createdAt
+		if err := config.GetDB().Create(&a).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	create("old-orphan.png", "old-orphan_thumb.png", nil, old)
+	create("new-orphan.png", "", nil, time.Now())
+	create("linked.png", "", &post.ID, old)
+
+	removed, err := CleanupOrphanUploads(context.Background())
+	if err != nil || removed != 1 {
+		t.Fatalf("removed %d, %v, want 1", removed, err)
+	}
+
+	for key, exists := range map[string]bool{"old-orphan.png": false, "old-orphan_thumb.png": false, "new-orphan.png": true, "linked.png": true} {
+		if _, err := os.Stat(filepath.Join(dir, key)); (err == nil) != exists {
+			t.Errorf("%s exists = %v, want %v", key, err == nil, exists)
+		}
+	}
+	var count int64
+	config.GetDB().Unscoped().Model(&models.Attachment{}).Count(&count)
+	if count != 2 {
+		t.Errorf("attachments = %d, want 2", count)
+	}
+}
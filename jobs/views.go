@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/test/blog/utils"
+	"github.com/test/blog/views"
+)
+
+// StartViewFlush 定期将内存中累积的浏览量写入数据库，ctx取消后停止
+// 停止时不会写入剩余的浏览量，关闭服务时应在HTTP服务器停止后调用 views.Default().Flush
+func StartViewFlush(ctx context.Context, interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			if err := views.Default().Flush(ctx); err != nil {
+				utils.LogError("flush post views failed", err)
+			}
+		}
+	}()
+}
@@ -7,25 +7,51 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/test/blog/cache"
 	"github.com/test/blog/config"
+	"github.com/test/blog/jobs"
+	"github.com/test/blog/middleware"
+	"github.com/test/blog/oidc"
 	"github.com/test/blog/routes"
+	"github.com/test/blog/storage"
 	"github.com/test/blog/utils"
+	"github.com/test/blog/views"
 	"go.uber.org/zap"
 )
 
 func main() {
-	// 验证配置
-	config.ValidateConfig()
+	// 子命令（如 blog config check）
+	if len(os.Args) > 1 && !strings.HasPrefix(os.Args[1], "-") {
+		os.Exit(runCommand(os.Args[1:]))
+	}
+
+	// 加载并验证配置（默认值 < 配置文件 < 环境变量 < 命令行参数）
+	log.Println("Loading configuration...")
+	cfg, err := config.Init(os.Args[1:])
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
 
-	// 加载配置
-	cfg := config.LoadConfig()
+	// 加载JWT密钥
+	if err := config.InitJWTKeys(cfg); err != nil {
+		log.Fatalf("Failed to load JWT keys: %v", err)
+	}
+
+	// 注册OIDC身份提供方
+	oidc.InitProviders(cfg.OIDC.Providers)
 
 	// 初始化日志系统
-	utils.InitLogger()
+	utils.InitLogger(cfg.Log.Level, cfg.Log.Format, cfg.Log.OutputPath)
+
+	// 配置重新加载后同步日志级别
+	config.OnReload(func(c *config.Config) {
+		utils.SetLogLevel(c.Log.Level)
+	})
 
 	// 打印配置信息
 	config.PrintConfig(cfg)
@@ -36,8 +62,39 @@ func main() {
 	// 初始化数据库
 	config.InitDB(cfg)
 
-	// 创建Gin引擎
-	r := gin.Default()
+	// 初始化上传文件存储
+	if err := storage.Init(cfg.Upload); err != nil {
+		log.Fatalf("Failed to initialize upload storage: %v", err)
+	}
+
+	// 初始化响应缓存
+	if err := cache.Init(cfg.Cache); err != nil {
+		log.Fatalf("Failed to initialize cache: %v", err)
+	}
+
+	if cfg.Site.BaseURL == "" {
+		utils.LogWarn("site.base_url is not set, feed and sitemap links are derived from the request and will not be cached publicly")
+	}
+
+	// 初始化浏览量统计
+	views.Init(cfg.Views)
+
+	// 启动后台任务
+	jobCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobs.StartOrphanUploadCleanup(jobCtx, time.Hour)
+	jobs.StartTrashPurge(jobCtx, time.Hour)
+	jobs.StartViewFlush(jobCtx, time.Duration(cfg.Views.FlushIntervalSeconds)*time.Second)
+	jobs.StartIdempotencyPurge(jobCtx, time.Hour)
+	jobs.StartDataExports(jobCtx, time.Minute)
+
+	// 创建Gin引擎，访问日志隐藏查询参数中的下载令牌
+	r := gin.New()
+	// 只信任配置的反向代理设置的X-Forwarded-For，否则客户端可以伪造IP绕过限流
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+	r.Use(middleware.Logger(), gin.Recovery())
 
 	// 设置路由
 	routes.SetupRoutes(r)
@@ -59,6 +116,19 @@ func main() {
 		}
 	}()
 
+	// 监听SIGHUP信号重新加载配置
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if _, err := config.Reload(); err != nil {
+				utils.LogError("Failed to reload configuration", err)
+				continue
+			}
+			utils.LogInfo("Configuration reloaded")
+		}
+	}()
+
 	// 等待中断信号
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -72,11 +142,19 @@ func main() {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
+	// 停止后台任务
+	stopJobs()
+
 	// 关闭服务器
 	if err := server.Shutdown(ctx); err != nil {
 		log.Fatal("Server forced to shutdown:", err)
 	}
 
+	// 写入尚未保存的浏览量
+	if err := views.Default().Flush(ctx); err != nil {
+		log.Printf("Error flushing post views: %v", err)
+	}
+
 	// 关闭数据库连接
 	if db := config.GetDB(); db != nil {
 		if sqlDB, err := db.DB(); err == nil {
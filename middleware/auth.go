@@ -3,13 +3,24 @@ package middleware
 import (
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/test/blog/config"
+	"github.com/test/blog/models"
 	"github.com/test/blog/utils"
 )
 
-// AuthMiddleware JWT认证中间件
+// 认证方式
+const (
+	AuthTypeJWT   = "jwt"   // 用户登录获得的JWT
+	AuthTypeToken = "token" // 个人访问令牌
+)
+
+// lastUsedInterval 个人访问令牌最后使用时间的更新间隔，避免每次请求都写数据库
+const lastUsedInterval = time.Minute
+
+// AuthMiddleware 认证中间件，支持JWT和个人访问令牌
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 获取Authorization头
@@ -36,9 +47,14 @@ func AuthMiddleware() gin.HandlerFunc {
 		// 提取token
 		token := strings.TrimPrefix(authHeader, "Bearer ")
 
+		// 个人访问令牌
+		if strings.HasPrefix(token, utils.APITokenPrefix) {
+			authenticateAPIToken(c, token)
+			return
+		}
+
 		// 验证token
-		cfg := config.LoadConfig()
-		claims, err := utils.ValidateToken(token, cfg.JWT.Secret)
+		claims, err := config.JWTKeys().ValidateToken(token)
 		if err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
@@ -48,9 +64,143 @@ func AuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
+		// 用户已被删除时，尚未过期的token也不再有效
+		// 角色以数据库为准，token中的角色在提升或降级后已经过时
+		var user models.User
+		if err := config.GetDB().Select("id", "role").First(&user, claims.UserID).Error; err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"success": false,
+				"message": "Invalid or expired token",
+			})
+			c.Abort()
+			return
+		}
+
 		// 将用户信息存储到上下文中
 		c.Set("user_id", claims.UserID)
 		c.Set("username", claims.Username)
+		c.Set("role", user.Role)
+		c.Set("auth_type", AuthTypeJWT)
+
+		c.Next()
+	}
+}
+
+// authenticateAPIToken 验证个人访问令牌
+func authenticateAPIToken(c *gin.Context, token string) {
+	var pat models.PersonalAccessToken
+	if err := config.GetDB().Preload("User").Where("token_hash = ?", utils.HashAPIToken(token)).First(&pat).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid or expired token",
+		})
+		c.Abort()
+		return
+	}
+
+	// 令牌过期或所属用户已被删除
+	now := time.Now()
+	if (pat.ExpiresAt != nil && now.After(*pat.ExpiresAt)) || pat.User.ID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid or expired token",
+		})
+		c.Abort()
+		return
+	}
+
+	// 更新最后使用时间
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) > lastUsedInterval {
+		if err := config.GetDB().Model(&pat).UpdateColumn("last_used_at", now).Error; err != nil {
+			utils.LogError("update token last used error", err, utils.WithUserID(pat.UserID))
+		}
+	}
+
+	// 将用户信息存储到上下文中
+	c.Set("user_id", pat.UserID)
+	c.Set("username", pat.User.Username)
+	c.Set("role", pat.User.Role)
+	c.Set("auth_type", AuthTypeToken)
+	c.Set("scopes", strings.Split(pat.Scopes, ","))
+
+	c.Next()
+}
+
+// RequireScope 要求个人访问令牌具有指定的权限范围
+// 用户登录获得的JWT拥有全部权限
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_type") == AuthTypeJWT {
+			c.Next()
+			return
+		}
+
+		for _, s := range c.GetStringSlice("scopes") {
+			if s == scope {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Token does not have the required scope: " + scope,
+		})
+		c.Abort()
+	}
+}
+
+// RequireUserSession 要求使用登录获得的JWT，禁止个人访问令牌访问
+// 用于令牌管理等敏感操作
+func RequireUserSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("auth_type") != AuthTypeJWT {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "This operation requires a user login session",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireAdminMFA 要求管理员已启用两步验证
+// 未启用的管理员只能访问两步验证设置接口
+func RequireAdminMFA() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != models.RoleAdmin {
+			c.Next()
+			return
+		}
+
+		var user models.User
+		if err := config.GetDB().Select("id", "totp_enabled").First(&user, c.GetUint("user_id")).Error; err != nil || !user.TOTPEnabled {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Administrators must enable two-factor authentication",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole 要求当前用户具有指定角色
+func RequireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetString("role") != role {
+			c.JSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"message": "Insufficient permissions",
+			})
+			c.Abort()
+			return
+		}
 
 		c.Next()
 	}
@@ -0,0 +1,138 @@
+package middleware
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+	"github.com/test/blog/utils"
+)
+
+// createAPIToken 创建个人访问令牌，返回明文
+func createAPIToken(t *testing.T, user models.User, scopes string, mutate ...func(*models.PersonalAccessToken)) string {
+	t.Helper()
+	plain, hash, err := utils.GenerateAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pat := models.PersonalAccessToken{UserID: user.ID, Name: "ci", TokenHash: hash, Prefix: plain[:12], Scopes: scopes}
+	for _, fn := range mutate {
+		fn(&pat)
+	}
+	if err := config.GetDB().Create(&pat).Error; err != nil {
+		t.Fatal(err)
+	}
+	return plain
+}
+
+func TestAuthMiddlewareAPITokens(t *testing.T) {
+	testutil.Setup(t)
+	user := testutil.CreateUser(t, "alice")
+	deleted := testutil.CreateUser(t, "bob")
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	readToken := createAPIToken(t, user, models.ScopeRead)
+	writeToken := createAPIToken(t, user, models.ScopeRead+","+models.ScopePostsWrite, func(p *models.PersonalAccessToken) { p.ExpiresAt = &future })
+	expiredToken := createAPIToken(t, user, models.ScopeRead, func(p *models.PersonalAccessToken) { p.ExpiresAt = &past })
+	revokedToken := createAPIToken(t, user, models.ScopeRead)
+	config.GetDB().Where("token_hash = ?", utils.HashAPIToken(revokedToken)).Delete(&models.PersonalAccessToken{})
+	deletedUserToken := createAPIToken(t, deleted, models.ScopeRead)
+	config.GetDB().Delete(&deleted)
+
+	r := gin.New()
+	r.Use(AuthMiddleware())
+	r.GET("/read", RequireScope(models.ScopeRead), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/write", RequireScope(models.ScopePostsWrite), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.POST("/session", RequireUserSession(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"jwt has every scope", http.MethodPost, "/write", testutil.Token(t, user), http.StatusOK},
+		{"jwt is a user session", http.MethodPost, "/session", testutil.Token(t, user), http.StatusOK},
+		{"token with scope", http.MethodGet, "/read", readToken, http.StatusOK},
+		{"token without scope", http.MethodPost, "/write", readToken, http.StatusForbidden},
+		{"token with write scope", http.MethodPost, "/write", writeToken, http.StatusOK},
+		{"token is not a user session", http.MethodPost, "/session", writeToken, http.StatusForbidden},
+		{"expired token", http.MethodGet, "/read", expiredToken, http.StatusUnauthorized},
+		{"revoked token", http.MethodGet, "/read", revokedToken, http.StatusUnauthorized},
+		{"token of deleted user", http.MethodGet, "/read", deletedUserToken, http.StatusUnauthorized},
+		{"unknown token", http.MethodGet, "/read", utils.APITokenPrefix + "unknown", http.StatusUnauthorized},
+		{"missing header", http.MethodGet, "/read", "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := testutil.Request(t, r, tt.method, tt.path, tt.token, nil)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAuthMiddlewareRecordsLastUsed(t *testing.T) {
+	testutil.Setup(t)
+	user := testutil.CreateUser(t, "alice")
+	token := createAPIToken(t, user, models.ScopeRead)
+
+	r := gin.New()
+	r.GET("/", AuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+	if w := testutil.Request(t, r, http.MethodGet, "/", token, nil); w.Code != http.StatusOK {
+		t.Fatalf("status = %d", w.Code)
+	}
+
+	var pat models.PersonalAccessToken
+	config.GetDB().Where("user_id = ?", user.ID).First(&pat)
+	if pat.LastUsedAt == nil {
+		t.Error("last_used_at was not recorded")
+	}
+}
+
+func TestRoleLoadedFromDatabase(t *testing.T) {
+	testutil.Setup(t)
+	promoted := testutil.CreateUser(t, "alice")
+	demoted := testutil.CreateUser(t, "bob", func(u *models.User) { u.Role = models.RoleAdmin; u.TOTPEnabled = true })
+	insecure := testutil.CreateUser(t, "carol")
+
+	// 签发token之后角色发生变化
+	promotedToken := testutil.Token(t, promoted)
+	demotedToken := testutil.Token(t, demoted)
+	insecureToken := testutil.Token(t, insecure)
+	config.GetDB().Model(&promoted).Updates(map[string]interface{}{"role": models.RoleAdmin, "totp_enabled": true})
+	config.GetDB().Model(&demoted).Update("role", models.RoleUser)
+	config.GetDB().Model(&insecure).Update("role", models.RoleAdmin)
+
+	r := gin.New()
+	r.Use(AuthMiddleware(), RequireAdminMFA())
+	r.GET("/admin", RequireRole(models.RoleAdmin), func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/posts", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	tests := []struct {
+		name  string
+		token string
+		path  string
+		want  int
+	}{
+		{"promoted user is admin", promotedToken, "/admin", http.StatusOK},
+		{"demoted admin is not admin", demotedToken, "/admin", http.StatusForbidden},
+		{"demoted admin without admin routes", demotedToken, "/posts", http.StatusOK},
+		// 提升为管理员后未启用两步验证
+		{"new admin without 2fa", insecureToken, "/posts", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if w := testutil.Request(t, r, http.MethodGet, tt.path, tt.token, nil); w.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/utils"
+)
+
+// PublicCache 公开内容的缓存策略，允许浏览器和CDN缓存maxAge
+// 成功的GET/HEAD响应没有ETag时根据响应内容生成弱ETag，并根据If-None-Match/If-Modified-Since返回304
+func PublicCache(maxAge time.Duration) gin.HandlerFunc {
+	policy := fmt.Sprintf("public, max-age=%d", int(maxAge.Seconds()))
+	return func(c *gin.Context) {
+		if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+
+		c.Header("Cache-Control", policy)
+		// 未匹配路由时gin在调用中间件之前已设置404，不能当作200缓存
+		w := &bufferedWriter{ResponseWriter: c.Writer, status: c.Writer.Status()}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		// 错误响应不缓存，避免短暂的故障被CDN放大
+		if w.status != http.StatusOK {
+			if w.status >= http.StatusBadRequest {
+				c.Header("Cache-Control", "no-store")
+			}
+			w.flush()
+			return
+		}
+
+		header := w.Header()
+		etag := header.Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(w.body.Bytes())
+			etag = `W/"` + hex.EncodeToString(sum[:16]) + `"`
+			header.Set("ETag", etag)
+		}
+		var lastModified time.Time
+		if value := header.Get("Last-Modified"); value != "" {
+			lastModified, _ = http.ParseTime(value)
+		}
+
+		if utils.NotModified(c.Request.Header, etag, lastModified) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			w.status = http.StatusNotModified
+			w.body.Reset()
+		}
+		w.flush()
+	}
+}
+
+// PrivateNoStore 需要认证的接口的缓存策略，响应包含用户私有数据，禁止任何缓存保存
+func PrivateNoStore() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "private, no-store")
+		c.Next()
+	}
+}
+
+// bufferedWriter 缓存响应内容，在处理完成后再决定返回完整响应还是304
+type bufferedWriter struct {
+	gin.ResponseWriter
+	body   bytes.Buffer
+	status int
+}
+
+// WriteHeader 记录状态码，实际写入在flush时进行
+func (w *bufferedWriter) WriteHeader(code int) {
+	if code > 0 {
+		w.status = code
+	}
+}
+
+// WriteHeaderNow 推迟到flush时写入
+func (w *bufferedWriter) WriteHeaderNow() {}
+
+// Write 写入缓冲区
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	return w.body.Write(data)
+}
+
+// WriteString 写入缓冲区
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	return w.body.WriteString(s)
+}
+
+// Status 返回处理器设置的状态码
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+// Size 返回已写入缓冲区的字节数
+func (w *bufferedWriter) Size() int {
+	return w.body.Len()
+}
+
+// Written 缓冲区写入前响应头都可以修改
+func (w *bufferedWriter) Written() bool {
+	return false
+}
+
+// flush 将状态码和缓冲的内容写入原始响应
+func (w *bufferedWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	} else {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
@@ -0,0 +1,79 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPublicCache(t *testing.T) {
+	r := gin.New()
+	r.Use(PublicCache(5 * time.Minute))
+	hello := func(c *gin.Context) { c.String(http.StatusOK, "hello") }
+	r.GET("/plain", hello)
+	r.HEAD("/plain", hello)
+	r.GET("/versioned", func(c *gin.Context) {
+		c.Header("ETag", `"post-1-v2"`)
+		c.Header("Last-Modified", "Mon, 01 Jan 2024 00:00:00 GMT")
+		c.JSON(http.StatusOK, gin.H{"id": 1})
+	})
+	r.GET("/missing", func(c *gin.Context) { c.JSON(http.StatusNotFound, gin.H{"success": false}) })
+	r.GET("/redirect", func(c *gin.Context) { c.Redirect(http.StatusMovedPermanently, "/plain") })
+	r.POST("/plain", func(c *gin.Context) { c.String(http.StatusCreated, "created") })
+
+	plainETag := `W/"2cf24dba5fb0a30e26e83b2ac5b9e29e"`
+	tests := []struct {
+		name      string
+		method    string
+		path      string
+		header    map[string]string
+		status    int
+		cache     string
+		etag      string
+		emptyBody bool
+	}{
+		{name: "generated etag", method: http.MethodGet, path: "/plain", status: http.StatusOK, cache: "public, max-age=300", etag: plainETag},
+		{name: "generated etag matches", method: http.MethodGet, path: "/plain", header: map[string]string{"If-None-Match": plainETag}, status: http.StatusNotModified, cache: "public, max-age=300", etag: plainETag, emptyBody: true},
+		{name: "handler etag is kept", method: http.MethodGet, path: "/versioned", status: http.StatusOK, cache: "public, max-age=300", etag: `"post-1-v2"`},
+		{name: "handler etag matches", method: http.MethodGet, path: "/versioned", header: map[string]string{"If-None-Match": `"post-1-v2"`}, status: http.StatusNotModified, cache: "public, max-age=300", etag: `"post-1-v2"`, emptyBody: true},
+		{name: "not modified since", method: http.MethodGet, path: "/versioned", header: map[string]string{"If-Modified-Since": "Tue, 02 Jan 2024 00:00:00 GMT"}, status: http.StatusNotModified, cache: "public, max-age=300", etag: `"post-1-v2"`, emptyBody: true},
+		{name: "modified since", method: http.MethodGet, path: "/versioned", header: map[string]string{"If-Modified-Since": "Sun, 31 Dec 2023 00:00:00 GMT"}, status: http.StatusOK, cache: "public, max-age=300", etag: `"post-1-v2"`},
+		{name: "head request", method: http.MethodHead, path: "/plain", status: http.StatusOK, cache: "public, max-age=300", etag: plainETag},
+		// 错误响应不缓存，避免短暂的故障被CDN放大
+		{name: "error is not stored", method: http.MethodGet, path: "/missing", status: http.StatusNotFound, cache: "no-store"},
+		{name: "unknown route", method: http.MethodGet, path: "/nothing", status: http.StatusNotFound, cache: "no-store"},
+		{name: "redirect keeps policy", method: http.MethodGet, path: "/redirect", status: http.StatusMovedPermanently, cache: "public, max-age=300"},
+		{name: "writes are not cached", method: http.MethodPost, path: "/plain", status: http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			for k, v := range tt.header {
+				req.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.status || w.Header().Get("Cache-Control") != tt.cache || w.Header().Get("ETag") != tt.etag {
+				t.Errorf("status = %d, Cache-Control %q, ETag %q, want %d %q %q",
+					w.Code, w.Header().Get("Cache-Control"), w.Header().Get("ETag"), tt.status, tt.cache, tt.etag)
+			}
+			if tt.emptyBody && (w.Body.Len() != 0 || w.Header().Get("Content-Type") != "") {
+				t.Errorf("304 has body %q, Content-Type %q", w.Body.String(), w.Header().Get("Content-Type"))
+			}
+		})
+	}
+}
+
+func TestPrivateNoStore(t *testing.T) {
+	r := gin.New()
+	r.GET("/profile", PrivateNoStore(), func(c *gin.Context) { c.String(http.StatusOK, "me") })
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/profile", nil))
+	if got := w.Header().Get("Cache-Control"); got != "private, no-store" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+}
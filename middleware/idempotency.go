@@ -0,0 +1,244 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"gorm.io/gorm"
+)
+
+// idempotencyScope Idempotency-Key请求头的幂等键作用域，请求方法和路径计入请求哈希，同一个键不能用于不同的接口
+const idempotencyScope = "http"
+
+const (
+	idempotencyLockTimeout  = time.Minute            // 处理中的记录超过该时间视为请求已中断，可以重新执行
+	idempotencyWait         = 5 * time.Second        // 相同的请求正在处理时等待其完成的最长时间
+	idempotencyPollInterval = 100 * time.Millisecond // 等待时检查的间隔
+)
+
+// replayedHeaders 随响应保存、重放时返回的响应头
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// storedResponse 保存的响应
+type storedResponse struct {
+	Header map[string]string `json:"header,omitempty"`
+	Body   []byte            `json:"body"`
+}
+
+// Idempotency 支持Idempotency-Key请求头，需要在认证中间件之后使用，没有请求头或未认证时不做处理
+// 同一个用户使用相同的键重试时返回第一次请求的响应，不会重复执行；相同的键用于不同的请求时返回409
+// 相同的请求正在处理时等待其完成后返回相同的响应，等待超时返回409
+// 服务器错误、409和429的响应表示可以重试，不会被保存
+func Idempotency() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		userID := c.GetUint("user_id")
+		if key == "" || userID == 0 {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Idempotency-Key must be at most 255 characters",
+			})
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Failed to read request body",
+			})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		hash := idempotencyHash(c.Request, body)
+
+		record, claimed, err := claimIdempotencyKey(c.Request.Context(), userID, key, hash)
+		if err != nil {
+			utils.LogError("claim idempotency key error", err, utils.WithUserID(userID))
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"success": false,
+				"message": "Internal server error",
+			})
+			c.Abort()
+			return
+		}
+		if !claimed {
+			replayIdempotent(c, record, hash)
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		c.Next()
+		c.Writer = w.ResponseWriter
+
+		finishIdempotencyKey(record, w)
+	}
+}
+
+// idempotencyHash 计算请求方法、路径和请求体的哈希
+func idempotencyHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// claimIdempotencyKey 插入处理中的记录以获取幂等键，返回记录和是否由当前请求获取
+// 键已被使用时返回已有的记录；相同的请求正在处理时最多等待idempotencyWait，期间完成则返回完成后的记录
+func claimIdempotencyKey(ctx context.Context, userID uint, key, hash string) (models.IdempotencyKey, bool, error) {
+	db := config.GetDB().WithContext(ctx)
+	deadline := time.Now().Add(idempotencyWait)
+
+	for {
+		now := time.Now()
+		record := models.IdempotencyKey{
+			UserID:      userID,
+			Scope:       idempotencyScope,
+			Key:         key,
+			RequestHash: hash,
+			ExpiresAt:   now.Add(time.Duration(config.Get().Idempotency.TTLHours) * time.Hour),
+		}
+		err := config.AsConflict(db.Create(&record).Error)
+		if err == nil {
+			return record, true, nil
+		}
+		var conflict *config.ConflictError
+		if !errors.As(err, &conflict) {
+			return record, false, err
+		}
+
+		var existing models.IdempotencyKey
+		err = db.Where("user_id = ? AND scope = ? AND idempotency_key = ?", userID, idempotencyScope, key).First(&existing).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// 记录刚被删除，重新获取
+			continue
+		}
+		if err != nil {
+			return existing, false, err
+		}
+
+		abandoned := existing.StatusCode == 0 && existing.CreatedAt.Before(now.Add(-idempotencyLockTimeout))
+		switch {
+		case existing.ExpiresAt.Before(now) || abandoned:
+			// 按id删除，不会删除其他请求刚获取的新记录
+			if err := db.Delete(&models.IdempotencyKey{}, existing.ID).Error; err != nil {
+				return existing, false, err
+			}
+			continue
+		case existing.RequestHash != hash || existing.StatusCode != 0:
+			return existing, false, nil
+		case now.After(deadline):
+			return existing, false, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return existing, false, ctx.Err()
+		case <-time.After(idempotencyPollInterval):
+		}
+	}
+}
+
+// replayIdempotent 幂等键已被使用时的响应
+func replayIdempotent(c *gin.Context, record models.IdempotencyKey, hash string) {
+	defer c.Abort()
+
+	if record.RequestHash != hash {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "Idempotency-Key was already used for a different request",
+		})
+		return
+	}
+	if record.StatusCode == 0 {
+		c.Header("Retry-After", "1")
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "A request with this Idempotency-Key is in progress, please retry",
+		})
+		return
+	}
+
+	var stored storedResponse
+	if err := json.Unmarshal(record.Response, &stored); err != nil {
+		utils.LogError("decode idempotent response error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Internal server error",
+		})
+		return
+	}
+	for name, value := range stored.Header {
+		c.Header(name, value)
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(record.StatusCode)
+	_, _ = c.Writer.Write(stored.Body)
+}
+
+// finishIdempotencyKey 保存请求的响应，可以重试的响应删除记录，之后使用同一个键会重新执行
+func finishIdempotencyKey(record models.IdempotencyKey, w *recordingWriter) {
+	// 客户端断开连接不应该导致记录停留在处理中
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	db := config.GetDB().WithContext(ctx)
+
+	status := w.Status()
+	if status >= http.StatusInternalServerError || status == http.StatusConflict || status == http.StatusTooManyRequests {
+		if err := db.Delete(&models.IdempotencyKey{}, record.ID).Error; err != nil {
+			utils.LogError("release idempotency key error", err)
+		}
+		return
+	}
+
+	stored := storedResponse{Header: make(map[string]string), Body: w.body.Bytes()}
+	for _, name := range replayedHeaders {
+		if value := w.Header().Get(name); value != "" {
+			stored.Header[name] = value
+		}
+	}
+	response, err := json.Marshal(stored)
+	if err == nil {
+		err = db.Model(&models.IdempotencyKey{}).Where("id = ?", record.ID).
+			Updates(map[string]interface{}{"status_code": status, "response": response}).Error
+	}
+	if err != nil {
+		utils.LogError("save idempotent response error", err)
+	}
+}
+
+// recordingWriter 在写入响应的同时保存响应内容
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+// Write 写入响应并保存
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+// WriteString 写入响应并保存
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
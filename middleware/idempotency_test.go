@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+// idempotentRouter 返回使用幂等中间件的路由，handler在认证和幂等检查之后执行
+func idempotentRouter(handler gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
+	r.POST("/items", AuthMiddleware(), Idempotency(), handler)
+	return r
+}
+
+// idempotentRequest 发送带Idempotency-Key的请求
+func idempotentRequest(r http.Handler, token, key, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/items", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestIdempotencyConcurrentRequests(t *testing.T) {
+	testutil.Setup(t)
+	token := testutil.Token(t, testutil.CreateUser(t, "alice"))
+
+	var executed atomic.Int32
+	r := idempotentRouter(func(c *gin.Context) {
+		n := executed.Add(1)
+		// 处理期间其他相同的请求到达，需要等待而不是重复执行
+		time.Sleep(300 * time.Millisecond)
+		c.Header("Location", "/items/1")
+		c.JSON(http.StatusCreated, gin.H{"success": true, "execution": n})
+	})
+
+	const clients = 8
+	responses := make([]*httptest.ResponseRecorder, clients)
+	var wg sync.WaitGroup
+	for i := range responses {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			responses[i] = idempotentRequest(r, token, "create-1", `{"name":"a"}`)
+		}(i)
+	}
+	wg.Wait()
+
+	if n := executed.Load(); n != 1 {
+		t.Fatalf("handler executed %d times, want 1", n)
+	}
+	replayed := 0
+	for i, w := range responses {
+		if w.Code != http.StatusCreated {
+			t.Errorf("response %d status = %d: %s", i, w.Code, w.Body.String())
+			continue
+		}
+		if w.Body.String() != responses[0].Body.String() || w.Header().Get("Location") != "/items/1" {
+			t.Errorf("response %d = %s %v, want the first response", i, w.Body.String(), w.Header())
+		}
+		if w.Header().Get("Idempotent-Replayed") == "true" {
+			replayed++
+		}
+	}
+	if replayed != clients-1 {
+		t.Errorf("%d responses replayed, want %d", replayed, clients-1)
+	}
+}
+
+func TestIdempotencyConcurrentDifferentRequest(t *testing.T) {
+	testutil.Setup(t)
+	token := testutil.Token(t, testutil.CreateUser(t, "alice"))
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := idempotentRouter(func(c *gin.Context) {
+		close(started)
+		<-release
+		c.JSON(http.StatusCreated, gin.H{"success": true})
+	})
+
+	first := make(chan *httptest.ResponseRecorder)
+	go func() { first <- idempotentRequest(r, token, "create-1", `{"name":"a"}`) }()
+	<-started
+
+	// 第一个请求处理中，相同的键用于不同的请求体立即返回409，不等待
+	w := idempotentRequest(r, token, "create-1", `{"name":"b"}`)
+	if w.Code != http.StatusConflict || !strings.Contains(w.Body.String(), "different request") {
+		t.Errorf("different request status = %d: %s", w.Code, w.Body.String())
+	}
+	close(release)
+	if w := <-first; w.Code != http.StatusCreated {
+		t.Errorf("first request status = %d", w.Code)
+	}
+}
+
+func TestIdempotencyStoredResponses(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		stored bool
+	}{
+		{"created", http.StatusCreated, true},
+		{"client error", http.StatusBadRequest, true},
+		{"conflict", http.StatusConflict, false},
+		{"rate limited", http.StatusTooManyRequests, false},
+		{"server error", http.StatusInternalServerError, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Setup(t)
+			token := testutil.Token(t, testutil.CreateUser(t, "alice"))
+
+			var executed atomic.Int32
+			r := idempotentRouter(func(c *gin.Context) {
+				executed.Add(1)
+				c.JSON(tt.status, gin.H{"success": tt.status < http.StatusBadRequest})
+			})
+
+			for range 2 {
+				if w := idempotentRequest(r, token, "key", `{}`); w.Code != tt.status {
+					t.Fatalf("status = %d, want %d", w.Code, tt.status)
+				}
+			}
+			want := int32(2)
+			if tt.stored {
+				want = 1
+			}
+			if n := executed.Load(); n != want {
+				t.Errorf("handler executed %d times, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestIdempotencyExistingRecords(t *testing.T) {
+	body := `{"name":"a"}`
+	req := httptest.NewRequest(http.MethodPost, "/items", nil)
+	hash := idempotencyHash(req, []byte(body))
+
+	tests := []struct {
+		name     string
+		record   func(userID uint) models.IdempotencyKey
+		status   int
+		executed bool
+	}{
+		{
+			name: "completed",
+			record: func(userID uint) models.IdempotencyKey {
+				return models.IdempotencyKey{UserID: userID, Scope: idempotencyScope, Key: "key", RequestHash: hash, StatusCode: http.StatusAccepted,
+					Response: []byte(`{"body":"e30="}`), ExpiresAt: time.Now().Add(time.Hour)}
+			},
+			status: http.StatusAccepted,
+		},
+		{
+			name: "expired",
+			record: func(userID uint) models.IdempotencyKey {
+				return models.IdempotencyKey{UserID: userID, Scope: idempotencyScope, Key: "key", RequestHash: hash, StatusCode: http.StatusAccepted,
+					Response: []byte(`{"body":"e30="}`), ExpiresAt: time.Now().Add(-time.Minute)}
+			},
+			status:   http.StatusCreated,
+			executed: true,
+		},
+		{
+			// 处理中的请求超过锁定时间没有完成，视为已中断
+			name: "abandoned",
+			record: func(userID uint) models.IdempotencyKey {
+				return models.IdempotencyKey{UserID: userID, Scope: idempotencyScope, Key: "key", RequestHash: hash,
+					ExpiresAt: time.Now().Add(time.Hour), CreatedAt: time.Now().Add(-2 * idempotencyLockTimeout)}
+			},
+			status:   http.StatusCreated,
+			executed: true,
+		},
+		{
+			name: "other user",
+			record: func(userID uint) models.IdempotencyKey {
+				return models.IdempotencyKey{UserID: userID + 1, Scope: idempotencyScope, Key: "key", RequestHash: hash, StatusCode: http.StatusAccepted,
+					Response: []byte(`{"body":"e30="}`), ExpiresAt: time.Now().Add(time.Hour)}
+			},
+			status:   http.StatusCreated,
+			executed: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testutil.Setup(t)
+			user := testutil.CreateUser(t, "alice")
+			record := tt.record(user.ID)
+			if err := config.GetDB().Create(&record).Error; err != nil {
+				t.Fatal(err)
+			}
+
+			executed := false
+			r := idempotentRouter(func(c *gin.Context) {
+				executed = true
+				c.JSON(http.StatusCreated, gin.H{"success": true})
+			})
+			w := idempotentRequest(r, testutil.Token(t, user), "key", body)
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d: %s", w.Code, tt.status, w.Body.String())
+			}
+			if executed != tt.executed {
+				t.Errorf("executed = %v, want %v", executed, tt.executed)
+			}
+		})
+	}
+}
+
+func TestIdempotencyWithoutKey(t *testing.T) {
+	testutil.Setup(t)
+	token := testutil.Token(t, testutil.CreateUser(t, "alice"))
+
+	var executed atomic.Int32
+	r := idempotentRouter(func(c *gin.Context) {
+		executed.Add(1)
+		c.JSON(http.StatusCreated, gin.H{"success": true})
+	})
+	idempotentRequest(r, token, "", `{}`)
+	idempotentRequest(r, token, "", `{}`)
+	if n := executed.Load(); n != 2 {
+		t.Errorf("requests without a key executed %d times, want 2", n)
+	}
+
+	if w := idempotentRequest(r, token, strings.Repeat("k", 256), `{}`); w.Code != http.StatusBadRequest {
+		t.Errorf("long key status = %d, want 400", w.Code)
+	}
+}
@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/api/posts", "/api/posts"},
+		{"/api/posts?page=2&limit=10", "/api/posts?page=2&limit=10"},
+		{"/api/profile/export/3/download?token=secret", "/api/profile/export/3/download?token=REDACTED"},
+		{"/api/auth/oidc/callback?state=abc&code=secret", "/api/auth/oidc/callback?code=REDACTED&state=abc"},
+		{"/download?token=a&token=b", "/download?token=REDACTED"},
+		{"/download?token=%zz", "/download?REDACTED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := redactQuery(tt.path); got != tt.want {
+				t.Errorf("redactQuery = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogger(t *testing.T) {
+	var buf bytes.Buffer
+	writer := gin.DefaultWriter
+	gin.DefaultWriter = &buf
+	t.Cleanup(func() { gin.DefaultWriter = writer })
+
+	r := gin.New()
+	r.Use(Logger())
+	r.GET("/download", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/download?token=secret-token&format=zip", nil))
+
+	line := buf.String()
+	if strings.Contains(line, "secret-token") || !strings.Contains(line, "/download?format=zip&token=REDACTED") || !strings.Contains(line, " 200 ") {
+		t.Errorf("log line = %q", line)
+	}
+}
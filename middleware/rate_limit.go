@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+)
+
+// bucket 令牌桶
+type bucket struct {
+	tokens   float64
+	lastSeen time.Time
+}
+
+// maxBuckets 令牌桶数量上限，超过后清理空闲的桶
+const maxBuckets = 10000
+
+// RateLimit 按客户端IP限流的中间件
+// 每次请求都读取当前配置，因此SIGHUP重新加载后的限流参数会立即生效
+func RateLimit() gin.HandlerFunc {
+	var (
+		mu      sync.Mutex
+		buckets = make(map[string]*bucket)
+	)
+
+	return func(c *gin.Context) {
+		rl := config.Get().RateLimit
+		if !rl.Enabled || rl.RequestsPerSecond <= 0 {
+			c.Next()
+			return
+		}
+
+		now := time.Now()
+		ip := c.ClientIP()
+
+		mu.Lock()
+		if len(buckets) >= maxBuckets {
+			for key, b := range buckets {
+				if now.Sub(b.lastSeen) > time.Minute {
+					delete(buckets, key)
+				}
+			}
+		}
+
+		b, ok := buckets[ip]
+		if !ok {
+			b = &bucket{tokens: float64(rl.Burst), lastSeen: now}
+			buckets[ip] = b
+		}
+
+		// 按时间补充令牌
+		b.tokens += now.Sub(b.lastSeen).Seconds() * rl.RequestsPerSecond
+		if b.tokens > float64(rl.Burst) {
+			b.tokens = float64(rl.Burst)
+		}
+		b.lastSeen = now
+
+		allowed := b.tokens >= 1
+		if allowed {
+			b.tokens--
+		}
+		mu.Unlock()
+
+		if !allowed {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"message": "Too many requests",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
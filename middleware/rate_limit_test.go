@@ -0,0 +1,50 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+)
+
+func TestRateLimitClientIP(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.RateLimit = config.RateLimitConfig{Enabled: true, RequestsPerSecond: 0.001, Burst: 2}
+	config.Set(cfg)
+
+	// httptest的请求来自192.0.2.1
+	tests := []struct {
+		name    string
+		proxies []string
+		allowed int // 4个伪造不同X-Forwarded-For的请求中通过的数量
+	}{
+		{"no trusted proxies", nil, 2},
+		{"other proxy", []string{"10.0.0.0/8"}, 2},
+		{"trusted proxy", []string{"192.0.2.1"}, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			if err := r.SetTrustedProxies(tt.proxies); err != nil {
+				t.Fatal(err)
+			}
+			r.GET("/", RateLimit(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+			allowed := 0
+			for _, ip := range []string{"203.0.113.1", "203.0.113.2", "203.0.113.3", "203.0.113.4"} {
+				req := httptest.NewRequest(http.MethodGet, "/", nil)
+				req.Header.Set("X-Forwarded-For", ip)
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				if w.Code == http.StatusOK {
+					allowed++
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("%d requests allowed, want %d", allowed, tt.allowed)
+			}
+		})
+	}
+}
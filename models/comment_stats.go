@@ -0,0 +1,37 @@
+package models
+
+import "gorm.io/gorm"
+
+// 根据未删除的评论计算文章评论统计的子查询，外层查询的表为posts
+const (
+	commentCountSQL  = "(SELECT COUNT(*) FROM comments WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL)"
+	lastCommentAtSQL = "(SELECT MAX(comments.created_at) FROM comments WHERE comments.post_id = posts.id AND comments.deleted_at IS NULL)"
+)
+
+// RefreshCommentStats 根据未删除的评论重新计算文章的评论数和最后评论时间
+// 包括回收站中的文章；不修改文章的updated_at和版本号
+func RefreshCommentStats(tx *gorm.DB, postIDs ...uint) error {
+	if len(postIDs) == 0 {
+		return nil
+	}
+	return tx.Unscoped().Model(&Post{}).Where("id IN ?", postIDs).UpdateColumns(map[string]interface{}{
+		"comment_count":   gorm.Expr(commentCountSQL),
+		"last_comment_at": gorm.Expr(lastCommentAtSQL),
+	}).Error
+}
+
+// DriftedCommentStats 返回postIDs中评论统计与RefreshCommentStats的计算结果不一致的文章，包括回收站中的文章
+// 最后评论时间按空值安全的方式比较：一侧为空另一侧不为空也算不一致
+func DriftedCommentStats(db *gorm.DB, postIDs ...uint) ([]uint, error) {
+	var drifted []uint
+	if len(postIDs) == 0 {
+		return drifted, nil
+	}
+	err := db.Unscoped().Model(&Post{}).
+		Where("id IN ?", postIDs).
+		Where("comment_count <> "+commentCountSQL+
+			" OR COALESCE(last_comment_at = "+lastCommentAtSQL+", last_comment_at IS NULL AND "+lastCommentAtSQL+" IS NULL) = FALSE").
+		Order("id").
+		Pluck("id", &drifted).Error
+	return drifted, err
+}
@@ -0,0 +1,54 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func TestRefreshCommentStats(t *testing.T) {
+	db := testutil.Setup(t)
+	user := testutil.CreateUser(t, "alice")
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		comments  []time.Time
+		deleted   int // 软删除最新的几条评论
+		wantCount int
+		wantLast  *time.Time
+	}{
+		{"no comments", nil, 0, 0, nil},
+		{"counts live comments", []time.Time{base, base.Add(time.Hour)}, 0, 2, &[]time.Time{base.Add(time.Hour)}[0]},
+		{"ignores deleted comments", []time.Time{base, base.Add(time.Hour)}, 1, 1, &base},
+		{"all deleted", []time.Time{base}, 1, 0, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 统计字段故意设置为错误的值
+			post := testutil.CreatePost(t, user, tt.name, func(p *models.Post) { p.CommentCount = 99 })
+			for i, at := range tt.comments {
+				comment := models.Comment{Content: "c", UserID: user.ID, PostID: post.ID}
+				comment.CreatedAt = at
+				db.Create(&comment)
+				if i >= len(tt.comments)-tt.deleted {
+					db.Delete(&comment)
+				}
+			}
+			if err := models.RefreshCommentStats(db, post.ID); err != nil {
+				t.Fatal(err)
+			}
+
+			var got models.Post
+			db.First(&got, post.ID)
+			if got.CommentCount != tt.wantCount {
+				t.Errorf("comment count = %d, want %d", got.CommentCount, tt.wantCount)
+			}
+			if (got.LastCommentAt == nil) != (tt.wantLast == nil) || (got.LastCommentAt != nil && !got.LastCommentAt.Equal(*tt.wantLast)) {
+				t.Errorf("last comment at = %v, want %v", got.LastCommentAt, tt.wantLast)
+			}
+		})
+	}
+}
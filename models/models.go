@@ -1,6 +1,8 @@
 package models
 
 import (
+	"time"
+
 	"gorm.io/gorm"
 )
 
@@ -10,20 +12,72 @@ type User struct {
 	Username  string         `json:"username" gorm:"uniqueIndex;not null;size:50"`
 	Password  string         `json:"-" gorm:"not null;size:255"` // json:"-" 表示不序列化密码字段
 	Email     string         `json:"email" gorm:"uniqueIndex;not null;size:100"`
+	Role      string         `json:"-" gorm:"not null;size:20;default:user"` // 只在 /api/profile 中返回
+	// 两步验证
+	TOTPSecret   string `json:"-" gorm:"size:64"`
+	TOTPEnabled  bool   `json:"-" gorm:"not null;default:false"`
+	TOTPLastStep int64  `json:"-" gorm:"not null;default:0"` // 最后一次使用的时间步，防止验证码重放
+	// 两步验证登录的失败计数，多实例部署时共享
+	MFAFailures    int        `json:"-" gorm:"not null;default:0"` // 连续失败次数，验证成功后清零
+	MFALockedUntil *time.Time `json:"-"`                           // 连续失败过多时在此之前拒绝验证
+	MFAVerifiedAt  *time.Time `json:"-"`                           // 最后一次验证成功的时间，之前签发的挑战令牌失效
 	// 关联关系
 	Posts    []Post    `json:"posts,omitempty" gorm:"foreignKey:UserID"`
 	Comments []Comment `json:"comments,omitempty" gorm:"foreignKey:UserID"`
 }
 
+// 用户角色
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin" // 管理员必须启用两步验证
+)
+
 // Post 文章模型
 type Post struct {
 	gorm.Model
 	Title     string         `json:"title" gorm:"not null;size:200"`
+	Slug      string         `json:"slug" gorm:"uniqueIndex;size:100"` // 根据标题自动生成，标题修改后旧slug重定向到新slug
 	Content   string         `json:"content" gorm:"type:text;not null"`
 	UserID    uint           `json:"user_id" gorm:"not null;index"`
+	Version   int            `json:"version" gorm:"not null;default:1"`    // 每次更新加1，用于乐观并发控制
+	ViewCount int64          `json:"view_count" gorm:"not null;default:0"` // 浏览量，在内存中累积后批量写入，比实际访问稍有延迟
+	// 评论统计，创建和删除评论时在同一事务中更新，可通过 blog comments reconcile 修正
+	CommentCount  int        `json:"comment_count" gorm:"not null;default:0;index"`
+	LastCommentAt *time.Time `json:"last_comment_at"`
 	// 关联关系
-	User     User      `json:"user,omitempty" gorm:"foreignKey:UserID"`
-	Comments []Comment `json:"comments,omitempty" gorm:"foreignKey:PostID"`
+	User        User         `json:"user,omitempty" gorm:"foreignKey:UserID"`
+	Comments    []Comment    `json:"comments,omitempty" gorm:"foreignKey:PostID"`
+	Attachments []Attachment `json:"attachments,omitempty" gorm:"foreignKey:PostID"`
+}
+
+// PostSlug 文章使用过的历史slug，用于旧地址重定向
+type PostSlug struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	PostID    uint      `json:"post_id" gorm:"not null;index"`
+	Slug      string    `json:"slug" gorm:"uniqueIndex;not null;size:100"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PostViewStat 文章每天的浏览量，用于计算热门文章
+type PostViewStat struct {
+	PostID uint      `json:"post_id" gorm:"primaryKey;autoIncrement:false"`
+	Day    time.Time `json:"day" gorm:"primaryKey;type:date;index"`
+	Views  int64     `json:"views" gorm:"not null;default:0"`
+}
+
+// PostRevision 文章修订版本，每次创建、更新或恢复文章时保存完整内容
+type PostRevision struct {
+	ID            uint      `json:"id" gorm:"primarykey"`
+	PostID        uint      `json:"post_id" gorm:"not null;uniqueIndex:idx_revision_post_number"`
+	Number        int       `json:"number" gorm:"not null;uniqueIndex:idx_revision_post_number"` // 从1开始递增
+	EditorID      uint      `json:"editor_id" gorm:"not null;index"`
+	Title         string    `json:"title" gorm:"not null;size:200"`
+	Content       string    `json:"content" gorm:"type:text;not null"`
+	RestoredFrom  *int      `json:"restored_from,omitempty"`        // 由哪个版本恢复而来
+	ChangedFields string    `json:"changed_fields" gorm:"size:255"` // 相对上一版本发生变化的字段，逗号分隔
+	CreatedAt     time.Time `json:"created_at"`
+	// 关联关系
+	Editor User `json:"editor,omitempty" gorm:"foreignKey:EditorID"`
 }
 
 // Comment 评论模型
@@ -36,3 +90,105 @@ type Comment struct {
 	User User `json:"user,omitempty" gorm:"foreignKey:UserID"`
 	Post Post `json:"post,omitempty" gorm:"foreignKey:PostID"`
 }
+
+// 个人访问令牌的权限范围
+const (
+	ScopeRead          = "read"           // 只读访问需要认证的接口
+	ScopePostsWrite    = "posts:write"    // 创建、更新、删除文章
+	ScopeCommentsWrite = "comments:write" // 发表评论
+)
+
+// ValidScopes 所有可用的权限范围
+var ValidScopes = []string{ScopeRead, ScopePostsWrite, ScopeCommentsWrite}
+
+// PersonalAccessToken 个人访问令牌模型
+// 令牌明文只在创建时返回一次，数据库中只保存哈希值；撤销即软删除
+type PersonalAccessToken struct {
+	gorm.Model
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	Name       string     `json:"name" gorm:"not null;size:100"`
+	TokenHash  string     `json:"-" gorm:"uniqueIndex;not null;size:64"`
+	Prefix     string     `json:"prefix" gorm:"not null;size:20"`  // 令牌前几位，便于用户识别
+	Scopes     string     `json:"scopes" gorm:"not null;size:255"` // 逗号分隔
+	LastUsedAt *time.Time `json:"last_used_at"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// UserIdentity 用户绑定的外部身份（OIDC）
+type UserIdentity struct {
+	gorm.Model
+	UserID   uint   `json:"user_id" gorm:"not null;index"`
+	Provider string `json:"provider" gorm:"not null;size:50;uniqueIndex:idx_identity_provider_subject"`
+	Subject  string `json:"subject" gorm:"not null;size:255;uniqueIndex:idx_identity_provider_subject"`
+	Email    string `json:"email" gorm:"size:100"`
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// RecoveryCode 两步验证恢复码，每个只能使用一次
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint       `json:"user_id" gorm:"not null;index"`
+	CodeHash string     `json:"-" gorm:"not null;size:64"`
+	UsedAt   *time.Time `json:"used_at"`
+}
+
+// Attachment 上传的文件（图片）
+// 上传后尚未关联文章的文件PostID为空，超过保留时间后会被清理
+type Attachment struct {
+	gorm.Model
+	UserID       uint   `json:"user_id" gorm:"not null;index"`
+	PostID       *uint  `json:"post_id" gorm:"index"`
+	StorageKey   string `json:"-" gorm:"uniqueIndex;not null;size:255"`
+	ThumbnailKey string `json:"-" gorm:"size:255"`
+	OriginalName string `json:"original_name" gorm:"size:255"`
+	ContentType  string `json:"content_type" gorm:"not null;size:100"`
+	Size         int64  `json:"size" gorm:"not null"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	// 访问地址，根据当前存储生成，不保存到数据库
+	URL          string `json:"url" gorm:"-"`
+	ThumbnailURL string `json:"thumbnail_url,omitempty" gorm:"-"`
+	// 关联关系
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// IdempotencyKey 客户端提供的幂等键及第一次请求的结果，重试时直接返回该结果，避免重复创建
+// 同一个用户的幂等键在同一作用域内唯一；过期后由清理任务删除
+type IdempotencyKey struct {
+	ID          uint      `json:"id" gorm:"primarykey"`
+	UserID      uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_idempotency_user_scope_key"`
+	Scope       string    `json:"scope" gorm:"not null;size:100;uniqueIndex:idx_idempotency_user_scope_key"` // 使用幂等键的接口
+	Key         string    `json:"key" gorm:"column:idempotency_key;not null;size:255;uniqueIndex:idx_idempotency_user_scope_key"`
+	RequestHash string    `json:"-" gorm:"not null;size:64"` // 请求内容的哈希，同一个键用于不同的请求时拒绝
+	StatusCode  int       `json:"status_code" gorm:"not null"`
+	Response    []byte    `json:"-" gorm:"type:mediumblob"`
+	CreatedAt   time.Time `json:"created_at"`
+	ExpiresAt   time.Time `json:"expires_at" gorm:"not null;index"`
+}
+
+// 个人数据导出的状态
+const (
+	DataExportPending = "pending" // 等待后台任务生成
+	DataExportRunning = "running"
+	DataExportReady   = "ready" // 可以下载
+	DataExportFailed  = "failed"
+	DataExportExpired = "expired" // 文件已过期删除
+)
+
+// DataExport 用户申请的个人数据导出，由后台任务生成JSON格式的zip文件
+// 文件保存在私有目录中，只能通过带签名的下载链接访问，过期后由清理任务删除文件
+type DataExport struct {
+	ID          uint       `json:"id" gorm:"primarykey"`
+	UserID      uint       `json:"user_id" gorm:"not null;index"`
+	Status      string     `json:"status" gorm:"not null;size:20;index"`
+	FileName    string     `json:"-" gorm:"size:255"` // 导出目录中的文件名，包含随机部分
+	Size        int64      `json:"size"`
+	Error       string     `json:"error,omitempty" gorm:"size:255"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at" gorm:"index"` // 文件删除时间，生成完成后设置
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestUserJSONHidesPrivateFields(t *testing.T) {
+	post := Post{Title: "hello", User: User{Username: "alice", Password: "hash", Role: RoleAdmin, TOTPEnabled: true, TOTPSecret: "secret"}}
+	data, err := json.Marshal(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{"password", "role", "totp", "mfa", "secret"} {
+		if strings.Contains(strings.ToLower(string(data)), field) {
+			t.Errorf("serialized post author contains %q: %s", field, data)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/test/blog/utils"
+	"gorm.io/gorm"
+)
+
+// defaultSlug 标题无法生成slug时（如只包含标点）使用的slug
+const defaultSlug = "post"
+
+// AssignSlug 根据标题生成唯一的slug，冲突时依次添加 -2、-3 等后缀
+// 已有slug的文章生成新slug后，旧slug保存到post_slugs用于重定向；调用方负责保存文章
+func (p *Post) AssignSlug(tx *gorm.DB) error {
+	base := utils.Slugify(p.Title)
+	if base == "" {
+		base = defaultSlug
+	}
+
+	for n := 1; ; n++ {
+		candidate := base
+		if n > 1 {
+			candidate = fmt.Sprintf("%s-%d", base, n)
+		}
+		if candidate == p.Slug {
+			return nil
+		}
+
+		available, err := p.slugAvailable(tx, candidate)
+		if err != nil {
+			return err
+		}
+		if !available {
+			continue
+		}
+
+		if p.ID != 0 {
+			// 重新使用自己的历史slug时删除对应的重定向
+			if err := tx.Where("post_id = ? AND slug = ?", p.ID, candidate).Delete(&PostSlug{}).Error; err != nil {
+				return err
+			}
+			if p.Slug != "" {
+				if err := tx.Create(&PostSlug{PostID: p.ID, Slug: p.Slug}).Error; err != nil {
+					return err
+				}
+			}
+		}
+		p.Slug = candidate
+		return nil
+	}
+}
+
+// slugAvailable 判断slug是否未被其他文章（包括已删除的文章）使用，其他文章的历史slug也视为已占用
+func (p *Post) slugAvailable(tx *gorm.DB, slug string) (bool, error) {
+	var post Post
+	err := tx.Unscoped().Select("id").Where("slug = ? AND id <> ?", slug, p.ID).First(&post).Error
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+
+	var old PostSlug
+	err = tx.Select("id").Where("slug = ? AND post_id <> ?", slug, p.ID).First(&old).Error
+	if err == nil {
+		return false, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, err
+	}
+	return true, nil
+}
+
+// SlugTaken 判断slug是否已被文章使用（包括已删除的文章和历史slug）
+func SlugTaken(tx *gorm.DB, slug string) (bool, error) {
+	var p Post
+	available, err := p.slugAvailable(tx, slug)
+	return !available, err
+}
@@ -0,0 +1,74 @@
+package models_test
+
+import (
+	"testing"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+// createPost 生成slug并保存文章
+func createPost(t *testing.T, title string) models.Post {
+	t.Helper()
+	post := models.Post{Title: title, Content: "c", UserID: 1}
+	if err := post.AssignSlug(config.GetDB()); err != nil {
+		t.Fatal(err)
+	}
+	if err := config.GetDB().Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+	return post
+}
+
+func TestAssignSlug(t *testing.T) {
+	testutil.Setup(t)
+
+	first := createPost(t, "Hello World")
+	second := createPost(t, "Hello, world!")
+	untitled := createPost(t, "???")
+	if first.Slug != "hello-world" || second.Slug != "hello-world-2" || untitled.Slug != "post" {
+		t.Fatalf("slugs = %q, %q, %q", first.Slug, second.Slug, untitled.Slug)
+	}
+
+	// 修改标题后旧slug保存为重定向，并且不能被其他文章占用
+	first.Title = "Renamed"
+	if err := first.AssignSlug(config.GetDB()); err != nil {
+		t.Fatal(err)
+	}
+	config.GetDB().Save(&first)
+	if first.Slug != "renamed" {
+		t.Fatalf("slug = %q, want renamed", first.Slug)
+	}
+	var old models.PostSlug
+	if err := config.GetDB().Where("slug = ?", "hello-world").First(&old).Error; err != nil || old.PostID != first.ID {
+		t.Fatalf("old slug redirect = %+v, %v", old, err)
+	}
+	if third := createPost(t, "Hello World"); third.Slug != "hello-world-3" {
+		t.Errorf("slug = %q, old slugs of other posts must stay reserved", third.Slug)
+	}
+
+	// 改回原标题时重新使用自己的旧slug，并删除对应的重定向
+	first.Title = "Hello World"
+	if err := first.AssignSlug(config.GetDB()); err != nil {
+		t.Fatal(err)
+	}
+	config.GetDB().Save(&first)
+	if first.Slug != "hello-world" {
+		t.Errorf("slug = %q, want hello-world", first.Slug)
+	}
+	if taken, _ := models.SlugTaken(config.GetDB(), "renamed"); !taken {
+		t.Error("the previous slug should be kept as a redirect")
+	}
+	var count int64
+	config.GetDB().Model(&models.PostSlug{}).Where("slug = ?", "hello-world").Count(&count)
+	if count != 0 {
+		t.Errorf("redirect for the reused slug was not removed")
+	}
+
+	// 标题不变时slug不变
+	unchanged := first.Slug
+	if err := first.AssignSlug(config.GetDB()); err != nil || first.Slug != unchanged {
+		t.Errorf("slug changed to %q, %v", first.Slug, err)
+	}
+}
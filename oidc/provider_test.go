@@ -0,0 +1,58 @@
+package oidc
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/test/blog/config"
+)
+
+// 获取JWKS期间不持有锁，已缓存的发现文档仍然可以立即读取
+func TestProviderFetchesWithoutLock(t *testing.T) {
+	release := make(chan struct{})
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/openid-configuration":
+			json.NewEncoder(w).Encode(discoveryDocument{
+				Issuer:                server.URL,
+				AuthorizationEndpoint: server.URL + "/authorize",
+				TokenEndpoint:         server.URL + "/token",
+				JWKSURI:               server.URL + "/jwks",
+			})
+		case "/jwks":
+			<-release
+			w.Write([]byte(`{"keys":[]}`))
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	p := NewProvider(config.OIDCProviderConfig{Name: "slow", Issuer: server.URL})
+	ctx := context.Background()
+	doc, err := p.getDiscovery(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go p.getKey(ctx, doc.JWKSURI, "unknown")
+	time.Sleep(50 * time.Millisecond)
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := p.getDiscovery(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("getDiscovery blocked while JWKS was being fetched")
+	}
+}
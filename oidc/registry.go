@@ -0,0 +1,37 @@
+package oidc
+
+import (
+	"sync"
+
+	"github.com/test/blog/config"
+)
+
+var (
+	mu        sync.RWMutex
+	providers = make(map[string]*Provider)
+
+	// States 进行中的授权请求
+	States = NewStateStore()
+)
+
+// InitProviders 根据配置注册身份提供方
+func InitProviders(cfgs []config.OIDCProviderConfig) {
+	for _, cfg := range cfgs {
+		Register(NewProvider(cfg))
+	}
+}
+
+// Register 注册身份提供方，同名的会被替换
+func Register(p *Provider) {
+	mu.Lock()
+	defer mu.Unlock()
+	providers[p.Name()] = p
+}
+
+// GetProvider 根据名称获取身份提供方
+func GetProvider(name string) (*Provider, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	p, ok := providers[name]
+	return p, ok
+}
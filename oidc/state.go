@@ -0,0 +1,86 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"sync"
+	"time"
+)
+
+// StateTTL 授权请求的有效期
+const StateTTL = 10 * time.Minute
+
+// AuthRequest 进行中的授权请求
+type AuthRequest struct {
+	Provider     string
+	CodeVerifier string
+	Nonce        string
+	LinkUserID   uint   // 非0表示将外部身份绑定到该用户，而不是登录
+	Binding      string // 发起授权的浏览器Cookie中的随机值，回调时必须一致
+	ExpiresAt    time.Time
+}
+
+// StateStore 保存进行中的授权请求，以state为键，每个state只能使用一次
+// 数据保存在内存中，多实例部署时需要让回调落到发起授权的实例上
+type StateStore struct {
+	mu    sync.Mutex
+	items map[string]AuthRequest
+}
+
+// NewStateStore 创建授权请求存储
+func NewStateStore() *StateStore {
+	return &StateStore{items: make(map[string]AuthRequest)}
+}
+
+// Save 保存授权请求并返回state
+func (s *StateStore) Save(req AuthRequest) (string, error) {
+	state, err := RandomString()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	req.ExpiresAt = now.Add(StateTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, item := range s.items {
+		if now.After(item.ExpiresAt) {
+			delete(s.items, key)
+		}
+	}
+	s.items[state] = req
+	return state, nil
+}
+
+// Take 取出并删除授权请求
+func (s *StateStore) Take(state string) (AuthRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	req, ok := s.items[state]
+	if !ok {
+		return AuthRequest{}, false
+	}
+	delete(s.items, state)
+	if time.Now().After(req.ExpiresAt) {
+		return AuthRequest{}, false
+	}
+	return req, true
+}
+
+// RandomString 生成随机字符串，用于state、nonce和PKCE code_verifier
+func RandomString() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// CodeChallenge 计算PKCE的S256 code_challenge
+func CodeChallenge(codeVerifier string) string {
+	sum := sha256.Sum256([]byte(codeVerifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -1,9 +1,19 @@
 package routes
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/test/blog/handlers"
 	"github.com/test/blog/middleware"
+	"github.com/test/blog/models"
+	"github.com/test/blog/storage"
+)
+
+// 公开内容允许浏览器和CDN缓存的时间
+const (
+	publicListMaxAge = time.Minute
+	feedMaxAge       = 5 * time.Minute
 )
 
 // SetupRoutes 设置路由
@@ -11,6 +21,9 @@ func SetupRoutes(r *gin.Engine) {
 	// 添加请求ID中间件
 	r.Use(middleware.RequestID())
 
+	// 添加限流中间件
+	r.Use(middleware.RateLimit())
+
 	// 健康检查
 	r.GET("/health", func(c *gin.Context) {
 		c.JSON(200, gin.H{
@@ -19,30 +32,128 @@ func SetupRoutes(r *gin.Engine) {
 		})
 	})
 
+	// JWT公钥集合，供其他服务验证token
+	r.GET("/.well-known/jwks.json", middleware.PublicCache(feedMaxAge), handlers.GetJWKS)
+
+	// RSS/Atom订阅源和站点地图
+	feeds := r.Group("")
+	feeds.Use(middleware.PublicCache(feedMaxAge))
+	{
+		feeds.GET("/feed.rss", handlers.GetSiteRSS)
+		feeds.GET("/feed.atom", handlers.GetSiteAtom)
+		feeds.GET("/authors/:username/feed.rss", handlers.GetAuthorRSS)
+		feeds.GET("/authors/:username/feed.atom", handlers.GetAuthorAtom)
+		feeds.GET("/sitemap.xml", handlers.GetSitemap)
+	}
+
+	// 本地存储的上传文件
+	if local, ok := storage.Get().(*storage.LocalStorage); ok {
+		r.Static(local.URLPrefix(), local.Dir())
+	}
+
 	// API路由组
 	api := r.Group("/api")
 	{
 		// 认证路由
 		auth := api.Group("/auth")
+		auth.Use(middleware.PrivateNoStore())
 		{
 			auth.POST("/register", handlers.Register)
 			auth.POST("/login", handlers.Login)
+
+			// OIDC第三方登录
+			auth.GET("/oidc/:provider/login", handlers.OIDCLogin)
+			auth.GET("/oidc/:provider/callback", handlers.OIDCCallback)
+
+			// 两步验证登录
+			auth.POST("/2fa/verify", handlers.VerifyTwoFactor)
 		}
 
-		// 需要认证的路由
-		authorized := api.Group("")
-		authorized.Use(middleware.AuthMiddleware())
+		// 个人数据导出的下载链接，通过链接中的签名令牌验证，无需认证
+		api.GET("/profile/export/:id/download", middleware.PrivateNoStore(), handlers.DownloadDataExport)
+
+		// 已认证的路由
+		authenticated := api.Group("")
+		authenticated.Use(middleware.PrivateNoStore(), middleware.AuthMiddleware())
+
+		// 两步验证设置（仅限登录会话，未启用两步验证的管理员也可以访问）
+		twoFactor := authenticated.Group("/profile/2fa")
+		twoFactor.Use(middleware.RequireUserSession())
+		{
+			twoFactor.POST("/setup", handlers.SetupTwoFactor)
+			twoFactor.POST("/confirm", handlers.ConfirmTwoFactor)
+			twoFactor.POST("/disable", handlers.DisableTwoFactor)
+			twoFactor.POST("/recovery-codes", handlers.RegenerateRecoveryCodes)
+		}
+
+		// 需要认证的路由（管理员必须已启用两步验证）
+		authorized := authenticated.Group("")
+		authorized.Use(middleware.RequireAdminMFA())
 		{
-			authorized.GET("/profile", handlers.GetProfile)
-			authorized.POST("/posts", handlers.CreatePost)
-			authorized.PUT("/posts/:id", handlers.UpdatePost)
-			authorized.DELETE("/posts/:id", handlers.DeletePost)
-			authorized.POST("/posts/:id/comments", handlers.CreateComment)
+			authorized.GET("/profile", middleware.RequireScope(models.ScopeRead), handlers.GetProfile)
+			authorized.DELETE("/profile", middleware.RequireUserSession(), handlers.DeleteAccount)
+			authorized.POST("/posts", middleware.RequireScope(models.ScopePostsWrite), middleware.Idempotency(), handlers.CreatePost)
+			authorized.POST("/posts/batch", middleware.RequireScope(models.ScopePostsWrite), middleware.Idempotency(), handlers.BatchPosts)
+			authorized.PUT("/posts/:id", middleware.RequireScope(models.ScopePostsWrite), handlers.UpdatePost)
+			authorized.PATCH("/posts/:id", middleware.RequireScope(models.ScopePostsWrite), handlers.PatchPost)
+			authorized.DELETE("/posts/:id", middleware.RequireScope(models.ScopePostsWrite), handlers.DeletePost)
+			authorized.POST("/posts/:id/comments", middleware.RequireScope(models.ScopeCommentsWrite), middleware.Idempotency(), handlers.CreateComment)
+			authorized.GET("/posts/:id/revisions", middleware.RequireScope(models.ScopeRead), handlers.GetPostRevisions)
+			authorized.GET("/posts/:id/revisions/diff", middleware.RequireScope(models.ScopeRead), handlers.DiffPostRevisions)
+			authorized.GET("/posts/:id/revisions/:rev", middleware.RequireScope(models.ScopeRead), handlers.GetPostRevision)
+			authorized.POST("/posts/:id/revisions/:rev/restore", middleware.RequireScope(models.ScopePostsWrite), handlers.RestorePostRevision)
+			authorized.POST("/uploads", middleware.RequireScope(models.ScopePostsWrite), handlers.UploadFile)
+
+			// 回收站
+			authorized.GET("/profile/trash", middleware.RequireScope(models.ScopeRead), handlers.GetTrash)
+			authorized.POST("/posts/:id/restore", middleware.RequireScope(models.ScopePostsWrite), handlers.RestorePost)
+
+			// 管理员接口（仅限登录会话，个人访问令牌的权限范围不包含管理操作）
+			admin := authorized.Group("/admin")
+			admin.Use(middleware.RequireRole(models.RoleAdmin), middleware.RequireUserSession())
+			{
+				admin.GET("/trash", handlers.GetAdminTrash)
+				admin.GET("/trash/:id", handlers.GetAdminTrashedPost)
+				admin.DELETE("/users/:id", handlers.AdminDeleteUser)
+				admin.GET("/export", handlers.AdminExportPosts)
+				admin.POST("/import", handlers.AdminImportPosts)
+			}
+
+			// 个人访问令牌管理（仅限登录会话）
+			tokens := authorized.Group("/profile/tokens")
+			tokens.Use(middleware.RequireUserSession())
+			{
+				tokens.GET("", handlers.GetTokens)
+				tokens.POST("", handlers.CreateToken)
+				tokens.DELETE("/:id", handlers.RevokeToken)
+			}
+
+			// 个人数据导出（仅限登录会话）
+			dataExport := authorized.Group("/profile/export")
+			dataExport.Use(middleware.RequireUserSession())
+			{
+				dataExport.POST("", handlers.RequestDataExport)
+				dataExport.GET("/:id", handlers.GetDataExport)
+			}
+
+			// 外部身份绑定（仅限登录会话）
+			identities := authorized.Group("/profile/identities")
+			identities.Use(middleware.RequireUserSession())
+			{
+				identities.GET("", handlers.GetIdentities)
+				identities.POST("/:provider", handlers.LinkIdentity)
+			}
 		}
 
 		// 公开路由
-		api.GET("/posts", handlers.GetPosts)
-		api.GET("/posts/:id", handlers.GetPost)
-		api.GET("/posts/:id/comments", handlers.GetComments)
+		public := api.Group("")
+		public.Use(middleware.PublicCache(publicListMaxAge))
+		{
+			public.GET("/posts", handlers.GetPosts)
+			public.GET("/posts/trending", handlers.GetTrendingPosts)
+			public.GET("/posts/:id", handlers.GetPost)
+			public.GET("/posts/by-slug/:slug", handlers.GetPostBySlug)
+			public.GET("/posts/:id/comments", handlers.GetComments)
+		}
 	}
 }
@@ -0,0 +1,59 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+	"github.com/test/blog/utils"
+)
+
+func TestAdminRoutesRequireUserSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	testutil.Setup(t)
+	admin := testutil.CreateUser(t, "admin", func(u *models.User) {
+		u.Role = models.RoleAdmin
+		u.TOTPEnabled = true
+	})
+	target := testutil.CreateUser(t, "target")
+
+	plain, hash, err := utils.GenerateAPIToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pat := models.PersonalAccessToken{UserID: admin.ID, Name: "ci", TokenHash: hash, Prefix: plain[:12], Scopes: models.ScopeRead}
+	if err := config.GetDB().Create(&pat).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	SetupRoutes(r)
+
+	// 只读的个人访问令牌不能执行管理操作
+	for _, route := range []struct{ method, path string }{
+		{http.MethodGet, "/api/admin/export"},
+		{http.MethodPost, "/api/admin/import"},
+		{http.MethodGet, "/api/admin/trash"},
+	} {
+		if w := testutil.Request(t, r, route.method, route.path, plain, nil); w.Code != http.StatusForbidden {
+			t.Errorf("%s %s with a read token status = %d, want 403", route.method, route.path, w.Code)
+		}
+	}
+	path := fmt.Sprintf("/api/admin/users/%d", target.ID)
+	if w := testutil.Request(t, r, http.MethodDelete, path, plain, nil); w.Code != http.StatusForbidden {
+		t.Fatalf("read token status = %d, want 403: %s", w.Code, w.Body.String())
+	}
+	var stored models.User
+	if err := config.GetDB().First(&stored, target.ID).Error; err != nil {
+		t.Fatalf("user was deleted with a read token: %v", err)
+	}
+
+	// 登录会话可以执行
+	if w := testutil.Request(t, r, http.MethodDelete, path, testutil.Token(t, admin), nil); w.Code != http.StatusOK {
+		t.Errorf("session status = %d, want 200: %s", w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLocalStorage(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewLocalStorage(dir, "/uploads/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	if err := s.Put(ctx, "2024/01/a.txt", strings.NewReader("hello"), 5, "text/plain"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "2024", "01", "a.txt"))
+	if err != nil || string(data) != "hello" {
+		t.Fatalf("stored %q, %v", data, err)
+	}
+	if got := s.URL("2024/01/a.txt"); got != "/uploads/2024/01/a.txt" {
+		t.Errorf("URL = %q", got)
+	}
+
+	if err := s.Delete(ctx, "2024/01/a.txt"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := s.Delete(ctx, "2024/01/a.txt"); err != nil {
+		t.Errorf("deleting a missing file: %v", err)
+	}
+}
+
+func TestLocalStorageRejectsInvalidKeys(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir(), "/uploads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"", "../escape.txt", "a/../../escape.txt", "/absolute.txt", "a//b.txt", "a/./b.txt"} {
+		if err := s.Put(context.Background(), key, strings.NewReader("x"), 1, "text/plain"); err == nil {
+			t.Errorf("Put(%q) should be rejected", key)
+		}
+	}
+}
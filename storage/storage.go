@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync/atomic"
+
+	"github.com/test/blog/config"
+)
+
+// Storage 上传文件存储
+type Storage interface {
+	// Put 保存文件，key 使用 / 分隔
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Delete 删除文件，文件不存在时不返回错误
+	Delete(ctx context.Context, key string) error
+	// URL 获取文件的访问地址
+	URL(key string) string
+}
+
+var current atomic.Value
+
+// Init 根据配置初始化存储
+func Init(cfg config.UploadConfig) error {
+	var s Storage
+	switch cfg.Storage {
+	case "local":
+		local, err := NewLocalStorage(cfg.LocalDir, cfg.LocalURLPrefix)
+		if err != nil {
+			return err
+		}
+		s = local
+	case "s3":
+		s = NewS3Storage(cfg.S3)
+	default:
+		return fmt.Errorf("unsupported storage %q", cfg.Storage)
+	}
+	Set(s)
+	return nil
+}
+
+// Set 设置当前使用的存储
+func Set(s Storage) {
+	current.Store(&s)
+}
+
+// Get 获取当前使用的存储
+func Get() Storage {
+	s, _ := current.Load().(*Storage)
+	if s == nil {
+		return nil
+	}
+	return *s
+}
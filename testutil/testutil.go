@@ -0,0 +1,131 @@
+// Package testutil 测试使用的内存数据库、配置和用户
+package testutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Setup 使用默认配置和SQLite内存数据库初始化全局状态，返回数据库
+// 每个测试使用独立的数据库，测试结束后关闭
+func Setup(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	cfg := config.DefaultConfig()
+	config.Set(cfg)
+	if err := config.InitJWTKeys(cfg); err != nil {
+		t.Fatalf("init JWT keys: %v", err)
+	}
+
+	name := strings.NewReplacer("/", "_", " ", "_").Replace(t.Name())
+	dsn := fmt.Sprintf("file:%s?mode=memory&cache=shared&_pragma=busy_timeout(5000)", name)
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{
+		Logger:                                   logger.Discard,
+		DisableForeignKeyConstraintWhenMigrating: true,
+		TranslateError:                           true, // 唯一约束冲突转换为gorm.ErrDuplicatedKey，与MySQL一样被识别为冲突
+	})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatalf("get sql.DB: %v", err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	config.DB = db
+	if err := config.AutoMigrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// CreateUser 创建用户，密码为 password123
+func CreateUser(t *testing.T, username string, mutate ...func(*models.User)) models.User {
+	t.Helper()
+	hash, err := utils.HashPassword("password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+	user := models.User{Username: username, Email: username + "@example.com", Password: hash, Role: models.RoleUser}
+	for _, fn := range mutate {
+		fn(&user)
+	}
+	if err := config.GetDB().Create(&user).Error; err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// CreatePost 创建文章并根据标题生成slug
+func CreatePost(t *testing.T, user models.User, title string, mutate ...func(*models.Post)) models.Post {
+	t.Helper()
+	post := models.Post{Title: title, Content: "Content of " + title, UserID: user.ID}
+	for _, fn := range mutate {
+		fn(&post)
+	}
+	if err := post.AssignSlug(config.GetDB()); err != nil {
+		t.Fatalf("assign slug: %v", err)
+	}
+	if err := config.GetDB().Create(&post).Error; err != nil {
+		t.Fatalf("create post: %v", err)
+	}
+	return post
+}
+
+// Token 为用户签发访问令牌
+func Token(t *testing.T, user models.User) string {
+	t.Helper()
+	token, err := config.JWTKeys().GenerateToken(user.ID, user.Username, user.Role, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+// Request 发送请求，body不为nil时编码为JSON，token不为空时作为Bearer令牌
+func Request(t *testing.T, h http.Handler, method, path, token string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+// Decode 解析JSON响应
+func Decode(t *testing.T, w *httptest.ResponseRecorder) map[string]any {
+	t.Helper()
+	var body map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode response %q: %v", w.Body.String(), err)
+	}
+	return body
+}
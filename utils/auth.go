@@ -1,7 +1,14 @@
 package utils
 
 import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -12,9 +19,17 @@ import (
 type JWTClaims struct {
 	UserID   uint   `json:"user_id"`
 	Username string `json:"username"`
+	Role     string `json:"role,omitempty"`
+	Purpose  string `json:"purpose,omitempty"` // 非空表示不是访问令牌，如两步验证挑战令牌
 	jwt.RegisteredClaims
 }
 
+// 非访问令牌的用途
+const (
+	purposeMFA        = "mfa"         // 两步验证挑战令牌
+	purposeDataExport = "data_export" // 个人数据导出的下载链接
+)
+
 // HashPassword 加密密码
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
@@ -27,33 +42,136 @@ func CheckPassword(password, hash string) bool {
 	return err == nil
 }
 
-// GenerateToken 生成JWT token
-func GenerateToken(userID uint, username, secret string) (string, error) {
-	// 获取JWT过期时间
-	expirationHours, err := GetEnvInt("JWT_EXPIRATION_HOURS")
+// APITokenPrefix 个人访问令牌前缀，用于和JWT区分
+const APITokenPrefix = "blog_pat_"
+
+// GenerateAPIToken 生成个人访问令牌，返回明文和哈希值
+func GenerateAPIToken() (string, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", err
+	}
+	token := APITokenPrefix + base64.RawURLEncoding.EncodeToString(buf)
+	return token, HashAPIToken(token), nil
+}
+
+// HashAPIToken 计算个人访问令牌的哈希值
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// GenerateToken 使用当前签名密钥生成JWT token
+func (ks *KeySet) GenerateToken(userID uint, username, role string, expiration time.Duration) (string, error) {
+	return ks.sign(JWTClaims{UserID: userID, Username: username, Role: role}, expiration)
+}
+
+// GenerateMFAToken 生成两步验证挑战令牌，只能用于完成两步验证，不能访问其他接口
+func (ks *KeySet) GenerateMFAToken(userID uint, username string, expiration time.Duration) (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	claims := JWTClaims{UserID: userID, Username: username, Purpose: purposeMFA}
+	claims.ID = hex.EncodeToString(buf)
+	return ks.sign(claims, expiration)
+}
+
+// GenerateDataExportToken 生成个人数据导出的下载令牌，令牌ID为导出记录的ID，只能用于下载该导出文件
+func (ks *KeySet) GenerateDataExportToken(userID, exportID uint, expiration time.Duration) (string, error) {
+	claims := JWTClaims{UserID: userID, Purpose: purposeDataExport}
+	claims.ID = strconv.FormatUint(uint64(exportID), 10)
+	return ks.sign(claims, expiration)
+}
+
+// sign 填充注册声明并使用当前签名密钥签名
+func (ks *KeySet) sign(claims JWTClaims, expiration time.Duration) (string, error) {
+	now := time.Now()
+	claims.Issuer = ks.issuer
+	claims.Subject = strconv.FormatUint(uint64(claims.UserID), 10)
+	claims.ExpiresAt = jwt.NewNumericDate(now.Add(expiration))
+	claims.IssuedAt = jwt.NewNumericDate(now)
+	claims.NotBefore = jwt.NewNumericDate(now)
+	if ks.audience != "" {
+		claims.Audience = jwt.ClaimStrings{ks.audience}
+	}
+
+	token := jwt.NewWithClaims(ks.active.method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.signKey)
+}
+
+// ValidateToken 验证访问令牌
+// 根据kid选择密钥，且签名算法必须与该密钥的算法一致，同时校验iss和aud
+func (ks *KeySet) ValidateToken(tokenString string) (*JWTClaims, error) {
+	claims, err := ks.parse(tokenString)
 	if err != nil {
-		expirationHours = 24 // 默认24小时
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, errors.New("token is not an access token")
 	}
+	return claims, nil
+}
 
-	claims := JWTClaims{
-		UserID:   userID,
-		Username: username,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expirationHours) * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-			NotBefore: jwt.NewNumericDate(time.Now()),
-		},
+// ValidateMFAToken 验证两步验证挑战令牌
+func (ks *KeySet) ValidateMFAToken(tokenString string) (*JWTClaims, error) {
+	claims, err := ks.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purposeMFA {
+		return nil, errors.New("token is not an MFA challenge token")
 	}
+	return claims, nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secret))
+// ValidateDataExportToken 验证个人数据导出的下载令牌，返回令牌对应的导出记录ID
+func (ks *KeySet) ValidateDataExportToken(tokenString string) (*JWTClaims, uint, error) {
+	claims, err := ks.parse(tokenString)
+	if err != nil {
+		return nil, 0, err
+	}
+	if claims.Purpose != purposeDataExport {
+		return nil, 0, errors.New("token is not a data export token")
+	}
+	exportID, err := strconv.ParseUint(claims.ID, 10, 64)
+	if err != nil || exportID == 0 {
+		return nil, 0, errors.New("invalid data export id")
+	}
+	return claims, uint(exportID), nil
 }
 
-// ValidateToken 验证JWT token
-func ValidateToken(tokenString, secret string) (*JWTClaims, error) {
+// parse 验证签名和注册声明
+// 没有kid的token是引入密钥轮换之前签发的，在过渡期内使用旧的HS256密钥验证
+func (ks *KeySet) parse(tokenString string) (*JWTClaims, error) {
+	if ks.legacySecret != nil && time.Now().Before(ks.legacyUntil) && !hasKeyID(tokenString) {
+		return ks.parseLegacy(tokenString)
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods(ks.algorithms()),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+	}
+	if ks.issuer != "" {
+		options = append(options, jwt.WithIssuer(ks.issuer))
+	}
+	if ks.audience != "" {
+		options = append(options, jwt.WithAudience(ks.audience))
+	}
+
 	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return []byte(secret), nil
-	})
+		kid, _ := token.Header["kid"].(string)
+		key, ok := ks.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key %q", kid)
+		}
+		if token.Method.Alg() != key.Algorithm {
+			return nil, fmt.Errorf("unexpected signing method %s for key %q", token.Method.Alg(), kid)
+		}
+		return key.verifyKey, nil
+	}, options...)
 
 	if err != nil {
 		return nil, err
@@ -65,3 +183,36 @@ func ValidateToken(tokenString, secret string) (*JWTClaims, error) {
 
 	return nil, errors.New("invalid token")
 }
+
+// parseLegacy 验证没有kid的旧token，只接受HS256签名
+// 旧token没有iss和aud，包含这两项时必须与当前配置一致，防止使用同一密钥的其他服务签发的token通过验证
+func (ks *KeySet) parseLegacy(tokenString string) (*JWTClaims, error) {
+	token, err := jwt.ParseWithClaims(tokenString, &JWTClaims{}, func(*jwt.Token) (interface{}, error) {
+		return ks.legacySecret, nil
+	}, jwt.WithValidMethods([]string{AlgHS256}), jwt.WithExpirationRequired(), jwt.WithIssuedAt())
+	if err != nil {
+		return nil, err
+	}
+
+	claims, ok := token.Claims.(*JWTClaims)
+	if !ok || !token.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if claims.Issuer != "" && claims.Issuer != ks.issuer {
+		return nil, errors.New("token has invalid issuer")
+	}
+	if len(claims.Audience) > 0 && (ks.audience == "" || !slices.Contains(claims.Audience, ks.audience)) {
+		return nil, errors.New("token has invalid audience")
+	}
+	return claims, nil
+}
+
+// hasKeyID 判断token头部是否包含kid，此时不验证签名
+func hasKeyID(tokenString string) bool {
+	token, _, err := jwt.NewParser().ParseUnverified(tokenString, &JWTClaims{})
+	if err != nil {
+		return true // 交给正常流程返回解析错误
+	}
+	_, ok := token.Header["kid"]
+	return ok
+}
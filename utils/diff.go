@@ -0,0 +1,301 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// 差异片段类型
+const (
+	DiffEqual  = "equal"
+	DiffInsert = "insert"
+	DiffDelete = "delete"
+)
+
+// DiffSegment 差异片段，相邻的同类型片段会被合并
+type DiffSegment struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+// diffEdit 单个token的编辑操作
+type diffEdit struct {
+	op    string
+	token string
+}
+
+// WordDiff 按词比较两段文本
+// 英文等按单词和空白切分，中日韩文字按单字切分
+func WordDiff(a, b string) []DiffSegment {
+	var segments []DiffSegment
+	for _, edit := range myersDiff(tokenizeWords(a), tokenizeWords(b)) {
+		if n := len(segments); n > 0 && segments[n-1].Type == edit.op {
+			segments[n-1].Text += edit.token
+			continue
+		}
+		segments = append(segments, DiffSegment{Type: edit.op, Text: edit.token})
+	}
+	return segments
+}
+
+// UnifiedDiff 按行比较两段文本，输出统一格式（unified diff），context为每处修改前后保留的行数
+// 两段文本相同时返回空字符串
+func UnifiedDiff(a, b, fromLabel, toLabel string, context int) string {
+	edits := myersDiff(splitLines(a), splitLines(b))
+
+	// 找出修改所在的位置，相距不超过2*context行的修改合并为一个hunk
+	var changes []int
+	for i, edit := range edits {
+		if edit.op != DiffEqual {
+			changes = append(changes, i)
+		}
+	}
+	if len(changes) == 0 {
+		return ""
+	}
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", fromLabel, toLabel)
+
+	// aLine/bLine 记录每个编辑操作之前两侧已经过的行数
+	aLine := make([]int, len(edits)+1)
+	bLine := make([]int, len(edits)+1)
+	for i, edit := range edits {
+		aLine[i+1], bLine[i+1] = aLine[i], bLine[i]
+		if edit.op != DiffInsert {
+			aLine[i+1]++
+		}
+		if edit.op != DiffDelete {
+			bLine[i+1]++
+		}
+	}
+
+	for i := 0; i < len(changes); {
+		start := max(changes[i]-context, 0)
+		j := i
+		for j+1 < len(changes) && changes[j+1]-changes[j] <= 2*context {
+			j++
+		}
+		end := min(changes[j]+context+1, len(edits))
+
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n",
+			hunkRange(aLine[start], aLine[end]-aLine[start]),
+			hunkRange(bLine[start], bLine[end]-bLine[start]))
+		for _, edit := range edits[start:end] {
+			prefix := " "
+			switch edit.op {
+			case DiffInsert:
+				prefix = "+"
+			case DiffDelete:
+				prefix = "-"
+			}
+			out.WriteString(prefix + edit.token + "\n")
+		}
+		i = j + 1
+	}
+	return out.String()
+}
+
+// hunkRange 生成hunk头部的行范围，行号从1开始，长度为0时行号指向前一行
+func hunkRange(start, length int) string {
+	if length == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if length == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, length)
+}
+
+// splitLines 按行切分文本，忽略末尾的换行符
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(strings.ReplaceAll(s, "\r\n", "\n"), "\n"), "\n")
+}
+
+// tokenizeWords 将文本切分为单词、空白、标点和中日韩单字
+func tokenizeWords(s string) []string {
+	var tokens []string
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch r := runes[i]; {
+		case unicode.IsSpace(r):
+			for j < len(runes) && unicode.IsSpace(runes[j]) {
+				j++
+			}
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !isIdeograph(r) {
+				for j < len(runes) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) && !isIdeograph(runes[j]) {
+					j++
+				}
+			}
+		}
+		tokens = append(tokens, string(runes[i:j]))
+		i = j
+	}
+	return tokens
+}
+
+// isIdeograph 判断是否为需要逐字比较的中日韩文字
+func isIdeograph(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+// maxDiffEdits 编辑距离上限，超过时不再计算最短编辑序列，直接视为整体删除后插入
+// 计算使用线性空间的Myers算法，内存与文本长度成正比，这个上限只限制计算时间
+const maxDiffEdits = 2000
+
+// myersDiff 使用Myers算法计算最短编辑序列
+// 每处连续的修改中删除排在插入之前
+func myersDiff(a, b []string) []diffEdit {
+	var d differ
+	edits, ok := d.diff(a, b, make([]diffEdit, 0, max(len(a), len(b))), maxDiffEdits)
+	if !ok {
+		// 差异过大，公共前后缀之外的部分整体删除后插入
+		prefix, suffix := commonAffixes(a, b)
+		edits = appendEdits(edits[:0], DiffEqual, a[:prefix])
+		edits = appendEdits(edits, DiffDelete, a[prefix:len(a)-suffix])
+		edits = appendEdits(edits, DiffInsert, b[prefix:len(b)-suffix])
+		edits = appendEdits(edits, DiffEqual, a[len(a)-suffix:])
+	}
+	return groupChanges(edits)
+}
+
+// differ 线性空间的Myers算法，递归过程中复用同一组数组
+type differ struct {
+	vf []int // vf[k]为正向搜索在对角线k上到达的最远x
+	vb []int // vb[k]为反向搜索在对角线k上从末尾倒退的最远距离
+}
+
+// diff 以中间蛇为界把问题一分为二递归求解，结果追加到edits
+// 编辑距离超过limit时返回false；子问题的编辑距离不超过父问题，使用父问题的编辑距离作为上限
+func (df *differ) diff(a, b []string, edits []diffEdit, limit int) ([]diffEdit, bool) {
+	prefix, suffix := commonAffixes(a, b)
+	edits = appendEdits(edits, DiffEqual, a[:prefix])
+	midA, midB := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	switch {
+	case len(midA) == 0:
+		edits = appendEdits(edits, DiffInsert, midB)
+	case len(midB) == 0:
+		edits = appendEdits(edits, DiffDelete, midA)
+	default:
+		// 去掉公共前后缀后两侧都不为空，编辑距离至少为2，两个子问题都严格更小
+		x, y, u, v, d, ok := df.middleSnake(midA, midB, limit)
+		if !ok {
+			return edits, false
+		}
+		edits, _ = df.diff(midA[:x], midB[:y], edits, d)
+		edits = appendEdits(edits, DiffEqual, midA[x:u])
+		edits, _ = df.diff(midA[u:], midB[v:], edits, d)
+	}
+
+	return appendEdits(edits, DiffEqual, a[len(a)-suffix:]), true
+}
+
+// middleSnake 同时从两端搜索，找出最短编辑路径中间的一段对角线 (x,y)-(u,v) 和编辑距离d
+// 编辑距离超过limit时返回false
+func (df *differ) middleSnake(a, b []string, limit int) (x, y, u, v, d int, ok bool) {
+	n, m := len(a), len(b)
+	delta := n - m
+	maxD := min((n+m+1)/2, limit/2+1)
+	offset := maxD + 1
+	size := 2*maxD + 3
+	if cap(df.vf) < size {
+		df.vf = make([]int, size)
+		df.vb = make([]int, size)
+	}
+	vf, vb := df.vf[:size], df.vb[:size]
+	clear(vf)
+	clear(vb)
+
+	for d := 0; d <= maxD; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x0 int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x0 = vf[offset+k+1]
+			} else {
+				x0 = vf[offset+k-1] + 1
+			}
+			x, y := x0, x0-k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+			// 编辑距离为奇数时，两端的路径在正向搜索中相遇
+			if c := delta - k; delta%2 != 0 && c >= -(d-1) && c <= d-1 && x+vb[offset+c] >= n {
+				if 2*d-1 > limit {
+					return 0, 0, 0, 0, 0, false
+				}
+				return x0, x0 - k, x, y, 2*d - 1, true
+			}
+		}
+
+		for c := -d; c <= d; c += 2 {
+			var x0 int
+			if c == -d || (c != d && vb[offset+c-1] < vb[offset+c+1]) {
+				x0 = vb[offset+c+1]
+			} else {
+				x0 = vb[offset+c-1] + 1
+			}
+			x, y := x0, x0-c
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			vb[offset+c] = x
+			// 编辑距离为偶数时，两端的路径在反向搜索中相遇
+			if k := delta - c; delta%2 == 0 && k >= -d && k <= d && x+vf[offset+k] >= n {
+				if 2*d > limit {
+					return 0, 0, 0, 0, 0, false
+				}
+				return n - x, m - y, n - x0, m - (x0 - c), 2 * d, true
+			}
+		}
+	}
+	return 0, 0, 0, 0, 0, false
+}
+
+// commonAffixes 计算公共前缀和公共后缀的长度，两者不重叠
+func commonAffixes(a, b []string) (prefix, suffix int) {
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	return prefix, suffix
+}
+
+// appendEdits 将tokens以同一种操作追加到edits
+func appendEdits(edits []diffEdit, op string, tokens []string) []diffEdit {
+	for _, token := range tokens {
+		edits = append(edits, diffEdit{op: op, token: token})
+	}
+	return edits
+}
+
+// groupChanges 把每处连续修改中的删除移到插入之前，不改变编辑序列的含义
+func groupChanges(edits []diffEdit) []diffEdit {
+	for start := 0; start < len(edits); {
+		if edits[start].op == DiffEqual {
+			start++
+			continue
+		}
+		end := start
+		for end < len(edits) && edits[end].op != DiffEqual {
+			end++
+		}
+		sort.SliceStable(edits[start:end], func(i, j int) bool {
+			return edits[start+i].op == DiffDelete && edits[start+j].op == DiffInsert
+		})
+		start = end
+	}
+	return edits
+}
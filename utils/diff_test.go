@@ -0,0 +1,203 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+// applyEdits 根据编辑序列还原出两侧的token
+func applyEdits(edits []diffEdit) (a, b []string) {
+	for _, edit := range edits {
+		if edit.op != DiffInsert {
+			a = append(a, edit.token)
+		}
+		if edit.op != DiffDelete {
+			b = append(b, edit.token)
+		}
+	}
+	return a, b
+}
+
+// editDistance 使用动态规划计算只允许插入和删除的编辑距离
+func editDistance(a, b []string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			if a[i-1] == b[j-1] {
+				cur[j] = prev[j-1]
+			} else {
+				cur[j] = min(prev[j], cur[j-1]) + 1
+			}
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func countChanges(edits []diffEdit) int {
+	n := 0
+	for _, edit := range edits {
+		if edit.op != DiffEqual {
+			n++
+		}
+	}
+	return n
+}
+
+func TestMyersDiffIsMinimal(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	randomTokens := func() []string {
+		tokens := make([]string, rng.Intn(30))
+		for i := range tokens {
+			tokens[i] = string(rune('a' + rng.Intn(4)))
+		}
+		return tokens
+	}
+
+	for i := 0; i < 500; i++ {
+		a, b := randomTokens(), randomTokens()
+		edits := myersDiff(a, b)
+		gotA, gotB := applyEdits(edits)
+		if strings.Join(gotA, "") != strings.Join(a, "") || strings.Join(gotB, "") != strings.Join(b, "") {
+			t.Fatalf("edits for %q -> %q do not reproduce the inputs", a, b)
+		}
+		if got, want := countChanges(edits), editDistance(a, b); got != want {
+			t.Fatalf("%q -> %q: %d changes, want %d", a, b, got, want)
+		}
+	}
+}
+
+func TestMyersDiffGroupsDeletesBeforeInserts(t *testing.T) {
+	edits := myersDiff(strings.Split("a b c d", " "), strings.Split("a x y d", " "))
+	var ops []string
+	for _, edit := range edits {
+		ops = append(ops, edit.op[:1]+edit.token)
+	}
+	want := []string{"ea", "db", "dc", "ix", "iy", "ed"}
+	if !reflect.DeepEqual(ops, want) {
+		t.Errorf("ops = %v, want %v", ops, want)
+	}
+}
+
+func TestMyersDiffTooManyEdits(t *testing.T) {
+	var a, b []string
+	for i := 0; i < maxDiffEdits; i++ {
+		a = append(a, fmt.Sprintf("a%d", i))
+		b = append(b, fmt.Sprintf("b%d", i))
+	}
+	a = append([]string{"same"}, append(a, "end")...)
+	b = append([]string{"same"}, append(b, "end")...)
+
+	edits := myersDiff(a, b)
+	if edits[0].op != DiffEqual || edits[len(edits)-1].op != DiffEqual {
+		t.Error("common prefix and suffix should be kept")
+	}
+	if edits[1].op != DiffDelete || edits[maxDiffEdits+1].op != DiffInsert || countChanges(edits) != 2*maxDiffEdits {
+		t.Error("expected a full delete followed by a full insert")
+	}
+}
+
+// 线性空间算法的内存与输入长度成正比，不随编辑距离平方增长
+func TestMyersDiffMemory(t *testing.T) {
+	var a, b []string
+	for i := 0; i < 20000; i++ {
+		line := fmt.Sprintf("line %d", i)
+		a = append(a, line)
+		if i%20 == 0 {
+			line = "changed " + line
+		}
+		b = append(b, line)
+	}
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	edits := myersDiff(a, b)
+	runtime.ReadMemStats(&after)
+
+	if got := countChanges(edits); got != 2000 {
+		t.Fatalf("changes = %d, want 2000", got)
+	}
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 8<<20 {
+		t.Errorf("diff allocated %d bytes", allocated)
+	}
+}
+
+func TestWordDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want []DiffSegment
+	}{
+		{"identical", "same text", "same text", []DiffSegment{{DiffEqual, "same text"}}},
+		{"replace a word", "the quick fox", "the slow fox", []DiffSegment{
+			{DiffEqual, "the "}, {DiffDelete, "quick"}, {DiffInsert, "slow"}, {DiffEqual, " fox"},
+		}},
+		{"append", "hello", "hello world", []DiffSegment{{DiffEqual, "hello"}, {DiffInsert, " world"}}},
+		{"punctuation is a separate token", "Hi, there", "Hi! there", []DiffSegment{
+			{DiffEqual, "Hi"}, {DiffDelete, ","}, {DiffInsert, "!"}, {DiffEqual, " there"},
+		}},
+		{"ideographs are compared one by one", "我喜欢猫", "我喜欢狗", []DiffSegment{
+			{DiffEqual, "我喜欢"}, {DiffDelete, "猫"}, {DiffInsert, "狗"},
+		}},
+		{"from empty", "", "new", []DiffSegment{{DiffInsert, "new"}}},
+		{"both empty", "", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WordDiff(tt.a, tt.b); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("WordDiff = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	lines := func(n int) []string {
+		var out []string
+		for i := 1; i <= n; i++ {
+			out = append(out, fmt.Sprintf("line %d", i))
+		}
+		return out
+	}
+	a := strings.Join(lines(12), "\n") + "\n"
+	changed := lines(12)
+	changed[1] = "line two"
+	changed = append(changed[:10], changed[11:]...)
+	b := strings.Join(changed, "\n") + "\n"
+
+	want := `--- v1
++++ v2
+@@ -1,5 +1,5 @@
+ line 1
+-line 2
++line two
+ line 3
+ line 4
+ line 5
+@@ -8,5 +8,4 @@
+ line 8
+ line 9
+ line 10
+-line 11
+ line 12
+`
+	if got := UnifiedDiff(a, b, "v1", "v2", 3); got != want {
+		t.Errorf("UnifiedDiff =\n%s\nwant\n%s", got, want)
+	}
+	if got := UnifiedDiff(a, a, "v1", "v2", 3); got != "" {
+		t.Errorf("identical texts should produce no diff, got %q", got)
+	}
+	if got := UnifiedDiff("", "new\n", "v1", "v2", 3); got != "--- v1\n+++ v2\n@@ -0,0 +1 @@\n+new\n" {
+		t.Errorf("diff from empty = %q", got)
+	}
+}
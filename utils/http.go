@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// NotModified 判断条件请求是否可以返回304，If-None-Match优先于If-Modified-Since
+// ETag使用弱比较，W/前缀不影响比较结果
+func NotModified(header http.Header, etag string, lastModified time.Time) bool {
+	if inm := header.Get("If-None-Match"); inm != "" {
+		etag = strings.TrimPrefix(etag, "W/")
+		for _, candidate := range strings.Split(inm, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+			if candidate == etag || candidate == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if ims := header.Get("If-Modified-Since"); ims != "" && !lastModified.IsZero() {
+		if t, err := http.ParseTime(ims); err == nil {
+			return !lastModified.Truncate(time.Second).After(t)
+		}
+	}
+	return false
+}
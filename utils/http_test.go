@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNotModified(t *testing.T) {
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 600, time.UTC)
+	tests := []struct {
+		name         string
+		header       map[string]string
+		etag         string
+		lastModified time.Time
+		want         bool
+	}{
+		{"no conditions", nil, `"v1"`, modified, false},
+		{"matching etag", map[string]string{"If-None-Match": `"v1"`}, `"v1"`, modified, true},
+		{"one of several", map[string]string{"If-None-Match": `"v0", "v1"`}, `"v1"`, modified, true},
+		{"weak comparison", map[string]string{"If-None-Match": `W/"v1"`}, `"v1"`, modified, true},
+		{"weak etag", map[string]string{"If-None-Match": `"v1"`}, `W/"v1"`, modified, true},
+		{"wildcard", map[string]string{"If-None-Match": "*"}, `"v1"`, modified, true},
+		{"different etag", map[string]string{"If-None-Match": `"v0"`}, `"v1"`, modified, false},
+		// If-None-Match存在时忽略If-Modified-Since
+		{"etag takes precedence", map[string]string{"If-None-Match": `"v0"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, `"v1"`, modified, false},
+		// 时间只精确到秒
+		{"same second", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, `"v1"`, modified, true},
+		{"modified later", map[string]string{"If-Modified-Since": modified.Add(-time.Second).Format(http.TimeFormat)}, `"v1"`, modified, false},
+		{"unknown modification time", map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, `"v1"`, time.Time{}, false},
+		{"invalid date", map[string]string{"If-Modified-Since": "yesterday"}, `"v1"`, modified, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			header := http.Header{}
+			for k, v := range tt.header {
+				header.Set(k, v)
+			}
+			if got := NotModified(header, tt.etag, tt.lastModified); got != tt.want {
+				t.Errorf("NotModified = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package utils
+
+import (
+	"bytes"
+	"image"
+	_ "image/gif" // 注册gif解码器
+	"image/jpeg"
+	"image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp" // 注册webp解码器
+)
+
+// 图片像素上限，防止解压炸弹耗尽内存
+const maxImagePixels = 40_000_000
+
+// ImageExtensions 支持的图片类型对应的扩展名
+var ImageExtensions = map[string]string{
+	"image/jpeg": ".jpg",
+	"image/png":  ".png",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// DecodeImageConfig 读取图片尺寸，拒绝像素数过大的图片
+func DecodeImageConfig(data []byte) (image.Config, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return cfg, err
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxImagePixels {
+		return cfg, image.ErrFormat
+	}
+	return cfg, nil
+}
+
+// GenerateThumbnail 按指定宽度等比缩放生成缩略图，原图不比缩略图宽时返回nil
+// png和gif输出png以保留透明度，其他类型输出jpeg；返回缩略图内容和类型
+func GenerateThumbnail(data []byte, contentType string, width int) ([]byte, string, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, "", err
+	}
+
+	bounds := src.Bounds()
+	if bounds.Dx() <= width {
+		return nil, "", nil
+	}
+	height := bounds.Dy() * width / bounds.Dx()
+	if height < 1 {
+		height = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+
+	var buf bytes.Buffer
+	if contentType == "image/png" || contentType == "image/gif" {
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), "image/png", nil
+	}
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), "image/jpeg", nil
+}
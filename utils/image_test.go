@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+func encodePNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	img.Set(0, 0, color.RGBA{R: 255, A: 255})
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestGenerateThumbnail(t *testing.T) {
+	tests := []struct {
+		name        string
+		width       int
+		height      int
+		contentType string
+		wantType    string
+		wantHeight  int
+	}{
+		{"png keeps transparency", 800, 600, "image/png", "image/png", 240},
+		{"jpeg output for other types", 800, 600, "image/webp", "image/jpeg", 240},
+		{"very wide image", 3200, 2, "image/png", "image/png", 1},
+		{"narrow image is not scaled", 320, 100, "image/png", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			thumb, thumbType, err := GenerateThumbnail(encodePNG(t, tt.width, tt.height), tt.contentType, 320)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if thumbType != tt.wantType {
+				t.Fatalf("type = %q, want %q", thumbType, tt.wantType)
+			}
+			if thumb == nil {
+				return
+			}
+			cfg, _, err := image.DecodeConfig(bytes.NewReader(thumb))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Width != 320 || cfg.Height != tt.wantHeight {
+				t.Errorf("thumbnail is %dx%d, want 320x%d", cfg.Width, cfg.Height, tt.wantHeight)
+			}
+		})
+	}
+}
+
+func TestDecodeImageConfig(t *testing.T) {
+	if cfg, err := DecodeImageConfig(encodePNG(t, 20, 10)); err != nil || cfg.Width != 20 || cfg.Height != 10 {
+		t.Errorf("DecodeImageConfig = %+v, %v", cfg, err)
+	}
+	if _, err := DecodeImageConfig([]byte("not an image")); err == nil {
+		t.Error("expected an error for invalid data")
+	}
+
+	// 只修改IHDR中的尺寸，模拟解压炸弹
+	data := encodePNG(t, 1, 1)
+	copy(data[16:24], []byte{0, 0, 0x27, 0x10, 0, 0, 0x27, 0x10}) // 10000x10000
+	binary.BigEndian.PutUint32(data[29:33], crc32.ChecksumIEEE(data[12:29]))
+	if cfg, err := DecodeImageConfig(data); err == nil || cfg.Width != 10000 {
+		t.Errorf("expected oversized image to be rejected after decoding its size, got %+v, %v", cfg, err)
+	}
+}
@@ -0,0 +1,231 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 支持的JWT签名算法
+const (
+	AlgHS256 = "HS256"
+	AlgRS256 = "RS256"
+	AlgEdDSA = "EdDSA"
+)
+
+// SigningKey JWT签名密钥
+// 对称密钥(HS256)使用Secret；非对称密钥(RS256/EdDSA)使用私钥签名、公钥验证，
+// 只有公钥的密钥仅用于验证（轮换中即将退役的密钥）
+type SigningKey struct {
+	ID         string
+	Algorithm  string
+	Secret     []byte
+	PrivateKey crypto.Signer
+	PublicKey  crypto.PublicKey
+
+	method    jwt.SigningMethod
+	signKey   interface{}
+	verifyKey interface{}
+}
+
+// CanSign 判断密钥是否可以用于签名
+func (k *SigningKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+// KeySet JWT密钥集合，包含一个当前签名密钥和若干验证密钥
+type KeySet struct {
+	active   *SigningKey
+	keys     map[string]*SigningKey
+	order    []string
+	issuer   string
+	audience string
+	// legacySecret 验证引入kid之前签发的HS256 token，这些token没有kid、iss和aud
+	legacySecret []byte
+	legacyUntil  time.Time // 之后不再接受没有kid的token
+}
+
+// NewKeySet 创建密钥集合
+func NewKeySet(keys []*SigningKey, activeKeyID, issuer, audience string) (*KeySet, error) {
+	ks := &KeySet{
+		keys:     make(map[string]*SigningKey, len(keys)),
+		issuer:   issuer,
+		audience: audience,
+	}
+
+	for _, key := range keys {
+		if _, exists := ks.keys[key.ID]; exists {
+			return nil, fmt.Errorf("duplicate JWT key id %q", key.ID)
+		}
+		if err := key.prepare(); err != nil {
+			return nil, fmt.Errorf("JWT key %q: %w", key.ID, err)
+		}
+		ks.keys[key.ID] = key
+		ks.order = append(ks.order, key.ID)
+	}
+
+	active, ok := ks.keys[activeKeyID]
+	if !ok {
+		return nil, fmt.Errorf("active JWT key %q not found", activeKeyID)
+	}
+	if !active.CanSign() {
+		return nil, fmt.Errorf("active JWT key %q has no private key", activeKeyID)
+	}
+	ks.active = active
+
+	return ks, nil
+}
+
+// SetLegacySecret 设置验证没有kid的旧token使用的HS256密钥，until之前接受没有kid的token
+// 没有设置时拒绝没有kid的token；until应为升级时间加上旧token的有效期（jwt.expiration_hours）
+func (ks *KeySet) SetLegacySecret(secret []byte, until time.Time) {
+	ks.legacySecret = secret
+	ks.legacyUntil = until
+}
+
+// prepare 根据算法确定签名方法和签名/验证密钥
+func (k *SigningKey) prepare() error {
+	switch k.Algorithm {
+	case AlgHS256:
+		if len(k.Secret) == 0 {
+			return errors.New("HS256 key requires a secret")
+		}
+		k.method = jwt.SigningMethodHS256
+		k.signKey = k.Secret
+		k.verifyKey = k.Secret
+	case AlgRS256:
+		k.method = jwt.SigningMethodRS256
+		if k.PrivateKey != nil {
+			priv, ok := k.PrivateKey.(*rsa.PrivateKey)
+			if !ok {
+				return errors.New("RS256 key requires an RSA private key")
+			}
+			k.signKey = priv
+			k.PublicKey = &priv.PublicKey
+		}
+		pub, ok := k.PublicKey.(*rsa.PublicKey)
+		if !ok {
+			return errors.New("RS256 key requires an RSA public key")
+		}
+		k.verifyKey = pub
+	case AlgEdDSA:
+		k.method = jwt.SigningMethodEdDSA
+		if k.PrivateKey != nil {
+			priv, ok := k.PrivateKey.(ed25519.PrivateKey)
+			if !ok {
+				return errors.New("EdDSA key requires an Ed25519 private key")
+			}
+			k.signKey = priv
+			k.PublicKey = priv.Public()
+		}
+		pub, ok := k.PublicKey.(ed25519.PublicKey)
+		if !ok {
+			return errors.New("EdDSA key requires an Ed25519 public key")
+		}
+		k.verifyKey = pub
+	default:
+		return fmt.Errorf("unsupported algorithm %q", k.Algorithm)
+	}
+	return nil
+}
+
+// algorithms 返回密钥集合中出现的所有算法
+func (ks *KeySet) algorithms() []string {
+	seen := make(map[string]bool)
+	var algs []string
+	for _, id := range ks.order {
+		alg := ks.keys[id].Algorithm
+		if !seen[alg] {
+			seen[alg] = true
+			algs = append(algs, alg)
+		}
+	}
+	return algs
+}
+
+// JWK JSON Web Key (RFC 7517)
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+}
+
+// JWKSet JSON Web Key Set
+type JWKSet struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 返回所有非对称密钥的公钥，供其他服务验证token
+// 对称密钥(HS256)不会被公开
+func (ks *KeySet) JWKS() JWKSet {
+	set := JWKSet{Keys: []JWK{}}
+	for _, id := range ks.order {
+		key := ks.keys[id]
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Algorithm,
+				N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Algorithm,
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	return set
+}
+
+// ParsePrivateKeyPEM 解析PEM格式的私钥（PKCS#8，或PKCS#1格式的RSA私钥）
+func ParsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	if key, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, errors.New("unsupported private key type")
+		}
+		return signer, nil
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("unsupported private key format")
+	}
+	return key, nil
+}
+
+// ParsePublicKeyPEM 解析PEM格式的公钥（PKIX）
+func ParsePublicKeyPEM(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
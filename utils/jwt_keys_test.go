@@ -0,0 +1,245 @@
+package utils
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const testSecret = "0123456789abcdef0123456789abcdef"
+
+func mustKeySet(t *testing.T, keys []*SigningKey, active string) *KeySet {
+	t.Helper()
+	ks, err := NewKeySet(keys, active, "blog", "blog-api")
+	if err != nil {
+		t.Fatalf("NewKeySet: %v", err)
+	}
+	return ks
+}
+
+func testKeys(t *testing.T) (rsaKey *rsa.PrivateKey, edKey ed25519.PrivateKey) {
+	t.Helper()
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, edKey, err = ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rsaKey, edKey
+}
+
+func TestKeySetSignAndValidate(t *testing.T) {
+	rsaKey, edKey := testKeys(t)
+	tests := []struct {
+		name string
+		key  *SigningKey
+	}{
+		{"HS256", &SigningKey{ID: "hs", Algorithm: AlgHS256, Secret: []byte(testSecret)}},
+		{"RS256", &SigningKey{ID: "rs", Algorithm: AlgRS256, PrivateKey: rsaKey}},
+		{"EdDSA", &SigningKey{ID: "ed", Algorithm: AlgEdDSA, PrivateKey: edKey}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ks := mustKeySet(t, []*SigningKey{tt.key}, tt.key.ID)
+			token, err := ks.GenerateToken(7, "alice", "user", time.Hour)
+			if err != nil {
+				t.Fatalf("GenerateToken: %v", err)
+			}
+			claims, err := ks.ValidateToken(token)
+			if err != nil {
+				t.Fatalf("ValidateToken: %v", err)
+			}
+			if claims.UserID != 7 || claims.Username != "alice" || claims.Subject != "7" {
+				t.Errorf("unexpected claims %+v", claims)
+			}
+		})
+	}
+}
+
+func TestKeySetRotation(t *testing.T) {
+	rsaKey, edKey := testKeys(t)
+	oldSet := mustKeySet(t, []*SigningKey{{ID: "old", Algorithm: AlgRS256, PrivateKey: rsaKey}}, "old")
+	oldToken, err := oldSet.GenerateToken(1, "alice", "user", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 轮换后旧密钥只保留公钥用于验证
+	newSet := mustKeySet(t, []*SigningKey{
+		{ID: "new", Algorithm: AlgEdDSA, PrivateKey: edKey},
+		{ID: "old", Algorithm: AlgRS256, PublicKey: &rsaKey.PublicKey},
+	}, "new")
+	if _, err := newSet.ValidateToken(oldToken); err != nil {
+		t.Errorf("token signed by the retired key should still validate: %v", err)
+	}
+	newToken, err := newSet.GenerateToken(1, "alice", "user", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := oldSet.ValidateToken(newToken); err == nil {
+		t.Error("token with an unknown kid should be rejected")
+	}
+
+	if _, err := NewKeySet([]*SigningKey{{ID: "old", Algorithm: AlgRS256, PublicKey: &rsaKey.PublicKey}}, "old", "", ""); err == nil {
+		t.Error("a verify-only key cannot be the active key")
+	}
+	jwks := newSet.JWKS()
+	if len(jwks.Keys) != 2 || jwks.Keys[0].KeyID != "new" || jwks.Keys[0].KeyType != "OKP" || jwks.Keys[1].KeyType != "RSA" {
+		t.Errorf("unexpected JWKS %+v", jwks)
+	}
+}
+
+func TestKeySetRejects(t *testing.T) {
+	rsaKey, _ := testKeys(t)
+	ks := mustKeySet(t, []*SigningKey{
+		{ID: "hs", Algorithm: AlgHS256, Secret: []byte(testSecret)},
+		{ID: "rs", Algorithm: AlgRS256, PrivateKey: rsaKey},
+	}, "rs")
+	valid := jwt.RegisteredClaims{
+		Issuer:    "blog",
+		Audience:  jwt.ClaimStrings{"blog-api"},
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	}
+	sign := func(method jwt.SigningMethod, kid string, key interface{}, registered jwt.RegisteredClaims) string {
+		token := jwt.NewWithClaims(method, JWTClaims{UserID: 1, RegisteredClaims: registered})
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		s, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	withIssuer := valid
+	withIssuer.Issuer = "other"
+	withAudience := valid
+	withAudience.Audience = jwt.ClaimStrings{"other"}
+	expired := valid
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Minute))
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		// kid指向RS256密钥，但使用HS256签名（算法混淆）
+		{"algorithm mismatch", sign(jwt.SigningMethodHS256, "rs", []byte(testSecret), valid)},
+		{"unknown kid", sign(jwt.SigningMethodHS256, "missing", []byte(testSecret), valid)},
+		{"wrong issuer", sign(jwt.SigningMethodHS256, "hs", []byte(testSecret), withIssuer)},
+		{"wrong audience", sign(jwt.SigningMethodHS256, "hs", []byte(testSecret), withAudience)},
+		{"expired", sign(jwt.SigningMethodHS256, "hs", []byte(testSecret), expired)},
+		{"bad signature", sign(jwt.SigningMethodHS256, "hs", []byte("another-secret"), valid)},
+		{"no kid without legacy secret", sign(jwt.SigningMethodHS256, "", []byte(testSecret), valid)},
+		{"garbage", "not-a-token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ks.ValidateToken(tt.token); err == nil {
+				t.Error("expected the token to be rejected")
+			}
+		})
+	}
+}
+
+func TestKeySetLegacyTokens(t *testing.T) {
+	rsaKey, _ := testKeys(t)
+	ks := mustKeySet(t, []*SigningKey{{ID: "rs", Algorithm: AlgRS256, PrivateKey: rsaKey}}, "rs")
+	ks.SetLegacySecret([]byte(testSecret), time.Now().Add(time.Hour))
+
+	// 升级前签发的token：没有kid、iss和aud
+	legacy := func(method jwt.SigningMethod, key interface{}, expiresIn time.Duration, mutate ...func(*JWTClaims)) string {
+		now := time.Now()
+		claims := JWTClaims{UserID: 3, Username: "bob", RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(expiresIn)),
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+		}}
+		for _, fn := range mutate {
+			fn(&claims)
+		}
+		s, err := jwt.NewWithClaims(method, claims).SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	hs := []byte(testSecret)
+
+	tests := []struct {
+		name  string
+		token string
+		ok    bool
+	}{
+		{"valid legacy token", legacy(jwt.SigningMethodHS256, hs, time.Hour), true},
+		{"matching issuer and audience", legacy(jwt.SigningMethodHS256, hs, time.Hour, func(c *JWTClaims) {
+			c.Issuer = "blog"
+			c.Audience = jwt.ClaimStrings{"blog-api"}
+		}), true},
+		{"expired legacy token", legacy(jwt.SigningMethodHS256, hs, -time.Minute), false},
+		{"wrong secret", legacy(jwt.SigningMethodHS256, []byte("another-secret"), time.Hour), false},
+		{"non HS256 algorithm", legacy(jwt.SigningMethodRS256, rsaKey, time.Hour), false},
+		{"purpose token is not an access token", legacy(jwt.SigningMethodHS256, hs, time.Hour, func(c *JWTClaims) { c.Purpose = purposeMFA }), false},
+		// 使用同一密钥的其他服务签发的token
+		{"wrong audience", legacy(jwt.SigningMethodHS256, hs, time.Hour, func(c *JWTClaims) { c.Audience = jwt.ClaimStrings{"other-api"} }), false},
+		{"wrong issuer", legacy(jwt.SigningMethodHS256, hs, time.Hour, func(c *JWTClaims) { c.Issuer = "other" }), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := ks.ValidateToken(tt.token)
+			if tt.ok && (err != nil || claims.UserID != 3) {
+				t.Fatalf("expected legacy token to validate, got %v", err)
+			}
+			if !tt.ok && err == nil {
+				t.Fatal("expected legacy token to be rejected")
+			}
+		})
+	}
+
+	// 过渡期结束后不再接受没有kid的token
+	ks.SetLegacySecret(hs, time.Now().Add(-time.Second))
+	if _, err := ks.ValidateToken(legacy(jwt.SigningMethodHS256, hs, time.Hour)); err == nil {
+		t.Error("expected legacy token to be rejected after legacy_until")
+	}
+}
+
+func TestPurposeTokens(t *testing.T) {
+	ks := mustKeySet(t, []*SigningKey{{ID: "hs", Algorithm: AlgHS256, Secret: []byte(testSecret)}}, "hs")
+	access, _ := ks.GenerateToken(1, "alice", "user", time.Hour)
+	mfa, _ := ks.GenerateMFAToken(1, "alice", time.Minute)
+	export, _ := ks.GenerateDataExportToken(1, 42, time.Minute)
+	expiredExport, _ := ks.GenerateDataExportToken(1, 42, -time.Minute)
+
+	tests := []struct {
+		name     string
+		token    string
+		access   bool
+		mfa      bool
+		exportID uint
+	}{
+		{"access token", access, true, false, 0},
+		{"mfa token", mfa, false, true, 0},
+		{"data export token", export, false, false, 42},
+		{"expired data export token", expiredExport, false, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ks.ValidateToken(tt.token); (err == nil) != tt.access {
+				t.Errorf("ValidateToken accepted=%v, want %v", err == nil, tt.access)
+			}
+			if _, err := ks.ValidateMFAToken(tt.token); (err == nil) != tt.mfa {
+				t.Errorf("ValidateMFAToken accepted=%v, want %v", err == nil, tt.mfa)
+			}
+			_, exportID, err := ks.ValidateDataExportToken(tt.token)
+			if (err == nil) != (tt.exportID != 0) || exportID != tt.exportID {
+				t.Errorf("ValidateDataExportToken = %d, %v, want %d", exportID, err, tt.exportID)
+			}
+		})
+	}
+}
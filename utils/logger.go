@@ -9,11 +9,13 @@ import (
 
 var Logger *zap.Logger
 
+// logLevel 可动态调整的日志级别
+var logLevel = zap.NewAtomicLevel()
+
 // InitLogger 初始化日志系统
-func InitLogger() {
-	// 获取日志级别
-	logLevel := GetEnvWithDefault("LOG_LEVEL", "info")
-	level := getLogLevel(logLevel)
+func InitLogger(level, logFormat, logOutputPath string) {
+	// 设置日志级别
+	SetLogLevel(level)
 
 	// 配置编码器
 	encoderConfig := zap.NewProductionEncoderConfig()
@@ -27,8 +29,6 @@ func InitLogger() {
 
 	// 配置输出
 	var core zapcore.Core
-	logFormat := GetEnvWithDefault("LOG_FORMAT", "json")
-	logOutputPath := GetEnvWithDefault("LOG_OUTPUT_PATH", "")
 
 	if logFormat == "json" {
 		encoder := zapcore.NewJSONEncoder(encoderConfig)
@@ -38,21 +38,26 @@ func InitLogger() {
 			if err != nil {
 				panic("Failed to open log file: " + err.Error())
 			}
-			core = zapcore.NewCore(encoder, zapcore.AddSync(file), level)
+			core = zapcore.NewCore(encoder, zapcore.AddSync(file), logLevel)
 		} else {
 			// 输出到控制台
-			core = zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), level)
+			core = zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), logLevel)
 		}
 	} else {
 		// 控制台格式
 		encoder := zapcore.NewConsoleEncoder(encoderConfig)
-		core = zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), level)
+		core = zapcore.NewCore(encoder, zapcore.AddSync(os.Stdout), logLevel)
 	}
 
 	// 创建logger
 	Logger = zap.New(core, zap.AddCaller(), zap.AddCallerSkip(1))
 }
 
+// SetLogLevel 动态设置日志级别
+func SetLogLevel(level string) {
+	logLevel.SetLevel(getLogLevel(level))
+}
+
 // getLogLevel 获取日志级别
 func getLogLevel(level string) zapcore.Level {
 	switch level {
@@ -0,0 +1,53 @@
+package utils
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/mozillazg/go-unidecode"
+)
+
+// maxSlugLength slug的最大长度，过长时在单词边界截断
+const maxSlugLength = 80
+
+// Slugify 将标题转换为URL友好的slug
+// 非ASCII字符先音译（中文转换为拼音），然后只保留小写字母和数字，其余字符合并为连字符
+func Slugify(title string) string {
+	var ascii strings.Builder
+	for _, r := range title {
+		switch {
+		case r < utf8.RuneSelf:
+			ascii.WriteRune(r)
+		case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul):
+			// 表意文字逐字音译并用空格分隔，避免相邻的拼音或英文单词连在一起
+			ascii.WriteString(" " + unidecode.Unidecode(string(r)) + " ")
+		default:
+			ascii.WriteString(unidecode.Unidecode(string(r)))
+		}
+	}
+
+	var slug strings.Builder
+	pendingHyphen := false
+	for _, r := range strings.ToLower(ascii.String()) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			if pendingHyphen && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			pendingHyphen = false
+			continue
+		}
+		pendingHyphen = true
+	}
+
+	result := slug.String()
+	if len(result) > maxSlugLength {
+		result = result[:maxSlugLength]
+		if i := strings.LastIndexByte(result, '-'); i > maxSlugLength/2 {
+			result = result[:i]
+		}
+		result = strings.TrimSuffix(result, "-")
+	}
+	return result
+}
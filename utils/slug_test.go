@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		title string
+		want  string
+	}{
+		{"Hello, World!", "hello-world"},
+		{"  Go 1.24 released  ", "go-1-24-released"},
+		{"Crème brûlée", "creme-brulee"},
+		{"你好世界", "ni-hao-shi-jie"},
+		{"Go语言入门", "go-yu-yan-ru-men"},
+		{"!!!", ""},
+		{"a---b", "a-b"},
+	}
+	for _, tt := range tests {
+		if got := Slugify(tt.title); got != tt.want {
+			t.Errorf("Slugify(%q) = %q, want %q", tt.title, got, tt.want)
+		}
+	}
+}
+
+func TestSlugifyTruncatesAtWordBoundary(t *testing.T) {
+	title := strings.Repeat("word ", 30)
+	got := Slugify(title)
+	if len(got) > maxSlugLength || strings.HasSuffix(got, "-") || strings.HasSuffix(got, "wor") {
+		t.Errorf("Slugify truncated to %q", got)
+	}
+
+	long := strings.Repeat("a", 100)
+	if got := Slugify(long); got != long[:maxSlugLength] {
+		t.Errorf("a single long word should be cut at the limit, got %q", got)
+	}
+}
@@ -0,0 +1,31 @@
+package utils
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	markdownImage    = regexp.MustCompile(`!\[[^\]]*\]\([^)]*\)`)
+	markdownLink     = regexp.MustCompile(`\[([^\]]*)\]\([^)]*\)`)
+	markdownHeading  = regexp.MustCompile(`(?m)^\s{0,3}#{1,6}\s+`)
+	markdownQuote    = regexp.MustCompile(`(?m)^\s{0,3}(>\s?)+`) // 只去掉行首的引用标记，正文中的">"保留
+	markdownEmphasis = regexp.MustCompile("[*_`~]+")
+)
+
+// Summarize 生成纯文本摘要：去除常见的Markdown标记并合并空白，超过maxRunes个字符时截断并添加省略号
+func Summarize(content string, maxRunes int) string {
+	text := markdownImage.ReplaceAllString(content, "")
+	text = markdownLink.ReplaceAllString(text, "$1")
+	text = markdownHeading.ReplaceAllString(text, "")
+	text = markdownQuote.ReplaceAllString(text, "")
+	text = markdownEmphasis.ReplaceAllString(text, "")
+	text = strings.Join(strings.Fields(text), " ")
+
+	if utf8.RuneCountInString(text) <= maxRunes {
+		return text
+	}
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:maxRunes])) + "…"
+}
@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestSummarize(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		maxRunes int
+		want     string
+	}{
+		{"plain text", "Hello world", 20, "Hello world"},
+		{"whitespace is collapsed", "Hello\n\n  world\t!", 20, "Hello world !"},
+		{"heading and emphasis", "# Title\n\nSome **bold** and _italic_ `code`", 50, "Title Some bold and italic code"},
+		{"links keep their text", "See [the docs](https://example.com) ![logo](logo.png)", 50, "See the docs"},
+		{"block quotes", "> quoted\n>> nested\ntext", 50, "quoted nested text"},
+		{"comparison inside text", "a > b and b < c", 50, "a > b and b < c"},
+		{"truncated with ellipsis", "one two three four", 7, "one two…"},
+		{"truncated by runes", "你好世界你好世界", 4, "你好世界…"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Summarize(tt.content, tt.maxRunes); got != tt.want {
+				t.Errorf("Summarize(%q, %d) = %q, want %q", tt.content, tt.maxRunes, got, tt.want)
+			}
+		})
+	}
+}
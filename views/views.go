@@ -0,0 +1,172 @@
+// Package views 文章浏览量统计
+package views
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// dayLayout 按天统计浏览量时使用的日期格式
+const dayLayout = "2006-01-02"
+
+// pendingKey 待写入的浏览量按文章和日期累积
+type pendingKey struct {
+	postID uint
+	day    string
+}
+
+// Tracker 文章浏览量计数器
+// 同一访客在去重窗口内重复访问同一篇文章只计一次；浏览量先在内存中累积，由Flush批量写入数据库
+// 去重记录只保存在当前实例内，部署多个实例时同一访客被分配到不同实例可能被重复计数
+// 去重记录最多保存maxSeen条，过期的记录在Flush时清理；达到上限后新访客不计数，
+// 避免大量伪造的访客占满内存并刷高浏览量
+type Tracker struct {
+	mu      sync.Mutex
+	window  time.Duration
+	maxSeen int
+	seen    map[string]time.Time // 文章id和访客 -> 最近一次计数的时间
+	pending map[pendingKey]int64
+}
+
+// NewTracker 创建浏览量计数器，window为去重窗口，maxSeen为最多保存的去重记录数
+func NewTracker(window time.Duration, maxSeen int) *Tracker {
+	return &Tracker{
+		window:  window,
+		maxSeen: maxSeen,
+		seen:    make(map[string]time.Time),
+		pending: make(map[pendingKey]int64),
+	}
+}
+
+var (
+	defaultMu      sync.RWMutex
+	defaultTracker *Tracker
+)
+
+// Init 根据配置初始化全局计数器
+func Init(cfg config.ViewsConfig) {
+	defaultMu.Lock()
+	defer defaultMu.Unlock()
+	defaultTracker = NewTracker(time.Duration(cfg.DedupWindowMinutes)*time.Minute, cfg.MaxVisitors)
+}
+
+// Default 获取全局计数器，未初始化时返回nil
+func Default() *Tracker {
+	defaultMu.RLock()
+	defer defaultMu.RUnlock()
+	return defaultTracker
+}
+
+// Record 记录一次浏览，返回是否计数（去重窗口内的重复访问不计数）
+func (t *Tracker) Record(postID uint, visitor string) bool {
+	if t == nil {
+		return false
+	}
+	now := time.Now()
+	seenKey := fmt.Sprintf("%d:%s", postID, visitor)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	last, ok := t.seen[seenKey]
+	if ok && now.Sub(last) < t.window {
+		return false
+	}
+	if !ok && len(t.seen) >= t.maxSeen {
+		return false
+	}
+	t.seen[seenKey] = now
+	t.pending[pendingKey{postID: postID, day: now.Format(dayLayout)}]++
+	return true
+}
+
+// Flush 将累积的浏览量写入数据库，并清理过期的去重记录
+// 写入失败时浏览量放回内存，下次重试
+func (t *Tracker) Flush(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+
+	t.mu.Lock()
+	pending := t.pending
+	t.pending = make(map[pendingKey]int64)
+	cutoff := time.Now().Add(-t.window)
+	for key, last := range t.seen {
+		if last.Before(cutoff) {
+			delete(t.seen, key)
+		}
+	}
+	t.mu.Unlock()
+
+	if len(pending) == 0 {
+		return nil
+	}
+
+	err := config.WithTx(ctx, func(tx *gorm.DB) error {
+		return writeViews(tx, pending)
+	})
+	if err != nil {
+		t.mu.Lock()
+		for key, n := range pending {
+			t.pending[key] += n
+		}
+		t.mu.Unlock()
+	}
+	return err
+}
+
+// writeViews 更新文章浏览量和每天的浏览统计，已经删除的文章被忽略
+func writeViews(tx *gorm.DB, pending map[pendingKey]int64) error {
+	totals := make(map[uint]int64)
+	for key, n := range pending {
+		totals[key.postID] += n
+	}
+	ids := make([]uint, 0, len(totals))
+	for postID := range totals {
+		ids = append(ids, postID)
+	}
+
+	var existing []uint
+	if err := tx.Model(&models.Post{}).Where("id IN ?", ids).Pluck("id", &existing).Error; err != nil {
+		return err
+	}
+	exists := make(map[uint]bool, len(existing))
+	for _, postID := range existing {
+		exists[postID] = true
+	}
+
+	// 不使用Update，避免修改updated_at
+	for postID, n := range totals {
+		if !exists[postID] {
+			continue
+		}
+		if err := tx.Model(&models.Post{}).Where("id = ?", postID).
+			UpdateColumn("view_count", gorm.Expr("view_count + ?", n)).Error; err != nil {
+			return err
+		}
+	}
+
+	var stats []models.PostViewStat
+	for key, n := range pending {
+		if !exists[key.postID] {
+			continue
+		}
+		day, err := time.ParseInLocation(dayLayout, key.day, time.Local)
+		if err != nil {
+			return err
+		}
+		stats = append(stats, models.PostViewStat{PostID: key.postID, Day: day, Views: n})
+	}
+	if len(stats) == 0 {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{
+		DoUpdates: clause.Assignments(map[string]interface{}{"views": gorm.Expr("views + VALUES(views)")}),
+	}).Create(&stats).Error
+}
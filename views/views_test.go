@@ -0,0 +1,103 @@
+package views
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/test/blog/config"
+	"github.com/test/blog/models"
+	"github.com/test/blog/testutil"
+)
+
+func TestRecordDeduplicates(t *testing.T) {
+	tracker := NewTracker(time.Hour, 100)
+	tests := []struct {
+		name    string
+		postID  uint
+		visitor string
+		want    bool
+	}{
+		{"first visit", 1, "a", true},
+		{"same visitor", 1, "a", false},
+		{"other visitor", 1, "b", true},
+		{"other post", 2, "a", true},
+		{"same visitor again", 2, "a", false},
+	}
+	for _, tt := range tests {
+		if got := tracker.Record(tt.postID, tt.visitor); got != tt.want {
+			t.Errorf("%s: Record = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+	today := time.Now().Format(dayLayout)
+	if tracker.pending[pendingKey{1, today}] != 2 || tracker.pending[pendingKey{2, today}] != 1 {
+		t.Errorf("pending = %v", tracker.pending)
+	}
+
+	// 超过去重窗口后重新计数
+	tracker.seen["1:a"] = time.Now().Add(-2 * time.Hour)
+	if !tracker.Record(1, "a") {
+		t.Error("visit after the window should be counted")
+	}
+
+	var nilTracker *Tracker
+	if nilTracker.Record(1, "a") || nilTracker.Flush(context.Background()) != nil {
+		t.Error("nil tracker should ignore views")
+	}
+}
+
+func TestRecordMaxVisitors(t *testing.T) {
+	testutil.Setup(t)
+	tracker := NewTracker(time.Hour, 2)
+	tracker.Record(1, "a")
+	tracker.Record(1, "b")
+
+	// 达到上限后新访客不计数，已有访客超过窗口后仍然计数
+	if tracker.Record(1, "c") {
+		t.Error("visitor beyond the limit should not be counted")
+	}
+	tracker.seen["1:a"] = time.Now().Add(-2 * time.Hour)
+	if !tracker.Record(1, "a") {
+		t.Error("known visitor after the window should be counted")
+	}
+	if len(tracker.seen) != 2 {
+		t.Errorf("seen = %d entries, want 2", len(tracker.seen))
+	}
+
+	// 清理过期记录后可以继续计数
+	tracker.seen["1:b"] = time.Now().Add(-2 * time.Hour)
+	tracker.Flush(context.Background())
+	if !tracker.Record(1, "c") {
+		t.Error("visitor should be counted after expired entries are removed")
+	}
+}
+
+func TestFlush(t *testing.T) {
+	testutil.Setup(t)
+	tracker := NewTracker(time.Minute, 100)
+	tracker.Record(404, "a")
+	tracker.seen["1:old"] = time.Now().Add(-time.Hour)
+
+	// 写入失败时浏览量放回内存，下次重试
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := tracker.Flush(ctx); err == nil {
+		t.Fatal("expected an error with a cancelled context")
+	}
+	if tracker.pending[pendingKey{404, time.Now().Format(dayLayout)}] != 1 {
+		t.Errorf("pending after failed flush = %v", tracker.pending)
+	}
+	if _, ok := tracker.seen["1:old"]; ok {
+		t.Error("expired dedup entry was not removed")
+	}
+
+	// 已删除的文章被忽略
+	if err := tracker.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var stats int64
+	config.GetDB().Model(&models.PostViewStat{}).Count(&stats)
+	if len(tracker.pending) != 0 || stats != 0 {
+		t.Errorf("pending = %v, stats %d", tracker.pending, stats)
+	}
+}